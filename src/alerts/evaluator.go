@@ -0,0 +1,193 @@
+package alerts
+
+import (
+	"com/connections/db"
+	"com/data"
+	"com/jobs"
+	"com/logs"
+	"com/notifications"
+	"com/utils"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var _ jobs.EventHandler = (*Evaluator)(nil)
+
+// Evaluates alert rules against events as they are stored, persisting each rule's state per device.
+type Evaluator struct {
+	dbConnection db.DBConnection
+	rules        []Rule
+}
+
+func NewEvaluator(dbConnection db.DBConnection, rules []Rule) (*Evaluator, error) {
+	for _, rule := range rules {
+		err := rule.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %v: %w", rule.Name, err)
+		}
+	}
+	return &Evaluator{dbConnection: dbConnection, rules: rules}, nil
+}
+
+// Evaluate every rule matching the device, so one failing does not hold up the others.
+func (e *Evaluator) HandleEvents(ctx context.Context, device *data.StoreDevice, events []data.StoreEvent) error {
+	errs := []error{}
+	for _, rule := range e.rules {
+		if !rule.Matches(device) {
+			continue
+		}
+		// Use the latest reading of the field
+		var latest *data.StoreEvent
+		for _, event := range events {
+			if event.FieldName == rule.Field && (latest == nil || event.EventTimestamp >= latest.EventTimestamp) {
+				latest = &event
+			}
+		}
+		if latest == nil {
+			continue
+		}
+
+		err := e.evaluate(ctx, rule, device, latest)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error evaluating rule %v for device %v: %w", rule.Name, device.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Changes of an alert's status that are announced.
+type transition int
+
+const (
+	unchanged transition = iota
+	fired
+	resolved
+)
+
+// Advance the rule's state for the device given its latest reading.
+func (e *Evaluator) evaluate(ctx context.Context, rule Rule, device *data.StoreDevice, event *data.StoreEvent) error {
+	state, err := e.getState(ctx, rule, device)
+	if err != nil {
+		return err
+	}
+	result, err := advance(rule, &state.AlertState, event)
+	if err != nil {
+		return fmt.Errorf("error advancing alert state %v: %w", state.ID, err)
+	}
+	state.Timestamp = utils.TimeSeconds()
+
+	switch result {
+	case fired:
+		logs.WarnWithContext(ctx, "Alert %v fired for device %v (%v): %v is %v, %v %v since %v",
+			rule.Name, device.Name, device.ID, rule.Field, event.FieldValue, rule.Operator, rule.Value, data.EpochSecondsToExcelDate(state.ConditionSince))
+		notifications.NotifyWithContext(ctx, alertNotification(rule, device, event, notifications.Critical,
+			fmt.Sprintf("Alert %v fired for %v", rule.Name, device.Name),
+			fmt.Sprintf("%v is %v, which is %v %v", rule.Field, event.FieldValue, rule.Operator, rule.Value),
+		))
+	case resolved:
+		logs.InfoWithContext(ctx, "Alert %v resolved for device %v (%v): %v is %v",
+			rule.Name, device.Name, device.ID, rule.Field, event.FieldValue)
+		notifications.NotifyWithContext(ctx, alertNotification(rule, device, event, notifications.Info,
+			fmt.Sprintf("Alert %v resolved for %v", rule.Name, device.Name),
+			fmt.Sprintf("%v is back to %v", rule.Field, event.FieldValue),
+		))
+	}
+
+	err = e.dbConnection.AlertStates().Edit(ctx, *state)
+	if err != nil {
+		return fmt.Errorf("error saving alert state %v: %w", state, err)
+	}
+	return nil
+}
+
+// Move the state to the rule's status given a reading. Durations are measured in event time, so that delayed
+// reports are judged by when they were taken.
+func advance(rule Rule, state *data.AlertState, event *data.StoreEvent) (transition, error) {
+	state.LastValue = event.FieldValue
+
+	switch state.Status {
+	case data.AlertOK, data.AlertPending:
+		isBreached, err := rule.IsBreached(event.FieldValue)
+		if err != nil {
+			return unchanged, err
+		}
+		if !isBreached {
+			state.Status = data.AlertOK
+			state.ConditionSince = 0
+			return unchanged, nil
+		}
+		if state.Status == data.AlertOK {
+			state.Status = data.AlertPending
+			state.ConditionSince = event.EventTimestamp
+		}
+		hasHeldLongEnough := event.EventTimestamp-state.ConditionSince >= rule.For.Seconds()
+		isCoolingDown := state.FiredTimestamp != 0 && event.EventTimestamp-state.FiredTimestamp < rule.Cooldown.Seconds()
+		if hasHeldLongEnough && !isCoolingDown {
+			state.Status = data.AlertFiring
+			state.FiredTimestamp = event.EventTimestamp
+			return fired, nil
+		}
+		return unchanged, nil
+	case data.AlertFiring:
+		isCleared, err := rule.IsCleared(event.FieldValue)
+		if err != nil {
+			return unchanged, err
+		}
+		if isCleared {
+			state.Status = data.AlertOK
+			state.ConditionSince = 0
+			state.ResolvedTimestamp = event.EventTimestamp
+			return resolved, nil
+		}
+		return unchanged, nil
+	default:
+		return unchanged, fmt.Errorf("unknown alert status %v", state.Status)
+	}
+}
+
+// Get the stored state of the rule for the device, creating it if it does not exist yet.
+func (e *Evaluator) getState(ctx context.Context, rule Rule, device *data.StoreDevice) (*data.StoreAlertState, error) {
+	states := e.dbConnection.AlertStates().Find(ctx, data.Query[data.AlertStateFilter]{
+		Filter: data.AlertStateFilter{RuleName: &rule.Name, DeviceID: &device.ID},
+		Limit:  1,
+	})
+	state, err := states.Next(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting alert state: %w", err)
+	}
+	if state != nil {
+		return state, nil
+	}
+
+	newState := data.AlertState{
+		RuleName:  rule.Name,
+		DeviceID:  device.ID,
+		Status:    data.AlertOK,
+		Timestamp: utils.TimeSeconds(),
+	}
+	id, err := e.dbConnection.AlertStates().Add(ctx, newState)
+	if err != nil {
+		return nil, fmt.Errorf("error adding alert state %v: %w", newState, err)
+	}
+	return &data.StoreAlertState{HasID: data.HasID{ID: id}, AlertState: newState}, nil
+}
+
+func alertNotification(rule Rule, device *data.StoreDevice, event *data.StoreEvent, severity notifications.Severity, title string, message string) notifications.Notification {
+	return notifications.Notification{
+		Title:    title,
+		Message:  message,
+		Severity: severity,
+		Source:   "alerts",
+		Fields: map[string]string{
+			"rule":        rule.Name,
+			"device":      device.Name,
+			"device_id":   device.ID,
+			"device_kind": device.Kind,
+			"field":       rule.Field,
+			"value":       event.FieldValue,
+			"reported_at": data.EpochSecondsToExcelDate(event.EventTimestamp),
+		},
+		Timestamp: utils.TimeSeconds(),
+	}
+}
@@ -0,0 +1,106 @@
+package alerts
+
+import (
+	"com/data"
+	"com/utils"
+	"testing"
+	"time"
+)
+
+const start int64 = 1700000000
+
+type reading struct {
+	offset   int64
+	value    string
+	expected transition
+	status   string
+}
+
+func runReadings(t *testing.T, rule Rule, readings []reading) {
+	t.Helper()
+	state := data.AlertState{Status: data.AlertOK}
+	for i, r := range readings {
+		event := data.StoreEvent{Event: data.Event{EventTimestamp: start + r.offset, FieldValue: r.value}}
+		result, err := advance(rule, &state, &event)
+		if err != nil {
+			t.Fatalf("reading %v: %v", i, err)
+		}
+		if result != r.expected || state.Status != r.status {
+			t.Fatalf("reading %v (%v at +%vs): expected %v and %v, got %v and %v", i, r.value, r.offset, r.expected, r.status, result, state.Status)
+		}
+	}
+}
+
+func TestFiresOnceHeldForDuration(t *testing.T) {
+	rule := Rule{Name: "freezer", Field: "state.temperature", Operator: Above, Value: "-10", For: utils.Duration(15 * time.Minute)}
+	runReadings(t, rule, []reading{
+		{0, "-12", unchanged, data.AlertOK},
+		{60, "-8", unchanged, data.AlertPending},
+		{600, "-7", unchanged, data.AlertPending},
+		{60 + 900, "-7", fired, data.AlertFiring},
+		{2000, "-6", unchanged, data.AlertFiring},
+	})
+}
+
+func TestPendingResetsWhenConditionBreaks(t *testing.T) {
+	rule := Rule{Name: "freezer", Field: "state.temperature", Operator: Above, Value: "-10", For: utils.Duration(15 * time.Minute)}
+	runReadings(t, rule, []reading{
+		{0, "-8", unchanged, data.AlertPending},
+		{600, "-11", unchanged, data.AlertOK},
+		{900, "-8", unchanged, data.AlertPending},
+		{1200, "-8", unchanged, data.AlertPending},
+		{1800, "-8", fired, data.AlertFiring},
+	})
+}
+
+func TestHysteresis(t *testing.T) {
+	rule := Rule{Name: "humidity", Field: "state.humidity", Operator: AboveOrEqual, Value: "60", Hysteresis: 5}
+	runReadings(t, rule, []reading{
+		{0, "60", fired, data.AlertFiring},
+		// Back under the threshold, but not past the hysteresis
+		{60, "58", unchanged, data.AlertFiring},
+		{120, "55.5", unchanged, data.AlertFiring},
+		{180, "54.9", resolved, data.AlertOK},
+		{240, "59", unchanged, data.AlertOK},
+	})
+}
+
+func TestCooldown(t *testing.T) {
+	rule := Rule{Name: "leak", Field: "state", Operator: Equal, Value: "alert", Cooldown: utils.Duration(time.Hour)}
+	runReadings(t, rule, []reading{
+		{0, "alert", fired, data.AlertFiring},
+		{60, "normal", resolved, data.AlertOK},
+		// Breached again within the hour, so it stays pending until the cooldown ends
+		{120, "alert", unchanged, data.AlertPending},
+		{3599, "alert", unchanged, data.AlertPending},
+		{3600, "alert", fired, data.AlertFiring},
+	})
+}
+
+// Delayed reports are judged by when they were taken, not when they arrived.
+func TestUsesEventTime(t *testing.T) {
+	rule := Rule{Name: "freezer", Field: "state.temperature", Operator: Above, Value: "-10", For: utils.Duration(15 * time.Minute)}
+	state := data.AlertState{Status: data.AlertOK}
+	for i, offset := range []int64{0, 300} {
+		event := data.StoreEvent{Event: data.Event{EventTimestamp: start + offset, FieldValue: "-5"}}
+		result, err := advance(rule, &state, &event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != unchanged {
+			t.Fatalf("reading %v fired after %vs of a 15 minute condition", i, offset)
+		}
+	}
+	if state.ConditionSince != start {
+		t.Fatalf("expected condition since %v, got %v", start, state.ConditionSince)
+	}
+}
+
+func TestNonNumericValueErrors(t *testing.T) {
+	rule := Rule{Name: "freezer", Field: "state.temperature", Operator: Above, Value: "-10"}
+	state := data.AlertState{Status: data.AlertOK}
+	_, err := advance(rule, &state, &data.StoreEvent{Event: data.Event{FieldValue: "warm"}})
+	if err == nil {
+		t.Fatal("expected an error comparing a non-numeric value")
+	}
+}
@@ -0,0 +1,135 @@
+package alerts
+
+import (
+	"com/data"
+	"com/utils"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+type Operator string
+
+const (
+	Above        Operator = ">"
+	AboveOrEqual Operator = ">="
+	Below        Operator = "<"
+	BelowOrEqual Operator = "<="
+	Equal        Operator = "=="
+	NotEqual     Operator = "!="
+)
+
+// A threshold on a single field of matching devices, e.g. a freezer's temperature being above -10 for 15 minutes.
+// Empty device matchers match every device.
+type Rule struct {
+	Name       string `json:"name"`
+	DeviceID   string `json:"deviceId,omitempty"`
+	DeviceName string `json:"deviceName,omitempty"`
+	DeviceKind string `json:"deviceKind,omitempty"`
+	// Flattened field name as stored in events, e.g. "state.temperature".
+	Field    string   `json:"field"`
+	Operator Operator `json:"operator"`
+	// Compared numerically when both sides are numbers, otherwise as strings. Strings only support == and !=.
+	Value string `json:"value"`
+	// How long the condition must hold before firing.
+	For utils.Duration `json:"for,omitempty"`
+	// How far back past the threshold a numeric value must go before a firing alert resolves.
+	Hysteresis float64 `json:"hysteresis,omitempty"`
+	// Minimum time between two firings of the rule for the same device.
+	Cooldown utils.Duration `json:"cooldown,omitempty"`
+}
+
+type RuleSet struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Read rules from a JSON file of the form {"rules": [...]}.
+func LoadRules(path string) ([]Rule, error) {
+	ruleSet, err := utils.ReadJsonFile[RuleSet](path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading alert rules: %w", err)
+	}
+	for _, rule := range ruleSet.Rules {
+		err = rule.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %v: %w", rule.Name, err)
+		}
+	}
+	return ruleSet.Rules, nil
+}
+
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("rule has no name")
+	}
+	if r.Field == "" {
+		return errors.New("rule has no field")
+	}
+	switch r.Operator {
+	case Equal, NotEqual:
+	case Above, AboveOrEqual, Below, BelowOrEqual:
+		_, err := strconv.ParseFloat(r.Value, 64)
+		if err != nil {
+			return fmt.Errorf("operator %v requires a numeric value, got %v: %w", r.Operator, r.Value, err)
+		}
+	default:
+		return fmt.Errorf("unknown operator %v", r.Operator)
+	}
+	if r.Hysteresis < 0 {
+		return fmt.Errorf("hysteresis must not be negative, got %v", r.Hysteresis)
+	}
+	return nil
+}
+
+// Whether the rule applies to the given device.
+func (r Rule) Matches(device *data.StoreDevice) bool {
+	return (r.DeviceID == "" || r.DeviceID == device.ID) &&
+		(r.DeviceName == "" || r.DeviceName == device.Name) &&
+		(r.DeviceKind == "" || r.DeviceKind == device.Kind)
+}
+
+// Whether the value breaches the rule's threshold.
+func (r Rule) IsBreached(value string) (bool, error) {
+	return r.compare(value, 0)
+}
+
+// Whether a value of a firing alert is far enough back from the threshold to resolve.
+func (r Rule) IsCleared(value string) (bool, error) {
+	breached, err := r.compare(value, r.Hysteresis)
+	return !breached, err
+}
+
+// Compare the value against the threshold, moving the threshold towards clearing by margin.
+func (r Rule) compare(value string, margin float64) (bool, error) {
+	threshold, thresholdErr := strconv.ParseFloat(r.Value, 64)
+	number, numberErr := strconv.ParseFloat(value, 64)
+	isNumeric := thresholdErr == nil && numberErr == nil
+
+	switch r.Operator {
+	case Equal:
+		if isNumeric {
+			return number == threshold, nil
+		}
+		return value == r.Value, nil
+	case NotEqual:
+		if isNumeric {
+			return number != threshold, nil
+		}
+		return value != r.Value, nil
+	}
+
+	if !isNumeric {
+		return false, fmt.Errorf("rule %v cannot compare non-numeric value %v with %v", r.Name, value, r.Operator)
+	}
+	switch r.Operator {
+	case Above:
+		return number > threshold-margin, nil
+	case AboveOrEqual:
+		return number >= threshold-margin, nil
+	case Below:
+		return number < threshold+margin, nil
+	case BelowOrEqual:
+		return number <= threshold+margin, nil
+	}
+	return false, fmt.Errorf("unknown operator %v", r.Operator)
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"com/battery"
+	"com/connections/db"
+	"com/data"
+	"com/logs"
+	"com/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+// Page sizes of listings.
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// Routes for querying collected data over HTTP.
+func NewHandler(ctx context.Context, dbConnection db.DBConnection) http.Handler {
+	mux := http.NewServeMux()
+
+	// Devices ranked by battery replacement urgency. Optional "window" parameter, e.g. "30d", limits readings used.
+	mux.HandleFunc("GET /battery", func(w http.ResponseWriter, r *http.Request) {
+		window := battery.DefaultForecastWindow
+		if param := r.URL.Query().Get("window"); param != "" {
+			parsed, err := utils.ParseDuration(param)
+			if err != nil {
+				writeError(ctx, w, http.StatusBadRequest, err)
+				return
+			}
+			window = parsed
+		}
+		forecasts, err := battery.Report(r.Context(), dbConnection, window)
+		if err != nil {
+			writeError(ctx, w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJson(ctx, w, forecasts)
+	})
+
+	// Latest value of every field of every device, keyed by device ID.
+	mux.HandleFunc("GET /devices/state", func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := dbConnection.DeviceStates().GetSnapshot(r.Context())
+		if err != nil {
+			writeError(ctx, w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJson(ctx, w, snapshot)
+	})
+
+	// A numeric field of a device over time, at a resolution suited to the range. start and end are epoch seconds.
+	mux.HandleFunc("GET /devices/{id}/series/{field}", func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parseTimeRange(r)
+		if err != nil {
+			writeError(ctx, w, http.StatusBadRequest, err)
+			return
+		}
+		points, resolution, err := dbConnection.Events().GetSeries(r.Context(), r.PathValue("id"), r.PathValue("field"), start, end)
+		if err != nil {
+			writeError(ctx, w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJson(ctx, w, map[string]any{"resolution": resolution, "points": points})
+	})
+
+	// An aggregate of numeric event values, e.g. ?function=avg&groupBy=device,field&bucket=1h&start=&end=.
+	// Optional "device" and "field" parameters filter events, and "percentile" is between 0 and 1 for function=percentile.
+	mux.HandleFunc("GET /events/aggregate", func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		start, end, err := parseTimeRange(r)
+		if err != nil {
+			writeError(ctx, w, http.StatusBadRequest, err)
+			return
+		}
+		query := data.AggregateQuery{Function: data.AggregateFunction(strings.ToUpper(params.Get("function")))}
+		if param := params.Get("percentile"); param != "" {
+			query.Percentile, err = strconv.ParseFloat(param, 64)
+			if err != nil {
+				writeError(ctx, w, http.StatusBadRequest, fmt.Errorf("percentile must be a number: %w", err))
+				return
+			}
+		}
+		if param := params.Get("groupBy"); param != "" {
+			for _, group := range strings.Split(param, ",") {
+				query.GroupBy = append(query.GroupBy, data.AggregateGroup(group))
+			}
+		}
+		if param := params.Get("bucket"); param != "" {
+			bucket, err := utils.ParseDuration(param)
+			if err != nil {
+				writeError(ctx, w, http.StatusBadRequest, err)
+				return
+			}
+			query.BucketSeconds = int64(bucket.Seconds())
+		}
+		err = query.Validate()
+		if err != nil {
+			writeError(ctx, w, http.StatusBadRequest, err)
+			return
+		}
+
+		filter := data.EventFilter{}
+		if param := params.Get("device"); param != "" {
+			filter.EventSourceDeviceID = &param
+		}
+		if param := params.Get("field"); param != "" {
+			filter.FieldName = &param
+		}
+		results, err := dbConnection.Events().Aggregate(r.Context(), filter, &start, &end, query)
+		if err != nil {
+			writeError(ctx, w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJson(ctx, w, results)
+	})
+
+	// Events in the order they were stored, a page at a time. Optional "device", "field", "start" and "end" (epoch seconds)
+	// parameters filter events, and "limit" sets the page size. Pass the returned cursor to get the next page, with the same filters.
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		query := data.Query[data.EventFilter]{Limit: defaultListLimit}
+		if param := params.Get("device"); param != "" {
+			query.Filter.EventSourceDeviceID = &param
+		}
+		if param := params.Get("field"); param != "" {
+			query.Filter.FieldName = &param
+		}
+		timeRange := data.Between{Column: "event_timestamp"}
+		for name, target := range map[string]*any{"start": &timeRange.Min, "end": &timeRange.Max} {
+			if param := params.Get(name); param != "" {
+				parsed, err := strconv.ParseInt(param, 10, 64)
+				if err != nil {
+					writeError(ctx, w, http.StatusBadRequest, fmt.Errorf("%v must be epoch seconds: %w", name, err))
+					return
+				}
+				*target = parsed
+			}
+		}
+		query.Where = timeRange
+		if param := params.Get("limit"); param != "" {
+			limit, err := strconv.Atoi(param)
+			if err != nil || limit <= 0 || limit > maxListLimit {
+				writeError(ctx, w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %v, got %v", maxListLimit, param))
+				return
+			}
+			query.Limit = limit
+		}
+
+		events, err := dbConnection.Events().FindFromCursor(r.Context(), query, params.Get("cursor"))
+		if err != nil {
+			writeError(ctx, w, http.StatusBadRequest, err)
+			return
+		}
+		items, err := data.Collect(events.All(r.Context()))
+		if err != nil {
+			writeError(ctx, w, http.StatusInternalServerError, err)
+			return
+		}
+		// A full page may have more after it
+		cursor := ""
+		if len(items) == query.Limit {
+			cursor = events.Cursor()
+		}
+		writeJson(ctx, w, map[string]any{"items": items, "cursor": cursor})
+	})
+
+	return mux
+}
+
+// The "start" and "end" epoch seconds parameters, defaulting to the last day.
+func parseTimeRange(r *http.Request) (int64, int64, error) {
+	end := utils.TimeSeconds()
+	start := end - 24*60*60
+	for name, target := range map[string]*int64{"start": &start, "end": &end} {
+		param := r.URL.Query().Get(name)
+		if param == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%v must be epoch seconds: %w", name, err)
+		}
+		*target = parsed
+	}
+	return start, end, nil
+}
+
+// Serve the handler on the address until the context is done.
+func Serve(ctx context.Context, address string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		logs.LogErrorsWithContext(ctx, func() error { return server.Shutdown(shutdownctx) }, "error shutting down API server")
+	}()
+	logs.InfoWithContext(ctx, "API listening on %v", address)
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error serving API on %v: %w", address, err)
+	}
+	return nil
+}
+
+func writeJson(ctx context.Context, w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		logs.ErrorWithContext(ctx, "error writing API response %v: %v", body, err)
+	}
+}
+
+func writeError(ctx context.Context, w http.ResponseWriter, status int, err error) {
+	logs.WarnWithContext(ctx, "API request failed with status %v: %v", status, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeJson(ctx, w, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,313 @@
+// Backups of every store into a portable archive, restorable into any DBConnection, such as to move between databases.
+// An archive is a gzipped tar of a manifest followed by a file per store, with a JSON object per row keyed by column.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"com/connections/db"
+	"com/logs"
+	"com/utils"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Version of the archive format written. Archives of later versions cannot be restored.
+const FormatVersion = 1
+
+// Name of the manifest, the first file of an archive.
+const ManifestName = "manifest.json"
+
+type Manifest struct {
+	Version   int    `json:"version"`
+	CreatedAt string `json:"createdAt"`
+	// In the order they are restored.
+	Stores []StoreManifest `json:"stores"`
+}
+
+// A store's file in an archive.
+type StoreManifest struct {
+	Name    string   `json:"name"`
+	File    string   `json:"file"`
+	Columns []string `json:"columns"`
+	Rows    int64    `json:"rows"`
+	Bytes   int64    `json:"bytes"`
+	SHA256  string   `json:"sha256"`
+}
+
+// Write every store into an archive at the path. The archive only appears once complete.
+// Stores are read within one read-only transaction, so the archive is consistent while collection continues.
+func Backup(ctx context.Context, dbConnection db.DBConnection, path string) (*Manifest, error) {
+	// Tar headers need each file's size, so stores are written to temporary files first
+	directory, err := os.MkdirTemp("", "backup")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer logs.LogErrorsWithContext(ctx, func() error { return os.RemoveAll(directory) }, "error removing temporary backup files")
+
+	manifest := &Manifest{Version: FormatVersion, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	err = dbConnection.WithTransaction(ctx, true, func(snapshot db.DBConnection) error {
+		for _, store := range backupStores {
+			storeManifest, err := backupStoreToFile(ctx, snapshot, store, directory)
+			if err != nil {
+				return err
+			}
+			logs.InfoWithContext(ctx, "Backed up %v rows of %v", storeManifest.Rows, store.name)
+			manifest.Stores = append(manifest.Stores, storeManifest)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	partialPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".partial")
+	err = writeArchive(partialPath, directory, manifest)
+	if err != nil {
+		_ = os.Remove(partialPath)
+		return nil, err
+	}
+	err = os.Rename(partialPath, path)
+	if err != nil {
+		_ = os.Remove(partialPath)
+		return nil, fmt.Errorf("error moving backup to %v: %w", path, err)
+	}
+	return manifest, nil
+}
+
+func backupStoreToFile(ctx context.Context, dbConnection db.DBConnection, store backupStore, directory string) (StoreManifest, error) {
+	storeManifest := StoreManifest{Name: store.name, File: store.name + ".ndjson", Columns: store.columns}
+	file, err := os.Create(filepath.Join(directory, storeManifest.File))
+	if err != nil {
+		return storeManifest, fmt.Errorf("error creating temporary file of %v: %w", store.name, err)
+	}
+	defer logs.LogErrorsWithContext(ctx, file.Close, fmt.Sprintf("error closing temporary file of %v", store.name))
+	hashed := utils.NewHashingWriter(file)
+	storeManifest.Rows, err = store.backup(ctx, dbConnection, hashed)
+	if err != nil {
+		return storeManifest, fmt.Errorf("error backing up %v: %w", store.name, err)
+	}
+	storeManifest.Bytes = hashed.Count()
+	storeManifest.SHA256 = hashed.SHA256()
+	return storeManifest, nil
+}
+
+func writeArchive(path string, directory string, manifest *Manifest) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating backup %v: %w", path, err)
+	}
+	compressor := gzip.NewWriter(file)
+	archive := tar.NewWriter(compressor)
+	err = writeArchiveFiles(archive, directory, manifest)
+	if err == nil {
+		err = archive.Close()
+	}
+	if err == nil {
+		err = compressor.Close()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing backup %v: %w", path, err)
+	}
+	return nil
+}
+
+func writeArchiveFiles(archive *tar.Writer, directory string, manifest *Manifest) error {
+	encoded, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+	modified := time.Now()
+	err = archive.WriteHeader(&tar.Header{Name: ManifestName, Mode: 0644, Size: int64(len(encoded)), ModTime: modified})
+	if err != nil {
+		return err
+	}
+	_, err = archive.Write(encoded)
+	if err != nil {
+		return err
+	}
+	for _, store := range manifest.Stores {
+		err = archive.WriteHeader(&tar.Header{Name: store.File, Mode: 0644, Size: store.Bytes, ModTime: modified})
+		if err != nil {
+			return err
+		}
+		err = copyFile(archive, filepath.Join(directory, store.File))
+		if err != nil {
+			return fmt.Errorf("error adding %v: %w", store.Name, err)
+		}
+	}
+	return nil
+}
+
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// Restore the archive at the path into the connection, whose stores must be empty.
+// The archive is checked against its manifest before anything is restored. Everything is restored within one
+// transaction, so a failed restore leaves the stores empty, ready to be retried.
+func Restore(ctx context.Context, dbConnection db.DBConnection, path string) (*Manifest, error) {
+	manifest, err := Verify(path)
+	if err != nil {
+		return nil, err
+	}
+	err = dbConnection.WithTransaction(ctx, false, func(tx db.DBConnection) error {
+		for _, store := range backupStores {
+			isEmpty, err := store.isEmpty(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if !isEmpty {
+				return fmt.Errorf("cannot restore into a database that is not empty: %v has rows", store.name)
+			}
+		}
+		_, err := readArchive(path, func(archive *tar.Reader, storeManifest StoreManifest) error {
+			store, _ := findBackupStore(storeManifest.Name)
+			rows, err := store.restore(ctx, tx, archive)
+			if err != nil {
+				return fmt.Errorf("error restoring %v, after %v rows, so nothing was restored: %w", store.name, rows, err)
+			}
+			logs.InfoWithContext(ctx, "Restored %v rows of %v", rows, store.name)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Read the archive's manifest and check each store's file matches it.
+func Verify(path string) (*Manifest, error) {
+	verified := map[string]bool{}
+	manifest, err := readArchive(path, func(archive *tar.Reader, storeManifest StoreManifest) error {
+		hashed := utils.NewHashingWriter(io.Discard)
+		rows, err := countLines(io.TeeReader(archive, hashed))
+		if err != nil {
+			return fmt.Errorf("error reading %v: %w", storeManifest.File, err)
+		}
+		checksum := hashed.SHA256()
+		if rows != storeManifest.Rows || hashed.Count() != storeManifest.Bytes || checksum != storeManifest.SHA256 {
+			return fmt.Errorf("%v does not match the manifest: %v rows, %v bytes with SHA-256 %v", storeManifest.File, rows, hashed.Count(), checksum)
+		}
+		verified[storeManifest.Name] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, store := range manifest.Stores {
+		if !verified[store.Name] {
+			return nil, fmt.Errorf("backup %v is missing %v", path, store.File)
+		}
+	}
+	return manifest, nil
+}
+
+// Read the archive's manifest, check it can be restored, then call readStore with each store's file in turn.
+func readArchive(path string, readStore func(archive *tar.Reader, storeManifest StoreManifest) error) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening backup %v: %w", path, err)
+	}
+	defer file.Close()
+	decompressor, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing backup %v: %w", path, err)
+	}
+	archive := tar.NewReader(decompressor)
+
+	header, err := archive.Next()
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup %v: %w", path, err)
+	}
+	if header.Name != ManifestName {
+		return nil, fmt.Errorf("backup %v does not start with a manifest", path)
+	}
+	manifest := &Manifest{}
+	err = json.NewDecoder(archive).Decode(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding manifest of %v: %w", path, err)
+	}
+	err = manifest.validate()
+	if err != nil {
+		return nil, fmt.Errorf("backup %v cannot be restored: %w", path, err)
+	}
+
+	files := map[string]StoreManifest{}
+	for _, store := range manifest.Stores {
+		files[store.File] = store
+	}
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return manifest, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading backup %v: %w", path, err)
+		}
+		store, ok := files[header.Name]
+		if !ok {
+			return nil, fmt.Errorf("backup %v has %v, which is not in its manifest", path, header.Name)
+		}
+		err = readStore(archive, store)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (m *Manifest) validate() error {
+	if m.Version < 1 || m.Version > FormatVersion {
+		return fmt.Errorf("unsupported backup version %v, expected at most %v", m.Version, FormatVersion)
+	}
+	for _, storeManifest := range m.Stores {
+		store, ok := findBackupStore(storeManifest.Name)
+		if !ok {
+			return fmt.Errorf("unknown store %v", storeManifest.Name)
+		}
+		for _, column := range storeManifest.Columns {
+			if !slices.Contains(store.columns, column) {
+				return fmt.Errorf("unknown column %v of %v", column, store.name)
+			}
+		}
+	}
+	return nil
+}
+
+func countLines(r io.Reader) (int64, error) {
+	var lines int64
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buffer)
+		lines += int64(bytes.Count(buffer[:n], []byte{'\n'}))
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"com/connections/db/dbfake"
+	"com/data"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const timestamp int64 = 1700000000
+
+// A connection with rows in every store, including NULL and non-NULL values.
+func populatedConnection(t *testing.T) *dbfake.Connection {
+	t.Helper()
+	ctx := t.Context()
+	dbConnection := dbfake.NewConnection()
+	deviceID, err := dbConnection.Devices().Add(ctx, data.Device{BrandID: "brand", Kind: "THSensor", Name: "Freezer", Timestamp: timestamp, Status: data.DeviceOnline})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []any{json.Number("-18.5"), json.Number("-17"), true, "open", nil} {
+		_, err = dbConnection.Events().Add(ctx, data.Event{EventSourceDeviceID: deviceID, EventTimestamp: timestamp, FieldName: "state"}.WithValue(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = dbConnection.Rollups().Recompute(ctx, data.ResolutionHour, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobID, err := dbConnection.Jobs().Add(ctx, data.Job{Category: "backup", StartTimestamp: timestamp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := []error{}
+	_, err = dbConnection.Batteries().Add(ctx, data.BatteryReading{DeviceID: deviceID, Level: 80, RawValue: "4", Timestamp: timestamp})
+	errs = append(errs, err)
+	_, err = dbConnection.AlertStates().Add(ctx, data.AlertState{RuleName: "freezer", DeviceID: deviceID, Status: data.AlertFiring, LastValue: "-5"})
+	errs = append(errs, err)
+	_, err = dbConnection.Deliveries().Add(ctx, data.Delivery{JobID: jobID, Channel: "hook", Title: "Freezer too warm", Attempt: 1, Status: data.DeliveryFailed, Error: "refused"})
+	errs = append(errs, err)
+	_, err = dbConnection.ExportMarks().Add(ctx, data.ExportMark{ExportName: "events", LastEventID: "event", ExportedCount: 5})
+	errs = append(errs, err)
+	_, err = dbConnection.Logs().Add(ctx, data.Log{JobID: jobID, Level: 2, Description: "Backed up", Timestamp: timestamp})
+	errs = append(errs, err)
+	err = errors.Join(errs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbConnection
+}
+
+// The rows of each store as backed up, by store.
+func storeRows(t *testing.T, dbConnection *dbfake.Connection) map[string]string {
+	t.Helper()
+	rows := map[string]string{}
+	for _, store := range backupStores {
+		var buffer bytes.Buffer
+		_, err := store.backup(t.Context(), dbConnection, &buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows[store.name] = buffer.String()
+	}
+	return rows
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	source := populatedConnection(t)
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	manifest, err := Backup(t.Context(), source, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Stores) != len(backupStores) {
+		t.Fatalf("expected every store in the manifest, got %v", manifest.Stores)
+	}
+	for _, store := range manifest.Stores {
+		if store.Rows == 0 {
+			t.Errorf("expected rows of %v backed up", store.Name)
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("expected only the backup, got %v", entries)
+	}
+
+	target := dbfake.NewConnection()
+	_, err = Restore(t.Context(), target, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := storeRows(t, source)
+	for name, rows := range storeRows(t, target) {
+		if rows != expected[name] {
+			t.Errorf("expected %v restored as\n%v\ngot\n%v", name, expected[name], rows)
+		}
+	}
+}
+
+func TestRestoreRefusesStoresWithRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	_, err := Backup(t.Context(), populatedConnection(t), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := dbfake.NewConnection()
+	_, err = target.Logs().Add(t.Context(), data.Log{Description: "existing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := storeRows(t, target)
+	_, err = Restore(t.Context(), target, path)
+	if err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Fatalf("expected restoring into stores with rows to fail, got %v", err)
+	}
+	for name, rows := range storeRows(t, target) {
+		if rows != before[name] {
+			t.Errorf("expected %v unchanged, got %v", name, rows)
+		}
+	}
+}
+
+// Rewrite the archive at the path, passing each file's content through change.
+func rewriteArchive(t *testing.T, path string, change func(name string, content []byte) []byte) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	decompressor, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	compressor := gzip.NewWriter(&buffer)
+	writer := tar.NewWriter(compressor)
+	reader := tar.NewReader(decompressor)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content = change(header.Name, content)
+		if content == nil {
+			continue
+		}
+		header.Size = int64(len(content))
+		err = errors.Join(writer.WriteHeader(header), func() error { _, err := writer.Write(content); return err }())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = errors.Join(writer.Close(), compressor.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewritten := filepath.Join(t.TempDir(), "rewritten.tar.gz")
+	err = os.WriteFile(rewritten, buffer.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rewritten
+}
+
+// Change the manifest's entry of the store.
+func changeManifest(t *testing.T, store string, change func(*StoreManifest)) func(string, []byte) []byte {
+	return func(name string, content []byte) []byte {
+		if name != ManifestName {
+			return content
+		}
+		var manifest Manifest
+		err := json.Unmarshal(content, &manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range manifest.Stores {
+			if manifest.Stores[i].Name == store {
+				change(&manifest.Stores[i])
+			}
+		}
+		encoded, err := json.Marshal(manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+}
+
+func TestVerifyRejectsTamperedArchives(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	_, err := Backup(t.Context(), populatedConnection(t), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Verify(path)
+	if err != nil {
+		t.Fatalf("expected the backup to verify, got %v", err)
+	}
+
+	tampered := map[string]func(name string, content []byte) []byte{
+		"row count": changeManifest(t, "events", func(store *StoreManifest) { store.Rows++ }),
+		"checksum":  changeManifest(t, "events", func(store *StoreManifest) { store.SHA256 = strings.Repeat("0", 64) }),
+		"content": func(name string, content []byte) []byte {
+			if name != "devices.ndjson" {
+				return content
+			}
+			return bytes.Replace(content, []byte("Freezer"), []byte("Fridge!"), 1)
+		},
+		"missing store": func(name string, content []byte) []byte {
+			if name == "logs.ndjson" {
+				return nil
+			}
+			return content
+		},
+	}
+	for name, change := range tampered {
+		_, err := Verify(rewriteArchive(t, path, change))
+		if err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	valid := Manifest{Version: FormatVersion, Stores: []StoreManifest{{Name: "devices", Columns: []string{"device_id", "device_name"}}}}
+	err := valid.validate()
+	if err != nil {
+		t.Fatalf("expected the manifest to be valid, got %v", err)
+	}
+
+	invalid := map[string]Manifest{
+		"no version":     {Stores: valid.Stores},
+		"future version": {Version: FormatVersion + 1, Stores: valid.Stores},
+		"unknown store":  {Version: FormatVersion, Stores: []StoreManifest{{Name: "sensors"}}},
+		"unknown column": {Version: FormatVersion, Stores: []StoreManifest{{Name: "devices", Columns: []string{"device_id", "device_color"}}}},
+	}
+	for name, manifest := range invalid {
+		err := manifest.validate()
+		if err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"com/connections/db"
+	"com/data"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// A store backups include, written as a JSON object per row keyed by column.
+type backupStore struct {
+	name    string
+	columns []string
+	backup  func(ctx context.Context, dbConnection db.DBConnection, w io.Writer) (int64, error)
+	restore func(ctx context.Context, dbConnection db.DBConnection, r io.Reader) (int64, error)
+	isEmpty func(ctx context.Context, dbConnection db.DBConnection) (bool, error)
+}
+
+// Every store, in the order they are restored, so rows are restored after those they reference.
+var backupStores = []backupStore{
+	newBackupStore("devices", func(c db.DBConnection) db.GenericStore[data.Device, data.StoreDevice, data.DeviceFilter] {
+		return c.Devices()
+	}),
+	newBackupStore("events", func(c db.DBConnection) db.GenericStore[data.Event, data.StoreEvent, data.EventFilter] {
+		return c.Events()
+	}),
+	newBackupStore("device_state", func(c db.DBConnection) db.GenericStore[data.DeviceState, data.StoreDeviceState, data.DeviceStateFilter] {
+		return c.DeviceStates()
+	}),
+	newBackupStore("rollups", func(c db.DBConnection) db.GenericStore[data.Rollup, data.StoreRollup, data.RollupFilter] {
+		return c.Rollups()
+	}),
+	newBackupStore("battery_readings", func(c db.DBConnection) db.GenericStore[data.BatteryReading, data.StoreBatteryReading, data.BatteryReadingFilter] {
+		return c.Batteries()
+	}),
+	newBackupStore("alert_states", func(c db.DBConnection) db.GenericStore[data.AlertState, data.StoreAlertState, data.AlertStateFilter] {
+		return c.AlertStates()
+	}),
+	newBackupStore("deliveries", func(c db.DBConnection) db.GenericStore[data.Delivery, data.StoreDelivery, data.DeliveryFilter] {
+		return c.Deliveries()
+	}),
+	newBackupStore("export_marks", func(c db.DBConnection) db.GenericStore[data.ExportMark, data.StoreExportMark, data.ExportMarkFilter] {
+		return c.ExportMarks()
+	}),
+	newBackupStore("jobs", func(c db.DBConnection) db.GenericStore[data.Job, data.StoreJob, data.JobFilter] {
+		return c.Jobs()
+	}),
+	newBackupStore("logs", func(c db.DBConnection) db.GenericStore[data.Log, data.StoreLog, data.LogFilter] {
+		return c.Logs()
+	}),
+}
+
+func findBackupStore(name string) (backupStore, bool) {
+	index := slices.IndexFunc(backupStores, func(s backupStore) bool { return s.name == name })
+	if index < 0 {
+		return backupStore{}, false
+	}
+	return backupStores[index], true
+}
+
+func newBackupStore[T any, S data.HasIDGetterAndSpreadable[S], F any](name string, store func(db.DBConnection) db.GenericStore[T, S, F]) backupStore {
+	columns := data.Columns[S]()
+	return backupStore{
+		name:    name,
+		columns: columns,
+		backup: func(ctx context.Context, dbConnection db.DBConnection, w io.Writer) (int64, error) {
+			encoder := json.NewEncoder(w)
+			var rows int64
+			var filter F
+			for item, err := range store(dbConnection).Get(ctx, filter).All(ctx) {
+				if err != nil {
+					return rows, fmt.Errorf("error reading %v: %w", name, err)
+				}
+				row := map[string]any{}
+				for index, value := range item.Spread() {
+					row[columns[index]] = value
+				}
+				err = encoder.Encode(row)
+				if err != nil {
+					return rows, fmt.Errorf("error encoding %v %v: %w", name, item.GetID(), err)
+				}
+				rows++
+			}
+			return rows, nil
+		},
+		restore: func(ctx context.Context, dbConnection db.DBConnection, r io.Reader) (int64, error) {
+			decoder := json.NewDecoder(r)
+			var rows int64
+			for decoder.More() {
+				row := map[string]json.RawMessage{}
+				err := decoder.Decode(&row)
+				if err != nil {
+					return rows, fmt.Errorf("error decoding row %v of %v: %w", rows+1, name, err)
+				}
+				var item S
+				itemAddress, addresses := item.SpreadAddresses()
+				for index, column := range columns {
+					value, ok := row[column]
+					if !ok {
+						// Columns added since the backup keep their zero value
+						continue
+					}
+					err = json.Unmarshal(value, addresses[index])
+					if err != nil {
+						return rows, fmt.Errorf("error decoding %v of row %v of %v: %w", column, rows+1, name, err)
+					}
+					delete(row, column)
+				}
+				for column := range row {
+					return rows, fmt.Errorf("row %v of %v has unknown column %v", rows+1, name, column)
+				}
+				err = store(dbConnection).Restore(ctx, *itemAddress)
+				if err != nil {
+					return rows, fmt.Errorf("error restoring %v: %w", name, err)
+				}
+				rows++
+			}
+			return rows, nil
+		},
+		isEmpty: func(ctx context.Context, dbConnection db.DBConnection) (bool, error) {
+			var filter F
+			item, err := store(dbConnection).Get(ctx, filter).Next(ctx)
+			if err != nil {
+				return false, fmt.Errorf("error reading %v: %w", name, err)
+			}
+			return item == nil, nil
+		},
+	}
+}
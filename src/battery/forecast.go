@@ -0,0 +1,137 @@
+package battery
+
+import (
+	"cmp"
+	"com/connections/db"
+	"com/data"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// How far back readings are used to estimate drain.
+const DefaultForecastWindow = 90 * 24 * time.Hour
+
+const secondsPerDay = 24 * 60 * 60
+
+// The battery outlook of a single device.
+type Forecast struct {
+	DeviceID   string `json:"deviceId"`
+	DeviceName string `json:"deviceName"`
+	DeviceKind string `json:"deviceKind"`
+	// Latest level as a percentage, nil if the device never reported one.
+	Level       *int  `json:"level"`
+	LastReading int64 `json:"lastReading"`
+	Readings    int   `json:"readings"`
+	// Percentage points lost per day, estimated from the trend over the window.
+	DailyDrain float64 `json:"dailyDrain"`
+	// Estimated days from now until depletion, nil if the level is not falling.
+	DaysRemaining *float64 `json:"daysRemaining"`
+}
+
+// Forecast every device, ranking those most in need of a battery replacement first.
+func Report(ctx context.Context, dbConnection db.DBConnection, window time.Duration) ([]Forecast, error) {
+	forecasts := []Forecast{}
+	devices := dbConnection.Devices().Get(ctx, data.DeviceFilter{})
+	for device, err := range devices.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("error getting next device: %w", err)
+		}
+		forecast, err := ForecastDevice(ctx, dbConnection, device, window)
+		if err != nil {
+			return nil, err
+		}
+		if forecast.Level == nil {
+			continue
+		}
+		forecasts = append(forecasts, *forecast)
+	}
+
+	slices.SortStableFunc(forecasts, func(a, b Forecast) int {
+		// Known depletion first, soonest first
+		if a.DaysRemaining != nil && b.DaysRemaining != nil {
+			return cmp.Compare(*a.DaysRemaining, *b.DaysRemaining)
+		}
+		if a.DaysRemaining != nil {
+			return -1
+		}
+		if b.DaysRemaining != nil {
+			return 1
+		}
+		return cmp.Compare(*a.Level, *b.Level)
+	})
+	return forecasts, nil
+}
+
+// Estimate when the device's battery runs out with a least squares fit of its readings within the window.
+func ForecastDevice(ctx context.Context, dbConnection db.DBConnection, device data.StoreDevice, window time.Duration) (*Forecast, error) {
+	now := time.Now().UTC().Unix()
+	start := now - int64(window/time.Second)
+	readings := dbConnection.Batteries().GetInTimeRange(ctx, data.BatteryReadingFilter{DeviceID: &device.ID}, &start, nil)
+
+	forecast := &Forecast{DeviceID: device.ID, DeviceName: device.Name, DeviceKind: device.Kind}
+	var sumX, sumY, sumXY, sumXX float64
+	for reading, err := range readings.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("error getting battery readings of device %v: %w", device.ID, err)
+		}
+		forecast.Readings++
+		if reading.Timestamp >= forecast.LastReading {
+			forecast.LastReading = reading.Timestamp
+			forecast.Level = &reading.Level
+		}
+		x := float64(reading.Timestamp-start) / secondsPerDay
+		y := float64(reading.Level)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	if forecast.Readings < 2 {
+		return forecast, nil
+	}
+
+	n := float64(forecast.Readings)
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return forecast, nil
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	forecast.DailyDrain = -slope
+	if slope < 0 {
+		intercept := (sumY - slope*sumX) / n
+		depletionDay := -intercept / slope
+		daysRemaining := max(0, depletionDay-float64(now-start)/secondsPerDay)
+		forecast.DaysRemaining = &daysRemaining
+	}
+	return forecast, nil
+}
+
+// Write forecasts as an aligned table.
+func WriteReport(w io.Writer, forecasts []Forecast) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, err := fmt.Fprintln(tw, "DEVICE\tKIND\tLEVEL\tLAST READING\tDRAIN/DAY\tDAYS LEFT")
+	if err != nil {
+		return fmt.Errorf("error writing report header: %w", err)
+	}
+	for _, f := range forecasts {
+		daysRemaining := "-"
+		if f.DaysRemaining != nil {
+			daysRemaining = strconv.FormatFloat(*f.DaysRemaining, 'f', 0, 64)
+		}
+		_, err = fmt.Fprintf(tw, "%v\t%v\t%v%%\t%v\t%.2f\t%v\n",
+			f.DeviceName, f.DeviceKind, *f.Level, data.EpochSecondsToExcelDate(f.LastReading), f.DailyDrain, daysRemaining)
+		if err != nil {
+			return fmt.Errorf("error writing report row for %v: %w", f.DeviceID, err)
+		}
+	}
+	err = tw.Flush()
+	if err != nil {
+		return fmt.Errorf("error flushing report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package battery
+
+import (
+	"com/connections/db"
+	"com/connections/sensors"
+	"com/data"
+	"com/jobs"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// YoLink reports battery levels from 0 to 4.
+const yoLinkMaxBatteryLevel = 4
+
+var _ jobs.EventHandler = (*Tracker)(nil)
+
+// Extracts battery readings from stored events into the battery store, once per device report.
+type Tracker struct {
+	dbConnection db.DBConnection
+}
+
+func NewTracker(dbConnection db.DBConnection) *Tracker {
+	return &Tracker{dbConnection: dbConnection}
+}
+
+func (t *Tracker) HandleEvents(ctx context.Context, device *data.StoreDevice, events []data.StoreEvent) error {
+	for _, event := range events {
+		if !IsBatteryField(event.FieldName) {
+			continue
+		}
+		level, err := ParseLevel(device.Brand, event.FieldValue)
+		if err != nil {
+			return fmt.Errorf("error reading battery level of event %v: %w", event.ID, err)
+		}
+
+		// Devices repeat their last report until they report again
+		existing := t.dbConnection.Batteries().Find(ctx, data.Query[data.BatteryReadingFilter]{
+			Filter: data.BatteryReadingFilter{DeviceID: &device.ID, Timestamp: &event.EventTimestamp},
+			Limit:  1,
+		})
+		first, err := existing.Next(ctx)
+		if err != nil {
+			return fmt.Errorf("error checking for existing battery readings: %w", err)
+		}
+		if first != nil {
+			continue
+		}
+
+		reading := data.BatteryReading{
+			DeviceID:  device.ID,
+			EventID:   event.ID,
+			Level:     level,
+			RawValue:  event.FieldValue,
+			Timestamp: event.EventTimestamp,
+		}
+		_, err = t.dbConnection.Batteries().Add(ctx, reading)
+		if err != nil {
+			return fmt.Errorf("error adding battery reading %v: %w", reading, err)
+		}
+	}
+	return nil
+}
+
+// Whether the flattened field name holds a battery level, such as "battery" or "state.battery".
+func IsBatteryField(fieldName string) bool {
+	return fieldName == "battery" || strings.HasSuffix(fieldName, ".battery")
+}
+
+// Convert a reported battery value into a percentage.
+func ParseLevel(brand string, value string) (int, error) {
+	level, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("battery level %v is not a number: %w", value, err)
+	}
+	if brand == sensors.YOLINK_BRAND_NAME {
+		level = level / yoLinkMaxBatteryLevel * 100
+	}
+	return int(math.Round(max(0, min(100, level)))), nil
+}
@@ -0,0 +1,42 @@
+package battery
+
+import (
+	"com/connections/sensors"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		brand    string
+		value    string
+		expected int
+	}{
+		{sensors.YOLINK_BRAND_NAME, "4", 100},
+		{sensors.YOLINK_BRAND_NAME, "3", 75},
+		{sensors.YOLINK_BRAND_NAME, "0", 0},
+		{sensors.YOLINK_BRAND_NAME, "5", 100},
+		{"other", "42.4", 42},
+		{"other", "-3", 0},
+	}
+	for _, test := range tests {
+		level, err := ParseLevel(test.brand, test.value)
+		if err != nil {
+			t.Fatalf("%v %v: %v", test.brand, test.value, err)
+		}
+		if level != test.expected {
+			t.Errorf("%v %v: expected %v, got %v", test.brand, test.value, test.expected, level)
+		}
+	}
+	_, err := ParseLevel(sensors.YOLINK_BRAND_NAME, "full")
+	if err == nil {
+		t.Error("expected an error for a non-numeric level")
+	}
+}
+
+func TestIsBatteryField(t *testing.T) {
+	for field, expected := range map[string]bool{"battery": true, "state.battery": true, "state.batteryType": false, "state": false} {
+		if IsBatteryField(field) != expected {
+			t.Errorf("%v: expected %v", field, expected)
+		}
+	}
+}
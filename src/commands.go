@@ -0,0 +1,292 @@
+package main
+
+import (
+	"com/backup"
+	"com/battery"
+	"com/connections/db"
+	"com/connections/db/mysql"
+	"com/data"
+	"com/exports"
+	"com/imports"
+	"com/logs"
+	"com/rollups"
+	"com/utils"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Commands selected by the first argument. Without one, the collector runs.
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"":                run,
+	"run":             run,
+	"battery-report":  batteryReport,
+	"rollup-backfill": rollupBackfill,
+	"pivot-export":    pivotExport,
+	"import":          importCSV,
+	"backup":          backupDB,
+	"restore":         restoreDB,
+}
+
+// Database simulated data is collected into, kept apart from real data.
+const simulationDatabaseName = "yolinksimulation"
+
+func connectDB(ctx context.Context) (*mysql.MySQLConnection, error) {
+	databaseName := mysql.DatabaseName
+	if *simulate {
+		databaseName = simulationDatabaseName
+	}
+	dbConnection, err := mysql.NewMySQLConnectionToDatabase(ctx, strings.TrimSpace(os.Getenv("MYSQL_CONNECTION_STRING")), databaseName, false)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to DB: %w", err)
+	}
+	return dbConnection, nil
+}
+
+// Like connectDB, connecting to the named database instead if there is one.
+func connectNamedDB(ctx context.Context, databaseName string) (*mysql.MySQLConnection, error) {
+	if databaseName == "" {
+		return connectDB(ctx)
+	}
+	dbConnection, err := mysql.NewMySQLConnectionToDatabase(ctx, strings.TrimSpace(os.Getenv("MYSQL_CONNECTION_STRING")), databaseName, false)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to DB: %w", err)
+	}
+	return dbConnection, nil
+}
+
+// Print devices ranked by how soon their batteries need replacing.
+func batteryReport(ctx context.Context, _ []string) error {
+	dbConnection, err := connectDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer logs.LogErrorsWithContext(ctx, dbConnection.Close, fmt.Sprintf("error closing db connection %v", dbConnection))
+
+	forecasts, err := battery.Report(ctx, dbConnection, battery.DefaultForecastWindow)
+	if err != nil {
+		return fmt.Errorf("error creating battery report: %w", err)
+	}
+	return battery.WriteReport(os.Stdout, forecasts)
+}
+
+// Recompute rollups from stored events. Optional -since duration, e.g. -since 30d, limits how far back.
+func rollupBackfill(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("rollup-backfill", flag.ContinueOnError)
+	since := flags.String("since", "", "how far back to recompute, e.g. 30d. Defaults to all events")
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("error parsing arguments: %w", err)
+	}
+	var startTime *int64
+	if *since != "" {
+		duration, err := utils.ParseDuration(*since)
+		if err != nil {
+			return err
+		}
+		start := time.Now().UTC().Add(-duration).Unix()
+		startTime = &start
+	}
+
+	dbConnection, err := connectDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer logs.LogErrorsWithContext(ctx, dbConnection.Close, fmt.Sprintf("error closing db connection %v", dbConnection))
+	return rollups.Backfill(ctx, dbConnection, startTime)
+}
+
+// Export events with a row per device and reading and a column per field.
+func pivotExport(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("pivot-export", flag.ContinueOnError)
+	fields := flags.String("fields", "", "comma separated fields to include, e.g. state.temperature,state.humidity. Defaults to all")
+	bucket := flags.String("bucket", "", "length of time buckets readings are grouped into, e.g. 15m. Defaults to exact timestamps")
+	since := flags.String("since", "", "how far back to export, e.g. 7d. Defaults to all events")
+	device := flags.String("device", "", "ID of a single device to export")
+	format := flags.String("format", "", "csv, ndjson, parquet or xlsx. Defaults to csv")
+	compress := flags.String("compress", "", "gzip or zstd. Defaults to uncompressed")
+	timestamps := flags.String("timestamps", "", "text, excel, rfc3339, epoch or epoch_ms. Defaults to the format's own dates")
+	timeZone := flags.String("timezone", "", "IANA time zone of timestamps, e.g. America/New_York. Defaults to UTC")
+	errorBudget := flags.Int("error-budget", 0, "rows that may fail to be written, and are skipped, before the export fails")
+	destination := flags.String("destination", "", "directory, s3:// or sftp:// URL, or - for standard output. Defaults to the export directory")
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("error parsing arguments: %w", err)
+	}
+	exportFormat, err := data.ParseExportFormat(*format)
+	if err != nil {
+		return err
+	}
+	compression, err := data.ParseExportCompression(*compress)
+	if err != nil {
+		return err
+	}
+	dates, err := data.ParseDateFormat(*timestamps, *timeZone)
+	if err != nil {
+		return err
+	}
+	pivot := data.PivotOptions{}
+	if *fields != "" {
+		pivot.Fields = strings.Split(*fields, ",")
+	}
+	if *bucket != "" {
+		duration, err := utils.ParseDuration(*bucket)
+		if err != nil {
+			return err
+		}
+		pivot.BucketSeconds = int64(duration.Seconds())
+	}
+	if *since != "" {
+		duration, err := utils.ParseDuration(*since)
+		if err != nil {
+			return err
+		}
+		start := time.Now().UTC().Add(-duration).Unix()
+		pivot.StartTime = &start
+	}
+	if *device != "" {
+		pivot.DeviceID = device
+	}
+
+	dbConnection, err := connectDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer logs.LogErrorsWithContext(ctx, dbConnection.Close, fmt.Sprintf("error closing db connection %v", dbConnection))
+	sink, err := exports.OpenSink(*destination)
+	if err != nil {
+		return fmt.Errorf("error opening export destination: %w", err)
+	}
+	defer logs.LogErrorsWithContext(ctx, func() error { return exports.CloseSink(sink) }, "error closing export destination")
+	report, err := exports.ExportPivotedEvents(ctx, dbConnection, pivot, data.ExportOptions{Format: exportFormat, Compression: compression, Sink: sink, Dates: dates, ErrorBudget: *errorBudget})
+	// The report goes to standard error, so it does not mix with exports to standard output
+	fmt.Fprintln(os.Stderr, "Exported", report)
+	if err != nil {
+		return fmt.Errorf("error exporting pivoted events: %w", err)
+	}
+	return nil
+}
+
+// Import events from CSV files described by a -mapping file, then recompute rollups from the earliest imported event.
+func importCSV(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	mappingPath := flags.String("mapping", "", "JSON file mapping the files' columns to devices and events")
+	recomputeRollups := flags.Bool("rollups", true, "recompute rollups of the imported events")
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("error parsing arguments: %w", err)
+	}
+	if *mappingPath == "" || flags.NArg() == 0 {
+		return errors.New("usage: import -mapping mapping.json [-rollups=false] file.csv...")
+	}
+	mapping, err := imports.LoadMapping(*mappingPath)
+	if err != nil {
+		return err
+	}
+
+	dbConnection, err := connectDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer logs.LogErrorsWithContext(ctx, dbConnection.Close, fmt.Sprintf("error closing db connection %v", dbConnection))
+	jobLogger, err := logs.CreateJob(ctx, dbConnection, logs.Import)
+	if err != nil {
+		return fmt.Errorf("error creating import job: %w", err)
+	}
+	ctx = logs.ContextWithLogger(ctx, jobLogger)
+	defer logs.EndJobWithContext(ctx)
+
+	var earliest *int64
+	for _, path := range flags.Args() {
+		report, err := importFile(ctx, dbConnection, *mapping, path)
+		if report.Earliest != nil && (earliest == nil || *report.Earliest < *earliest) {
+			earliest = report.Earliest
+		}
+		if err != nil {
+			logs.ErrorWithContext(ctx, "Import failed after %v", report)
+			return err
+		}
+		if report.SkippedRows > 0 {
+			logs.WarnWithContext(ctx, "Imported %v", report)
+		} else {
+			logs.InfoWithContext(ctx, "Imported %v", report)
+		}
+	}
+	if !*recomputeRollups || earliest == nil {
+		return nil
+	}
+	return rollups.Backfill(ctx, dbConnection, earliest)
+}
+
+func importFile(ctx context.Context, dbConnection db.DBConnection, mapping imports.Mapping, path string) (imports.Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return imports.Report{Name: path}, fmt.Errorf("error opening %v: %w", path, err)
+	}
+	defer logs.LogErrorsWithContext(ctx, file.Close, fmt.Sprintf("error closing %v", path))
+	return imports.ImportCSV(ctx, dbConnection, file, mapping, path)
+}
+
+// Write every store of the -database flag's database into a backup archive at the given path.
+func backupDB(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	database := flags.String("database", "", "database to back up. Defaults to the collector's")
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("error parsing arguments: %w", err)
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: backup [-database name] backup.tar.gz")
+	}
+	path := flags.Arg(0)
+	dbConnection, err := connectNamedDB(ctx, *database)
+	if err != nil {
+		return err
+	}
+	defer logs.LogErrorsWithContext(ctx, dbConnection.Close, fmt.Sprintf("error closing db connection %v", dbConnection))
+	manifest, err := backup.Backup(ctx, dbConnection, path)
+	if err != nil {
+		return fmt.Errorf("error backing up: %w", err)
+	}
+	logs.InfoWithContext(ctx, "Backed up %v stores to %v", len(manifest.Stores), path)
+	return nil
+}
+
+// Restore a backup archive into the -database flag's database, which must be empty.
+func restoreDB(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	database := flags.String("database", "", "database to restore into, which must be empty. Defaults to the collector's")
+	isVerifyOnly := flags.Bool("verify", false, "only check the archive against its manifest")
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("error parsing arguments: %w", err)
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: restore [-database name] [-verify] backup.tar.gz")
+	}
+	path := flags.Arg(0)
+	if *isVerifyOnly {
+		manifest, err := backup.Verify(path)
+		if err != nil {
+			return err
+		}
+		logs.InfoWithContext(ctx, "Verified backup %v of version %v from %v", path, manifest.Version, manifest.CreatedAt)
+		return nil
+	}
+
+	dbConnection, err := connectNamedDB(ctx, *database)
+	if err != nil {
+		return err
+	}
+	defer logs.LogErrorsWithContext(ctx, dbConnection.Close, fmt.Sprintf("error closing db connection %v", dbConnection))
+	manifest, err := backup.Restore(ctx, dbConnection, path)
+	if err != nil {
+		return fmt.Errorf("error restoring %v: %w", path, err)
+	}
+	logs.InfoWithContext(ctx, "Restored %v stores from %v", len(manifest.Stores), path)
+	return nil
+}
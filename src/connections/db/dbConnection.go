@@ -2,6 +2,7 @@ package db
 
 import (
 	"com/connections"
+	"context"
 )
 
 type DBConnection interface {
@@ -10,4 +11,14 @@ type DBConnection interface {
 	Events() EventStore
 	Logs() LogStore
 	Jobs() JobStore
+	AlertStates() AlertStateStore
+	Deliveries() DeliveryStore
+	Batteries() BatteryStore
+	DeviceStates() DeviceStateStore
+	Rollups() RollupStore
+	ExportMarks() ExportMarkStore
+	// Run fn with a connection whose stores all work within one transaction, committed if fn succeeds and rolled back
+	// otherwise. Read-only transactions see every store as of the same moment, whatever is written meanwhile.
+	// The connection given to fn is only valid until fn returns, and must not be closed.
+	WithTransaction(ctx context.Context, isReadOnly bool, fn func(tx DBConnection) error) error
 }
@@ -0,0 +1,203 @@
+// An in-memory DBConnection for tests, behaving as the MySQL stores do as far as the conformance suite checks.
+// Unlike MySQL, strings compare exactly rather than case-insensitively, and events need no stored device.
+package dbfake
+
+import (
+	"com/connections"
+	"com/connections/db"
+	"com/data"
+	"context"
+	"maps"
+	"sync"
+)
+
+// Tables of every store, shared by the connections to the database.
+type Database struct {
+	mutex  sync.Mutex
+	tables map[string]table
+	// Whether this is the copy of a read-only transaction, which cannot be written to.
+	isReadOnly bool
+	// Held by write transactions, so that they run one at a time.
+	transactionMutex sync.Mutex
+}
+
+// A store's items by ID.
+type table interface {
+	clone() table
+}
+
+type items[S any] map[string]S
+
+func (i items[S]) clone() table {
+	return maps.Clone(i)
+}
+
+func NewDatabase() *Database {
+	return &Database{tables: map[string]table{}}
+}
+
+// The tables as they are now, unaffected by later writes.
+func (d *Database) snapshot() map[string]table {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	tables := map[string]table{}
+	for name, t := range d.tables {
+		tables[name] = t.clone()
+	}
+	return tables
+}
+
+var _ db.DBConnection = (*Connection)(nil)
+
+type Connection struct {
+	database *Database
+	// Whether stores work within WithTransaction.
+	isTransaction    bool
+	eventStore       *EventStore
+	deviceStore      *EditableStore[data.Device, data.StoreDevice, data.DeviceFilter]
+	jobStore         *ClosableStore[data.Job, data.StoreJob, data.JobFilter]
+	logStore         *TimestampedDataStore[data.Log, data.StoreLog, data.LogFilter]
+	alertStateStore  *EditableStore[data.AlertState, data.StoreAlertState, data.AlertStateFilter]
+	deliveryStore    *TimestampedDataStore[data.Delivery, data.StoreDelivery, data.DeliveryFilter]
+	batteryStore     *TimestampedDataStore[data.BatteryReading, data.StoreBatteryReading, data.BatteryReadingFilter]
+	deviceStateStore *DeviceStateStore
+	rollupStore      *RollupStore
+	exportMarkStore  *EditableStore[data.ExportMark, data.StoreExportMark, data.ExportMarkFilter]
+}
+
+// A connection to a new, empty database.
+func NewConnection() *Connection {
+	return NewDatabase().Connect(true)
+}
+
+// Connect to the database, setting up its stores. isSetupDestructive true empties them.
+func (d *Database) Connect(isSetupDestructive bool) *Connection {
+	connection := newConnection(d, false)
+	for _, setup := range connection.setups() {
+		// Setting up in-memory stores cannot fail
+		_ = setup(context.Background(), isSetupDestructive)
+	}
+	return connection
+}
+
+func newConnection(database *Database, isTransaction bool) *Connection {
+	devices := newEditableStore[data.Device, data.StoreDevice, data.DeviceFilter](database, "devices")
+	deviceStates := &DeviceStateStore{Store: newStore[data.DeviceState, data.StoreDeviceState, data.DeviceStateFilter](database, "device_state", "device_id", "field_name")}
+	rollups := &RollupStore{TimestampedDataStore: TimestampedDataStore[data.Rollup, data.StoreRollup, data.RollupFilter]{
+		Store:        newStore[data.Rollup, data.StoreRollup, data.RollupFilter](database, "rollups", "device_id", "field_name", "rollup_resolution", "bucket_start"),
+		timestampKey: "bucket_start",
+		valueKey:     "rollup_mean",
+		groupKeys:    map[data.AggregateGroup]string{data.GroupByDevice: "device_id", data.GroupByField: "field_name"},
+		summaryKeys:  &summaryKeys{min: "rollup_min", max: "rollup_max", count: "rollup_count"},
+	}, eventsTableName: "events"}
+	events := &EventStore{
+		TimestampedDataStore: TimestampedDataStore[data.Event, data.StoreEvent, data.EventFilter]{
+			Store:        newStore[data.Event, data.StoreEvent, data.EventFilter](database, "events"),
+			timestampKey: "event_timestamp",
+			valueKey:     "numeric_value",
+			groupKeys:    map[data.AggregateGroup]string{data.GroupByDevice: "event_source_device_id", data.GroupByField: "field_name"},
+		},
+		deviceStates: deviceStates,
+		rollups:      rollups,
+	}
+	return &Connection{
+		database:         database,
+		isTransaction:    isTransaction,
+		eventStore:       events,
+		deviceStore:      devices,
+		deviceStateStore: deviceStates,
+		rollupStore:      rollups,
+		jobStore: &ClosableStore[data.Job, data.StoreJob, data.JobFilter]{
+			TimestampedDataStore: TimestampedDataStore[data.Job, data.StoreJob, data.JobFilter]{
+				Store:        newStore[data.Job, data.StoreJob, data.JobFilter](database, "jobs"),
+				timestampKey: "job_start_timestamp",
+			},
+			closeKey: "job_end_timestamp",
+		},
+		logStore: &TimestampedDataStore[data.Log, data.StoreLog, data.LogFilter]{
+			Store:        newStore[data.Log, data.StoreLog, data.LogFilter](database, "logs"),
+			timestampKey: "log_timestamp",
+		},
+		alertStateStore: newEditableStore[data.AlertState, data.StoreAlertState, data.AlertStateFilter](database, "alert_states", "alert_rule_name", "device_id"),
+		deliveryStore: &TimestampedDataStore[data.Delivery, data.StoreDelivery, data.DeliveryFilter]{
+			Store:        newStore[data.Delivery, data.StoreDelivery, data.DeliveryFilter](database, "deliveries"),
+			timestampKey: "delivery_timestamp",
+		},
+		batteryStore: &TimestampedDataStore[data.BatteryReading, data.StoreBatteryReading, data.BatteryReadingFilter]{
+			Store:        newStore[data.BatteryReading, data.StoreBatteryReading, data.BatteryReadingFilter](database, "battery_readings"),
+			timestampKey: "battery_timestamp",
+			valueKey:     "battery_level",
+			groupKeys:    map[data.AggregateGroup]string{data.GroupByDevice: "device_id"},
+		},
+		exportMarkStore: newEditableStore[data.ExportMark, data.StoreExportMark, data.ExportMarkFilter](database, "export_marks", "export_name"),
+	}
+}
+
+func (c *Connection) setups() []func(ctx context.Context, isDestructive bool) error {
+	return []func(ctx context.Context, isDestructive bool) error{
+		c.deviceStore.Setup, c.deviceStateStore.Setup, c.rollupStore.Setup, c.eventStore.Setup, c.jobStore.Setup,
+		c.logStore.Setup, c.alertStateStore.Setup, c.deliveryStore.Setup, c.batteryStore.Setup, c.exportMarkStore.Setup,
+	}
+}
+
+// Write transactions work on the database itself, and put back its tables as they were if fn fails, so writes from
+// outside the transaction meanwhile are undone with it. Read-only transactions work on a copy of the tables.
+func (c *Connection) WithTransaction(ctx context.Context, isReadOnly bool, fn func(tx db.DBConnection) error) error {
+	if c.isTransaction {
+		return fn(c)
+	}
+	if isReadOnly {
+		return fn(newConnection(&Database{tables: c.database.snapshot(), isReadOnly: true}, true))
+	}
+	c.database.transactionMutex.Lock()
+	defer c.database.transactionMutex.Unlock()
+	backup := c.database.snapshot()
+	err := fn(newConnection(c.database, true))
+	if err != nil {
+		c.database.mutex.Lock()
+		c.database.tables = backup
+		c.database.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (c *Connection) Open(context.Context) error {
+	return nil
+}
+func (c *Connection) Close() error {
+	return nil
+}
+func (c *Connection) Status(context.Context) (connections.PingResult, string) {
+	return connections.Good, ""
+}
+func (c *Connection) Devices() db.DeviceStore {
+	return c.deviceStore
+}
+func (c *Connection) Events() db.EventStore {
+	return c.eventStore
+}
+func (c *Connection) Jobs() db.JobStore {
+	return c.jobStore
+}
+func (c *Connection) Logs() db.LogStore {
+	return c.logStore
+}
+func (c *Connection) AlertStates() db.AlertStateStore {
+	return c.alertStateStore
+}
+func (c *Connection) Deliveries() db.DeliveryStore {
+	return c.deliveryStore
+}
+func (c *Connection) Batteries() db.BatteryStore {
+	return c.batteryStore
+}
+func (c *Connection) DeviceStates() db.DeviceStateStore {
+	return c.deviceStateStore
+}
+func (c *Connection) Rollups() db.RollupStore {
+	return c.rollupStore
+}
+func (c *Connection) ExportMarks() db.ExportMarkStore {
+	return c.exportMarkStore
+}
@@ -0,0 +1,24 @@
+package dbfake_test
+
+import (
+	"com/connections/db"
+	"com/connections/db/dbfake"
+	"com/connections/db/dbtest"
+	"context"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	database := dbfake.NewDatabase()
+	connect := func(ctx context.Context, isSetupDestructive bool) (db.DBConnection, error) {
+		return database.Connect(isSetupDestructive), nil
+	}
+	for _, c := range dbtest.Cases() {
+		t.Run(c.Name, func(t *testing.T) {
+			err := c.Run(t.Context(), connect)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
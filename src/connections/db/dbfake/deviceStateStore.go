@@ -0,0 +1,61 @@
+package dbfake
+
+import (
+	"com/connections/db"
+	"com/data"
+	"context"
+	"fmt"
+
+	"github.com/samborkent/uuidv7"
+)
+
+var _ db.DeviceStateStore = (*DeviceStateStore)(nil)
+
+type DeviceStateStore struct {
+	Store[data.DeviceState, data.StoreDeviceState, data.DeviceStateFilter]
+}
+
+func (s *DeviceStateStore) GetSnapshot(ctx context.Context) (map[string]data.DeviceSnapshot, error) {
+	snapshots := map[string]data.DeviceSnapshot{}
+	states := s.Get(ctx, data.DeviceStateFilter{})
+	for state, err := range states.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("error getting next device state: %w", err)
+		}
+		snapshot, ok := snapshots[state.DeviceID]
+		if !ok {
+			snapshot = data.DeviceSnapshot{DeviceID: state.DeviceID, Fields: map[string]data.StoreDeviceState{}}
+			snapshots[state.DeviceID] = snapshot
+		}
+		snapshot.Fields[state.FieldName] = state
+	}
+	return snapshots, nil
+}
+
+// Set the device's field to the event's value, unless a later event already set it. The database's mutex must be held.
+func (s *DeviceStateStore) upsertFromEvent(eventID string, event data.Event) {
+	states := s.items()
+	for id, state := range states {
+		if state.DeviceID != event.EventSourceDeviceID || state.FieldName != event.FieldName {
+			continue
+		}
+		if event.EventTimestamp >= state.EventTimestamp {
+			state.FieldValue = event.FieldValue
+			state.EventID = eventID
+			state.EventTimestamp = event.EventTimestamp
+			states[id] = state
+		}
+		return
+	}
+	id := uuidv7.New().String()
+	states[id] = data.StoreDeviceState{
+		HasID: data.HasID{ID: id},
+		DeviceState: data.DeviceState{
+			DeviceID:       event.EventSourceDeviceID,
+			FieldName:      event.FieldName,
+			FieldValue:     event.FieldValue,
+			EventID:        eventID,
+			EventTimestamp: event.EventTimestamp,
+		},
+	}
+}
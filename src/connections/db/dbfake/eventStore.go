@@ -0,0 +1,82 @@
+package dbfake
+
+import (
+	"cmp"
+	"com/connections/db"
+	"com/data"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/samborkent/uuidv7"
+)
+
+var _ db.EventStore = (*EventStore)(nil)
+
+type EventStore struct {
+	TimestampedDataStore[data.Event, data.StoreEvent, data.EventFilter]
+
+	deviceStates *DeviceStateStore
+	rollups      *RollupStore
+}
+
+// Add the event and update the device's state together.
+func (s *EventStore) Add(ctx context.Context, item data.Event) (string, error) {
+	id := uuidv7.New().String()
+	err := s.write(func(items items[data.StoreEvent]) error {
+		err := s.insert(items, newItem[data.StoreEvent](append([]any{id}, item.Spread()...)))
+		if err != nil {
+			return err
+		}
+		s.deviceStates.upsertFromEvent(id, item)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error adding event %v: %w", item, err)
+	}
+	return id, nil
+}
+
+func (s *EventStore) GetSeries(ctx context.Context, deviceID string, fieldName string, startTime int64, endTime int64) ([]data.SeriesPoint, data.Resolution, error) {
+	resolution := data.ResolutionForRange(startTime, endTime)
+	points := []data.SeriesPoint{}
+
+	// Rollups, including the bucket startTime falls in
+	if resolution != data.ResolutionRaw {
+		bucketStart := resolution.BucketStart(startTime)
+		rollups := s.rollups.GetInTimeRange(ctx, data.RollupFilter{DeviceID: &deviceID, FieldName: &fieldName, Resolution: &resolution}, &bucketStart, &endTime)
+		for rollup, err := range rollups.All(ctx) {
+			if err != nil {
+				return nil, resolution, fmt.Errorf("error getting next rollup: %w", err)
+			}
+			points = append(points, rollup.SeriesPoint())
+		}
+		slices.SortFunc(points, func(a, b data.SeriesPoint) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+		return points, resolution, nil
+	}
+
+	// Raw readings, counting repeated reports once
+	events := s.GetInTimeRange(ctx, data.EventFilter{EventSourceDeviceID: &deviceID, FieldName: &fieldName}, &startTime, &endTime)
+	seen := map[int64]bool{}
+	for event, err := range events.All(ctx) {
+		if err != nil {
+			return nil, resolution, fmt.Errorf("error getting next event: %w", err)
+		}
+		if event.NumericValue == nil || seen[event.EventTimestamp] {
+			continue
+		}
+		seen[event.EventTimestamp] = true
+		value := *event.NumericValue
+		points = append(points, data.SeriesPoint{
+			Timestamp: event.EventTimestamp,
+			Count:     1,
+			Min:       value,
+			Max:       value,
+			Mean:      value,
+			First:     value,
+			Last:      value,
+		})
+	}
+	slices.SortFunc(points, func(a, b data.SeriesPoint) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+	return points, resolution, nil
+}
@@ -0,0 +1,455 @@
+package dbfake
+
+import (
+	"cmp"
+	"com/data"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func (s *Store[T, S, F]) Find(ctx context.Context, query data.Query[F]) *data.IterablePaginatedData[S] {
+	paginator, err := s.FindFromCursor(ctx, query, "")
+	if err != nil {
+		return failedPaginatedData[S](err)
+	}
+	return paginator
+}
+func (s *Store[T, S, F]) FindFromCursor(ctx context.Context, query data.Query[F], cursor string) (*data.IterablePaginatedData[S], error) {
+	where := filterExpression(query.Filter)
+	if query.Where != nil {
+		where = append(where, query.Where)
+	}
+	for _, order := range query.OrderBy {
+		if !slices.Contains(s.columns, order.Column) {
+			return nil, fmt.Errorf("invalid order on %v: unknown column %v", s.tableName, order.Column)
+		}
+	}
+	return s.find(where, query.OrderBy, query.Limit, cursor)
+}
+
+// Paginate through items matching the expression, in the given order and then by ID, continuing after the cursor if any.
+// Positions are the ordered values and ID of an item, encoded as by the MySQL stores.
+func (s *Store[T, S, F]) find(where data.Expression, orderBy []data.Order, limit int, cursor string) (*data.IterablePaginatedData[S], error) {
+	matches, err := s.compile(where)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query on %v: %w", s.tableName, err)
+	}
+	orderIndexes := []int{}
+	for _, order := range orderBy {
+		orderIndexes = append(orderIndexes, slices.Index(s.columns, order.Column))
+	}
+
+	// The position of an item is its ordered values followed by its ID
+	positionValues := func(item S) []any {
+		values := item.Spread()
+		positionValues := []any{}
+		for _, index := range orderIndexes {
+			positionValues = append(positionValues, values[index])
+		}
+		return append(positionValues, item.GetID())
+	}
+	position := func(item S) string {
+		encoded, err := json.Marshal(positionValues(item))
+		if err != nil {
+			// Column values are strings, numbers, booleans or nil
+			panic(fmt.Errorf("error encoding position of %v in %v: %w", item.GetID(), s.tableName, err))
+		}
+		return string(encoded)
+	}
+	// Items come in order of their normalized position values. NULLs sort before every value, as in MySQL.
+	compare := func(a []any, b []any) int {
+		for i, order := range orderBy {
+			c := compareValues(a[i], b[i])
+			if order.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(a[len(orderBy)].(string), b[len(orderBy)].(string))
+	}
+
+	// Malformed cursors fail before any page is fetched
+	if cursor != "" {
+		decoded, err := data.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if decoded.Position != "" {
+			_, err = decodePosition(decoded.Position, len(orderBy))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Queries differing in anything but the page are different queries
+	fingerprint := sha256.Sum256(fmt.Appendf(nil, "%s|%v|%v|%d", s.tableName, where, orderBy, limit))
+
+	// Limits count from where the cursor resumes
+	returned := 0
+	paginator, err := data.ResumeIterablePaginatedData(
+		func(ctx context.Context, lastPosition *string) ([]S, *string, error) {
+			pageSize := data.PAGE_SIZE
+			if limit > 0 {
+				pageSize = min(pageSize, limit-returned)
+				if pageSize <= 0 {
+					return []S{}, nil, nil
+				}
+				returned += pageSize
+			}
+			var after []any
+			if lastPosition != nil {
+				decoded, err := decodePosition(*lastPosition, len(orderBy))
+				if err != nil {
+					return nil, nil, err
+				}
+				after = normalizeAll(decoded)
+			}
+
+			type row struct {
+				item     S
+				position []any
+			}
+			rows := []row{}
+			for _, item := range s.rows() {
+				if matches(normalizeAll(item.Spread())) != isTrue {
+					continue
+				}
+				position := normalizeAll(positionValues(item))
+				if after != nil && compare(position, after) <= 0 {
+					continue
+				}
+				rows = append(rows, row{item: item, position: position})
+			}
+			slices.SortFunc(rows, func(a, b row) int { return compare(a.position, b.position) })
+			if len(rows) == 0 {
+				return []S{}, nil, nil
+			}
+			items := []S{}
+			for _, row := range rows[:min(pageSize, len(rows))] {
+				items = append(items, row.item)
+			}
+			nextPosition := position(items[len(items)-1])
+			return items, &nextPosition, nil
+		},
+		hex.EncodeToString(fingerprint[:16]),
+		cursor,
+		position,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error resuming from cursor %v: %w", cursor, err)
+	}
+	return &paginator, nil
+}
+
+// The ordered values and ID of a position. Whole numbers are decoded as integers to compare exactly.
+func decodePosition(position string, orderedColumns int) ([]any, error) {
+	decoder := json.NewDecoder(strings.NewReader(position))
+	decoder.UseNumber()
+	var values []any
+	err := decoder.Decode(&values)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cursor position %v: %w", position, err)
+	}
+	if len(values) != orderedColumns+1 {
+		return nil, fmt.Errorf("cursor position %v has %d values, expected %d", position, len(values), orderedColumns+1)
+	}
+	if _, ok := values[orderedColumns].(string); !ok {
+		return nil, fmt.Errorf("cursor position %v does not end with an ID", position)
+	}
+	for i, value := range values {
+		number, ok := value.(json.Number)
+		if !ok {
+			continue
+		}
+		if integer, err := number.Int64(); err == nil {
+			values[i] = integer
+		} else if float, err := number.Float64(); err == nil {
+			values[i] = float
+		} else {
+			return nil, fmt.Errorf("cursor position %v has invalid number %v: %w", position, number, err)
+		}
+	}
+	return values, nil
+}
+
+// Whether a condition holds of a row, with SQL's three-valued logic: conditions on NULLs are unknown.
+type truth int
+
+// Ordered so that AND is the least and OR the greatest of their operands.
+const (
+	isFalse truth = iota
+	isUnknown
+	isTrue
+)
+
+func truthOf(holds bool) truth {
+	if holds {
+		return isTrue
+	}
+	return isFalse
+}
+
+// A condition on the normalized values of a row's columns.
+type condition func(values []any) truth
+
+// The condition of the expression. Errors on columns the table does not have.
+func (s *Store[T, S, F]) compile(expression data.Expression) (condition, error) {
+	column := func(name string) (int, error) {
+		index := slices.Index(s.columns, name)
+		if index < 0 {
+			return 0, fmt.Errorf("unknown column %v", name)
+		}
+		return index, nil
+	}
+
+	switch e := expression.(type) {
+	case data.Comparison:
+		index, err := column(e.Column)
+		if err != nil {
+			return nil, err
+		}
+		holds, ok := map[data.Operator]func(int) bool{
+			data.Equal:              func(c int) bool { return c == 0 },
+			data.NotEqual:           func(c int) bool { return c != 0 },
+			data.LessThan:           func(c int) bool { return c < 0 },
+			data.LessThanOrEqual:    func(c int) bool { return c <= 0 },
+			data.GreaterThan:        func(c int) bool { return c > 0 },
+			data.GreaterThanOrEqual: func(c int) bool { return c >= 0 },
+		}[e.Operator]
+		if !ok {
+			return nil, fmt.Errorf("unknown operator %v", e.Operator)
+		}
+		value := normalize(e.Value)
+		return func(values []any) truth {
+			if values[index] == nil || value == nil {
+				return isUnknown
+			}
+			return truthOf(holds(compareValues(values[index], value)))
+		}, nil
+	case data.In:
+		index, err := column(e.Column)
+		if err != nil {
+			return nil, err
+		}
+		candidates := normalizeAll(e.Values)
+		return func(values []any) truth {
+			if len(candidates) == 0 {
+				return isFalse
+			}
+			if values[index] == nil {
+				return isUnknown
+			}
+			result := isFalse
+			for _, candidate := range candidates {
+				if candidate == nil {
+					result = isUnknown
+				} else if compareValues(values[index], candidate) == 0 {
+					return isTrue
+				}
+			}
+			return result
+		}, nil
+	case data.Between:
+		bounds := data.And{}
+		if e.Min != nil {
+			bounds = append(bounds, data.Comparison{Column: e.Column, Operator: data.GreaterThanOrEqual, Value: e.Min})
+		}
+		if e.Max != nil {
+			bounds = append(bounds, data.Comparison{Column: e.Column, Operator: data.LessThanOrEqual, Value: e.Max})
+		}
+		_, err := column(e.Column)
+		if err != nil {
+			return nil, err
+		}
+		return s.compile(bounds)
+	case data.Like:
+		index, err := column(e.Column)
+		if err != nil {
+			return nil, err
+		}
+		pattern := likePattern(e.Pattern)
+		return func(values []any) truth {
+			if values[index] == nil {
+				return isUnknown
+			}
+			return truthOf(pattern.MatchString(text(values[index])))
+		}, nil
+	case data.Prefix:
+		index, err := column(e.Column)
+		if err != nil {
+			return nil, err
+		}
+		return func(values []any) truth {
+			if values[index] == nil {
+				return isUnknown
+			}
+			return truthOf(strings.HasPrefix(text(values[index]), e.Prefix))
+		}, nil
+	case data.IsNull:
+		index, err := column(e.Column)
+		if err != nil {
+			return nil, err
+		}
+		return func(values []any) truth {
+			return truthOf(values[index] == nil)
+		}, nil
+	case data.Not:
+		if e.Expression == nil {
+			return nil, fmt.Errorf("NOT without an expression")
+		}
+		inner, err := s.compile(e.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return func(values []any) truth {
+			return isTrue - inner(values)
+		}, nil
+	case data.And:
+		return s.compileJoined(e, isTrue, func(a, b truth) truth { return min(a, b) })
+	case data.Or:
+		return s.compileJoined(e, isFalse, func(a, b truth) truth { return max(a, b) })
+	default:
+		return nil, fmt.Errorf("unknown expression %T", expression)
+	}
+}
+
+// The expressions combined by the operator, or the empty value if there are none.
+func (s *Store[T, S, F]) compileJoined(expressions []data.Expression, empty truth, combine func(truth, truth) truth) (condition, error) {
+	conditions := []condition{}
+	for _, expression := range expressions {
+		condition, err := s.compile(expression)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return func(values []any) truth {
+		result := empty
+		for _, condition := range conditions {
+			result = combine(result, condition(values))
+		}
+		return result
+	}, nil
+}
+
+// A regular expression of a LIKE pattern, with % and _ as wildcards and backslashes escaping them.
+func likePattern(pattern string) *regexp.Regexp {
+	expression := strings.Builder{}
+	expression.WriteString("(?s)^")
+	isEscaped := false
+	for _, r := range pattern {
+		switch {
+		case isEscaped:
+			expression.WriteString(regexp.QuoteMeta(string(r)))
+			isEscaped = false
+		case r == '\\':
+			isEscaped = true
+		case r == '%':
+			expression.WriteString(".*")
+		case r == '_':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expression.WriteString("$")
+	return regexp.MustCompile(expression.String())
+}
+
+// The value as compared: nil for NULL, and otherwise a string, int64 or float64, with booleans as 1 or 0 as in MySQL.
+func normalize(value any) any {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		if v.Bool() {
+			return int64(1)
+		}
+		return int64(0)
+	}
+	return fmt.Sprint(v.Interface())
+}
+func normalizeAll(values []any) []any {
+	normalized := make([]any, len(values))
+	for i, value := range values {
+		normalized[i] = normalize(value)
+	}
+	return normalized
+}
+
+// Compare normalized values, with NULLs first. Strings compare exactly, unlike with MySQL's case-insensitive
+// collations, and are compared to numbers as numbers.
+func compareValues(a any, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	aString, aIsString := a.(string)
+	bString, bIsString := b.(string)
+	if aIsString && bIsString {
+		return strings.Compare(aString, bString)
+	}
+	aInteger, aIsInteger := a.(int64)
+	bInteger, bIsInteger := b.(int64)
+	if aIsInteger && bIsInteger {
+		return cmp.Compare(aInteger, bInteger)
+	}
+	return cmp.Compare(number(a), number(b))
+}
+
+// The normalized value as a number, 0 for strings that are not numbers.
+func number(value any) float64 {
+	switch v := value.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case string:
+		parsed, _ := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return parsed
+	}
+	return 0
+}
+
+// The normalized value as text, empty for NULL.
+func text(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package dbfake
+
+import (
+	"cmp"
+	"com/connections/db"
+	"com/data"
+	"com/utils"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/samborkent/uuidv7"
+)
+
+var _ db.RollupStore = (*RollupStore)(nil)
+
+type RollupStore struct {
+	TimestampedDataStore[data.Rollup, data.StoreRollup, data.RollupFilter]
+
+	eventsTableName string
+}
+
+// A distinct numeric reading of a device's field.
+type reading struct {
+	deviceID  string
+	fieldName string
+	timestamp int64
+	value     float64
+}
+
+type bucket struct {
+	deviceID  string
+	fieldName string
+	start     int64
+}
+
+// Rows affected count 1 per inserted rollup and 2 per changed one, as with MySQL's upserts.
+func (s *RollupStore) Recompute(ctx context.Context, resolution data.Resolution, startTime *int64, endTime *int64) (int64, error) {
+	bucketSeconds := resolution.Seconds()
+	if bucketSeconds == 0 {
+		return 0, fmt.Errorf("cannot roll up events at resolution %v", resolution)
+	}
+
+	rowsAffected := int64(0)
+	err := s.write(func(rollups items[data.StoreRollup]) error {
+		// Devices repeat their last report until they report again, so identical readings are only counted once
+		events, _ := s.database.tables[s.eventsTableName].(items[data.StoreEvent])
+		readings := map[reading]bool{}
+		for _, event := range events {
+			if event.NumericValue == nil ||
+				(startTime != nil && event.EventTimestamp < resolution.BucketStart(*startTime)) ||
+				(endTime != nil && event.EventTimestamp >= *endTime) {
+				continue
+			}
+			readings[reading{event.EventSourceDeviceID, event.FieldName, event.EventTimestamp, *event.NumericValue}] = true
+		}
+		buckets := map[bucket][]reading{}
+		for r := range readings {
+			key := bucket{r.deviceID, r.fieldName, resolution.BucketStart(r.timestamp)}
+			buckets[key] = append(buckets[key], r)
+		}
+
+		existing := map[bucket]data.StoreRollup{}
+		for _, rollup := range rollups {
+			if rollup.Resolution == resolution {
+				existing[bucket{rollup.DeviceID, rollup.FieldName, rollup.BucketStart}] = rollup
+			}
+		}
+		now := utils.TimeSeconds()
+		for key, readings := range buckets {
+			// First and last values are the ends of the bucket's readings ordered by time
+			slices.SortFunc(readings, func(a, b reading) int {
+				return cmp.Or(cmp.Compare(a.timestamp, b.timestamp), cmp.Compare(a.value, b.value))
+			})
+			rollup := data.Rollup{
+				DeviceID: key.deviceID, FieldName: key.fieldName, Resolution: resolution, BucketStart: key.start,
+				Count: int64(len(readings)), Min: readings[0].value, Max: readings[0].value,
+				First: readings[0].value, Last: readings[len(readings)-1].value, Timestamp: now,
+			}
+			sum := 0.0
+			for _, r := range readings {
+				rollup.Min = min(rollup.Min, r.value)
+				rollup.Max = max(rollup.Max, r.value)
+				sum += r.value
+			}
+			rollup.Mean = sum / float64(len(readings))
+
+			stored, ok := existing[key]
+			switch {
+			case !ok:
+				id := uuidv7.New().String()
+				rollups[id] = data.StoreRollup{HasID: data.HasID{ID: id}, Rollup: rollup}
+				rowsAffected++
+			case stored.Rollup != rollup:
+				rollups[stored.ID] = data.StoreRollup{HasID: stored.HasID, Rollup: rollup}
+				rowsAffected += 2
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error recomputing %v rollups from %v to %v: %w", resolution, startTime, endTime, err)
+	}
+	return rowsAffected, nil
+}
@@ -0,0 +1,415 @@
+package dbfake
+
+import (
+	"cmp"
+	"com/data"
+	"com/utils"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/samborkent/uuidv7"
+)
+
+// Generic in-memory store, keeping each item by ID in a table of its database.
+type Store[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F data.Spreadable] struct {
+	database  *Database
+	tableName string
+	columns   []string
+	// Columns of the table's unique index besides its ID, if it has one.
+	uniqueKey []string
+}
+
+func newStore[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F data.Spreadable](database *Database, tableName string, uniqueKey ...string) Store[T, S, F] {
+	return Store[T, S, F]{database: database, tableName: tableName, columns: data.Columns[S](), uniqueKey: uniqueKey}
+}
+
+// The store's items. The database's mutex must be held.
+func (s *Store[T, S, F]) items() items[S] {
+	table, ok := s.database.tables[s.tableName].(items[S])
+	if !ok {
+		table = items[S]{}
+		s.database.tables[s.tableName] = table
+	}
+	return table
+}
+
+// Run fn on the store's items while holding the database's mutex. Errors within read-only transactions.
+func (s *Store[T, S, F]) write(fn func(items items[S]) error) error {
+	s.database.mutex.Lock()
+	defer s.database.mutex.Unlock()
+	if s.database.isReadOnly {
+		return fmt.Errorf("cannot write to %v within a read-only transaction", s.tableName)
+	}
+	return fn(s.items())
+}
+
+// Copies of the store's items, in no particular order.
+func (s *Store[T, S, F]) rows() []S {
+	s.database.mutex.Lock()
+	defer s.database.mutex.Unlock()
+	rows := make([]S, 0, len(s.items()))
+	for item := range maps.Values(s.items()) {
+		rows = append(rows, newItem[S](item.Spread()))
+	}
+	return rows
+}
+
+// An item with the values of every column, ID first. Pointers are copied, so the item shares no memory with the values.
+func newItem[S data.HasIDGetterAndSpreadable[S]](values []any) S {
+	var empty S
+	item, addresses := empty.SpreadAddresses()
+	for i, address := range addresses {
+		value := reflect.ValueOf(values[i])
+		if value.Kind() == reflect.Pointer && !value.IsNil() {
+			copied := reflect.New(value.Type().Elem())
+			copied.Elem().Set(value.Elem())
+			value = copied
+		}
+		if value.IsValid() {
+			reflect.ValueOf(address).Elem().Set(value)
+		}
+	}
+	return *item
+}
+
+func (s *Store[T, S, F]) Add(ctx context.Context, item T) (string, error) {
+	id := uuidv7.New().String()
+	err := s.write(func(items items[S]) error {
+		return s.insert(items, newItem[S](append([]any{id}, item.Spread()...)))
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+func (s *Store[T, S, F]) Restore(ctx context.Context, storeItem S) error {
+	return s.write(func(items items[S]) error {
+		return s.insert(items, newItem[S](storeItem.Spread()))
+	})
+}
+
+// Insert the item, unless its ID or unique key is taken. The database's mutex must be held.
+func (s *Store[T, S, F]) insert(items items[S], item S) error {
+	if _, ok := items[item.GetID()]; ok {
+		return fmt.Errorf("error inserting into %s: duplicate id %v", s.tableName, item.GetID())
+	}
+	err := s.checkUniqueKey(items, item)
+	if err != nil {
+		return fmt.Errorf("error inserting into %s: %w", s.tableName, err)
+	}
+	items[item.GetID()] = item
+	return nil
+}
+
+// Errors if another item has the same unique key as the item.
+func (s *Store[T, S, F]) checkUniqueKey(items items[S], item S) error {
+	if len(s.uniqueKey) == 0 {
+		return nil
+	}
+	key := s.uniqueKeyOf(item)
+	for id, other := range items {
+		if id != item.GetID() && slices.Equal(key, s.uniqueKeyOf(other)) {
+			return fmt.Errorf("duplicate entry %v for unique key %v", key, s.uniqueKey)
+		}
+	}
+	return nil
+}
+func (s *Store[T, S, F]) uniqueKeyOf(item S) []any {
+	values := item.Spread()
+	key := []any{}
+	for _, column := range s.uniqueKey {
+		key = append(key, normalize(values[slices.Index(s.columns, column)]))
+	}
+	return key
+}
+func (s *Store[T, S, F]) Get(ctx context.Context, filter F) *data.IterablePaginatedData[S] {
+	return s.Find(ctx, data.Query[F]{Filter: filter})
+}
+func (s *Store[T, S, F]) Delete(ctx context.Context, storeItem S) error {
+	return s.write(func(items items[S]) error {
+		if _, ok := items[storeItem.GetID()]; !ok {
+			return fmt.Errorf("no rows deleted for id %v in table %s", storeItem.GetID(), s.tableName)
+		}
+		delete(items, storeItem.GetID())
+		return nil
+	})
+}
+func (s *Store[T, S, F]) Setup(ctx context.Context, isDestructive bool) error {
+	s.database.mutex.Lock()
+	defer s.database.mutex.Unlock()
+	if isDestructive {
+		delete(s.database.tables, s.tableName)
+	}
+	s.items()
+	return nil
+}
+
+// Conditions matching the filter's non-nil values, in the order of its columns.
+func filterExpression[F data.Spreadable](filter F) data.And {
+	where := data.And{}
+	filterValues := filter.Spread()
+	for index, columnName := range data.Columns[F]() {
+		filterInterface := filterValues[index]
+		filterValue := reflect.ValueOf(filterInterface)
+		if filterValue.IsNil() {
+			continue
+		}
+		if rangeValue, ok := filterInterface.(data.RangeFilterValue); ok {
+			lower, upper := rangeValue.Bounds()
+			where = append(where, data.Between{Column: columnName, Min: lower, Max: upper})
+			continue
+		}
+		where = append(where, data.Eq(columnName, filterValue.Elem().Interface()))
+	}
+	return where
+}
+
+type TimestampedDataStore[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F data.Spreadable] struct {
+	Store[T, S, F]
+
+	timestampKey string
+	// Numeric column aggregates are computed over. Without one, only counts are supported.
+	valueKey string
+	// Columns holding each group aggregates can be grouped by.
+	groupKeys map[data.AggregateGroup]string
+	// For stores whose rows each summarize several values, such as rollups. Nil when rows are single values.
+	summaryKeys *summaryKeys
+}
+
+// Columns of a row summarizing several values. Aggregates combine the summaries rather than their means alone, and
+// valueKey is the mean.
+type summaryKeys struct {
+	min   string
+	max   string
+	count string
+}
+
+func (s *TimestampedDataStore[T, S, F]) GetInTimeRange(ctx context.Context, filter F, startTime *int64, endTime *int64) *data.IterablePaginatedData[S] {
+	paginator, err := s.find(s.timeRangeExpression(filter, startTime, endTime), nil, 0, "")
+	if err != nil {
+		return failedPaginatedData[S](err)
+	}
+	return paginator
+}
+
+// Conditions matching the filter's non-nil values and the time range.
+func (s *TimestampedDataStore[T, S, F]) timeRangeExpression(filter F, startTime *int64, endTime *int64) data.And {
+	where := filterExpression(filter)
+	if startTime != nil {
+		where = append(where, data.Comparison{Column: s.timestampKey, Operator: data.GreaterThanOrEqual, Value: *startTime})
+	}
+	if endTime != nil {
+		where = append(where, data.Comparison{Column: s.timestampKey, Operator: data.LessThan, Value: *endTime})
+	}
+	return where
+}
+
+// A value or summary of values to aggregate.
+type summary struct {
+	value float64
+	min   float64
+	max   float64
+	count int64
+}
+
+type aggregateGroup struct {
+	deviceID    string
+	fieldName   string
+	bucketStart int64
+}
+
+func (s *TimestampedDataStore[T, S, F]) Aggregate(ctx context.Context, filter F, startTime *int64, endTime *int64, query data.AggregateQuery) ([]data.AggregateResult, error) {
+	err := query.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid aggregate query %v: %w", query, err)
+	}
+	if s.valueKey == "" && query.Function != data.AggregateCount {
+		return nil, fmt.Errorf("table %v has no numeric value, so only supports counts", s.tableName)
+	}
+	groupIndexes := map[data.AggregateGroup]int{}
+	for _, group := range query.GroupBy {
+		column, ok := s.groupKeys[group]
+		if !ok {
+			return nil, fmt.Errorf("table %v cannot be grouped by %v", s.tableName, group)
+		}
+		groupIndexes[group] = slices.Index(s.columns, column)
+	}
+	matches, err := s.compile(s.timeRangeExpression(filter, startTime, endTime))
+	if err != nil {
+		return nil, fmt.Errorf("invalid aggregate query on %v: %w", s.tableName, err)
+	}
+
+	// Rows to aggregate, reduced to their group, bucket and value
+	groups := map[aggregateGroup][]summary{}
+	for _, item := range s.rows() {
+		values := normalizeAll(item.Spread())
+		if matches(values) != isTrue {
+			continue
+		}
+		row := summary{value: 1, min: 1, max: 1, count: 1}
+		if s.valueKey != "" {
+			value := values[slices.Index(s.columns, s.valueKey)]
+			if value == nil && query.Function != data.AggregateCount {
+				continue
+			}
+			row = summary{value: number(value), min: number(value), max: number(value), count: 1}
+		}
+		if s.summaryKeys != nil {
+			row.min = number(values[slices.Index(s.columns, s.summaryKeys.min)])
+			row.max = number(values[slices.Index(s.columns, s.summaryKeys.max)])
+			row.count = int64(number(values[slices.Index(s.columns, s.summaryKeys.count)]))
+		}
+		group := aggregateGroup{}
+		if index, ok := groupIndexes[data.GroupByDevice]; ok {
+			group.deviceID = text(values[index])
+		}
+		if index, ok := groupIndexes[data.GroupByField]; ok {
+			group.fieldName = text(values[index])
+		}
+		if query.BucketSeconds > 0 {
+			timestamp := int64(number(values[slices.Index(s.columns, s.timestampKey)]))
+			group.bucketStart = timestamp - timestamp%query.BucketSeconds
+		}
+		groups[group] = append(groups[group], row)
+	}
+
+	results := []data.AggregateResult{}
+	for group, rows := range groups {
+		results = append(results, aggregate(group, rows, query))
+	}
+	slices.SortFunc(results, func(a, b data.AggregateResult) int {
+		return cmp.Or(strings.Compare(a.DeviceID, b.DeviceID), strings.Compare(a.FieldName, b.FieldName), cmp.Compare(a.BucketStart, b.BucketStart))
+	})
+	return results, nil
+}
+
+// The group's aggregate, combining summaries with means weighted by their counts. Percentiles are the lowest value
+// whose rank reaches the percentile, ranking summaries by their means.
+func aggregate(group aggregateGroup, rows []summary, query data.AggregateQuery) data.AggregateResult {
+	result := data.AggregateResult{DeviceID: group.deviceID, FieldName: group.fieldName, BucketStart: group.bucketStart}
+	if query.Function == data.AggregatePercentile {
+		values := []float64{}
+		for _, row := range rows {
+			values = append(values, row.value)
+		}
+		slices.Sort(values)
+		position := max(int(math.Ceil(query.Percentile*float64(len(values)))), 1)
+		result.Value = values[position-1]
+		result.Count = int64(len(values))
+		return result
+	}
+	weighted := 0.0
+	result.Value = rows[0].min
+	if query.Function == data.AggregateMax {
+		result.Value = rows[0].max
+	}
+	for _, row := range rows {
+		weighted += row.value * float64(row.count)
+		result.Count += row.count
+		switch query.Function {
+		case data.AggregateMin:
+			result.Value = min(result.Value, row.min)
+		case data.AggregateMax:
+			result.Value = max(result.Value, row.max)
+		}
+	}
+	switch query.Function {
+	case data.AggregateSum:
+		result.Value = weighted
+	case data.AggregateAvg:
+		result.Value = weighted / float64(result.Count)
+	case data.AggregateCount:
+		result.Value = float64(result.Count)
+	}
+	return result
+}
+
+// Deletes the items matching first in order of their IDs.
+func (s *TimestampedDataStore[T, S, F]) DeleteBefore(ctx context.Context, filter F, where data.Expression, endTime int64, limit int) (int64, error) {
+	conditions := filterExpression(filter)
+	if where != nil {
+		conditions = append(conditions, where)
+	}
+	conditions = append(conditions, data.Comparison{Column: s.timestampKey, Operator: data.LessThan, Value: endTime})
+	matches, err := s.compile(conditions)
+	if err != nil {
+		return 0, fmt.Errorf("invalid deletion from %v: %w", s.tableName, err)
+	}
+	var deleted int64
+	err = s.write(func(items items[S]) error {
+		for _, id := range slices.Sorted(maps.Keys(items)) {
+			if deleted >= int64(limit) {
+				break
+			}
+			if matches(normalizeAll(items[id].Spread())) == isTrue {
+				delete(items, id)
+				deleted++
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+type EditableStore[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F data.Spreadable] struct {
+	Store[T, S, F]
+}
+
+func newEditableStore[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F data.Spreadable](database *Database, tableName string, uniqueKey ...string) *EditableStore[T, S, F] {
+	return &EditableStore[T, S, F]{Store: newStore[T, S, F](database, tableName, uniqueKey...)}
+}
+
+func (s *EditableStore[T, S, F]) Edit(ctx context.Context, storeItem S) error {
+	return s.write(func(items items[S]) error {
+		if _, ok := items[storeItem.GetID()]; !ok {
+			return fmt.Errorf("no rows found while editing item %v in table %v", storeItem, s.tableName)
+		}
+		item := newItem[S](storeItem.Spread())
+		err := s.checkUniqueKey(items, item)
+		if err != nil {
+			return fmt.Errorf("error editing item %v in table %v: %w", storeItem, s.tableName, err)
+		}
+		items[item.GetID()] = item
+		return nil
+	})
+}
+
+type ClosableStore[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F data.Spreadable] struct {
+	TimestampedDataStore[T, S, F]
+
+	closeKey string
+}
+
+func (s *ClosableStore[T, S, F]) Close(ctx context.Context, storeItem S) error {
+	return s.write(func(items items[S]) error {
+		stored, ok := items[storeItem.GetID()]
+		if !ok {
+			return fmt.Errorf("no rows found while closing item %v in table %v", storeItem, s.tableName)
+		}
+		item, addresses := stored.SpreadAddresses()
+		field := reflect.ValueOf(addresses[slices.Index(s.columns, s.closeKey)]).Elem()
+		now := reflect.ValueOf(utils.TimeSeconds())
+		if field.Kind() == reflect.Pointer {
+			closed := reflect.New(field.Type().Elem())
+			closed.Elem().Set(now.Convert(field.Type().Elem()))
+			field.Set(closed)
+		} else {
+			field.Set(now.Convert(field.Type()))
+		}
+		items[storeItem.GetID()] = *item
+		return nil
+	})
+}
+
+// Paginated data whose first page fails with the error, for queries that are invalid before they run.
+func failedPaginatedData[T data.HasIDGetterAndSpreadable[T]](err error) *data.IterablePaginatedData[T] {
+	paginator := data.NewIterablePaginatedData(func(ctx context.Context, lastID *string) ([]T, *string, error) {
+		return nil, nil, err
+	}, "")
+	return &paginator
+}
@@ -0,0 +1,873 @@
+package dbtest
+
+import (
+	"cmp"
+	"com/connections/db"
+	"com/data"
+	"com/exports"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/samborkent/uuidv7"
+)
+
+const timestamp int64 = 1700000000
+
+func checkAddAndFilters(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	// Events must come from a stored device
+	deviceID, err := connection.Devices().Add(ctx, data.Device{BrandID: "event-device", Timestamp: timestamp})
+	if err != nil {
+		return fmt.Errorf("error adding device: %w", err)
+	}
+	checks := map[string]func() error{
+		"devices": func() error {
+			return checkStoreFilters(ctx, connection.Devices(), data.Device{
+				BrandID: "brand-device", Brand: "conformance", Kind: "THSensor", Name: "Conformance device", Token: "token",
+				Timestamp: timestamp, Status: data.DeviceOnline, LastEventTimestamp: timestamp + 1,
+			})
+		},
+		"events": func() error {
+			return checkStoreFilters(ctx, connection.Events(), data.Event{
+				RequestDeviceID: deviceID, EventSourceDeviceID: deviceID,
+				ResponseTimestamp: timestamp + 1, EventTimestamp: timestamp, FieldName: "state.temperature",
+			}.WithValue(json.Number("21.5")))
+		},
+		"boolean events": func() error {
+			return checkStoreFilters(ctx, connection.Events(), data.Event{
+				RequestDeviceID: deviceID, EventSourceDeviceID: deviceID,
+				ResponseTimestamp: timestamp + 1, EventTimestamp: timestamp, FieldName: "online",
+			}.WithValue(true))
+		},
+		"logs": func() error {
+			return checkStoreFilters(ctx, connection.Logs(), data.Log{
+				JobID: "job", Level: 2, StackTrace: "trace", Description: "description", Timestamp: timestamp,
+			})
+		},
+		"jobs": func() error {
+			return checkStoreFilters(ctx, connection.Jobs(), data.Job{
+				ParentID: "parent", Category: "MAIN", StartTimestamp: timestamp, EndTimestamp: timestamp + 1,
+			})
+		},
+		"alert states": func() error {
+			return checkStoreFilters(ctx, connection.AlertStates(), data.AlertState{
+				RuleName: "rule", DeviceID: "device", Status: data.AlertFiring, LastValue: "30",
+				ConditionSince: timestamp, FiredTimestamp: timestamp + 1, ResolvedTimestamp: timestamp + 2, Timestamp: timestamp + 3,
+			})
+		},
+		"deliveries": func() error {
+			return checkStoreFilters(ctx, connection.Deliveries(), data.Delivery{
+				JobID: "job", Channel: "channel", Title: "title", Attempt: 1, Status: data.DeliveryFailed, Error: "error", Timestamp: timestamp,
+			})
+		},
+		"battery readings": func() error {
+			return checkStoreFilters(ctx, connection.Batteries(), data.BatteryReading{
+				DeviceID: "device", EventID: "event", Level: 75, RawValue: "3", Timestamp: timestamp,
+			})
+		},
+		"device states": func() error {
+			return checkStoreFilters(ctx, connection.DeviceStates(), data.DeviceState{
+				DeviceID: "state-device", FieldName: "state.humidity", FieldValue: "40", EventID: "event", EventTimestamp: timestamp,
+			})
+		},
+		"export marks": func() error {
+			return checkStoreFilters(ctx, connection.ExportMarks(), data.ExportMark{
+				ExportName: "export", LastEventID: "event", LastEventTimestamp: timestamp, ExportedCount: 10, Timestamp: timestamp + 1,
+			})
+		},
+		"rollups": func() error {
+			return checkStoreFilters(ctx, connection.Rollups(), data.Rollup{
+				DeviceID: "device", FieldName: "state.temperature", Resolution: data.ResolutionHour, BucketStart: timestamp,
+				Count: 4, Min: 1.5, Max: 4.5, Mean: 3, First: 2, Last: 4, Timestamp: timestamp + 1,
+			})
+		},
+	}
+	errs := []error{}
+	for name, check := range checks {
+		err := check()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Items spanning several pages are each returned once, in order, including with ordering and limits.
+func checkPagination(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	count := 2*data.PAGE_SIZE + 1
+	jobID := "pagination"
+	for i := range count {
+		_, err := connection.Logs().Add(ctx, data.Log{JobID: jobID, Description: fmt.Sprint(i), Timestamp: timestamp + int64(i%7)})
+		if err != nil {
+			return fmt.Errorf("error adding log %v: %w", i, err)
+		}
+	}
+
+	ids, err := collectIDs(ctx, connection.Logs().Get(ctx, data.LogFilter{JobID: &jobID}))
+	if err != nil {
+		return err
+	}
+	if len(ids) != count || !slices.IsSorted(ids) || len(slices.Compact(slices.Clone(ids))) != count {
+		return fmt.Errorf("expected %v distinct logs in ID order, got %v", count, ids)
+	}
+
+	limit := data.PAGE_SIZE + 1
+	logs, err := data.Collect(connection.Logs().Find(ctx, data.Query[data.LogFilter]{
+		Filter:  data.LogFilter{JobID: &jobID},
+		OrderBy: []data.Order{{Column: "log_timestamp", Descending: true}},
+		Limit:   limit,
+	}).All(ctx))
+	if err != nil {
+		return err
+	}
+	if len(logs) != limit {
+		return fmt.Errorf("expected %v logs with a limit of %v, got %v", limit, limit, len(logs))
+	}
+	for i := 1; i < len(logs); i++ {
+		previous, current := logs[i-1], logs[i]
+		if previous.Timestamp < current.Timestamp || (previous.Timestamp == current.Timestamp && previous.ID >= current.ID) {
+			return fmt.Errorf("logs out of order at %v: %v then %v", i, previous, current)
+		}
+	}
+	return nil
+}
+
+// Ordering by a nullable column pages through NULLs, and cursors resume after their last item even once it is deleted.
+func checkNullablePagination(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	deviceID, err := connection.Devices().Add(ctx, data.Device{BrandID: "nullable-pagination", Timestamp: timestamp})
+	if err != nil {
+		return fmt.Errorf("error adding device: %w", err)
+	}
+	// Every third value is a string, leaving numeric_value NULL across page boundaries
+	count := 2*data.PAGE_SIZE + 1
+	for i := range count {
+		var value any = json.Number(fmt.Sprint(i % 5))
+		if i%3 == 0 {
+			value = "text"
+		}
+		_, err := connection.Events().Add(ctx, data.Event{EventSourceDeviceID: deviceID, EventTimestamp: timestamp, FieldName: "field"}.WithValue(value))
+		if err != nil {
+			return fmt.Errorf("error adding event %v: %w", i, err)
+		}
+	}
+
+	for _, descending := range []bool{false, true} {
+		query := data.Query[data.EventFilter]{
+			Filter:  data.EventFilter{EventSourceDeviceID: &deviceID},
+			OrderBy: []data.Order{{Column: "numeric_value", Descending: descending}},
+		}
+		events, err := data.Collect(connection.Events().Find(ctx, query).All(ctx))
+		if err != nil {
+			return err
+		}
+		err = checkNumericOrder(events, count, descending)
+		if err != nil {
+			return fmt.Errorf("descending %v: %w", descending, err)
+		}
+
+		// Resume after an item that was deleted in the meantime
+		paginator := connection.Events().Find(ctx, query)
+		firstPage, err := data.Collect(data.Take(paginator.All(ctx), data.PAGE_SIZE))
+		if err != nil {
+			return err
+		}
+		err = connection.Events().Delete(ctx, firstPage[len(firstPage)-1])
+		if err != nil {
+			return fmt.Errorf("error deleting event: %w", err)
+		}
+		resumed, err := connection.Events().FindFromCursor(ctx, query, paginator.Cursor())
+		if err != nil {
+			return fmt.Errorf("error resuming: %w", err)
+		}
+		rest, err := data.Collect(resumed.All(ctx))
+		if err != nil {
+			return err
+		}
+		err = checkNumericOrder(append(firstPage, rest...), count, descending)
+		if err != nil {
+			return fmt.Errorf("descending %v, resumed after a deleted event: %w", descending, err)
+		}
+		count--
+	}
+	return nil
+}
+
+// Events are distinct and ordered by numeric value with NULLs first when ascending, and then by ID.
+func checkNumericOrder(events []data.StoreEvent, count int, descending bool) error {
+	if len(events) != count {
+		return fmt.Errorf("expected %v events, got %v", count, len(events))
+	}
+	// NULLs sort lowest
+	key := func(event data.StoreEvent) float64 {
+		if event.NumericValue == nil {
+			return -1
+		}
+		return *event.NumericValue
+	}
+	for i := 1; i < len(events); i++ {
+		previous, current := key(events[i-1]), key(events[i])
+		if descending {
+			previous, current = current, previous
+		}
+		if previous > current || (previous == current && events[i-1].ID >= events[i].ID) {
+			return fmt.Errorf("events out of order at %v: %v then %v", i, events[i-1], events[i])
+		}
+	}
+	return nil
+}
+
+func checkDelete(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	id, err := connection.Logs().Add(ctx, data.Log{JobID: "delete", Timestamp: timestamp})
+	if err != nil {
+		return fmt.Errorf("error adding log: %w", err)
+	}
+	log, err := getByID(ctx, connection.Logs(), id)
+	if err != nil {
+		return err
+	}
+	err = connection.Logs().Delete(ctx, *log)
+	if err != nil {
+		return fmt.Errorf("error deleting log %v: %w", id, err)
+	}
+	ids, err := collectIDs(ctx, connection.Logs().Get(ctx, data.LogFilter{ID: &id}))
+	if err != nil {
+		return err
+	}
+	if len(ids) != 0 {
+		return fmt.Errorf("log %v remains after deletion", id)
+	}
+	err = connection.Logs().Delete(ctx, *log)
+	if err == nil {
+		return fmt.Errorf("deleting log %v twice did not fail", id)
+	}
+	return nil
+}
+
+// Restored items keep their IDs and values, without the side effects of adding them. Restoring an ID twice fails.
+func checkRestore(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	device := data.StoreDevice{HasID: data.HasID{ID: "restored-device"}, Device: data.Device{BrandID: "restore", Name: "Restored", Timestamp: timestamp}}
+	event := data.StoreEvent{HasID: data.HasID{ID: "restored-event"}, Event: data.Event{
+		RequestDeviceID: device.ID, EventSourceDeviceID: device.ID, EventTimestamp: timestamp, FieldName: "state.temperature",
+	}.WithValue(json.Number("20"))}
+	err = connection.Devices().Restore(ctx, device)
+	if err != nil {
+		return fmt.Errorf("error restoring device: %w", err)
+	}
+	err = connection.Events().Restore(ctx, event)
+	if err != nil {
+		return fmt.Errorf("error restoring event: %w", err)
+	}
+	storedDevice, err := getByID(ctx, connection.Devices(), device.ID)
+	if err != nil {
+		return err
+	}
+	storedEvent, err := getByID(ctx, connection.Events(), event.ID)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(*storedDevice, device) || !reflect.DeepEqual(*storedEvent, event) {
+		return fmt.Errorf("restored %v and %v but read back %v and %v", device, event, *storedDevice, *storedEvent)
+	}
+	states, err := collectIDs(ctx, connection.DeviceStates().Get(ctx, data.DeviceStateFilter{DeviceID: &device.ID}))
+	if err != nil {
+		return err
+	}
+	if len(states) != 0 {
+		return fmt.Errorf("restoring an event updated device states %v", states)
+	}
+	err = connection.Devices().Restore(ctx, device)
+	if err == nil {
+		return fmt.Errorf("restoring device %v twice did not fail", device.ID)
+	}
+	return nil
+}
+
+// Transactions commit everything written within them, or nothing when they fail, including the device states of events.
+// Read-only transactions keep seeing the stores as they were at their first read.
+func checkTransaction(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	errFailed := errors.New("failed on purpose")
+	var deviceID string
+	err = connection.WithTransaction(ctx, false, func(tx db.DBConnection) error {
+		deviceID, err = tx.Devices().Add(ctx, data.Device{BrandID: "rolled-back", Timestamp: timestamp})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Events().Add(ctx, data.Event{EventSourceDeviceID: deviceID, EventTimestamp: timestamp, FieldName: "state"}.WithValue("normal"))
+		if err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		return fmt.Errorf("expected the transaction's error, got %v", err)
+	}
+	devices, err := collectIDs(ctx, connection.Devices().Get(ctx, data.DeviceFilter{}))
+	if err != nil {
+		return err
+	}
+	states, err := collectIDs(ctx, connection.DeviceStates().Get(ctx, data.DeviceStateFilter{}))
+	if err != nil {
+		return err
+	}
+	if len(devices) != 0 || len(states) != 0 {
+		return fmt.Errorf("a failed transaction left devices %v and device states %v", devices, states)
+	}
+
+	err = connection.WithTransaction(ctx, false, func(tx db.DBConnection) error {
+		deviceID, err = tx.Devices().Add(ctx, data.Device{BrandID: "committed", Timestamp: timestamp})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	_, err = getByID(ctx, connection.Devices(), deviceID)
+	if err != nil {
+		return fmt.Errorf("committed device: %w", err)
+	}
+
+	return connection.WithTransaction(ctx, true, func(snapshot db.DBConnection) error {
+		before, err := collectIDs(ctx, snapshot.Devices().Get(ctx, data.DeviceFilter{}))
+		if err != nil {
+			return err
+		}
+		_, err = connection.Devices().Add(ctx, data.Device{BrandID: "after snapshot", Timestamp: timestamp})
+		if err != nil {
+			return fmt.Errorf("error adding device outside of the snapshot: %w", err)
+		}
+		after, err := collectIDs(ctx, snapshot.Devices().Get(ctx, data.DeviceFilter{}))
+		if err != nil {
+			return err
+		}
+		if !slices.Equal(before, after) {
+			return fmt.Errorf("a read-only transaction saw devices %v, then %v", before, after)
+		}
+		return nil
+	})
+}
+
+func checkEdit(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	id, err := connection.Devices().Add(ctx, data.Device{BrandID: "edit", Name: "before", Timestamp: timestamp})
+	if err != nil {
+		return fmt.Errorf("error adding device: %w", err)
+	}
+	device, err := getByID(ctx, connection.Devices(), id)
+	if err != nil {
+		return err
+	}
+	device.Name = "after"
+	device.Status = data.DeviceStale
+	err = connection.Devices().Edit(ctx, *device)
+	if err != nil {
+		return fmt.Errorf("error editing device %v: %w", id, err)
+	}
+	edited, err := getByID(ctx, connection.Devices(), id)
+	if err != nil {
+		return err
+	}
+	if *edited != *device {
+		return fmt.Errorf("edited device to %v but read back %v", device, edited)
+	}
+
+	missing := *device
+	missing.ID = "missing"
+	err = connection.Devices().Edit(ctx, missing)
+	if err == nil {
+		return errors.New("editing a missing device did not fail")
+	}
+	return nil
+}
+
+func checkClose(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	id, err := connection.Jobs().Add(ctx, data.Job{Category: "MAIN", StartTimestamp: timestamp})
+	if err != nil {
+		return fmt.Errorf("error adding job: %w", err)
+	}
+	job, err := getByID(ctx, connection.Jobs(), id)
+	if err != nil {
+		return err
+	}
+	err = connection.Jobs().Close(ctx, *job)
+	if err != nil {
+		return fmt.Errorf("error closing job %v: %w", id, err)
+	}
+	closed, err := getByID(ctx, connection.Jobs(), id)
+	if err != nil {
+		return err
+	}
+	if closed.EndTimestamp < closed.StartTimestamp {
+		return fmt.Errorf("closed job %v has end %v before its start %v", id, closed.EndTimestamp, closed.StartTimestamp)
+	}
+	return nil
+}
+
+// Start times are inclusive, end times exclusive, and nil times leave the range open.
+func checkTimeRange(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	deviceID, err := connection.Devices().Add(ctx, data.Device{BrandID: "time-range", Timestamp: timestamp})
+	if err != nil {
+		return fmt.Errorf("error adding device: %w", err)
+	}
+	filter := data.EventFilter{EventSourceDeviceID: &deviceID}
+	for _, offset := range []int64{0, 100, 200} {
+		_, err := connection.Events().Add(ctx, data.Event{EventSourceDeviceID: deviceID, EventTimestamp: timestamp + offset, FieldName: "field"}.WithValue("value"))
+		if err != nil {
+			return fmt.Errorf("error adding event: %w", err)
+		}
+	}
+
+	at := func(offset int64) *int64 {
+		t := timestamp + offset
+		return &t
+	}
+	ranges := []struct {
+		start    *int64
+		end      *int64
+		expected []int64
+	}{
+		{at(100), at(200), []int64{100}},
+		{nil, at(100), []int64{0}},
+		{at(100), nil, []int64{100, 200}},
+		{nil, nil, []int64{0, 100, 200}},
+		{at(101), at(200), []int64{}},
+	}
+	for _, r := range ranges {
+		events, err := data.Collect(connection.Events().GetInTimeRange(ctx, filter, r.start, r.end).All(ctx))
+		if err != nil {
+			return err
+		}
+		offsets := []int64{}
+		for _, event := range events {
+			offsets = append(offsets, event.EventTimestamp-timestamp)
+		}
+		slices.Sort(offsets)
+		if !slices.Equal(offsets, r.expected) {
+			return fmt.Errorf("range %v to %v: expected offsets %v, got %v", r.start, r.end, r.expected, offsets)
+		}
+	}
+	return nil
+}
+
+// Non-destructive setup keeps existing data, and destructive setup removes it.
+func checkSetup(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	id, err := connection.Devices().Add(ctx, data.Device{BrandID: "setup", Timestamp: timestamp})
+	closeConnection()
+	if err != nil {
+		return fmt.Errorf("error adding device: %w", err)
+	}
+
+	for _, isDestructive := range []bool{false, true} {
+		connection, err := connect(ctx, isDestructive)
+		if err != nil {
+			return fmt.Errorf("error connecting with destructive setup %v: %w", isDestructive, err)
+		}
+		ids, err := collectIDs(ctx, connection.Devices().Get(ctx, data.DeviceFilter{ID: &id}))
+		_ = connection.Close()
+		if err != nil {
+			return err
+		}
+		if isDestructive == (len(ids) != 0) {
+			return fmt.Errorf("after setup with destructive %v, found devices %v", isDestructive, ids)
+		}
+	}
+	return nil
+}
+
+// CSV exports have a header of the table's columns and a row per item. Other formats write a file.
+// Failed exports leave no file behind.
+func checkExport(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	jobID := "export"
+	ids := []string{}
+	for i := range 3 {
+		id, err := connection.Logs().Add(ctx, data.Log{JobID: jobID, Description: fmt.Sprintf("line, with \"quotes\" %v", i), Timestamp: timestamp})
+		if err != nil {
+			return fmt.Errorf("error adding log: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	dir, err := os.MkdirTemp("", "export")
+	if err != nil {
+		return fmt.Errorf("error creating export directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	filenames := map[data.ExportFormat]string{}
+	for _, format := range data.ExportFormats {
+		report, err := exports.Items(ctx, "logs", connection.Logs().Get(ctx, data.LogFilter{JobID: &jobID}), data.ExportOptions{Format: format, Sink: &exports.LocalSink{Dir: dir}})
+		if err != nil {
+			return fmt.Errorf("error exporting logs as %v: %w", format, err)
+		}
+		if report.Rows != int64(len(ids)) || report.SkippedRows != 0 {
+			return fmt.Errorf("expected export as %v to report %v rows, got %v", format, len(ids), report)
+		}
+		info, err := os.Stat(report.File)
+		if err != nil || info.Size() == 0 {
+			return fmt.Errorf("export %v is missing or empty: %v", report.File, err)
+		}
+		filenames[format] = report.File
+	}
+	err = checkFailedExport(ctx, connection)
+	if err != nil {
+		return err
+	}
+
+	filename := filenames[data.ExportCSV]
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("error opening export %v: %w", filename, err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return fmt.Errorf("error reading export %v: %w", filename, err)
+	}
+	if len(rows) == 0 || !slices.Equal(rows[0], data.Columns[data.StoreLog]()) {
+		return fmt.Errorf("export %v does not start with the log columns", filename)
+	}
+	exportedIDs := []string{}
+	for _, row := range rows[1:] {
+		exportedIDs = append(exportedIDs, row[0])
+	}
+	slices.Sort(ids)
+	if !slices.Equal(exportedIDs, ids) {
+		return fmt.Errorf("expected export %v to have logs %v, got %v", filename, ids, exportedIDs)
+	}
+	return checkManifest(filename, int64(len(ids)))
+}
+
+// Check the export's manifest describes it, with its row count and checksum.
+func checkManifest(filename string, rows int64) error {
+	content, err := os.ReadFile(filename + exports.ManifestSuffix)
+	if err != nil {
+		return fmt.Errorf("error reading manifest of %v: %w", filename, err)
+	}
+	var entry exports.ManifestEntry
+	err = json.Unmarshal(content, &entry)
+	if err != nil {
+		return fmt.Errorf("error decoding manifest of %v: %w", filename, err)
+	}
+	exported, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading export %v: %w", filename, err)
+	}
+	checksum := sha256.Sum256(exported)
+	if entry.Rows != rows || entry.Bytes != int64(len(exported)) || entry.SHA256 != hex.EncodeToString(checksum[:]) {
+		return fmt.Errorf("manifest %+v does not describe export %v of %v rows", entry, filename, rows)
+	}
+	return nil
+}
+
+// Export a query that fails, into an empty directory, and check it is reported and leaves the directory empty.
+func checkFailedExport(ctx context.Context, connection db.DBConnection) error {
+	dir, err := os.MkdirTemp("", "failed_export")
+	if err != nil {
+		return fmt.Errorf("error creating export directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	invalid := connection.Logs().Find(ctx, data.Query[data.LogFilter]{Where: data.Comparison{Column: "no_such_column", Operator: data.Equal, Value: 1}})
+	report, err := exports.Items(ctx, "logs", invalid, data.ExportOptions{Sink: &exports.LocalSink{Dir: dir}})
+	if err == nil || report.File != "" {
+		return fmt.Errorf("expected an export of an invalid query to fail, got report %v and error %v", report, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error reading export directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("failed export left %v behind", entries[0].Name())
+	}
+	return nil
+}
+
+// Rollups summarize each device's field per bucket, counting repeated readings once, and keep their IDs when recomputed.
+// IDs are UUIDv7s of when they were first computed, like those of every other store.
+func checkRecompute(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	deviceID, err := connection.Devices().Add(ctx, data.Device{BrandID: "rollups", Timestamp: timestamp})
+	if err != nil {
+		return fmt.Errorf("error adding device: %w", err)
+	}
+	bucketStart := data.ResolutionHour.BucketStart(timestamp)
+	readings := []struct {
+		offset int64
+		value  string
+	}{{10, "3"}, {20, "1"}, {20, "1"}, {30, "8"}, {3600, "5"}}
+	for _, reading := range readings {
+		_, err := connection.Events().Add(ctx, data.Event{
+			EventSourceDeviceID: deviceID, EventTimestamp: bucketStart + reading.offset, FieldName: "state.temperature",
+		}.WithValue(json.Number(reading.value)))
+		if err != nil {
+			return fmt.Errorf("error adding event: %w", err)
+		}
+	}
+
+	before := data.FirstIDAt(time.Now())
+	_, err = connection.Rollups().Recompute(ctx, data.ResolutionHour, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error recomputing rollups: %w", err)
+	}
+	after := data.FirstIDAt(time.Now().Add(time.Millisecond))
+	rollups, err := data.Collect(connection.Rollups().Get(ctx, data.RollupFilter{DeviceID: &deviceID}).All(ctx))
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(rollups, func(a, b data.StoreRollup) int { return int(a.BucketStart - b.BucketStart) })
+	expected := []data.Rollup{
+		{DeviceID: deviceID, FieldName: "state.temperature", Resolution: data.ResolutionHour, BucketStart: bucketStart, Count: 3, Min: 1, Max: 8, Mean: 4, First: 3, Last: 8},
+		{DeviceID: deviceID, FieldName: "state.temperature", Resolution: data.ResolutionHour, BucketStart: bucketStart + 3600, Count: 1, Min: 5, Max: 5, Mean: 5, First: 5, Last: 5},
+	}
+	if len(rollups) != len(expected) {
+		return fmt.Errorf("expected %v rollups, got %v", len(expected), rollups)
+	}
+	ids := []string{}
+	for i, rollup := range rollups {
+		computed := rollup.Rollup
+		computed.Timestamp = 0
+		if computed != expected[i] {
+			return fmt.Errorf("expected rollup %v, got %v", expected[i], computed)
+		}
+		if !uuidv7.IsValidString(rollup.ID) || rollup.ID < before || rollup.ID >= after {
+			return fmt.Errorf("rollup ID %v is not a UUIDv7 of when it was computed, between %v and %v", rollup.ID, before, after)
+		}
+		ids = append(ids, rollup.ID)
+	}
+
+	_, err = connection.Rollups().Recompute(ctx, data.ResolutionHour, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error recomputing rollups again: %w", err)
+	}
+	recomputed, err := collectIDs(ctx, connection.Rollups().Get(ctx, data.RollupFilter{DeviceID: &deviceID}))
+	if err != nil {
+		return err
+	}
+	slices.Sort(ids)
+	if !slices.Equal(recomputed, ids) {
+		return fmt.Errorf("recomputing replaced rollups %v with %v", ids, recomputed)
+	}
+	return nil
+}
+
+// Aggregates combine numeric values per group and bucket, ordered by device, field and bucket. NULL values only count
+// towards counts, and rollups combine their summaries with means weighted by their counts.
+func checkAggregate(ctx context.Context, connect Factory) error {
+	connection, closeConnection, err := connectFresh(ctx, connect)
+	if err != nil {
+		return err
+	}
+	defer closeConnection()
+
+	first, err := connection.Devices().Add(ctx, data.Device{BrandID: "aggregate-first", Timestamp: timestamp})
+	if err != nil {
+		return fmt.Errorf("error adding device: %w", err)
+	}
+	second, err := connection.Devices().Add(ctx, data.Device{BrandID: "aggregate-second", Timestamp: timestamp})
+	if err != nil {
+		return fmt.Errorf("error adding device: %w", err)
+	}
+	bucket := data.ResolutionHour.BucketStart(timestamp)
+	next := bucket + 3600
+	events := []struct {
+		deviceID  string
+		offset    int64
+		fieldName string
+		value     any
+	}{
+		{first, 0, "temperature", json.Number("1")},
+		{first, 10, "temperature", json.Number("3")},
+		{first, 3600, "temperature", json.Number("5")},
+		{first, 0, "humidity", json.Number("10")},
+		{first, 0, "state", "open"},
+		{second, 0, "temperature", json.Number("2")},
+	}
+	for _, event := range events {
+		_, err := connection.Events().Add(ctx, data.Event{
+			EventSourceDeviceID: event.deviceID, EventTimestamp: bucket + event.offset, FieldName: event.fieldName,
+		}.WithValue(event.value))
+		if err != nil {
+			return fmt.Errorf("error adding event: %w", err)
+		}
+	}
+	_, err = connection.Rollups().Recompute(ctx, data.ResolutionHour, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error recomputing rollups: %w", err)
+	}
+
+	start, end := bucket, bucket+7200
+	aggregateEvents := func(query data.AggregateQuery) ([]data.AggregateResult, error) {
+		return connection.Events().Aggregate(ctx, data.EventFilter{}, &start, &end, query)
+	}
+	aggregateRollups := func(query data.AggregateQuery) ([]data.AggregateResult, error) {
+		return connection.Rollups().Aggregate(ctx, data.RollupFilter{}, &start, &end, query)
+	}
+	byDevice := []data.AggregateGroup{data.GroupByDevice}
+	byField := []data.AggregateGroup{data.GroupByField}
+	byDeviceAndField := []data.AggregateGroup{data.GroupByDevice, data.GroupByField}
+	cases := []struct {
+		name      string
+		aggregate func(data.AggregateQuery) ([]data.AggregateResult, error)
+		query     data.AggregateQuery
+		expected  []data.AggregateResult
+	}{
+		{"minimum", aggregateEvents, data.AggregateQuery{Function: data.AggregateMin}, []data.AggregateResult{{Value: 1, Count: 5}}},
+		{"maximum", aggregateEvents, data.AggregateQuery{Function: data.AggregateMax}, []data.AggregateResult{{Value: 10, Count: 5}}},
+		{"sum", aggregateEvents, data.AggregateQuery{Function: data.AggregateSum}, []data.AggregateResult{{Value: 21, Count: 5}}},
+		{"average", aggregateEvents, data.AggregateQuery{Function: data.AggregateAvg}, []data.AggregateResult{{Value: 4.2, Count: 5}}},
+		{"count", aggregateEvents, data.AggregateQuery{Function: data.AggregateCount}, []data.AggregateResult{{Value: 6, Count: 6}}},
+		{"median", aggregateEvents, data.AggregateQuery{Function: data.AggregatePercentile, Percentile: 0.5}, []data.AggregateResult{{Value: 3, Count: 5}}},
+		{"lowest percentile", aggregateEvents, data.AggregateQuery{Function: data.AggregatePercentile, Percentile: 0}, []data.AggregateResult{{Value: 1, Count: 5}}},
+		{"sum by device", aggregateEvents, data.AggregateQuery{Function: data.AggregateSum, GroupBy: byDevice}, []data.AggregateResult{
+			{DeviceID: first, Value: 19, Count: 4},
+			{DeviceID: second, Value: 2, Count: 1},
+		}},
+		{"sum by field", aggregateEvents, data.AggregateQuery{Function: data.AggregateSum, GroupBy: byField}, []data.AggregateResult{
+			{FieldName: "humidity", Value: 10, Count: 1},
+			{FieldName: "temperature", Value: 11, Count: 4},
+		}},
+		{"sum by device and field", aggregateEvents, data.AggregateQuery{Function: data.AggregateSum, GroupBy: byDeviceAndField}, []data.AggregateResult{
+			{DeviceID: first, FieldName: "humidity", Value: 10, Count: 1},
+			{DeviceID: first, FieldName: "temperature", Value: 9, Count: 3},
+			{DeviceID: second, FieldName: "temperature", Value: 2, Count: 1},
+		}},
+		{"count by device and field per hour", aggregateEvents, data.AggregateQuery{Function: data.AggregateCount, GroupBy: byDeviceAndField, BucketSeconds: 3600}, []data.AggregateResult{
+			{DeviceID: first, FieldName: "humidity", BucketStart: bucket, Value: 1, Count: 1},
+			{DeviceID: first, FieldName: "state", BucketStart: bucket, Value: 1, Count: 1},
+			{DeviceID: first, FieldName: "temperature", BucketStart: bucket, Value: 2, Count: 2},
+			{DeviceID: first, FieldName: "temperature", BucketStart: next, Value: 1, Count: 1},
+			{DeviceID: second, FieldName: "temperature", BucketStart: bucket, Value: 1, Count: 1},
+		}},
+		{"maximum by field per hour", aggregateEvents, data.AggregateQuery{Function: data.AggregateMax, GroupBy: byField, BucketSeconds: 3600}, []data.AggregateResult{
+			{FieldName: "humidity", BucketStart: bucket, Value: 10, Count: 1},
+			{FieldName: "temperature", BucketStart: bucket, Value: 3, Count: 3},
+			{FieldName: "temperature", BucketStart: next, Value: 5, Count: 1},
+		}},
+		{"rollup average by device", aggregateRollups, data.AggregateQuery{Function: data.AggregateAvg, GroupBy: byDevice}, []data.AggregateResult{
+			{DeviceID: first, Value: 4.75, Count: 4},
+			{DeviceID: second, Value: 2, Count: 1},
+		}},
+		{"rollup minimum by field", aggregateRollups, data.AggregateQuery{Function: data.AggregateMin, GroupBy: byField}, []data.AggregateResult{
+			{FieldName: "humidity", Value: 10, Count: 1},
+			{FieldName: "temperature", Value: 1, Count: 4},
+		}},
+	}
+	for _, c := range cases {
+		results, err := c.aggregate(c.query)
+		if err != nil {
+			return fmt.Errorf("%v: error aggregating: %w", c.name, err)
+		}
+		// Devices are ordered by ID, which need not follow the order they were added in
+		slices.SortStableFunc(c.expected, func(a, b data.AggregateResult) int { return cmp.Compare(a.DeviceID, b.DeviceID) })
+		if len(results) != len(c.expected) {
+			return fmt.Errorf("%v: expected %v, got %v", c.name, c.expected, results)
+		}
+		for i, result := range results {
+			expected := c.expected[i]
+			// Averages need not be exact
+			if math.Abs(result.Value-expected.Value) < 1e-9 {
+				result.Value = expected.Value
+			}
+			if result != expected {
+				return fmt.Errorf("%v: expected %v, got %v", c.name, c.expected, results)
+			}
+		}
+	}
+
+	invalid := map[string]func() error{
+		"unknown function": func() error {
+			_, err := aggregateEvents(data.AggregateQuery{Function: "MEDIAN"})
+			return err
+		},
+		"percentile above 1": func() error {
+			_, err := aggregateEvents(data.AggregateQuery{Function: data.AggregatePercentile, Percentile: 1.5})
+			return err
+		},
+		"group the store lacks": func() error {
+			_, err := connection.Batteries().Aggregate(ctx, data.BatteryReadingFilter{}, nil, nil, data.AggregateQuery{Function: data.AggregateCount, GroupBy: byField})
+			return err
+		},
+		"average without a value": func() error {
+			_, err := connection.Logs().Aggregate(ctx, data.LogFilter{}, nil, nil, data.AggregateQuery{Function: data.AggregateAvg})
+			return err
+		},
+	}
+	for name, aggregate := range invalid {
+		if aggregate() == nil {
+			return fmt.Errorf("%v: expected an error", name)
+		}
+	}
+	return nil
+}
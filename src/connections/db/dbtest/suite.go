@@ -0,0 +1,133 @@
+// Conformance suite every DBConnection implementation must pass.
+// It needs a database it may freely destroy, so it only runs under go test: against dbfake, and against MySQL when
+// MYSQL_TEST_CONNECTION_STRING names a server to run it against.
+package dbtest
+
+import (
+	"com/connections/db"
+	"com/data"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Connect to the database under test. isSetupDestructive true starts from empty stores.
+type Factory func(ctx context.Context, isSetupDestructive bool) (db.DBConnection, error)
+
+type Case struct {
+	Name string
+	Run  func(ctx context.Context, connect Factory) error
+}
+
+func Cases() []Case {
+	return []Case{
+		{"add and get by each filter field", checkAddAndFilters},
+		{"pagination across pages", checkPagination},
+		{"pagination over NULLs and deleted items", checkNullablePagination},
+		{"delete", checkDelete},
+		{"edit", checkEdit},
+		{"close", checkClose},
+		{"time range boundaries", checkTimeRange},
+		{"destructive and non-destructive setup", checkSetup},
+		{"export", checkExport},
+		{"restore", checkRestore},
+		{"transactions", checkTransaction},
+		{"rollup recompute", checkRecompute},
+		{"aggregates", checkAggregate},
+	}
+}
+
+// Connect with empty stores, returning a function that closes the connection.
+func connectFresh(ctx context.Context, connect Factory) (db.DBConnection, func(), error) {
+	connection, err := connect(ctx, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error connecting: %w", err)
+	}
+	return connection, func() { _ = connection.Close() }, nil
+}
+
+// Add the item, check it reads back the same, then check a filter on each of its columns alone finds it.
+// Filter fields that cannot hold the column's value as is, such as ranges, are skipped.
+func checkStoreFilters[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F any](ctx context.Context, store db.GenericStore[T, S, F], item T) error {
+	id, err := store.Add(ctx, item)
+	if err != nil {
+		return fmt.Errorf("error adding %v: %w", item, err)
+	}
+	stored, err := getByID(ctx, store, id)
+	if err != nil {
+		return err
+	}
+	storedValues := (*stored).Spread()
+	if !reflect.DeepEqual(storedValues[1:], item.Spread()) {
+		return fmt.Errorf("added %v but read back %v", item.Spread(), storedValues)
+	}
+
+	columns := data.Columns[S]()
+	filterType := reflect.TypeFor[F]()
+	for _, field := range reflect.VisibleFields(filterType) {
+		column, ok := field.Tag.Lookup("db")
+		if !ok {
+			continue
+		}
+		index := slices.Index(columns, column)
+		if index < 0 {
+			return fmt.Errorf("filter column %v is not a column of %v", column, reflect.TypeFor[S]())
+		}
+		value := reflect.ValueOf(storedValues[index])
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				continue
+			}
+			value = value.Elem()
+		}
+		valueType := field.Type.Elem()
+		if !value.Type().ConvertibleTo(valueType) {
+			continue
+		}
+		filterValue := reflect.New(valueType)
+		filterValue.Elem().Set(value.Convert(valueType))
+		filter := reflect.New(filterType).Elem()
+		filter.FieldByIndex(field.Index).Set(filterValue)
+
+		ids, err := collectIDs(ctx, store.Get(ctx, filter.Interface().(F)))
+		if err != nil {
+			return fmt.Errorf("error getting by %v: %w", column, err)
+		}
+		if !slices.Contains(ids, id) {
+			return fmt.Errorf("filtering by %v = %v did not find item %v", column, value, id)
+		}
+	}
+	return nil
+}
+
+// The item with the ID, found with a filter on only the ID.
+func getByID[T any, S data.HasIDGetterAndSpreadable[S], F any](ctx context.Context, store db.GenericStore[T, S, F], id string) (*S, error) {
+	var filter F
+	idField, ok := idFilterField(reflect.TypeFor[F](), data.Columns[S]()[0])
+	if !ok {
+		return nil, fmt.Errorf("filter %T has no ID field", filter)
+	}
+	reflect.ValueOf(&filter).Elem().FieldByIndex(idField.Index).Set(reflect.ValueOf(&id))
+	items, err := data.Collect(store.Get(ctx, filter).All(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error getting item %v: %w", id, err)
+	}
+	if len(items) != 1 {
+		return nil, fmt.Errorf("expected 1 item with ID %v, got %v", id, len(items))
+	}
+	return &items[0], nil
+}
+
+func idFilterField(filterType reflect.Type, idColumn string) (reflect.StructField, bool) {
+	for _, field := range reflect.VisibleFields(filterType) {
+		if field.Tag.Get("db") == idColumn {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func collectIDs[S data.HasIDGetter](ctx context.Context, items *data.IterablePaginatedData[S]) ([]string, error) {
+	return data.Collect(data.Map(items.All(ctx), func(item S) (string, error) { return item.GetID(), nil }))
+}
@@ -0,0 +1,46 @@
+package mysql
+
+import (
+	"com/connections/db"
+	"com/data"
+)
+
+var _ db.AlertStateStore = (*MySQLAlertStateStore)(nil)
+
+type MySQLAlertStateStore struct {
+	MySQLEditableStore[data.AlertState, data.StoreAlertState, data.AlertStateFilter]
+}
+
+func NewMySQLAlertStateStore(db database) MySQLAlertStateStore {
+	return MySQLAlertStateStore{
+		MySQLEditableStore: MySQLEditableStore[data.AlertState, data.StoreAlertState, data.AlertStateFilter]{
+			MySQLStore: MySQLStore[data.AlertState, data.StoreAlertState, data.AlertStateFilter]{
+				db:        db,
+				tableName: "alert_states",
+				tableCreationSQL: `
+				CREATE TABLE IF NOT EXISTS alert_states (
+					alert_state_id 				VARCHAR(36) NOT NULL,
+					alert_rule_name 			VARCHAR(60) NOT NULL,
+					device_id 					VARCHAR(40) NOT NULL,
+					alert_status 				VARCHAR(10) NOT NULL,
+					alert_last_value 			TEXT		NOT NULL,
+					alert_condition_since 		BIGINT		NOT NULL,
+					alert_fired_timestamp 		BIGINT		NOT NULL,
+					alert_resolved_timestamp 	BIGINT		NOT NULL,
+					alert_timestamp 			BIGINT		NOT NULL,
+					PRIMARY KEY (alert_state_id),
+					UNIQUE INDEX alert_rule_device_idx (alert_rule_name, device_id)
+				) ENGINE = InnoDB;
+				`,
+				tableColumns: data.Columns[data.StoreAlertState](),
+				primaryKey:   "alert_state_id",
+				tableMigrations: []migration{
+					{
+						appliedQuery: columnTypeQuery("alert_states", "alert_last_value", "text"),
+						statements:   []string{`ALTER TABLE alert_states MODIFY COLUMN alert_last_value TEXT NOT NULL`},
+					},
+				},
+			},
+		},
+	}
+}
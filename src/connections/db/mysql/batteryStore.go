@@ -0,0 +1,42 @@
+package mysql
+
+import (
+	"com/connections/db"
+	"com/data"
+)
+
+var _ db.BatteryStore = (*MySQLBatteryStore)(nil)
+
+type MySQLBatteryStore struct {
+	MySQLTimestampedDataStore[data.BatteryReading, data.StoreBatteryReading, data.BatteryReadingFilter]
+}
+
+func NewMySQLBatteryStore(db database) MySQLBatteryStore {
+	return MySQLBatteryStore{
+		MySQLTimestampedDataStore: MySQLTimestampedDataStore[data.BatteryReading, data.StoreBatteryReading, data.BatteryReadingFilter]{
+			timestampKey: "battery_timestamp",
+			valueKey:     "battery_level",
+			groupKeys: map[data.AggregateGroup]string{
+				data.GroupByDevice: "device_id",
+			},
+			MySQLStore: MySQLStore[data.BatteryReading, data.StoreBatteryReading, data.BatteryReadingFilter]{
+				db:        db,
+				tableName: "battery_readings",
+				tableCreationSQL: `
+					CREATE TABLE IF NOT EXISTS battery_readings (
+						battery_reading_id 	VARCHAR(36) NOT NULL,
+						device_id 			VARCHAR(40) NOT NULL,
+						event_id 			VARCHAR(36) NOT NULL,
+						battery_level 		INT			NOT NULL,
+						battery_raw_value 	VARCHAR(45) NOT NULL,
+						battery_timestamp 	BIGINT		NOT NULL,
+						PRIMARY KEY (battery_reading_id),
+						INDEX battery_device_timestamp_idx (device_id, battery_timestamp)
+					) ENGINE = InnoDB;
+				`,
+				tableColumns: data.Columns[data.StoreBatteryReading](),
+				primaryKey:   "battery_reading_id",
+			},
+		},
+	}
+}
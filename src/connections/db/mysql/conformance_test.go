@@ -0,0 +1,33 @@
+package mysql_test
+
+import (
+	"com/connections/db"
+	"com/connections/db/dbtest"
+	"com/connections/db/mysql"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Database the suite runs against, which it empties.
+const conformanceDatabaseName = "yolinkconformance"
+
+// Runs the conformance suite against the MySQL server of MYSQL_TEST_CONNECTION_STRING, such as "root@tcp(127.0.0.1:3306)/".
+func TestConformance(t *testing.T) {
+	connectionString := strings.TrimSpace(os.Getenv("MYSQL_TEST_CONNECTION_STRING"))
+	if connectionString == "" {
+		t.Skip("MYSQL_TEST_CONNECTION_STRING is not set")
+	}
+	connect := func(ctx context.Context, isSetupDestructive bool) (db.DBConnection, error) {
+		return mysql.NewMySQLConnectionToDatabase(ctx, connectionString, conformanceDatabaseName, isSetupDestructive)
+	}
+	for _, c := range dbtest.Cases() {
+		t.Run(c.Name, func(t *testing.T) {
+			err := c.Run(t.Context(), connect)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
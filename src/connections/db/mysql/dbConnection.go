@@ -19,14 +19,27 @@ var _ db.DBConnection = (*MySQLConnection)(nil)
 type MySQLConnection struct {
 	connectionString string
 	db               *sql.DB
+	// The transaction stores work within, nil outside of WithTransaction.
+	tx               database
 	eventStore       db.EventStore
 	deviceStore      db.DeviceStore
 	jobStore         db.JobStore
 	logStore         db.LogStore
+	alertStateStore  db.AlertStateStore
+	deliveryStore    db.DeliveryStore
+	batteryStore     db.BatteryStore
+	deviceStateStore db.DeviceStateStore
+	rollupStore      db.RollupStore
+	exportMarkStore  db.ExportMarkStore
 }
 
 // connectionString excludes the database name and includes the slash at the end.
 func NewMySQLConnection(ctx context.Context, connectionString string, isSetupDestructive bool) (*MySQLConnection, error) {
+	return NewMySQLConnectionToDatabase(ctx, connectionString, DatabaseName, isSetupDestructive)
+}
+
+// Like NewMySQLConnection, using the named database, which is created if it does not exist.
+func NewMySQLConnectionToDatabase(ctx context.Context, connectionString string, databaseName string, isSetupDestructive bool) (*MySQLConnection, error) {
 	mySQL := &MySQLConnection{connectionString: connectionString}
 	err := mySQL.Open(ctx)
 	if err != nil {
@@ -34,48 +47,86 @@ func NewMySQLConnection(ctx context.Context, connectionString string, isSetupDes
 	}
 	sqlctx, cancel := context.WithTimeout(ctx, RequestTimeout)
 	defer cancel()
-	_, err = mySQL.DB().ExecContext(sqlctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", DatabaseName))
+	_, err = mySQL.DB().ExecContext(sqlctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", databaseName))
 	if err != nil {
 		return nil, fmt.Errorf("error while creating database: %w", err)
 	}
 
-	db := &MySQLConnection{connectionString: connectionString + DatabaseName}
+	db := &MySQLConnection{connectionString: connectionString + databaseName}
 	err = db.Open(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error while connecting to database: %w", err)
 	}
 
-	// Create stores
-	devices := NewMySQLDeviceStore(db.db)
-	err = devices.Setup(ctx, isSetupDestructive)
-	if err != nil {
-		return nil, fmt.Errorf("error setting up devices: %w", err)
+	for _, store := range db.createStores(db.db) {
+		err = store.setup(ctx, isSetupDestructive)
+		if err != nil {
+			return nil, fmt.Errorf("error setting up %v: %w", store.name, err)
+		}
 	}
-	db.deviceStore = &devices
+	return db, nil
+}
 
-	events := NewMySQLEventStore(db.db)
-	err = events.Setup(ctx, isSetupDestructive)
-	if err != nil {
-		return nil, fmt.Errorf("error setting up events: %w", err)
-	}
-	db.eventStore = &events
+// A store's setup, named for errors.
+type storeSetup struct {
+	name  string
+	setup func(ctx context.Context, isSetupDestructive bool) error
+}
 
-	jobs := NewMySQLJobStore(db.db)
-	err = jobs.Setup(ctx, isSetupDestructive)
-	if err != nil {
-		return nil, fmt.Errorf("error setting up events: %w", err)
-	}
-	db.jobStore = &jobs
+// Create the stores, querying the database, which is the connection's own or a transaction within it.
+// Returns their setups in the order they must run, as tables reference those set up before them.
+func (manager *MySQLConnection) createStores(database database) []storeSetup {
+	devices := NewMySQLDeviceStore(database)
+	manager.deviceStore = &devices
+	deviceStates := NewMySQLDeviceStateStore(database)
+	manager.deviceStateStore = &deviceStates
+	rollups := NewMySQLRollupStore(database, "events")
+	manager.rollupStore = &rollups
+	events := NewMySQLEventStore(database, &deviceStates, &rollups)
+	manager.eventStore = &events
+	jobs := NewMySQLJobStore(database)
+	manager.jobStore = &jobs
+	logs := NewMySQLLogStore(database)
+	manager.logStore = &logs
+	alertStates := NewMySQLAlertStateStore(database)
+	manager.alertStateStore = &alertStates
+	deliveries := NewMySQLDeliveryStore(database)
+	manager.deliveryStore = &deliveries
+	batteries := NewMySQLBatteryStore(database)
+	manager.batteryStore = &batteries
+	exportMarks := NewMySQLExportMarkStore(database)
+	manager.exportMarkStore = &exportMarks
 
-	logs := NewMySQLLogStore(db.db)
-	err = logs.Setup(ctx, isSetupDestructive)
-	if err != nil {
-		return nil, fmt.Errorf("error setting up events: %w", err)
+	return []storeSetup{
+		{"devices", devices.Setup},
+		{"device states", deviceStates.Setup},
+		{"rollups", rollups.Setup},
+		{"events", events.Setup},
+		{"jobs", jobs.Setup},
+		{"logs", logs.Setup},
+		{"alert states", alertStates.Setup},
+		{"deliveries", deliveries.Setup},
+		{"battery readings", batteries.Setup},
+		{"export marks", exportMarks.Setup},
 	}
-	db.logStore = &logs
+}
 
-	return db, nil
+// Read-only transactions are repeatable reads, which see every table as of the first read.
+func (manager *MySQLConnection) WithTransaction(ctx context.Context, isReadOnly bool, fn func(tx db.DBConnection) error) error {
+	if manager.tx != nil {
+		return fn(manager)
+	}
+	options := &sql.TxOptions{}
+	if isReadOnly {
+		options = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+	return inTransaction(ctx, manager.db, options, func(tx database) error {
+		connection := &MySQLConnection{connectionString: manager.connectionString, db: manager.db, tx: tx}
+		connection.createStores(tx)
+		return fn(connection)
+	})
 }
+
 func (manager *MySQLConnection) Open(ctx context.Context) error {
 	db, err := sql.Open("mysql", manager.connectionString)
 	if err != nil {
@@ -124,3 +175,21 @@ func (manager *MySQLConnection) Jobs() db.JobStore {
 func (manager *MySQLConnection) Logs() db.LogStore {
 	return manager.logStore
 }
+func (manager *MySQLConnection) AlertStates() db.AlertStateStore {
+	return manager.alertStateStore
+}
+func (manager *MySQLConnection) Deliveries() db.DeliveryStore {
+	return manager.deliveryStore
+}
+func (manager *MySQLConnection) Batteries() db.BatteryStore {
+	return manager.batteryStore
+}
+func (manager *MySQLConnection) DeviceStates() db.DeviceStateStore {
+	return manager.deviceStateStore
+}
+func (manager *MySQLConnection) Rollups() db.RollupStore {
+	return manager.rollupStore
+}
+func (manager *MySQLConnection) ExportMarks() db.ExportMarkStore {
+	return manager.exportMarkStore
+}
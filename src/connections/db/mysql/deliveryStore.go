@@ -0,0 +1,39 @@
+package mysql
+
+import (
+	"com/connections/db"
+	"com/data"
+)
+
+var _ db.DeliveryStore = (*MySQLDeliveryStore)(nil)
+
+type MySQLDeliveryStore struct {
+	MySQLTimestampedDataStore[data.Delivery, data.StoreDelivery, data.DeliveryFilter]
+}
+
+func NewMySQLDeliveryStore(db database) MySQLDeliveryStore {
+	return MySQLDeliveryStore{
+		MySQLTimestampedDataStore: MySQLTimestampedDataStore[data.Delivery, data.StoreDelivery, data.DeliveryFilter]{
+			timestampKey: "delivery_timestamp",
+			MySQLStore: MySQLStore[data.Delivery, data.StoreDelivery, data.DeliveryFilter]{
+				db:        db,
+				tableName: "deliveries",
+				tableCreationSQL: `
+					CREATE TABLE IF NOT EXISTS deliveries (
+						delivery_id 		VARCHAR(36) NOT NULL,
+						job_id 				VARCHAR(36) NOT NULL,
+						delivery_channel 	VARCHAR(60) NOT NULL,
+						delivery_title 		TEXT		NOT NULL,
+						delivery_attempt 	INT			NOT NULL,
+						delivery_status 	VARCHAR(10) NOT NULL,
+						delivery_error 		TEXT		NOT NULL,
+						delivery_timestamp 	BIGINT		NOT NULL,
+						PRIMARY KEY (delivery_id)
+					) ENGINE = InnoDB;
+				`,
+				tableColumns: data.Columns[data.StoreDelivery](),
+				primaryKey:   "delivery_id",
+			},
+		},
+	}
+}
@@ -0,0 +1,84 @@
+package mysql
+
+import (
+	"com/connections/db"
+	"com/data"
+	"context"
+	"fmt"
+
+	"github.com/samborkent/uuidv7"
+)
+
+var _ db.DeviceStateStore = (*MySQLDeviceStateStore)(nil)
+
+type MySQLDeviceStateStore struct {
+	MySQLStore[data.DeviceState, data.StoreDeviceState, data.DeviceStateFilter]
+}
+
+func NewMySQLDeviceStateStore(db database) MySQLDeviceStateStore {
+	return MySQLDeviceStateStore{
+		MySQLStore: MySQLStore[data.DeviceState, data.StoreDeviceState, data.DeviceStateFilter]{
+			db:        db,
+			tableName: "device_state",
+			tableCreationSQL: `
+				CREATE TABLE IF NOT EXISTS device_state (
+					device_state_id 	VARCHAR(36) NOT NULL,
+					device_id 			VARCHAR(40) NOT NULL,
+					field_name 			VARCHAR(45) NOT NULL,
+					field_value 		TEXT		NOT NULL,
+					event_id 			VARCHAR(36) NOT NULL,
+					event_timestamp 	BIGINT		NOT NULL,
+					PRIMARY KEY (device_state_id),
+					UNIQUE INDEX device_field_idx (device_id, field_name)
+				) ENGINE = InnoDB;
+			`,
+			tableColumns: data.Columns[data.StoreDeviceState](),
+			primaryKey:   "device_state_id",
+			tableMigrations: []migration{
+				{
+					appliedQuery: columnTypeQuery("device_state", "field_value", "text"),
+					statements:   []string{`ALTER TABLE device_state MODIFY COLUMN field_value TEXT NOT NULL`},
+				},
+			},
+		},
+	}
+}
+
+func (s *MySQLDeviceStateStore) GetSnapshot(ctx context.Context) (map[string]data.DeviceSnapshot, error) {
+	snapshots := map[string]data.DeviceSnapshot{}
+	states := s.Get(ctx, data.DeviceStateFilter{})
+	for state, err := range states.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("error getting next device state: %w", err)
+		}
+		snapshot, ok := snapshots[state.DeviceID]
+		if !ok {
+			snapshot = data.DeviceSnapshot{DeviceID: state.DeviceID, Fields: map[string]data.StoreDeviceState{}}
+			snapshots[state.DeviceID] = snapshot
+		}
+		snapshot.Fields[state.FieldName] = state
+	}
+	return snapshots, nil
+}
+
+// Set the device's field to the event's value, unless a later event already set it.
+func (s *MySQLDeviceStateStore) upsertFromEvent(ctx context.Context, executor executor, eventID string, event data.Event) error {
+	// Assignments run in order, so the timestamp is compared before it is updated
+	query := fmt.Sprintf(`
+		INSERT INTO %s (device_state_id, device_id, field_name, field_value, event_id, event_timestamp)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			field_value = IF(VALUES(event_timestamp) >= event_timestamp, VALUES(field_value), field_value),
+			event_id = IF(VALUES(event_timestamp) >= event_timestamp, VALUES(event_id), event_id),
+			event_timestamp = GREATEST(event_timestamp, VALUES(event_timestamp))
+	`, s.tableName)
+
+	sqlctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+	_, err := executor.ExecContext(sqlctx, query,
+		uuidv7.New().String(), event.EventSourceDeviceID, event.FieldName, event.FieldValue, eventID, event.EventTimestamp)
+	if err != nil {
+		return fmt.Errorf("error updating device state from event %v: %w", eventID, err)
+	}
+	return nil
+}
@@ -3,7 +3,6 @@ package mysql
 import (
 	"com/connections/db"
 	"com/data"
-	"database/sql"
 )
 
 var _ db.GenericStore[data.Device, data.StoreDevice, data.DeviceFilter] = (*MySQLDeviceStore)(nil)
@@ -12,7 +11,7 @@ type MySQLDeviceStore struct {
 	MySQLEditableStore[data.Device, data.StoreDevice, data.DeviceFilter]
 }
 
-func NewMySQLDeviceStore(db *sql.DB) MySQLDeviceStore {
+func NewMySQLDeviceStore(db database) MySQLDeviceStore {
 	return MySQLDeviceStore{
 		MySQLEditableStore: MySQLEditableStore[data.Device, data.StoreDevice, data.DeviceFilter]{
 			MySQLStore: MySQLStore[data.Device, data.StoreDevice, data.DeviceFilter]{
@@ -27,19 +26,17 @@ func NewMySQLDeviceStore(db *sql.DB) MySQLDeviceStore {
 					device_name 		VARCHAR(60) NOT NULL,
 					device_token 		VARCHAR(60) NOT NULL, 
 					device_timestamp 	VARCHAR(45) NOT NULL,
+					device_status 		VARCHAR(10) NOT NULL DEFAULT 'UNKNOWN',
+					device_last_event_timestamp BIGINT NOT NULL DEFAULT 0,
 					PRIMARY KEY (device_id)
 					) ENGINE = InnoDB;
 				`,
-				tableColumns: []string{
-					"device_id",
-					"brand_device_id",
-					"device_brand",
-					"device_kind",
-					"device_name",
-					"device_token",
-					"device_timestamp",
+				tableColumns: data.Columns[data.StoreDevice](),
+				primaryKey:   "device_id",
+				tableMigrations: []migration{
+					{statements: []string{`ALTER TABLE devices ADD COLUMN device_status VARCHAR(10) NOT NULL DEFAULT 'UNKNOWN'`}},
+					{statements: []string{`ALTER TABLE devices ADD COLUMN device_last_event_timestamp BIGINT NOT NULL DEFAULT 0`}},
 				},
-				primaryKey: "device_id",
 			},
 		},
 	}
@@ -1,21 +1,35 @@
 package mysql
 
 import (
+	"cmp"
 	"com/connections/db"
 	"com/data"
-	"database/sql"
+	"context"
+	"fmt"
+	"slices"
 )
 
-var _ db.TimestampedDataStore[data.Event, data.StoreEvent, data.EventFilter] = (*MySQLEventStore)(nil)
+var _ db.EventStore = (*MySQLEventStore)(nil)
 
 type MySQLEventStore struct {
 	MySQLTimestampedDataStore[data.Event, data.StoreEvent, data.EventFilter]
+
+	deviceStates *MySQLDeviceStateStore
+	rollups      *MySQLRollupStore
 }
 
-func NewMySQLEventStore(db *sql.DB) MySQLEventStore {
+// Device states are updated alongside every added event. Long series are read from rollups.
+func NewMySQLEventStore(db database, deviceStates *MySQLDeviceStateStore, rollups *MySQLRollupStore) MySQLEventStore {
 	return MySQLEventStore{
+		deviceStates: deviceStates,
+		rollups:      rollups,
 		MySQLTimestampedDataStore: MySQLTimestampedDataStore[data.Event, data.StoreEvent, data.EventFilter]{
 			timestampKey: "event_timestamp",
+			valueKey:     "numeric_value",
+			groupKeys: map[data.AggregateGroup]string{
+				data.GroupByDevice: "event_source_device_id",
+				data.GroupByField:  "field_name",
+			},
 			MySQLStore: MySQLStore[data.Event, data.StoreEvent, data.EventFilter]{
 				db:        db,
 				tableName: "events",
@@ -29,11 +43,15 @@ func NewMySQLEventStore(db *sql.DB) MySQLEventStore {
 
 						event_timestamp BIGINT      NOT NULL,
 						field_name 		VARCHAR(45) NOT NULL,
-						field_value 	VARCHAR(45) NOT NULL,
+						field_value 	TEXT		NOT NULL,
+						value_type 		VARCHAR(10) NOT NULL DEFAULT 'string',
+						numeric_value 	DOUBLE		NULL,
+						boolean_value 	BOOLEAN		NULL,
 
 						PRIMARY KEY (event_id),
 
 						INDEX event_source_device_id_idx (event_source_device_id ASC),
+						INDEX event_timestamp_idx (event_timestamp ASC),
 						CONSTRAINT event_source_device_id
 							FOREIGN KEY (event_source_device_id)
 							REFERENCES devices (device_id)
@@ -42,17 +60,95 @@ func NewMySQLEventStore(db *sql.DB) MySQLEventStore {
 							
 					) ENGINE = InnoDB;
 				`,
-				tableColumns: []string{
-					"event_id",
-					"request_device_id",
-					"event_source_device_id",
-					"response_timestamp",
-					"event_timestamp",
-					"field_name",
-					"field_value",
+				tableColumns: data.Columns[data.StoreEvent](),
+				primaryKey:   "event_id",
+				tableMigrations: []migration{
+					{statements: []string{`ALTER TABLE events ADD INDEX event_timestamp_idx (event_timestamp ASC)`}},
+					{
+						appliedQuery: columnTypeQuery("events", "field_value", "text"),
+						statements:   []string{`ALTER TABLE events MODIFY COLUMN field_value TEXT NOT NULL`},
+					},
+					// Left NULL for rows stored before typing until their types are inferred below
+					{statements: []string{`ALTER TABLE events ADD COLUMN value_type VARCHAR(10) NULL`}},
+					{statements: []string{`ALTER TABLE events ADD COLUMN numeric_value DOUBLE NULL`}},
+					{statements: []string{`ALTER TABLE events ADD COLUMN boolean_value BOOLEAN NULL`}},
+					{
+						// Values stored before typing were strings, so infer the types of untyped rows, which may be many.
+						// Each statement only touches rows still untyped, so a backfill interrupted midway picks up where it stopped.
+						appliedQuery: columnNotNullQuery("events", "value_type"),
+						isLong:       true,
+						statements: []string{
+							`UPDATE events SET value_type = 'number', numeric_value = CAST(field_value AS DOUBLE)
+								WHERE value_type IS NULL AND field_value REGEXP '^-?[0-9]+(\\.[0-9]+)?([eE][-+]?[0-9]+)?$'`,
+							`UPDATE events SET value_type = 'boolean', boolean_value = (field_value = 'true')
+								WHERE value_type IS NULL AND field_value IN ('true', 'false')`,
+							`UPDATE events SET value_type = 'string' WHERE value_type IS NULL`,
+							`ALTER TABLE events MODIFY COLUMN value_type VARCHAR(10) NOT NULL DEFAULT 'string'`,
+						},
+					},
 				},
-				primaryKey: "event_id",
 			},
 		},
 	}
 }
+
+// Add the event and update the device's state in a single transaction.
+func (s *MySQLEventStore) Add(ctx context.Context, item data.Event) (string, error) {
+	var id string
+	err := inTransaction(ctx, s.db, nil, func(tx database) error {
+		var err error
+		id, err = s.add(ctx, tx, item)
+		if err != nil {
+			return err
+		}
+		return s.deviceStates.upsertFromEvent(ctx, tx, id, item)
+	})
+	if err != nil {
+		return "", fmt.Errorf("error adding event %v: %w", item, err)
+	}
+	return id, nil
+}
+
+func (s *MySQLEventStore) GetSeries(ctx context.Context, deviceID string, fieldName string, startTime int64, endTime int64) ([]data.SeriesPoint, data.Resolution, error) {
+	resolution := data.ResolutionForRange(startTime, endTime)
+	points := []data.SeriesPoint{}
+
+	// Rollups, including the bucket startTime falls in
+	if resolution != data.ResolutionRaw {
+		bucketStart := resolution.BucketStart(startTime)
+		rollups := s.rollups.GetInTimeRange(ctx, data.RollupFilter{DeviceID: &deviceID, FieldName: &fieldName, Resolution: &resolution}, &bucketStart, &endTime)
+		for rollup, err := range rollups.All(ctx) {
+			if err != nil {
+				return nil, resolution, fmt.Errorf("error getting next rollup: %w", err)
+			}
+			points = append(points, rollup.SeriesPoint())
+		}
+		slices.SortFunc(points, func(a, b data.SeriesPoint) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+		return points, resolution, nil
+	}
+
+	// Raw readings, counting repeated reports once
+	events := s.GetInTimeRange(ctx, data.EventFilter{EventSourceDeviceID: &deviceID, FieldName: &fieldName}, &startTime, &endTime)
+	seen := map[int64]bool{}
+	for event, err := range events.All(ctx) {
+		if err != nil {
+			return nil, resolution, fmt.Errorf("error getting next event: %w", err)
+		}
+		if event.NumericValue == nil || seen[event.EventTimestamp] {
+			continue
+		}
+		seen[event.EventTimestamp] = true
+		value := *event.NumericValue
+		points = append(points, data.SeriesPoint{
+			Timestamp: event.EventTimestamp,
+			Count:     1,
+			Min:       value,
+			Max:       value,
+			Mean:      value,
+			First:     value,
+			Last:      value,
+		})
+	}
+	slices.SortFunc(points, func(a, b data.SeriesPoint) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+	return points, resolution, nil
+}
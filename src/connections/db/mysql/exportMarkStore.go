@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"com/connections/db"
+	"com/data"
+)
+
+var _ db.ExportMarkStore = (*MySQLExportMarkStore)(nil)
+
+type MySQLExportMarkStore struct {
+	MySQLEditableStore[data.ExportMark, data.StoreExportMark, data.ExportMarkFilter]
+}
+
+func NewMySQLExportMarkStore(db database) MySQLExportMarkStore {
+	return MySQLExportMarkStore{
+		MySQLEditableStore: MySQLEditableStore[data.ExportMark, data.StoreExportMark, data.ExportMarkFilter]{
+			MySQLStore: MySQLStore[data.ExportMark, data.StoreExportMark, data.ExportMarkFilter]{
+				db:        db,
+				tableName: "export_marks",
+				tableCreationSQL: `
+				CREATE TABLE IF NOT EXISTS export_marks (
+					export_mark_id 				VARCHAR(36) NOT NULL,
+					export_name 				VARCHAR(60) NOT NULL,
+					export_last_event_id 		VARCHAR(36) NOT NULL,
+					export_last_event_timestamp BIGINT		NOT NULL,
+					export_count 				BIGINT		NOT NULL,
+					export_mark_timestamp 		BIGINT		NOT NULL,
+					PRIMARY KEY (export_mark_id),
+					UNIQUE INDEX export_name_idx (export_name)
+				) ENGINE = InnoDB;
+				`,
+				tableColumns: data.Columns[data.StoreExportMark](),
+				primaryKey:   "export_mark_id",
+			},
+		},
+	}
+}
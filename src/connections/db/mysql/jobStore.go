@@ -3,7 +3,6 @@ package mysql
 import (
 	"com/connections/db"
 	"com/data"
-	"database/sql"
 )
 
 var _ db.ClosableStore[data.Job, data.StoreJob, data.JobFilter] = (*MySQLJobStore)(nil)
@@ -12,7 +11,7 @@ type MySQLJobStore struct {
 	MySQLClosableStore[data.Job, data.StoreJob, data.JobFilter]
 }
 
-func NewMySQLJobStore(db *sql.DB) MySQLJobStore {
+func NewMySQLJobStore(db database) MySQLJobStore {
 	return MySQLJobStore{
 		MySQLClosableStore: MySQLClosableStore[data.Job, data.StoreJob, data.JobFilter]{
 			closeKey: "job_end_timestamp",
@@ -30,14 +29,8 @@ func NewMySQLJobStore(db *sql.DB) MySQLJobStore {
 						job_end_timestamp 	BIGINT		NOT NULL
 					) ENGINE = InnoDB;
 					`,
-					tableColumns: []string{
-						"job_id",
-						"parent_job_id",
-						"job_category",
-						"job_start_timestamp",
-						"job_end_timestamp",
-					},
-					primaryKey: "job_id",
+					tableColumns: data.Columns[data.StoreJob](),
+					primaryKey:   "job_id",
 				},
 			},
 		},
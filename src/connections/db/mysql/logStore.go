@@ -3,7 +3,6 @@ package mysql
 import (
 	"com/connections/db"
 	"com/data"
-	"database/sql"
 )
 
 var _ db.TimestampedDataStore[data.Log, data.StoreLog, data.LogFilter] = (*MySQLLogStore)(nil)
@@ -12,7 +11,7 @@ type MySQLLogStore struct {
 	MySQLTimestampedDataStore[data.Log, data.StoreLog, data.LogFilter]
 }
 
-func NewMySQLLogStore(db *sql.DB) MySQLLogStore {
+func NewMySQLLogStore(db database) MySQLLogStore {
 	return MySQLLogStore{
 		MySQLTimestampedDataStore: MySQLTimestampedDataStore[data.Log, data.StoreLog, data.LogFilter]{
 			timestampKey: "log_timestamp",
@@ -29,15 +28,8 @@ func NewMySQLLogStore(db *sql.DB) MySQLLogStore {
 						log_timestamp   BIGINT		NOT NULL
 					) ENGINE = InnoDB;
 				`,
-				tableColumns: []string{
-					"log_id",
-					"job_id",
-					"log_level",
-					"log_stack_trace",
-					"log_description",
-					"log_timestamp",
-				},
-				primaryKey: "log_id",
+				tableColumns: data.Columns[data.StoreLog](),
+				primaryKey:   "log_id",
 			},
 		},
 	}
@@ -0,0 +1,295 @@
+package mysql
+
+import (
+	"com/data"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Escapes LIKE wildcards so the value matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *MySQLStore[T, S, F]) Find(ctx context.Context, query data.Query[F]) *data.IterablePaginatedData[S] {
+	paginator, err := s.FindFromCursor(ctx, query, "")
+	if err != nil {
+		return failedPaginatedData[S](err)
+	}
+	return paginator
+}
+func (s *MySQLStore[T, S, F]) FindFromCursor(ctx context.Context, query data.Query[F], cursor string) (*data.IterablePaginatedData[S], error) {
+	conditions, args := s.filterConditions(query.Filter)
+	if query.Where != nil {
+		condition, expressionArgs, err := s.expressionSQL(query.Where)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query on %v: %w", s.tableName, err)
+		}
+		conditions = append(conditions, condition)
+		args = append(args, expressionArgs...)
+	}
+	for _, order := range query.OrderBy {
+		if !slices.Contains(s.tableColumns, order.Column) {
+			return nil, fmt.Errorf("invalid order on %v: unknown column %v", s.tableName, order.Column)
+		}
+	}
+	return s.find(conditions, args, query.OrderBy, query.Limit, cursor)
+}
+
+// Paginate through items matching all conditions, in the given order and then by ID, continuing after the cursor if any.
+// Pages continue after the ordered values and ID of the last item of the previous page, which the cursor carries.
+func (s *MySQLStore[T, S, F]) find(conditions []string, args []any, orderBy []data.Order, limit int, cursor string) (*data.IterablePaginatedData[S], error) {
+	orderClauses := []string{}
+	orderIndexes := []int{}
+	for _, order := range orderBy {
+		direction := "ASC"
+		if order.Descending {
+			direction = "DESC"
+		}
+		orderClauses = append(orderClauses, order.Column+" "+direction)
+		orderIndexes = append(orderIndexes, slices.Index(s.tableColumns, order.Column))
+	}
+	orderClauses = append(orderClauses, s.primaryKey+" ASC")
+
+	// The position of an item is its ordered values followed by its ID
+	position := func(item S) string {
+		values := item.Spread()
+		positionValues := []any{}
+		for _, index := range orderIndexes {
+			positionValues = append(positionValues, values[index])
+		}
+		encoded, err := json.Marshal(append(positionValues, item.GetID()))
+		if err != nil {
+			// Column values are strings, numbers, booleans or nil
+			panic(fmt.Errorf("error encoding position of %v in %v: %w", item.GetID(), s.tableName, err))
+		}
+		return string(encoded)
+	}
+
+	// Malformed cursors fail before any page is fetched
+	if cursor != "" {
+		decoded, err := data.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if decoded.Position != "" {
+			_, _, err = pageCondition(orderBy, s.primaryKey, decoded.Position)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Queries differing in anything but the page are different queries
+	fingerprint := sha256.Sum256(fmt.Appendf(nil, "%s|%v|%v|%v|%d", s.tableName, conditions, args, orderClauses, limit))
+
+	// Limits count from where the cursor resumes
+	returned := 0
+	return newSQLIterablePaginatedData(s.db, hex.EncodeToString(fingerprint[:16]), cursor, position, func(lastPosition *string) (string, []any, bool, error) {
+		pageSize := data.PAGE_SIZE
+		if limit > 0 {
+			pageSize = min(pageSize, limit-returned)
+			if pageSize <= 0 {
+				return "", nil, false, nil
+			}
+			returned += pageSize
+		}
+
+		pageQueryConditions := slices.Clone(conditions)
+		pageQueryArgs := slices.Clone(args)
+		if lastPosition != nil {
+			condition, conditionArgs, err := pageCondition(orderBy, s.primaryKey, *lastPosition)
+			if err != nil {
+				return "", nil, false, err
+			}
+			pageQueryConditions = append(pageQueryConditions, condition)
+			pageQueryArgs = append(pageQueryArgs, conditionArgs...)
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(s.tableColumns, ", "), s.tableName)
+		if len(pageQueryConditions) > 0 {
+			query += " WHERE " + strings.Join(pageQueryConditions, " AND ")
+		}
+		query += fmt.Sprintf(" ORDER BY %s LIMIT ?", strings.Join(orderClauses, ", "))
+		return query, append(pageQueryArgs, pageSize), true, nil
+	})
+}
+
+// The condition for items after the position: equal on the leading ordered columns and past it on the next, for any
+// number of leading columns, and then past its ID. MySQL sorts NULLs first, so they come before every value in
+// ascending order and after every value in descending order.
+func pageCondition(orderBy []data.Order, primaryKey string, position string) (string, []any, error) {
+	values, err := decodePosition(position, len(orderBy))
+	if err != nil {
+		return "", nil, err
+	}
+
+	pageConditions := []string{}
+	args := []any{}
+	equalities := []string{}
+	equalityArgs := []any{}
+	for i, order := range orderBy {
+		value := values[i]
+		var after string
+		afterArgs := []any{}
+		switch {
+		case value == nil && order.Descending:
+			// Nothing is after NULLs
+		case value == nil:
+			after = order.Column + " IS NOT NULL"
+		case order.Descending:
+			after = "(" + order.Column + " < ? OR " + order.Column + " IS NULL)"
+			afterArgs = append(afterArgs, value)
+		default:
+			after = order.Column + " > ?"
+			afterArgs = append(afterArgs, value)
+		}
+		if after != "" {
+			pageConditions = append(pageConditions, strings.Join(append(slices.Clone(equalities), after), " AND "))
+			args = append(append(args, equalityArgs...), afterArgs...)
+		}
+		equalities = append(equalities, order.Column+" <=> ?")
+		equalityArgs = append(equalityArgs, value)
+	}
+	pageConditions = append(pageConditions, strings.Join(append(equalities, primaryKey+" > ?"), " AND "))
+	args = append(append(args, equalityArgs...), values[len(orderBy)])
+	return "((" + strings.Join(pageConditions, ") OR (") + "))", args, nil
+}
+
+// The ordered values and ID of a position. Whole numbers are decoded as integers to compare exactly.
+func decodePosition(position string, orderedColumns int) ([]any, error) {
+	decoder := json.NewDecoder(strings.NewReader(position))
+	decoder.UseNumber()
+	var values []any
+	err := decoder.Decode(&values)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cursor position %v: %w", position, err)
+	}
+	if len(values) != orderedColumns+1 {
+		return nil, fmt.Errorf("cursor position %v has %d values, expected %d", position, len(values), orderedColumns+1)
+	}
+	if _, ok := values[orderedColumns].(string); !ok {
+		return nil, fmt.Errorf("cursor position %v does not end with an ID", position)
+	}
+	for i, value := range values {
+		number, ok := value.(json.Number)
+		if !ok {
+			continue
+		}
+		if integer, err := number.Int64(); err == nil {
+			values[i] = integer
+		} else if float, err := number.Float64(); err == nil {
+			values[i] = float
+		} else {
+			return nil, fmt.Errorf("cursor position %v has invalid number %v: %w", position, number, err)
+		}
+	}
+	return values, nil
+}
+
+// Parameterized SQL for the expression and its arguments. Errors on columns the table does not have.
+func (s *MySQLStore[T, S, F]) expressionSQL(expression data.Expression) (string, []any, error) {
+	column := func(name string) (string, error) {
+		if !slices.Contains(s.tableColumns, name) {
+			return "", fmt.Errorf("unknown column %v", name)
+		}
+		return name, nil
+	}
+
+	switch e := expression.(type) {
+	case data.Comparison:
+		name, err := column(e.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		switch e.Operator {
+		case data.Equal, data.NotEqual, data.LessThan, data.LessThanOrEqual, data.GreaterThan, data.GreaterThanOrEqual:
+		default:
+			return "", nil, fmt.Errorf("unknown operator %v", e.Operator)
+		}
+		return fmt.Sprintf("%s %s ?", name, e.Operator), []any{e.Value}, nil
+	case data.In:
+		name, err := column(e.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(e.Values) == 0 {
+			return "FALSE", nil, nil
+		}
+		placeholders := strings.Repeat("?, ", len(e.Values)-1) + "?"
+		return fmt.Sprintf("%s IN (%s)", name, placeholders), slices.Clone(e.Values), nil
+	case data.Between:
+		name, err := column(e.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions := []string{}
+		args := []any{}
+		if e.Min != nil {
+			conditions = append(conditions, name+" >= ?")
+			args = append(args, e.Min)
+		}
+		if e.Max != nil {
+			conditions = append(conditions, name+" <= ?")
+			args = append(args, e.Max)
+		}
+		if len(conditions) == 0 {
+			return "TRUE", nil, nil
+		}
+		return "(" + strings.Join(conditions, " AND ") + ")", args, nil
+	case data.Like:
+		name, err := column(e.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		return name + " LIKE ?", []any{e.Pattern}, nil
+	case data.Prefix:
+		name, err := column(e.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		return name + " LIKE ?", []any{likeEscaper.Replace(e.Prefix) + "%"}, nil
+	case data.IsNull:
+		name, err := column(e.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		return name + " IS NULL", nil, nil
+	case data.Not:
+		if e.Expression == nil {
+			return "", nil, fmt.Errorf("NOT without an expression")
+		}
+		condition, args, err := s.expressionSQL(e.Expression)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + condition + ")", args, nil
+	case data.And:
+		return s.joinedExpressionSQL(e, " AND ", "TRUE")
+	case data.Or:
+		return s.joinedExpressionSQL(e, " OR ", "FALSE")
+	default:
+		return "", nil, fmt.Errorf("unknown expression %T", expression)
+	}
+}
+
+// The expressions joined by the operator, or the empty value if there are none.
+func (s *MySQLStore[T, S, F]) joinedExpressionSQL(expressions []data.Expression, operator string, empty string) (string, []any, error) {
+	if len(expressions) == 0 {
+		return empty, nil, nil
+	}
+	conditions := []string{}
+	args := []any{}
+	for _, expression := range expressions {
+		condition, expressionArgs, err := s.expressionSQL(expression)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, expressionArgs...)
+	}
+	// Parenthesized as a whole, so that it binds tighter than the conditions it is combined with
+	return "((" + strings.Join(conditions, ")"+operator+"(") + "))", args, nil
+}
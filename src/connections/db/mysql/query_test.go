@@ -0,0 +1,123 @@
+package mysql
+
+import (
+	"com/data"
+	"reflect"
+	"testing"
+)
+
+var testStore = &MySQLStore[data.Event, data.StoreEvent, data.EventFilter]{
+	tableName:    "events",
+	tableColumns: data.Columns[data.StoreEvent](),
+	primaryKey:   "event_id",
+}
+
+func TestExpressionSQL(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression data.Expression
+		sql        string
+		args       []any
+	}{
+		{"comparison", data.Comparison{Column: "event_timestamp", Operator: data.GreaterThanOrEqual, Value: 10}, "event_timestamp >= ?", []any{10}},
+		{"in", data.In{Column: "field_name", Values: []any{"state", "battery"}}, "field_name IN (?, ?)", []any{"state", "battery"}},
+		{"empty in", data.In{Column: "field_name"}, "FALSE", nil},
+		{"between", data.Between{Column: "event_timestamp", Min: 1, Max: 2}, "(event_timestamp >= ? AND event_timestamp <= ?)", []any{1, 2}},
+		{"open between", data.Between{Column: "event_timestamp", Max: 2}, "(event_timestamp <= ?)", []any{2}},
+		{"unbounded between", data.Between{Column: "event_timestamp"}, "TRUE", nil},
+		{"prefix", data.Prefix{Column: "field_name", Prefix: "state_%"}, "field_name LIKE ?", []any{`state\_\%%`}},
+		{"is null", data.IsNull{Column: "numeric_value"}, "numeric_value IS NULL", nil},
+		{"not", data.Not{Expression: data.Eq("field_name", "state")}, "NOT (field_name = ?)", []any{"state"}},
+		{"empty and", data.And{}, "TRUE", nil},
+		{"empty or", data.Or{}, "FALSE", nil},
+		{
+			"nested",
+			data.And{data.Eq("field_name", "state"), data.Or{data.IsNull{Column: "numeric_value"}, data.Like{Column: "field_value", Pattern: "a%"}}},
+			"((field_name = ?) AND (((numeric_value IS NULL) OR (field_value LIKE ?))))",
+			[]any{"state", "a%"},
+		},
+	}
+	for _, test := range tests {
+		sql, args, err := testStore.expressionSQL(test.expression)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if sql != test.sql || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%v: expected %v with %v, got %v with %v", test.name, test.sql, test.args, sql, args)
+		}
+	}
+}
+
+func TestExpressionSQLRejectsUnknownInput(t *testing.T) {
+	expressions := map[string]data.Expression{
+		"unknown column":      data.Eq("event_timestamp; DROP TABLE events", 1),
+		"unknown operator":    data.Comparison{Column: "field_name", Operator: "LIKE", Value: "a"},
+		"nested unknown":      data.Or{data.Eq("field_name", "a"), data.IsNull{Column: "password"}},
+		"not without operand": data.Not{},
+		"unknown expression":  nil,
+	}
+	for name, expression := range expressions {
+		_, _, err := testStore.expressionSQL(expression)
+		if err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestPageCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		orderBy  []data.Order
+		position string
+		sql      string
+		args     []any
+	}{
+		{"by ID", nil, `["id"]`, "((event_id > ?))", []any{"id"}},
+		{
+			"ascending", []data.Order{{Column: "event_timestamp"}}, `[10,"id"]`,
+			"((event_timestamp > ?) OR (event_timestamp <=> ? AND event_id > ?))", []any{int64(10), int64(10), "id"},
+		},
+		{
+			"descending", []data.Order{{Column: "numeric_value", Descending: true}}, `[1.5,"id"]`,
+			"(((numeric_value < ? OR numeric_value IS NULL)) OR (numeric_value <=> ? AND event_id > ?))", []any{1.5, 1.5, "id"},
+		},
+		{
+			"ascending after NULL", []data.Order{{Column: "numeric_value"}}, `[null,"id"]`,
+			"((numeric_value IS NOT NULL) OR (numeric_value <=> ? AND event_id > ?))", []any{nil, "id"},
+		},
+		{
+			"descending after NULL", []data.Order{{Column: "numeric_value", Descending: true}}, `[null,"id"]`,
+			"((numeric_value <=> ? AND event_id > ?))", []any{nil, "id"},
+		},
+		{
+			"two columns", []data.Order{{Column: "field_name"}, {Column: "event_timestamp", Descending: true}}, `["state",10,"id"]`,
+			"((field_name > ?) OR (field_name <=> ? AND (event_timestamp < ? OR event_timestamp IS NULL)) OR (field_name <=> ? AND event_timestamp <=> ? AND event_id > ?))",
+			[]any{"state", "state", int64(10), "state", int64(10), "id"},
+		},
+	}
+	for _, test := range tests {
+		sql, args, err := pageCondition(test.orderBy, "event_id", test.position)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if sql != test.sql || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%v: expected\n%v with %#v, got\n%v with %#v", test.name, test.sql, test.args, sql, args)
+		}
+	}
+}
+
+func TestDecodePosition(t *testing.T) {
+	values, err := decodePosition(`[9007199254740993,0.5,null,"id"]`, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, []any{int64(9007199254740993), 0.5, nil, "id"}) {
+		t.Fatalf("expected large integers to be kept exactly, got %#v", values)
+	}
+	for _, position := range []string{`[1,"id"]`, `[1,2]`, `not json`} {
+		_, err := decodePosition(position, 2)
+		if err == nil {
+			t.Errorf("%v: expected an error", position)
+		}
+	}
+}
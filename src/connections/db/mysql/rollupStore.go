@@ -0,0 +1,198 @@
+package mysql
+
+import (
+	"com/connections/db"
+	"com/data"
+	"com/utils"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/samborkent/uuidv7"
+)
+
+var _ db.RollupStore = (*MySQLRollupStore)(nil)
+
+type MySQLRollupStore struct {
+	MySQLTimestampedDataStore[data.Rollup, data.StoreRollup, data.RollupFilter]
+
+	eventsTableName string
+}
+
+// Rollups are computed from the given events table.
+func NewMySQLRollupStore(db database, eventsTableName string) MySQLRollupStore {
+	return MySQLRollupStore{
+		eventsTableName: eventsTableName,
+		MySQLTimestampedDataStore: MySQLTimestampedDataStore[data.Rollup, data.StoreRollup, data.RollupFilter]{
+			timestampKey: "bucket_start",
+			valueKey:     "rollup_mean",
+			summaryKeys:  &summaryKeys{min: "rollup_min", max: "rollup_max", count: "rollup_count"},
+			groupKeys: map[data.AggregateGroup]string{
+				data.GroupByDevice: "device_id",
+				data.GroupByField:  "field_name",
+			},
+			MySQLStore: MySQLStore[data.Rollup, data.StoreRollup, data.RollupFilter]{
+				db:        db,
+				tableName: "rollups",
+				tableCreationSQL: `
+					CREATE TABLE IF NOT EXISTS rollups (
+						rollup_id 			VARCHAR(36) NOT NULL,
+						device_id 			VARCHAR(40) NOT NULL,
+						field_name 			VARCHAR(45) NOT NULL,
+						rollup_resolution 	VARCHAR(10) NOT NULL,
+						bucket_start 		BIGINT		NOT NULL,
+						rollup_count 		BIGINT		NOT NULL,
+						rollup_min 			DOUBLE		NOT NULL,
+						rollup_max 			DOUBLE		NOT NULL,
+						rollup_mean 		DOUBLE		NOT NULL,
+						rollup_first 		DOUBLE		NOT NULL,
+						rollup_last 		DOUBLE		NOT NULL,
+						rollup_timestamp 	BIGINT		NOT NULL,
+						PRIMARY KEY (rollup_id),
+						UNIQUE INDEX rollup_bucket_idx (device_id, field_name, rollup_resolution, bucket_start),
+						INDEX rollup_resolution_bucket_idx (rollup_resolution, bucket_start)
+					) ENGINE = InnoDB;
+				`,
+				tableColumns: data.Columns[data.StoreRollup](),
+				primaryKey:   "rollup_id",
+			},
+		},
+	}
+}
+
+// Buckets are recomputed a week of events at a time, so that each statement finishes well within RequestTimeout.
+const recomputeChunkSeconds int64 = 7 * 24 * 60 * 60
+
+func (s *MySQLRollupStore) Recompute(ctx context.Context, resolution data.Resolution, startTime *int64, endTime *int64) (int64, error) {
+	bucketSeconds := resolution.Seconds()
+	if bucketSeconds == 0 {
+		return 0, fmt.Errorf("cannot roll up events at resolution %v", resolution)
+	}
+
+	// Find the range of events to roll up, by event time alone so that the range is read from its index
+	conditions := []string{"TRUE"}
+	args := []any{}
+	if startTime != nil {
+		conditions = append(conditions, "event_timestamp >= ?")
+		args = append(args, resolution.BucketStart(*startTime))
+	}
+	if endTime != nil {
+		conditions = append(conditions, "event_timestamp < ?")
+		args = append(args, *endTime)
+	}
+	var first, last sql.NullInt64
+	sqlctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	err := s.db.QueryRowContext(
+		sqlctx,
+		fmt.Sprintf("SELECT MIN(event_timestamp), MAX(event_timestamp) FROM %s WHERE %s", s.eventsTableName, strings.Join(conditions, " AND ")),
+		args...,
+	).Scan(&first, &last)
+	cancel()
+	if err != nil {
+		return 0, fmt.Errorf("error finding events to recompute %v rollups from %v to %v: %w", resolution, startTime, endTime, err)
+	}
+	if !first.Valid {
+		return 0, nil
+	}
+
+	rowsAffected := int64(0)
+	for _, chunk := range recomputeChunks(resolution, first.Int64, last.Int64, endTime) {
+		rows, err := s.recomputeChunk(ctx, resolution, chunk.start, chunk.end)
+		if err != nil {
+			return rowsAffected, err
+		}
+		rowsAffected += rows
+	}
+	return rowsAffected, nil
+}
+
+type chunk struct {
+	start int64
+	// Nil for an open end.
+	end *int64
+}
+
+// Ranges covering the events from first to last, which start on bucket boundaries so that each bucket is computed by
+// a single statement. Without an end time, the last chunk is left open to include events added meanwhile.
+func recomputeChunks(resolution data.Resolution, first int64, last int64, endTime *int64) []chunk {
+	bucketSeconds := resolution.Seconds()
+	chunkSeconds := max(bucketSeconds, recomputeChunkSeconds-recomputeChunkSeconds%bucketSeconds)
+	chunks := []chunk{}
+	for start := resolution.BucketStart(first); start <= last; start += chunkSeconds {
+		end := start + chunkSeconds
+		switch {
+		case endTime != nil && *endTime < end:
+			chunks = append(chunks, chunk{start: start, end: endTime})
+		case endTime == nil && end > last:
+			chunks = append(chunks, chunk{start: start})
+		default:
+			chunks = append(chunks, chunk{start: start, end: &end})
+		}
+	}
+	return chunks
+}
+
+// Recompute rollups of the resolution from events between startTime, which starts a bucket, and endTime if not nil.
+func (s *MySQLRollupStore) recomputeChunk(ctx context.Context, resolution data.Resolution, startTime int64, endTime *int64) (int64, error) {
+	// Build conditions
+	conditions := []string{"numeric_value IS NOT NULL", "event_timestamp >= ?"}
+	args := []any{resolution.Seconds(), startTime}
+	if endTime != nil {
+		conditions = append(conditions, "event_timestamp < ?")
+		args = append(args, *endTime)
+	}
+
+	// Devices repeat their last report until they report again, so identical readings are only counted once.
+	// First and last values are the ends of each bucket's readings ordered by time.
+	// IDs are UUIDv7s like those of other stores, counting rows in their last 48 bits, as allowed for UUIDv7 counters.
+	query := fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		SELECT CONCAT(?, LPAD(LOWER(HEX(ROW_NUMBER() OVER (ORDER BY device_id, field_name, bucket_start))), 12, '0')), buckets.*
+		FROM (
+			SELECT device_id, field_name, ? AS bucket_resolution, bucket_start,
+				COUNT(*) AS bucket_count, MIN(value) AS bucket_min, MAX(value) AS bucket_max, AVG(value) AS bucket_mean,
+				CAST(SUBSTRING_INDEX(GROUP_CONCAT(value ORDER BY reading_timestamp ASC), ',', 1) AS DOUBLE) AS bucket_first,
+				CAST(SUBSTRING_INDEX(GROUP_CONCAT(value ORDER BY reading_timestamp DESC), ',', 1) AS DOUBLE) AS bucket_last,
+				? AS computed_timestamp
+			FROM (
+				SELECT DISTINCT
+					event_source_device_id AS device_id,
+					field_name,
+					event_timestamp AS reading_timestamp,
+					numeric_value AS value,
+					event_timestamp - MOD(event_timestamp, ?) AS bucket_start
+				FROM %s
+				WHERE %s
+			) readings
+			GROUP BY device_id, field_name, bucket_start
+		) buckets
+		ON DUPLICATE KEY UPDATE
+			rollup_count = buckets.bucket_count,
+			rollup_min = buckets.bucket_min,
+			rollup_max = buckets.bucket_max,
+			rollup_mean = buckets.bucket_mean,
+			rollup_first = buckets.bucket_first,
+			rollup_last = buckets.bucket_last,
+			rollup_timestamp = buckets.computed_timestamp
+	`, s.tableName, strings.Join(s.tableColumns, ", "), s.eventsTableName, strings.Join(conditions, " AND "))
+	args = append([]any{rollupIDPrefix(), resolution, utils.TimeSeconds()}, args...)
+
+	sqlctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+	result, err := s.db.ExecContext(sqlctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error recomputing %v rollups from %v to %v: %w", resolution, startTime, endTime, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected while recomputing rollups: %w", err)
+	}
+	return rowsAffected, nil
+}
+
+// The first 80 bits of a new UUIDv7, with its time, version and variant, as formatted before its last 48 bits.
+func rollupIDPrefix() string {
+	id := uuidv7.New().String()
+	return id[:len(id)-12]
+}
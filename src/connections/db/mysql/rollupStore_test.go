@@ -0,0 +1,41 @@
+package mysql
+
+import (
+	"com/data"
+	"testing"
+)
+
+func TestRecomputeChunks(t *testing.T) {
+	const hour int64 = 60 * 60
+	const week = 7 * 24 * hour
+	first := 1700000000 - 1700000000%week + 90
+	last := first + 2*week
+	end := first + week + 5*hour
+
+	chunks := recomputeChunks(data.ResolutionHour, first, last, nil)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %v", chunks)
+	}
+	for i, c := range chunks {
+		if c.start != first-90+int64(i)*week {
+			t.Errorf("chunk %v starts at %v", i, c.start)
+		}
+		if i < 2 && (c.end == nil || *c.end != c.start+week) {
+			t.Errorf("chunk %v does not end a week later: %v", i, c.end)
+		}
+	}
+	if chunks[2].end != nil {
+		t.Errorf("expected the last chunk to be open without an end time, got %v", *chunks[2].end)
+	}
+
+	chunks = recomputeChunks(data.ResolutionHour, first, end-1, &end)
+	if len(chunks) != 2 || *chunks[1].end != end {
+		t.Fatalf("expected 2 chunks ending at %v, got %v", end, chunks)
+	}
+
+	// Daily chunks start on day boundaries
+	chunks = recomputeChunks(data.ResolutionDay, first+3*hour, first+3*hour, nil)
+	if len(chunks) != 1 || chunks[0].start%(24*hour) != 0 {
+		t.Fatalf("expected a single chunk starting a day, got %v", chunks)
+	}
+}
@@ -1,39 +1,124 @@
 package mysql
 
 import (
-	"com/connections/db"
 	"com/data"
 	"com/logs"
 	"com/utils"
 	"context"
 	"database/sql"
-	"encoding/csv"
+	"errors"
 	"fmt"
-	"os"
 	"reflect"
 	"strings"
-	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/samborkent/uuidv7"
 )
 
+// MySQL error numbers for adding a column or index that already exists, which migrations may safely skip.
+const (
+	errDuplicateColumn uint16 = 1060
+	errDuplicateKey    uint16 = 1061
+)
+
 // Generic MySQL Store. Instanatiations require a couple assertions:
 // When returning properties in a list, or doing anything, it must always be in the same order.
 // SQL Queries, anything. All in the same order every time.
 // The ID comes first in this order.
-// The main way this order is coordinated is via the data structs "Spread" and related functions. These methods only respect that order.
+// The main way this order is coordinated is via the data structs' db tags, from which "Spread", related functions and tableColumns are derived.
 type MySQLStore[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F data.Spreadable] struct {
-	db               *sql.DB
+	db               database
 	tableName        string
 	tableCreationSQL string
 	tableColumns     []string
 	primaryKey       string
+	// Migrations run after table creation to bring existing tables up to date with tableCreationSQL, in order.
+	tableMigrations []migration
+}
+
+// A list of statements that is applied once. A migration whose first statement adds a column or index that already
+// exists has been applied before, so the rest of its statements are skipped. Statements that succeed when rerun, such as
+// changing a column's type, need an appliedQuery instead.
+type migration struct {
+	// Optional query selecting a row if the migration was applied before.
+	appliedQuery string
+	// Whether statements run without RequestTimeout, such as backfills of large tables.
+	isLong     bool
+	statements []string
+}
+
+// A query selecting a row if the table's column is NOT NULL.
+func columnNotNullQuery(tableName string, column string) string {
+	return fmt.Sprintf(
+		`SELECT 1 FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = '%s' AND COLUMN_NAME = '%s' AND IS_NULLABLE = 'NO'`,
+		tableName, column,
+	)
+}
+
+// A query selecting a row if the table's column has the data type, as named in information_schema, such as "text".
+func columnTypeQuery(tableName string, column string, dataType string) string {
+	return fmt.Sprintf(
+		`SELECT 1 FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = '%s' AND COLUMN_NAME = '%s' AND DATA_TYPE = '%s'`,
+		tableName, column, dataType,
+	)
+}
+
+// Either a database or a transaction.
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// What stores query: the connection's database, or a transaction within it.
+type database interface {
+	executor
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Run fn within a transaction of the database, committed if fn succeeds and rolled back otherwise.
+// If the database is already a transaction, fn runs within it, and it is left to its owner to commit.
+func inTransaction(ctx context.Context, db database, options *sql.TxOptions, fn func(tx database) error) error {
+	sqlDB, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := sqlDB.BeginTx(ctx, options)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	err = fn(tx)
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			logs.ErrorWithContext(ctx, "error rolling back transaction: %v", rollbackErr)
+		}
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
 }
 
 func (s *MySQLStore[T, S, F]) Add(ctx context.Context, item T) (string, error) {
-	// Build query
+	return s.add(ctx, s.db, item)
+}
+func (s *MySQLStore[T, S, F]) add(ctx context.Context, executor executor, item T) (string, error) {
 	id := uuidv7.New().String()
-	sqlArgs := append([]any{id}, item.Spread()...)
+	err := s.insert(ctx, executor, append([]any{id}, item.Spread()...))
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+func (s *MySQLStore[T, S, F]) Restore(ctx context.Context, storeItem S) error {
+	return s.insert(ctx, s.db, storeItem.Spread())
+}
+
+// Insert a row of values of every column, ID first.
+func (s *MySQLStore[T, S, F]) insert(ctx context.Context, executor executor, values []any) error {
+	// Build query
 	sqlColumns := strings.Join(s.tableColumns, ", ")
 	sqlPlaceholders := strings.Repeat("?, ", len(s.tableColumns)-1) + "?"
 	sqlQuery := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", s.tableName, sqlColumns, sqlPlaceholders)
@@ -41,48 +126,20 @@ func (s *MySQLStore[T, S, F]) Add(ctx context.Context, item T) (string, error) {
 	// Execute query
 	sqlctx, cancel := context.WithTimeout(ctx, RequestTimeout)
 	defer cancel()
-	_, err := s.db.ExecContext(sqlctx, sqlQuery, sqlArgs...)
+	_, err := executor.ExecContext(sqlctx, sqlQuery, values...)
 	if err != nil {
-		return "", fmt.Errorf("error inserting into %s with values %v: %w", s.tableName, item.Spread(), err)
+		return fmt.Errorf("error inserting into %s with values %v: %w", s.tableName, values[1:], err)
 	}
-	return id, nil
+	return nil
 }
 func (s *MySQLStore[T, S, F]) Get(ctx context.Context, filter F) *data.IterablePaginatedData[S] {
-	// Build conditions
-	args := []any{}
-	conditions := []string{}
-	for index, columnName := range s.tableColumns {
-		filterInterface := filter.Spread()[index]
-		filterValue := reflect.ValueOf(filterInterface)
-		if filterValue.IsNil() {
-			continue
-		}
-		conditions = append(conditions, columnName+" = ?")
-		args = append(args, filterInterface)
-	}
-
-	// Combine into query
-	query := "SELECT * FROM " + s.tableName
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
-	}
-
-	// Add pagination condition
-	if len(conditions) > 0 {
-		query += " AND "
-	} else {
-		query += " WHERE "
-	}
-	query += fmt.Sprintf("%v > ? ORDER BY %v LIMIT ?", s.primaryKey, s.primaryKey)
-
-	paginator := newSQLIterablePaginatedData[S](s.db, query, args)
-	return &paginator
+	return s.Find(ctx, data.Query[F]{Filter: filter})
 }
 func (s *MySQLStore[T, S, F]) Delete(ctx context.Context, storeItem S) error {
 	sqlctx, cancel := context.WithTimeout(ctx, RequestTimeout)
 	defer cancel()
 
-	res, err := s.db.ExecContext(sqlctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", s.tableName), storeItem.GetID())
+	res, err := s.db.ExecContext(sqlctx, fmt.Sprintf("DELETE FROM %s WHERE %s = ?", s.tableName, s.primaryKey), storeItem.GetID())
 	if err != nil {
 		return fmt.Errorf("error deleting id %v from table %s: %w", storeItem.GetID(), s.tableName, err)
 	}
@@ -127,97 +184,259 @@ func (s *MySQLStore[T, S, F]) Setup(ctx context.Context, isDestructive bool) err
 	if err != nil {
 		return fmt.Errorf("error creating table %s: %w", s.tableName, err)
 	}
-	return nil
-}
-func (s *MySQLStore[T, S, F]) Export(ctx context.Context, storeItems *data.IterablePaginatedData[S]) error {
-	// Ensure exports directory exists
-	var OwnerReadWriteExecuteAndOthersReadExecute = 0755
-	err := os.MkdirAll(db.EXPORT_DIR, os.FileMode(OwnerReadWriteExecuteAndOthersReadExecute))
-	if err != nil {
-		return fmt.Errorf("error creating export directory: %w", err)
-	}
 
-	// Generate filename
-	now := time.Now().Format("2006-01-02_15-04-05")
-	filename := fmt.Sprintf("%s/%s_%v.csv", db.EXPORT_DIR, s.tableName, now)
-
-	f, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("error creating export file: %w", err)
+	// Migrate table
+	for _, migration := range s.tableMigrations {
+		err := s.migrate(ctx, migration)
+		if err != nil {
+			return err
+		}
 	}
-	defer logs.LogErrorsWithContext(ctx, f.Close, fmt.Sprintf("error closing file %v", filename))
-
-	writer := csv.NewWriter(f)
-	defer writer.Flush()
+	return nil
+}
 
-	// Write CSV header
-	err = writer.Write(s.tableColumns)
-	if err != nil {
-		return fmt.Errorf("error writing CSV header: %w", err)
+// Run the migration's statements in order, each with its own timeout unless the migration is long, unless it was applied before.
+func (s *MySQLStore[T, S, F]) migrate(ctx context.Context, migration migration) error {
+	if migration.appliedQuery != "" {
+		sqlctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+		var applied int
+		err := s.db.QueryRowContext(sqlctx, migration.appliedQuery).Scan(&applied)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("error checking migration of table %s with %v: %w", s.tableName, migration.appliedQuery, err)
+		}
 	}
-
-	// Write each row
-	for {
-		item, err := storeItems.Next(ctx)
-		if err != nil {
-			logs.ErrorWithContext(ctx, "Error while fetching item from %v while exporting: %v", s.tableName, err)
+	for index, statement := range migration.statements {
+		var sqlctx context.Context
+		var cancel context.CancelFunc
+		if migration.isLong {
+			sqlctx, cancel = context.WithCancel(ctx)
+		} else {
+			sqlctx, cancel = context.WithTimeout(ctx, RequestTimeout)
 		}
-		if item == nil {
-			break
+		_, err := s.db.ExecContext(sqlctx, statement)
+		cancel()
+		var mySQLErr *mysql.MySQLError
+		isApplied := errors.As(err, &mySQLErr) && (mySQLErr.Number == errDuplicateColumn || mySQLErr.Number == errDuplicateKey)
+		if index == 0 && isApplied {
+			return nil
 		}
-
-		err = writer.Write((*item).SpreadForExport())
 		if err != nil {
-			logs.ErrorWithContext(ctx, "Error while writing csv row with data %v: %v", item, err)
+			return fmt.Errorf("error migrating table %s with %v: %w", s.tableName, statement, err)
 		}
 	}
-
 	return nil
 }
 
+// Conditions matching the filter's non-nil values, in the order of its columns, and their arguments.
+func (s *MySQLStore[T, S, F]) filterConditions(filter F) ([]string, []any) {
+	args := []any{}
+	conditions := []string{}
+	filterValues := filter.Spread()
+	for index, columnName := range data.Columns[F]() {
+		filterInterface := filterValues[index]
+		filterValue := reflect.ValueOf(filterInterface)
+		if filterValue.IsNil() {
+			continue
+		}
+		if rangeValue, ok := filterInterface.(data.RangeFilterValue); ok {
+			lower, upper := rangeValue.Bounds()
+			if lower != nil {
+				conditions = append(conditions, columnName+" >= ?")
+				args = append(args, lower)
+			}
+			if upper != nil {
+				conditions = append(conditions, columnName+" <= ?")
+				args = append(args, upper)
+			}
+			continue
+		}
+		conditions = append(conditions, columnName+" = ?")
+		args = append(args, filterValue.Elem().Interface())
+	}
+	return conditions, args
+}
+
 type MySQLTimestampedDataStore[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F data.Spreadable] struct {
 	MySQLStore[T, S, F]
 
 	timestampKey string
+	// Numeric column aggregates are computed over. Without one, only counts are supported.
+	valueKey string
+	// Columns holding each group aggregates can be grouped by.
+	groupKeys map[data.AggregateGroup]string
+	// For stores whose rows each summarize several values, such as rollups. Nil when rows are single values.
+	summaryKeys *summaryKeys
+}
+
+// Columns of a row summarizing several values. Aggregates combine the summaries rather than their means alone, and
+// valueKey is the mean.
+type summaryKeys struct {
+	min   string
+	max   string
+	count string
 }
 
 func (s *MySQLTimestampedDataStore[T, S, F]) GetInTimeRange(ctx context.Context, filter F, startTime *int64, endTime *int64) *data.IterablePaginatedData[S] {
-	// Build conditions
-	args := []any{}
-	conditions := []string{}
-	for index, columnName := range s.tableColumns {
-		filterValue := filter.Spread()[index]
-		if filterValue == nil {
-			continue
-		}
-		conditions = append(conditions, columnName+" = ?")
-		args = append(args, filterValue)
+	conditions, args := s.timeRangeConditions(filter, startTime, endTime)
+	paginator, err := s.find(conditions, args, nil, 0, "")
+	if err != nil {
+		return failedPaginatedData[S](err)
 	}
+	return paginator
+}
+
+// Conditions matching the filter's non-nil values and the time range, and their arguments.
+func (s *MySQLTimestampedDataStore[T, S, F]) timeRangeConditions(filter F, startTime *int64, endTime *int64) ([]string, []any) {
+	conditions, args := s.filterConditions(filter)
 	if startTime != nil {
-		conditions = append(conditions, s.timestampKey+" > ?")
-		args = append(args, startTime)
+		conditions = append(conditions, s.timestampKey+" >= ?")
+		args = append(args, *startTime)
 	}
 	if endTime != nil {
 		conditions = append(conditions, s.timestampKey+" < ?")
-		args = append(args, startTime)
+		args = append(args, *endTime)
+	}
+	return conditions, args
+}
+func (s *MySQLTimestampedDataStore[T, S, F]) Aggregate(ctx context.Context, filter F, startTime *int64, endTime *int64, query data.AggregateQuery) ([]data.AggregateResult, error) {
+	err := query.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid aggregate query %v: %w", query, err)
+	}
+	if s.valueKey == "" && query.Function != data.AggregateCount {
+		return nil, fmt.Errorf("table %v has no numeric value, so only supports counts", s.tableName)
 	}
 
-	// Combine into query
-	query := "SELECT * FROM " + s.tableName
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	// Group columns, always selecting both groups so results scan the same way
+	selectedGroups := []string{}
+	for _, group := range []data.AggregateGroup{data.GroupByDevice, data.GroupByField} {
+		// Aliased apart from table columns, which would take precedence in GROUP BY
+		alias := map[data.AggregateGroup]string{data.GroupByDevice: "group_device", data.GroupByField: "group_field"}[group]
+		if !query.IsGroupedBy(group) {
+			selectedGroups = append(selectedGroups, "'' AS "+alias)
+			continue
+		}
+		column, ok := s.groupKeys[group]
+		if !ok {
+			return nil, fmt.Errorf("table %v cannot be grouped by %v", s.tableName, group)
+		}
+		selectedGroups = append(selectedGroups, column+" AS "+alias)
+	}
+	bucket := "0"
+	args := []any{}
+	if query.BucketSeconds > 0 {
+		bucket = fmt.Sprintf("%s - MOD(%s, ?)", s.timestampKey, s.timestampKey)
+		args = append(args, query.BucketSeconds)
 	}
 
-	// Add pagination condition
+	// Rows to aggregate, reduced to their group, bucket and value
+	conditions, conditionArgs := s.timeRangeConditions(filter, startTime, endTime)
+	args = append(args, conditionArgs...)
+	value := "1"
+	if s.valueKey != "" {
+		value = s.valueKey
+		if query.Function != data.AggregateCount {
+			conditions = append(conditions, s.valueKey+" IS NOT NULL")
+		}
+	}
+	// Single values are summaries of one value
+	summary := fmt.Sprintf("%s AS value_min, %s AS value_max, 1 AS value_count", value, value)
+	if s.summaryKeys != nil {
+		summary = fmt.Sprintf("%s AS value_min, %s AS value_max, %s AS value_count", s.summaryKeys.min, s.summaryKeys.max, s.summaryKeys.count)
+	}
+	where := ""
 	if len(conditions) > 0 {
-		query += " AND "
-	} else {
-		query += " WHERE "
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	rowsQuery := fmt.Sprintf("SELECT %s, %s AS bucket_start, %s AS value, %s FROM %s %s",
+		strings.Join(selectedGroups, ", "), bucket, value, summary, s.tableName, where)
+
+	var sqlQuery string
+	switch query.Function {
+	case data.AggregatePercentile:
+		// The lowest value whose rank within its group reaches the percentile. Summaries are ranked by their means.
+		sqlQuery = fmt.Sprintf(`
+			SELECT group_device, group_field, bucket_start, MIN(value), MAX(total)
+			FROM (
+				SELECT group_device, group_field, bucket_start, value,
+					ROW_NUMBER() OVER (PARTITION BY group_device, group_field, bucket_start ORDER BY value) AS position,
+					COUNT(*) OVER (PARTITION BY group_device, group_field, bucket_start) AS total
+				FROM (%s) aggregated_rows
+			) ranked_rows
+			WHERE position >= CEIL(? * total)
+			GROUP BY group_device, group_field, bucket_start
+			ORDER BY group_device, group_field, bucket_start
+		`, rowsQuery)
+		args = append(args, query.Percentile)
+	default:
+		// Combine the summaries, weighting means by their counts
+		aggregate := map[data.AggregateFunction]string{
+			data.AggregateMin:   "MIN(value_min)",
+			data.AggregateMax:   "MAX(value_max)",
+			data.AggregateSum:   "SUM(value * value_count)",
+			data.AggregateAvg:   "SUM(value * value_count) / SUM(value_count)",
+			data.AggregateCount: "SUM(value_count)",
+		}[query.Function]
+		sqlQuery = fmt.Sprintf(`
+			SELECT group_device, group_field, bucket_start, %s, SUM(value_count)
+			FROM (%s) aggregated_rows
+			GROUP BY group_device, group_field, bucket_start
+			ORDER BY group_device, group_field, bucket_start
+		`, aggregate, rowsQuery)
 	}
-	query += fmt.Sprintf("%v > ? ORDER BY %v LIMIT ?", s.primaryKey, s.primaryKey)
 
-	paginator := newSQLIterablePaginatedData[S](s.db, query, args)
-	return &paginator
+	// Execute query
+	sqlctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+	rows, err := s.db.QueryContext(sqlctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error running aggregate query %v with args %v: %w", sqlQuery, args, err)
+	}
+	defer logs.LogErrorsWithContext(ctx, rows.Close, fmt.Sprintf("error closing rows for aggregate query %v", sqlQuery))
+	results := []data.AggregateResult{}
+	for rows.Next() {
+		var result data.AggregateResult
+		err = rows.Scan(&result.DeviceID, &result.FieldName, &result.BucketStart, &result.Value, &result.Count)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning aggregate result: %w", err)
+		}
+		results = append(results, result)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error in aggregate rows: %w", err)
+	}
+	return results, nil
+}
+func (s *MySQLTimestampedDataStore[T, S, F]) DeleteBefore(ctx context.Context, filter F, where data.Expression, endTime int64, limit int) (int64, error) {
+	conditions, args := s.filterConditions(filter)
+	if where != nil {
+		condition, expressionArgs, err := s.expressionSQL(where)
+		if err != nil {
+			return 0, fmt.Errorf("invalid deletion from %v: %w", s.tableName, err)
+		}
+		conditions = append(conditions, condition)
+		args = append(args, expressionArgs...)
+	}
+	conditions = append(conditions, s.timestampKey+" < ?")
+	args = append(args, endTime, limit)
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT ?", s.tableName, strings.Join(conditions, " AND "))
+
+	sqlctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+	result, err := s.db.ExecContext(sqlctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting from %s with args %v: %w", s.tableName, args, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected while deleting from %v: %w", s.tableName, err)
+	}
+	return rowsAffected, nil
 }
 
 type MySQLEditableStore[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F data.Spreadable] struct {
@@ -225,7 +444,7 @@ type MySQLEditableStore[T data.Spreadable, S data.HasIDGetterAndSpreadable[S], F
 }
 
 func (s *MySQLEditableStore[T, S, F]) Edit(ctx context.Context, storeItem S) error {
-	sqlEdits := strings.TrimSuffix(strings.Join(s.tableColumns, " = ?, "), ", ")
+	sqlEdits := strings.Join(s.tableColumns, " = ?, ") + " = ?"
 
 	sqlctx, cancel := context.WithTimeout(ctx, RequestTimeout)
 	defer cancel()
@@ -281,23 +500,27 @@ func (s *MySQLClosableStore[T, S, F]) Close(ctx context.Context, storeItem S) er
 	return nil
 }
 
-// Helper function for get methods.
-func newSQLIterablePaginatedData[T data.HasIDGetterAndSpreadable[T]](db *sql.DB, query string, args []any) data.IterablePaginatedData[T] {
+// Helper function for get methods. pageQuery builds the query and arguments for the page after the position of the
+// last item, which is nil for the first page, or reports that there are no more pages. Pagination resumes from the
+// cursor if it is not empty.
+func newSQLIterablePaginatedData[T data.HasIDGetterAndSpreadable[T]](db database, fingerprint string, cursor string, position func(T) string, pageQuery func(lastPosition *string) (string, []any, bool, error)) (*data.IterablePaginatedData[T], error) {
 	// Define pagination function
-	return data.NewIterablePaginatedData(
-		func(ctx context.Context, lastID *string) ([]T, *string, error) {
-			// Peform query. First query has no pagination filter, subsequent queries use the last id from the previous filter
-			var filterID string
-			if lastID != nil {
-				filterID = *lastID
+	paginator, err := data.ResumeIterablePaginatedData(
+		func(ctx context.Context, lastPosition *string) ([]T, *string, error) {
+			query, args, hasPage, err := pageQuery(lastPosition)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !hasPage {
+				return []T{}, nil, nil
 			}
 			context, cancel := context.WithTimeout(ctx, RequestTimeout)
 			defer cancel()
-			rows, err := db.QueryContext(context, query, append(args, filterID, data.PAGE_SIZE)...)
+			rows, err := db.QueryContext(context, query, args...)
 			if err != nil {
-				return nil, nil, fmt.Errorf("error running query %v with args %v: %w", query, append(args, filterID, data.PAGE_SIZE), err)
+				return nil, nil, fmt.Errorf("error running query %v with args %v: %w", query, args, err)
 			}
-			defer logs.LogErrorsWithContext(ctx, rows.Close, fmt.Sprintf("error closing rows for query %v and lastID %v", query, lastID))
+			defer logs.LogErrorsWithContext(ctx, rows.Close, fmt.Sprintf("error closing rows for query %v and args %v", query, args))
 
 			// Scan all results into the next "page" of data to store
 			var items []T
@@ -317,9 +540,23 @@ func newSQLIterablePaginatedData[T data.HasIDGetterAndSpreadable[T]](db *sql.DB,
 			if len(items) == 0 {
 				return []T{}, nil, nil
 			}
-			lastItem := items[len(items)-1]
-			id := lastItem.GetID()
-			return items, &id, nil
+			nextPosition := position(items[len(items)-1])
+			return items, &nextPosition, nil
 		},
+		fingerprint,
+		cursor,
+		position,
 	)
+	if err != nil {
+		return nil, fmt.Errorf("error resuming from cursor %v: %w", cursor, err)
+	}
+	return &paginator, nil
+}
+
+// Paginated data whose first page fails with the error, for queries that are invalid before they run.
+func failedPaginatedData[T data.HasIDGetterAndSpreadable[T]](err error) *data.IterablePaginatedData[T] {
+	paginator := data.NewIterablePaginatedData(func(ctx context.Context, lastID *string) ([]T, *string, error) {
+		return nil, nil, err
+	}, "")
+	return &paginator
 }
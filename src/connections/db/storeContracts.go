@@ -13,16 +13,21 @@ const EXPORT_DIR string = "../export"
 type GenericStore[T any, S data.HasIDGetter, F any] interface {
 	// Add the object, return the ID.
 	Add(context context.Context, item T) (string, error)
+	// Add the store item as is, keeping its ID, as when restoring a backup. Errors if an item with the ID exists.
+	// Unlike Add, nothing else is updated, such as the device state of events.
+	Restore(context context.Context, storeItem S) error
 	// Fully remove the given item.
 	Delete(context context.Context, storeItem S) error
 	// Data is lazily fetched, so there is no error returned from the getter, which merely sets up the query.
 	Get(context context.Context, filter F) *data.IterablePaginatedData[S]
+	// Like Get, with expressions beyond the filter's exact matches, ordering and a limit. Invalid queries fail on the first Next.
+	Find(context context.Context, query data.Query[F]) *data.IterablePaginatedData[S]
+	// Like Find, continuing after the position of a cursor from the Cursor of an earlier Find with the same query.
+	// Errors if the query is invalid, or the cursor is malformed or was issued for a different query.
+	FindFromCursor(context context.Context, query data.Query[F], cursor string) (*data.IterablePaginatedData[S], error)
 	// Create the objects necessary to store data.
 	// if isDestructive is false, tables or data should not be destroyed.
 	Setup(context context.Context, isDestructive bool) error
-	// Export all rows that match the given filter into a csv file into /exports at the root directory of the project (1 above src).
-	// Names should follow [export date]_[data label].csv.
-	Export(context context.Context, storeItems *data.IterablePaginatedData[S]) error
 }
 
 type EditableStore[T any, S data.HasIDGetter, F any] interface {
@@ -35,7 +40,14 @@ type EditableStore[T any, S data.HasIDGetter, F any] interface {
 type TimestampedDataStore[T any, S data.HasIDGetter, F any] interface {
 	GenericStore[T, S, F]
 	// Data is lazily fetched, so there is no error returned from the getter, which merely sets up the query.
+	// startTime is inclusive and endTime is exclusive. Either may be nil for an open range.
 	GetInTimeRange(context context.Context, filter F, startTime *int64, endTime *int64) *data.IterablePaginatedData[S]
+	// Delete at most limit items matching the filter, and the expression if not nil, from before endTime (exclusive),
+	// returning how many were deleted. Deleting in bounded batches keeps locks short on large tables.
+	DeleteBefore(context context.Context, filter F, where data.Expression, endTime int64, limit int) (int64, error)
+	// Aggregate the store's numeric value over items matching the filter between startTime (inclusive) and endTime (exclusive).
+	// Results are ordered by group and then time.
+	Aggregate(context context.Context, filter F, startTime *int64, endTime *int64, query data.AggregateQuery) ([]data.AggregateResult, error)
 }
 
 // Stores that have ongoing anc closable events that can be ended.
@@ -51,6 +63,10 @@ type DeviceStore interface {
 
 type EventStore interface {
 	TimestampedDataStore[data.Event, data.StoreEvent, data.EventFilter]
+	// The numeric field of the device between startTime (inclusive) and endTime (exclusive), as raw readings or rollups
+	// depending on the length of the range. Rollups cover whole buckets, so the first may start before startTime.
+	// Returns the resolution used.
+	GetSeries(context context.Context, deviceID string, fieldName string, startTime int64, endTime int64) ([]data.SeriesPoint, data.Resolution, error)
 }
 
 type LogStore interface {
@@ -61,3 +77,36 @@ type JobStore interface {
 	TimestampedDataStore[data.Job, data.StoreJob, data.JobFilter]
 	ClosableStore[data.Job, data.StoreJob, data.JobFilter]
 }
+
+type AlertStateStore interface {
+	EditableStore[data.AlertState, data.StoreAlertState, data.AlertStateFilter]
+}
+
+type DeliveryStore interface {
+	TimestampedDataStore[data.Delivery, data.StoreDelivery, data.DeliveryFilter]
+}
+
+type BatteryStore interface {
+	TimestampedDataStore[data.BatteryReading, data.StoreBatteryReading, data.BatteryReadingFilter]
+}
+
+// The latest value of every field of every device, kept up to date as events are added.
+type DeviceStateStore interface {
+	GenericStore[data.DeviceState, data.StoreDeviceState, data.DeviceStateFilter]
+	// The latest state of every device, keyed by device ID.
+	GetSnapshot(context context.Context) (map[string]data.DeviceSnapshot, error)
+}
+
+// How far each named incremental export has got.
+type ExportMarkStore interface {
+	EditableStore[data.ExportMark, data.StoreExportMark, data.ExportMarkFilter]
+}
+
+// Hourly and daily aggregates of numeric event fields.
+type RollupStore interface {
+	TimestampedDataStore[data.Rollup, data.StoreRollup, data.RollupFilter]
+	// Recompute rollups of the resolution from events between startTime (inclusive) and endTime (exclusive).
+	// Nil times leave the range open. Returns the number of affected rows. Long ranges are recomputed in chunks of
+	// whole buckets, so on error the rollups of earlier chunks are already updated.
+	Recompute(context context.Context, resolution data.Resolution, startTime *int64, endTime *int64) (int64, error)
+}
@@ -10,11 +10,13 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
-const TOKEN_URL = "https://api.yosmart.com/open/yolink/token"
-const API_URL = "https://api.yosmart.com/open/yolink/v2/api"
+const DEFAULT_BASE_URL = "https://api.yosmart.com"
+const TOKEN_PATH = "/open/yolink/token"
+const API_PATH = "/open/yolink/v2/api"
 const TOKEN_REFRESH_BUFFER_MINUTES = 30
 const YOLINK_BRAND_NAME = "yolink"
 
@@ -38,6 +40,7 @@ var ErrYoLinkAPIError *YoLinkAPIError = new(YoLinkAPIError)
 var _ SensorConnection = (*YoLinkConnection)(nil)
 
 type YoLinkConnection struct {
+	baseURL             string
 	userId              string
 	userKey             string
 	accessToken         string
@@ -46,7 +49,13 @@ type YoLinkConnection struct {
 }
 
 func NewYoLinkConnection(ctx context.Context, userId string, userKey string) (*YoLinkConnection, error) {
+	return NewYoLinkConnectionWithBaseURL(ctx, DEFAULT_BASE_URL, userId, userKey)
+}
+
+// Connect to a YoLink API at the base URL rather than YoLink's own, such as a fake server.
+func NewYoLinkConnectionWithBaseURL(ctx context.Context, baseURL string, userId string, userKey string) (*YoLinkConnection, error) {
 	c := &YoLinkConnection{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
 		userId:  userId,
 		userKey: userKey,
 	}
@@ -81,7 +90,7 @@ func (c *YoLinkConnection) Open(ctx context.Context) error {
 	if !hasToken || isTokenExpired {
 		logs.DebugWithContext(ctx, "creating YoLink token")
 		response, err = utils.PostForm[AuthenticationResponse](ctx,
-			TOKEN_URL,
+			c.baseURL+TOKEN_PATH,
 			map[string]string{
 				"grant_type":    "client_credentials",
 				"client_id":     c.userId,
@@ -125,6 +134,9 @@ func (c *YoLinkConnection) GetDeviceState(ctx context.Context, device *data.Stor
 	}
 	// Make request
 	deviceState, err := MakeYoLinkRequest[BUDP](ctx, c, SimpleBDDP{Method: YoLinkMethod(device.Kind + ".getState"), TargetDevice: &device.BrandID, Token: &device.Token})
+	if err != nil {
+		return nil, fmt.Errorf("error while quering device: %w", err)
+	}
 	if deviceState == nil {
 		return nil, errors.New("YoLink request was malformed and could not be read")
 	}
@@ -134,9 +146,6 @@ func (c *YoLinkConnection) GetDeviceState(ctx context.Context, device *data.Stor
 			Description: fmt.Sprintf("device %v (name: %v) in connection %v at time %v", device.BrandID, device.Name, c, utils.TimeSeconds()),
 		}
 	}
-	if err != nil {
-		return nil, fmt.Errorf("error while quering device: %w", err)
-	}
 
 	// Process response
 	dataMap, err := utils.ToMap[any](deviceState.Data)
@@ -172,8 +181,7 @@ func (c *YoLinkConnection) GetDeviceState(ctx context.Context, device *data.Stor
 			ResponseTimestamp:   deviceState.Time / 1000, // Convert to seconds
 			EventTimestamp:      eventTimestamp.Unix(),
 			FieldName:           pair.K,
-			FieldValue:          pair.V,
-		})
+		}.WithValue(pair.Raw))
 	}
 	return events, nil
 }
@@ -197,7 +205,7 @@ func MakeYoLinkRequest[T any](ctx context.Context, c *YoLinkConnection, simpleBD
 		"Content-Type":  "application/json",
 		"Authorization": fmt.Sprintf("Bearer %v", c.accessToken),
 	}
-	response, err := utils.PostJson[T](ctx, API_URL, headers, BDDPMap)
+	response, err := utils.PostJson[T](ctx, c.baseURL+API_PATH, headers, BDDPMap)
 	if err != nil {
 		return nil, fmt.Errorf("error making request with body %v and headers %v: %w", BDDPMap, headers, err)
 	}
@@ -212,27 +220,29 @@ func (c *YoLinkConnection) UpdateManagedDevices(ctx context.Context, dbConnectio
 	if result == nil {
 		return errors.New("YoLink device list null without associated error")
 	}
+	if result.Code != "000000" {
+		return &YoLinkAPIError{Code: result.Code, Description: fmt.Sprintf("device list in connection %v", c)}
+	}
+	if result.Data == nil {
+		return errors.New("YoLink device list missing data")
+	}
 
 	// Store unique devices
 	numDevicesAdded := 0
+	brand := YOLINK_BRAND_NAME
 	for _, device := range result.Data.Devices {
-		// Check if device exists
-		existingDevices := dbConnection.Devices().Get(ctx, data.DeviceFilter{ID: &device.DeviceID})
-		firstItem, err := existingDevices.Next(ctx)
-		if err != nil {
-			return fmt.Errorf("error getting first item: %w", err)
-		}
-		secondItem, err := existingDevices.Next(ctx)
+		// Check if device exists. Stored IDs are generated, so match on YoLink's ID
+		existingDevices, err := data.Collect(data.Take(dbConnection.Devices().Get(ctx, data.DeviceFilter{BrandID: &device.DeviceID, Brand: &brand}).All(ctx), 2))
 		if err != nil {
-			return fmt.Errorf("error getting second item: %w", err)
+			return fmt.Errorf("error getting existing devices: %w", err)
 		}
 
 		// Duplicates exist
-		if firstItem != nil && secondItem != nil {
+		if len(existingDevices) > 1 {
 			logs.WarnWithContext(ctx, "Device with ID %v has duplicate entries!", device.DeviceID)
 		}
 		// Item already exists
-		if firstItem != nil {
+		if len(existingDevices) > 0 {
 			continue
 		}
 
@@ -244,6 +254,7 @@ func (c *YoLinkConnection) UpdateManagedDevices(ctx context.Context, dbConnectio
 			Token:     device.Token,
 			BrandID:   device.DeviceID,
 			Timestamp: utils.TimeSeconds(),
+			Status:    data.DeviceUnknown,
 		})
 		if err != nil {
 			return fmt.Errorf("error adding device %v: %w", device, err)
@@ -257,7 +268,7 @@ func (c *YoLinkConnection) UpdateManagedDevices(ctx context.Context, dbConnectio
 // Refresh the current token. Requires an existing token to exist.
 func (c *YoLinkConnection) refreshCurrentToken(ctx context.Context) error {
 	response, err := utils.PostForm[AuthenticationResponse](ctx,
-		TOKEN_URL,
+		c.baseURL+TOKEN_PATH,
 		map[string]string{
 			"grant_type":    "refresh_token",
 			"client_id":     c.userId,
@@ -0,0 +1,83 @@
+package yolinkfake
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"time"
+)
+
+// Device states recorded from the YoLink API, by device kind.
+//
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// A device served by the fake, with the state its getState method reports.
+type Device struct {
+	DeviceID string
+	Name     string
+	// YoLink's device type, e.g. THSensor, which prefixes the device's methods.
+	Kind  string
+	Token string
+	State map[string]any
+	// When the state was reported. Zero reports the server's start time, or the current time in live mode.
+	ReportAt time.Time
+}
+
+// Recorded state of the device kind, such as THSensor, LeakSensor, DoorSensor or MotionSensor.
+func FixtureState(kind string) (map[string]any, error) {
+	content, err := fixtures.ReadFile("fixtures/" + kind + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixture for device kind %v: %w", kind, err)
+	}
+	state := map[string]any{}
+	err = json.Unmarshal(content, &state)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding fixture for device kind %v: %w", kind, err)
+	}
+	return state, nil
+}
+
+// One device of each recorded kind.
+func DefaultDevices() []Device {
+	devices := []Device{}
+	for i, kind := range []string{"THSensor", "LeakSensor", "DoorSensor", "MotionSensor"} {
+		// Fixtures are embedded, so failing to read them is a programming error
+		state, err := FixtureState(kind)
+		if err != nil {
+			panic(err)
+		}
+		devices = append(devices, Device{
+			DeviceID: fmt.Sprintf("d88b4c01000%v", 1000+i),
+			Name:     fmt.Sprintf("Simulated %v", kind),
+			Kind:     kind,
+			Token:    fmt.Sprintf("token-%v", kind),
+			State:    state,
+		})
+	}
+	return devices
+}
+
+// Amplitudes of daily cycles applied to numeric readings in live mode.
+var liveAmplitudes = map[string]float64{
+	"temperature":    3,
+	"humidity":       5,
+	"devTemperature": 2,
+}
+
+// The state with readings following daily cycles, offset by the phase so devices differ.
+func liveState(state map[string]any, now time.Time, phase float64) map[string]any {
+	live := maps.Clone(state)
+	dayFraction := float64(now.Unix()%86400) / 86400
+	for key, amplitude := range liveAmplitudes {
+		value, ok := live[key].(float64)
+		if !ok {
+			continue
+		}
+		value += amplitude * math.Sin(2*math.Pi*dayFraction+phase)
+		live[key] = math.Round(value*10) / 10
+	}
+	return live
+}
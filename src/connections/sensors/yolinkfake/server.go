@@ -0,0 +1,347 @@
+// Fake YoLink API serving recorded device states, for running without YoLink's servers, such as in tests and simulation.
+// It issues expiring tokens, serves Home.getDeviceList and the getState method of each device, and responds with
+// YoLink's error codes for invalid tokens, unknown devices, injected failures and exceeded rate limits.
+package yolinkfake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultClientID      = "fake-client"
+	DefaultClientSecret  = "fake-secret"
+	DefaultTokenLifetime = 2 * time.Hour
+
+	TokenPath = "/open/yolink/token"
+	APIPath   = "/open/yolink/v2/api"
+)
+
+// Codes of YoLink API responses.
+const (
+	CodeSuccess            = "000000"
+	CodeTokenInvalid       = "000103"
+	CodeDeviceUnreachable  = "000201"
+	CodeMethodNotSupported = "010203"
+	CodeTokenExpired       = "010104"
+	CodeRateLimited        = "010301"
+)
+
+type Config struct {
+	// Credentials accepted by the token endpoint. Default to DefaultClientID and DefaultClientSecret.
+	ClientID     string
+	ClientSecret string
+	Devices      []Device
+	// How long access tokens last. Defaults to DefaultTokenLifetime.
+	TokenLifetime time.Duration
+	// API requests allowed in any minute before rate limiting. Zero does not limit.
+	RequestsPerMinute int
+	// Whether states are reported at the current time, with readings following daily cycles.
+	Live bool
+	// Current time. Defaults to time.Now, and can be replaced to expire tokens in tests.
+	Now func() time.Time
+}
+
+type Server struct {
+	config    Config
+	server    *httptest.Server
+	startTime time.Time
+
+	mu sync.Mutex
+	// Expiration times by access token, and the refresh tokens that are still valid.
+	accessTokens  map[string]time.Time
+	refreshTokens map[string]bool
+	// Codes to respond with instead of device states, by device ID.
+	failures map[string]string
+	// Times of API requests within the last minute.
+	requestTimes []time.Time
+}
+
+// Start serving on a local port. Close stops the server.
+func NewServer(config Config) *Server {
+	if config.ClientID == "" {
+		config.ClientID = DefaultClientID
+	}
+	if config.ClientSecret == "" {
+		config.ClientSecret = DefaultClientSecret
+	}
+	if config.TokenLifetime == 0 {
+		config.TokenLifetime = DefaultTokenLifetime
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	s := &Server{
+		config:        config,
+		startTime:     config.Now(),
+		accessTokens:  map[string]time.Time{},
+		refreshTokens: map[string]bool{},
+		failures:      map[string]string{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+TokenPath, s.handleToken)
+	mux.HandleFunc("POST "+APIPath, s.handleAPI)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// Base URL to connect to in place of YoLink's.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+func (s *Server) ClientID() string {
+	return s.config.ClientID
+}
+
+func (s *Server) ClientSecret() string {
+	return s.config.ClientSecret
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Respond to the device's getState with the code until cleared with an empty code.
+func (s *Server) FailDevice(deviceID string, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if code == "" {
+		delete(s.failures, deviceID)
+		return
+	}
+	s.failures[deviceID] = code
+}
+
+// Replace the state the device reports. Returns false if there is no such device.
+func (s *Server) SetState(deviceID string, state map[string]any, reportAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.config.Devices {
+		if s.config.Devices[i].DeviceID == deviceID {
+			s.config.Devices[i].State = state
+			s.config.Devices[i].ReportAt = reportAt
+			return true
+		}
+	}
+	return false
+}
+
+// Expire every access token issued so far, as if their lifetimes had passed. Refresh tokens stay valid.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token := range s.accessTokens {
+		s.accessTokens[token] = time.Time{}
+	}
+}
+
+type tokenResponse struct {
+	AccessToken  string   `json:"access_token"`
+	RefreshToken string   `json:"refresh_token"`
+	ExpiresIn    int      `json:"expires_in"`
+	TokenType    string   `json:"token_type"`
+	Scope        []string `json:"scope"`
+}
+
+// Grant tokens for client credentials, or exchange a refresh token for new ones.
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("client_id") != s.config.ClientID {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.PostForm.Get("grant_type") {
+	case "client_credentials":
+		if r.PostForm.Get("client_secret") != s.config.ClientSecret {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+			return
+		}
+	case "refresh_token":
+		refreshToken := r.PostForm.Get("refresh_token")
+		if !s.refreshTokens[refreshToken] {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		delete(s.refreshTokens, refreshToken)
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	response := tokenResponse{
+		AccessToken:  newToken(),
+		RefreshToken: newToken(),
+		ExpiresIn:    int(s.config.TokenLifetime.Seconds()),
+		TokenType:    "bearer",
+		Scope:        []string{"create"},
+	}
+	s.accessTokens[response.AccessToken] = s.config.Now().Add(s.config.TokenLifetime)
+	s.refreshTokens[response.RefreshToken] = true
+	writeJSON(w, http.StatusOK, response)
+}
+
+type request struct {
+	Method       string  `json:"method"`
+	TargetDevice *string `json:"targetDevice"`
+	Token        *string `json:"token"`
+}
+
+type response struct {
+	Time   int64  `json:"time"`
+	Method string `json:"method"`
+	MsgID  int64  `json:"msgid"`
+	Code   string `json:"code"`
+	Desc   string `json:"desc"`
+	Data   any    `json:"data,omitempty"`
+}
+
+// Serve a BDDP request. As with YoLink, errors are reported through codes in successful responses.
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	var body request
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.config.Now()
+	reply := func(code string, desc string, data any) {
+		writeJSON(w, http.StatusOK, response{Time: now.UnixMilli(), Method: body.Method, MsgID: now.UnixMilli(), Code: code, Desc: desc, Data: data})
+	}
+
+	// Authorize
+	accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	expiration, isIssued := s.accessTokens[accessToken]
+	if !ok || !isIssued {
+		reply(CodeTokenInvalid, "Token is invalid", nil)
+		return
+	}
+	if !now.Before(expiration) {
+		reply(CodeTokenExpired, "Token is expired", nil)
+		return
+	}
+
+	// Rate limit
+	if s.isRateLimited(now) {
+		reply(CodeRateLimited, "Access denied due to reaching limit", nil)
+		return
+	}
+
+	// Methods
+	if body.Method == "Home.getDeviceList" {
+		reply(CodeSuccess, "Success", s.deviceList())
+		return
+	}
+	kind, isGetState := strings.CutSuffix(body.Method, ".getState")
+	if !isGetState {
+		reply(CodeMethodNotSupported, "Method is not supported", nil)
+		return
+	}
+	index := s.deviceIndex(body.TargetDevice, body.Token)
+	if index < 0 || s.config.Devices[index].Kind != kind {
+		reply(CodeDeviceUnreachable, "Cannot connect to the device", nil)
+		return
+	}
+	code, isFailing := s.failures[s.config.Devices[index].DeviceID]
+	if isFailing {
+		reply(code, "Injected failure", nil)
+		return
+	}
+	reply(CodeSuccess, "Success", s.stateOf(index, now))
+}
+
+// Record the request, returning whether it exceeds the requests allowed in the last minute.
+func (s *Server) isRateLimited(now time.Time) bool {
+	if s.config.RequestsPerMinute == 0 {
+		return false
+	}
+	recent := []time.Time{}
+	for _, requestTime := range s.requestTimes {
+		if now.Sub(requestTime) < time.Minute {
+			recent = append(recent, requestTime)
+		}
+	}
+	s.requestTimes = recent
+	if len(recent) >= s.config.RequestsPerMinute {
+		return true
+	}
+	s.requestTimes = append(s.requestTimes, now)
+	return false
+}
+
+func (s *Server) deviceList() map[string]any {
+	devices := []map[string]any{}
+	for _, device := range s.config.Devices {
+		devices = append(devices, map[string]any{
+			"deviceId":   device.DeviceID,
+			"deviceUDID": device.DeviceID + "-udid",
+			"name":       device.Name,
+			"token":      device.Token,
+			"type":       device.Kind,
+		})
+	}
+	return map[string]any{"devices": devices}
+}
+
+// Index of the device with the ID if the token is its own, otherwise -1.
+func (s *Server) deviceIndex(deviceID *string, token *string) int {
+	if deviceID == nil || token == nil {
+		return -1
+	}
+	for i, device := range s.config.Devices {
+		if device.DeviceID == *deviceID && device.Token == *token {
+			return i
+		}
+	}
+	return -1
+}
+
+// The getState data of the device at the index. In live mode the index offsets the device's daily cycles.
+func (s *Server) stateOf(index int, now time.Time) map[string]any {
+	device := s.config.Devices[index]
+	state := device.State
+	reportAt := device.ReportAt
+	if s.config.Live {
+		state = liveState(state, now, float64(index))
+		if reportAt.IsZero() {
+			reportAt = now
+		}
+	}
+	if reportAt.IsZero() {
+		reportAt = s.startTime
+	}
+	return map[string]any{
+		"online":   true,
+		"state":    state,
+		"deviceId": device.DeviceID,
+		"reportAt": reportAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func newToken() string {
+	bytes := make([]byte, 16)
+	_, _ = rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
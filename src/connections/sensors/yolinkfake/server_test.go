@@ -0,0 +1,178 @@
+package yolinkfake_test
+
+import (
+	"com/connections/sensors"
+	"com/connections/sensors/yolinkfake"
+	"com/data"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var reportAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newServer(t *testing.T, config yolinkfake.Config) *yolinkfake.Server {
+	t.Helper()
+	if config.Devices == nil {
+		config.Devices = yolinkfake.DefaultDevices()
+	}
+	server := yolinkfake.NewServer(config)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func storeDevice(device yolinkfake.Device) *data.StoreDevice {
+	return &data.StoreDevice{
+		HasID:  data.HasID{ID: "store-" + device.DeviceID},
+		Device: data.Device{BrandID: device.DeviceID, Brand: sensors.YOLINK_BRAND_NAME, Kind: device.Kind, Name: device.Name, Token: device.Token},
+	}
+}
+
+// The connection reads the recorded state of a device as events at its report time.
+func TestGetDeviceState(t *testing.T) {
+	server := newServer(t, yolinkfake.Config{})
+	ctx := context.Background()
+	connection, err := sensors.NewYoLinkConnectionWithBaseURL(ctx, server.URL(), server.ClientID(), server.ClientSecret())
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := yolinkfake.DefaultDevices()[0]
+	server.SetState(device.DeviceID, map[string]any{"temperature": 21.5, "state": "normal"}, reportAt)
+	events, err := connection.GetDeviceState(ctx, storeDevice(device))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, event := range events {
+		if event.EventTimestamp != reportAt.Unix() {
+			t.Errorf("expected events at %v, got %v", reportAt.Unix(), event.EventTimestamp)
+		}
+		values[event.FieldName] = event.FieldValue
+	}
+	if values["state.temperature"] != "21.5" || values["state.state"] != "normal" || values["deviceId"] != device.DeviceID {
+		t.Fatalf("unexpected events %v", values)
+	}
+}
+
+func TestInjectedFailures(t *testing.T) {
+	server := newServer(t, yolinkfake.Config{})
+	ctx := context.Background()
+	connection, err := sensors.NewYoLinkConnectionWithBaseURL(ctx, server.URL(), server.ClientID(), server.ClientSecret())
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := yolinkfake.DefaultDevices()[1]
+	server.FailDevice(device.DeviceID, yolinkfake.CodeDeviceUnreachable)
+	_, err = connection.GetDeviceState(ctx, storeDevice(device))
+	var apiError *sensors.YoLinkAPIError
+	if !errors.As(err, &apiError) || apiError.Code != yolinkfake.CodeDeviceUnreachable {
+		t.Fatalf("expected the injected code, got %v", err)
+	}
+	server.FailDevice(device.DeviceID, "")
+	_, err = connection.GetDeviceState(ctx, storeDevice(device))
+	if err != nil {
+		t.Fatalf("expected the failure to be cleared, got %v", err)
+	}
+
+	wrongToken := storeDevice(device)
+	wrongToken.Token = "stolen"
+	_, err = connection.GetDeviceState(ctx, wrongToken)
+	if !errors.As(err, &apiError) || apiError.Code != yolinkfake.CodeDeviceUnreachable {
+		t.Fatalf("expected devices to require their own token, got %v", err)
+	}
+}
+
+func TestRejectsInvalidCredentials(t *testing.T) {
+	server := newServer(t, yolinkfake.Config{})
+	_, err := sensors.NewYoLinkConnectionWithBaseURL(context.Background(), server.URL(), server.ClientID(), "wrong")
+	if err == nil {
+		t.Fatal("expected an error connecting with the wrong secret")
+	}
+}
+
+// Raw API responses, for the codes the connection does not distinguish.
+func TestTokensAndRateLimits(t *testing.T) {
+	now := reportAt
+	server := newServer(t, yolinkfake.Config{
+		RequestsPerMinute: 2,
+		TokenLifetime:     time.Hour,
+		Now:               func() time.Time { return now },
+	})
+	accessToken, refreshToken := requestToken(t, server, url.Values{"grant_type": {"client_credentials"}, "client_secret": {server.ClientSecret()}})
+
+	if code := getDeviceList(t, server, "unknown"); code != yolinkfake.CodeTokenInvalid {
+		t.Errorf("expected an invalid token, got %v", code)
+	}
+	if code := getDeviceList(t, server, accessToken); code != yolinkfake.CodeSuccess {
+		t.Errorf("expected success, got %v", code)
+	}
+	if code := getDeviceList(t, server, accessToken); code != yolinkfake.CodeSuccess {
+		t.Errorf("expected success, got %v", code)
+	}
+	if code := getDeviceList(t, server, accessToken); code != yolinkfake.CodeRateLimited {
+		t.Errorf("expected the third request in a minute to be rate limited, got %v", code)
+	}
+
+	now = now.Add(time.Hour)
+	if code := getDeviceList(t, server, accessToken); code != yolinkfake.CodeTokenExpired {
+		t.Errorf("expected the token to expire after its lifetime, got %v", code)
+	}
+	refreshedToken, _ := requestToken(t, server, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+	if code := getDeviceList(t, server, refreshedToken); code != yolinkfake.CodeSuccess {
+		t.Errorf("expected success with the refreshed token, got %v", code)
+	}
+	server.ExpireTokens()
+	if code := getDeviceList(t, server, refreshedToken); code != yolinkfake.CodeTokenExpired {
+		t.Errorf("expected expired tokens, got %v", code)
+	}
+}
+
+func requestToken(t *testing.T, server *yolinkfake.Server, form url.Values) (string, string) {
+	t.Helper()
+	form.Set("client_id", server.ClientID())
+	response, err := http.PostForm(server.URL()+yolinkfake.TokenPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected a token, got status %v", response.Status)
+	}
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body.AccessToken, body.RefreshToken
+}
+
+// The response code of Home.getDeviceList with the access token.
+func getDeviceList(t *testing.T, server *yolinkfake.Server, accessToken string) string {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodPost, server.URL()+yolinkfake.APIPath, strings.NewReader(`{"method":"Home.getDeviceList"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var body struct {
+		Code string `json:"code"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body.Code
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+type AggregateFunction string
+
+const (
+	AggregateMin        AggregateFunction = "MIN"
+	AggregateMax        AggregateFunction = "MAX"
+	AggregateAvg        AggregateFunction = "AVG"
+	AggregateSum        AggregateFunction = "SUM"
+	AggregateCount      AggregateFunction = "COUNT"
+	AggregatePercentile AggregateFunction = "PERCENTILE"
+)
+
+// What aggregates can be grouped by besides time. Stores support the groups that apply to their data.
+type AggregateGroup string
+
+const (
+	GroupByDevice AggregateGroup = "device"
+	GroupByField  AggregateGroup = "field"
+)
+
+// An aggregation of a store's numeric value, such as the hourly mean of each device's fields.
+type AggregateQuery struct {
+	Function AggregateFunction
+	// Between 0 and 1, for AggregatePercentile only. Uses the nearest-rank method.
+	Percentile float64
+	GroupBy    []AggregateGroup
+	// Length of time buckets. 0 aggregates the whole range into a single bucket.
+	BucketSeconds int64
+}
+
+func (q AggregateQuery) Validate() error {
+	switch q.Function {
+	case AggregateMin, AggregateMax, AggregateAvg, AggregateSum, AggregateCount:
+	case AggregatePercentile:
+		if q.Percentile < 0 || q.Percentile > 1 {
+			return fmt.Errorf("percentile must be between 0 and 1, got %v", q.Percentile)
+		}
+	default:
+		return fmt.Errorf("unknown aggregate function %v", q.Function)
+	}
+	for _, group := range q.GroupBy {
+		if group != GroupByDevice && group != GroupByField {
+			return fmt.Errorf("unknown aggregate group %v", group)
+		}
+	}
+	if q.BucketSeconds < 0 {
+		return errors.New("bucket length must not be negative")
+	}
+	return nil
+}
+
+// Whether the query groups by the given group.
+func (q AggregateQuery) IsGroupedBy(group AggregateGroup) bool {
+	return slices.Contains(q.GroupBy, group)
+}
+
+// A single aggregated value. Fields that were not grouped by are empty, and BucketStart is 0 when not bucketed.
+type AggregateResult struct {
+	DeviceID    string
+	FieldName   string
+	BucketStart int64
+	Value       float64
+	// Number of values aggregated.
+	Count int64
+}
@@ -0,0 +1,69 @@
+package data
+
+// Statuses an alert can be in for a given rule and device.
+const (
+	AlertOK      = "OK"
+	AlertPending = "PENDING"
+	AlertFiring  = "FIRING"
+)
+
+// An alert state as read from a store. Mutations are not implicitly persisted.
+var _ HasIDGetterAndSpreadable[StoreAlertState] = StoreAlertState{}
+
+type StoreAlertState struct {
+	HasID `db:"alert_state_id"`
+	AlertState
+}
+
+func (a StoreAlertState) GetID() string {
+	return a.ID
+}
+func (a StoreAlertState) Spread() []any {
+	return SpreadColumns(a)
+}
+func (a StoreAlertState) SpreadForExport(dates DateFormat) []string {
+	return SpreadColumnsForExport(a, dates)
+}
+func (a StoreAlertState) SpreadAddresses() (*StoreAlertState, []any) {
+	return &a, SpreadColumnAddresses(&a)
+}
+
+// The state of an alert rule for a single device that is not necessarily associated with a Store object.
+var _ Spreadable = AlertState{}
+
+type AlertState struct {
+	RuleName string `db:"alert_rule_name"`
+	DeviceID string `db:"device_id" export:"device"`
+	Status   string `db:"alert_status"`
+	// The last value the rule was evaluated against.
+	LastValue string `db:"alert_last_value"`
+	// Event timestamp at which the rule's condition started holding, 0 if it does not hold.
+	ConditionSince    int64 `db:"alert_condition_since" export:"date"`
+	FiredTimestamp    int64 `db:"alert_fired_timestamp" export:"date"`
+	ResolvedTimestamp int64 `db:"alert_resolved_timestamp" export:"date"`
+	// Last time the state was evaluated.
+	Timestamp int64 `db:"alert_timestamp" export:"date"`
+}
+
+func (a AlertState) Spread() []any {
+	return SpreadColumns(a)
+}
+
+// A partial alert state for querying a store.
+var _ Spreadable = AlertStateFilter{}
+
+type AlertStateFilter struct {
+	ID                *string `db:"alert_state_id"`
+	RuleName          *string `db:"alert_rule_name"`
+	DeviceID          *string `db:"device_id"`
+	Status            *string `db:"alert_status"`
+	LastValue         *string `db:"alert_last_value"`
+	ConditionSince    *int64  `db:"alert_condition_since"`
+	FiredTimestamp    *int64  `db:"alert_fired_timestamp"`
+	ResolvedTimestamp *int64  `db:"alert_resolved_timestamp"`
+	Timestamp         *int64  `db:"alert_timestamp"`
+}
+
+func (a AlertStateFilter) Spread() []any {
+	return SpreadColumns(a)
+}
@@ -0,0 +1,57 @@
+package data
+
+// A battery reading as read from a store. Mutations are not implicitly persisted.
+var _ HasIDGetterAndSpreadable[StoreBatteryReading] = StoreBatteryReading{}
+
+type StoreBatteryReading struct {
+	HasID `db:"battery_reading_id"`
+	BatteryReading
+}
+
+func (b StoreBatteryReading) GetID() string {
+	return b.ID
+}
+func (b StoreBatteryReading) Spread() []any {
+	return SpreadColumns(b)
+}
+func (b StoreBatteryReading) SpreadForExport(dates DateFormat) []string {
+	return SpreadColumnsForExport(b, dates)
+}
+func (b StoreBatteryReading) SpreadAddresses() (*StoreBatteryReading, []any) {
+	return &b, SpreadColumnAddresses(&b)
+}
+
+// A battery reading that is not necessarily associated with a Store object.
+var _ Spreadable = BatteryReading{}
+
+type BatteryReading struct {
+	DeviceID string `db:"device_id" export:"device"`
+	// Event the reading was extracted from.
+	EventID string `db:"event_id"`
+	// Percentage from 0 to 100.
+	Level int `db:"battery_level"`
+	// The value as reported by the device, which may be on a different scale.
+	RawValue string `db:"battery_raw_value"`
+	// Time the device reported the reading.
+	Timestamp int64 `db:"battery_timestamp" export:"date"`
+}
+
+func (b BatteryReading) Spread() []any {
+	return SpreadColumns(b)
+}
+
+// A partial battery reading for querying a store.
+var _ Spreadable = BatteryReadingFilter{}
+
+type BatteryReadingFilter struct {
+	ID        *string `db:"battery_reading_id"`
+	DeviceID  *string `db:"device_id"`
+	EventID   *string `db:"event_id"`
+	Level     *int    `db:"battery_level"`
+	RawValue  *string `db:"battery_raw_value"`
+	Timestamp *int64  `db:"battery_timestamp"`
+}
+
+func (b BatteryReadingFilter) Spread() []any {
+	return SpreadColumns(b)
+}
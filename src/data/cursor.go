@@ -0,0 +1,40 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrCursorMismatch = errors.New("cursor was issued for a different query")
+
+// A position within a query's results, after the last item returned. Serialized as an opaque token.
+type Cursor struct {
+	// Identifies the query the cursor was issued for.
+	Fingerprint string `json:"f"`
+	// The position of the last item returned, as encoded by the query. Empty before the first item.
+	Position string `json:"p"`
+}
+
+func (c Cursor) Encode() string {
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		// Marshalling strings cannot fail
+		panic(fmt.Errorf("error encoding cursor %v: %w", c, err))
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func DecodeCursor(token string) (Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("error decoding cursor %v: %w", token, err)
+	}
+	var cursor Cursor
+	err = json.Unmarshal(decoded, &cursor)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("error reading cursor %v: %w", token, err)
+	}
+	return cursor, nil
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{Fingerprint: "events?limit=10", Position: `[null,1.5,"id"]`}
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != cursor {
+		t.Fatalf("expected %v, got %v", cursor, decoded)
+	}
+	for _, token := range []string{"not base64!", "bm90IGpzb24"} {
+		_, err = DecodeCursor(token)
+		if err == nil {
+			t.Errorf("%v: expected an error", token)
+		}
+	}
+}
+
+// Pages of devices with IDs 00 to 09, two at a time, after the last ID.
+func testDevicePages() func(ctx context.Context, lastID *string) ([]StoreDevice, *string, error) {
+	devices := []StoreDevice{}
+	for i := range 10 {
+		devices = append(devices, StoreDevice{HasID: HasID{ID: fmt.Sprintf("%02d", i)}})
+	}
+	return func(_ context.Context, lastID *string) ([]StoreDevice, *string, error) {
+		start := 0
+		if lastID != nil {
+			start = slices.IndexFunc(devices, func(device StoreDevice) bool { return device.ID > *lastID })
+			if start < 0 {
+				return nil, lastID, nil
+			}
+		}
+		page := devices[start:min(start+2, len(devices))]
+		last := page[len(page)-1].ID
+		return page, &last, nil
+	}
+}
+
+func collectIDs(t *testing.T, paginator *IterablePaginatedData[StoreDevice], count int) []string {
+	t.Helper()
+	ids := []string{}
+	for device, err := range paginator.All(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, device.ID)
+		if len(ids) == count {
+			break
+		}
+	}
+	return ids
+}
+
+// A cursor taken mid-page resumes after the last item returned, not after the page fetched.
+func TestResumeFromCursor(t *testing.T) {
+	paginator := NewIterablePaginatedData(testDevicePages(), "devices")
+	first := collectIDs(t, &paginator, 3)
+	resumed, err := ResumeIterablePaginatedData(testDevicePages(), "devices", paginator.Cursor(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest := collectIDs(t, &resumed, -1)
+	if !slices.Equal(append(first, rest...), []string{"00", "01", "02", "03", "04", "05", "06", "07", "08", "09"}) {
+		t.Fatalf("expected every device once, got %v then %v", first, rest)
+	}
+}
+
+func TestResumeFromEmptyCursor(t *testing.T) {
+	paginator := NewIterablePaginatedData(testDevicePages(), "devices")
+	resumed, err := ResumeIterablePaginatedData(testDevicePages(), "devices", paginator.Cursor(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := collectIDs(t, &resumed, 1); !slices.Equal(ids, []string{"00"}) {
+		t.Fatalf("expected to start from the beginning, got %v", ids)
+	}
+}
+
+func TestResumeRejectsOtherQueries(t *testing.T) {
+	paginator := NewIterablePaginatedData(testDevicePages(), "devices")
+	collectIDs(t, &paginator, 1)
+	_, err := ResumeIterablePaginatedData(testDevicePages(), "events", paginator.Cursor(), nil)
+	if !errors.Is(err, ErrCursorMismatch) {
+		t.Fatalf("expected a cursor mismatch, got %v", err)
+	}
+}
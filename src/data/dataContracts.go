@@ -16,8 +16,8 @@ type SpreadableAddresses[T any] interface {
 	SpreadAddresses() (*T, []any)
 }
 type SpreadableForExport interface {
-	// Spread elements for export, in order.
-	SpreadForExport() []string
+	// Spread elements for export, in order, with dates in the format.
+	SpreadForExport(dates DateFormat) []string
 }
 
 type HasIDGetterAndSpreadable[T any] interface {
@@ -26,3 +26,28 @@ type HasIDGetterAndSpreadable[T any] interface {
 	SpreadableAddresses[T]
 	SpreadableForExport
 }
+
+// Filter values that match a range of values rather than a single one.
+type RangeFilterValue interface {
+	// Inclusive bounds, nil where unbounded.
+	Bounds() (lower any, upper any)
+}
+
+// An inclusive range for filters. Either end may be nil for an open range.
+var _ RangeFilterValue = (*Range[int])(nil)
+
+type Range[T any] struct {
+	Min *T
+	Max *T
+}
+
+func (r *Range[T]) Bounds() (any, any) {
+	var lower, upper any
+	if r.Min != nil {
+		lower = *r.Min
+	}
+	if r.Max != nil {
+		upper = *r.Max
+	}
+	return lower, upper
+}
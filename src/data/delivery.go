@@ -0,0 +1,65 @@
+package data
+
+// Outcomes of a single notification delivery attempt.
+const (
+	DeliverySucceeded = "SUCCEEDED"
+	DeliveryFailed    = "FAILED"
+)
+
+// A notification delivery attempt as read from a store. Mutations are not implicitly persisted.
+var _ HasIDGetterAndSpreadable[StoreDelivery] = StoreDelivery{}
+
+type StoreDelivery struct {
+	HasID `db:"delivery_id"`
+	Delivery
+}
+
+func (d StoreDelivery) GetID() string {
+	return d.ID
+}
+func (d StoreDelivery) Spread() []any {
+	return SpreadColumns(d)
+}
+func (d StoreDelivery) SpreadForExport(dates DateFormat) []string {
+	return SpreadColumnsForExport(d, dates)
+}
+func (d StoreDelivery) SpreadAddresses() (*StoreDelivery, []any) {
+	return &d, SpreadColumnAddresses(&d)
+}
+
+// A notification delivery attempt that is not necessarily associated with a Store object.
+var _ Spreadable = Delivery{}
+
+type Delivery struct {
+	// Job during which the notification was sent, if any.
+	JobID   string `db:"job_id"`
+	Channel string `db:"delivery_channel"`
+	Title   string `db:"delivery_title"`
+	// 1 for the first attempt at sending a notification over a channel.
+	Attempt   int    `db:"delivery_attempt"`
+	Status    string `db:"delivery_status"`
+	Error     string `db:"delivery_error"`
+	Timestamp int64  `db:"delivery_timestamp" export:"date"`
+}
+
+func (d Delivery) Spread() []any {
+	return SpreadColumns(d)
+}
+
+// A partial delivery for querying a store.
+var _ Spreadable = DeliveryFilter{}
+
+type DeliveryFilter struct {
+	ID        *string `db:"delivery_id"`
+	JobID     *string `db:"job_id"`
+	Channel   *string `db:"delivery_channel"`
+	Title     *string `db:"delivery_title"`
+	Attempt   *int    `db:"delivery_attempt"`
+	Status    *string `db:"delivery_status"`
+	Error     *string `db:"delivery_error"`
+	Timestamp *int64  `db:"delivery_timestamp"`
+}
+
+func (d DeliveryFilter) Spread() []any {
+	return SpreadColumns(d)
+}
@@ -1,10 +1,18 @@
 package data
 
+// Statuses of a device according to how recently it last reported.
+const (
+	DeviceUnknown = "UNKNOWN"
+	DeviceOnline  = "ONLINE"
+	DeviceStale   = "STALE"
+	DeviceOffline = "OFFLINE"
+)
+
 // A device as read from a Store. Mutations are not implicitly persisted.
 var _ HasIDGetterAndSpreadable[StoreDevice] = StoreDevice{}
 
 type StoreDevice struct {
-	HasID
+	HasID `db:"device_id"`
 	Device
 }
 
@@ -12,83 +20,50 @@ func (device StoreDevice) GetID() string {
 	return device.ID
 }
 func (e StoreDevice) Spread() []any {
-	return []any{
-		e.ID,
-		e.BrandID,
-		e.Brand,
-		e.Kind,
-		e.Name,
-		e.Token,
-		e.Timestamp,
-	}
+	return SpreadColumns(e)
 }
-func (e StoreDevice) SpreadForExport() []string {
-	return []string{
-		e.ID,
-		e.BrandID,
-		e.Brand,
-		e.Kind,
-		e.Name,
-		e.Token,
-		EpochSecondsToExcelDate(e.Timestamp),
-	}
+func (e StoreDevice) SpreadForExport(dates DateFormat) []string {
+	return SpreadColumnsForExport(e, dates)
 }
 func (e StoreDevice) SpreadAddresses() (*StoreDevice, []any) {
-	return &e, []any{
-		&e.ID,
-		&e.BrandID,
-		&e.Brand,
-		&e.Kind,
-		&e.Name,
-		&e.Token,
-		&e.Timestamp,
-	}
+	return &e, SpreadColumnAddresses(&e)
 }
 
 // A device that is not necessarily associated with a Store object.
 var _ Spreadable = Device{}
 
 type Device struct {
-	BrandID   string
-	Brand     string
-	Kind      string
-	Name      string
-	Token     string
-	Timestamp int64
+	BrandID   string `db:"brand_device_id"`
+	Brand     string `db:"device_brand"`
+	Kind      string `db:"device_kind"`
+	Name      string `db:"device_name"`
+	Token     string `db:"device_token"`
+	Timestamp int64  `db:"device_timestamp" export:"date"`
+	// One of the Device statuses, updated by staleness checks.
+	Status string `db:"device_status"`
+	// Latest timestamp the device reported, 0 if it never did.
+	LastEventTimestamp int64 `db:"device_last_event_timestamp" export:"date"`
 }
 
 func (e Device) Spread() []any {
-	return []any{
-		e.BrandID,
-		e.Brand,
-		e.Kind,
-		e.Name,
-		e.Token,
-		e.Timestamp,
-	}
+	return SpreadColumns(e)
 }
 
 // A partial device object for querying.
 var _ Spreadable = DeviceFilter{}
 
 type DeviceFilter struct {
-	ID        *string
-	BrandID   *string
-	Brand     *string
-	Kind      *string
-	Name      *string
-	Token     *string
-	Timestamp *int64
+	ID                 *string `db:"device_id"`
+	BrandID            *string `db:"brand_device_id"`
+	Brand              *string `db:"device_brand"`
+	Kind               *string `db:"device_kind"`
+	Name               *string `db:"device_name"`
+	Token              *string `db:"device_token"`
+	Timestamp          *int64  `db:"device_timestamp"`
+	Status             *string `db:"device_status"`
+	LastEventTimestamp *int64  `db:"device_last_event_timestamp"`
 }
 
 func (d DeviceFilter) Spread() []any {
-	return []any{
-		d.ID,
-		d.BrandID,
-		d.Brand,
-		d.Kind,
-		d.Name,
-		d.Token,
-		d.Timestamp,
-	}
+	return SpreadColumns(d)
 }
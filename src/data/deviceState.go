@@ -0,0 +1,60 @@
+package data
+
+// The latest value of a device's field as read from a store. Mutations are not implicitly persisted.
+var _ HasIDGetterAndSpreadable[StoreDeviceState] = StoreDeviceState{}
+
+type StoreDeviceState struct {
+	HasID `db:"device_state_id"`
+	DeviceState
+}
+
+func (d StoreDeviceState) GetID() string {
+	return d.ID
+}
+func (d StoreDeviceState) Spread() []any {
+	return SpreadColumns(d)
+}
+func (d StoreDeviceState) SpreadForExport(dates DateFormat) []string {
+	return SpreadColumnsForExport(d, dates)
+}
+func (d StoreDeviceState) SpreadAddresses() (*StoreDeviceState, []any) {
+	return &d, SpreadColumnAddresses(&d)
+}
+
+// The latest value of a device's field that is not necessarily associated with a Store object.
+var _ Spreadable = DeviceState{}
+
+type DeviceState struct {
+	DeviceID   string `db:"device_id" export:"device"`
+	FieldName  string `db:"field_name"`
+	FieldValue string `db:"field_value"`
+	// The event the value was last set by.
+	EventID        string `db:"event_id"`
+	EventTimestamp int64  `db:"event_timestamp" export:"date"`
+}
+
+func (d DeviceState) Spread() []any {
+	return SpreadColumns(d)
+}
+
+// A partial device state for querying a store.
+var _ Spreadable = DeviceStateFilter{}
+
+type DeviceStateFilter struct {
+	ID             *string `db:"device_state_id"`
+	DeviceID       *string `db:"device_id"`
+	FieldName      *string `db:"field_name"`
+	FieldValue     *string `db:"field_value"`
+	EventID        *string `db:"event_id"`
+	EventTimestamp *int64  `db:"event_timestamp"`
+}
+
+func (d DeviceStateFilter) Spread() []any {
+	return SpreadColumns(d)
+}
+
+// Every latest field value of a single device, keyed by field name.
+type DeviceSnapshot struct {
+	DeviceID string
+	Fields   map[string]StoreDeviceState
+}
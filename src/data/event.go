@@ -1,10 +1,24 @@
 package data
 
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Types of event values, as they were received.
+const (
+	ValueString  = "string"
+	ValueNumber  = "number"
+	ValueBoolean = "boolean"
+	ValueNull    = "null"
+)
+
 // An event as read from a store. Mutations are not implicitly persisted.
 var _ HasIDGetterAndSpreadable[StoreEvent] = StoreEvent{}
 
 type StoreEvent struct {
-	HasID
+	HasID `db:"event_id"`
 	Event
 }
 
@@ -12,83 +26,91 @@ func (event StoreEvent) GetID() string {
 	return event.ID
 }
 func (e StoreEvent) Spread() []any {
-	return []any{
-		e.ID,
-		e.RequestDeviceID,
-		e.EventSourceDeviceID,
-		e.ResponseTimestamp,
-		e.EventTimestamp,
-		e.FieldName,
-		e.FieldValue,
-	}
+	return SpreadColumns(e)
 }
-func (e StoreEvent) SpreadForExport() []string {
-	return []string{
-		e.ID,
-		e.RequestDeviceID,
-		e.EventSourceDeviceID,
-		EpochSecondsToExcelDate(e.ResponseTimestamp),
-		EpochSecondsToExcelDate(e.EventTimestamp),
-		e.FieldName,
-		e.FieldValue,
-	}
+func (e StoreEvent) SpreadForExport(dates DateFormat) []string {
+	return SpreadColumnsForExport(e, dates)
 }
 func (e StoreEvent) SpreadAddresses() (*StoreEvent, []any) {
-	return &e, []any{
-		&e.ID,
-		&e.RequestDeviceID,
-		&e.EventSourceDeviceID,
-		&e.ResponseTimestamp,
-		&e.EventTimestamp,
-		&e.FieldName,
-		&e.FieldValue,
-	}
+	return &e, SpreadColumnAddresses(&e)
 }
 
 // An event that is not necessarily associated with a Store object.
 var _ Spreadable = Event{}
 
 type Event struct {
-	RequestDeviceID     string
-	EventSourceDeviceID string
-	ResponseTimestamp   int64
-	EventTimestamp      int64
-	FieldName           string
-	FieldValue          string
+	RequestDeviceID     string `db:"request_device_id"`
+	EventSourceDeviceID string `db:"event_source_device_id" export:"device"`
+	ResponseTimestamp   int64  `db:"response_timestamp" export:"date"`
+	EventTimestamp      int64  `db:"event_timestamp" export:"date"`
+	FieldName           string `db:"field_name"`
+	// The value as it was received, regardless of type.
+	FieldValue string `db:"field_value"`
+	// One of the Value types. Numbers and booleans are also stored in their typed fields.
+	ValueType    string   `db:"value_type"`
+	NumericValue *float64 `db:"numeric_value"`
+	BooleanValue *bool    `db:"boolean_value"`
 }
 
 func (e Event) Spread() []any {
-	return []any{
-		e.RequestDeviceID,
-		e.EventSourceDeviceID,
-		e.ResponseTimestamp,
-		e.EventTimestamp,
-		e.FieldName,
-		e.FieldValue,
+	return SpreadColumns(e)
+}
+
+// Set the event's value from a decoded JSON value, keeping its type. Numbers should be decoded as json.Number to keep their representation.
+func (e Event) WithValue(value any) Event {
+	e.NumericValue = nil
+	e.BooleanValue = nil
+	switch value := value.(type) {
+	case nil:
+		e.ValueType = ValueNull
+		e.FieldValue = "null"
+	case bool:
+		e.ValueType = ValueBoolean
+		e.FieldValue = strconv.FormatBool(value)
+		e.BooleanValue = &value
+	case json.Number:
+		e.ValueType = ValueNumber
+		e.FieldValue = value.String()
+		number, err := value.Float64()
+		if err == nil {
+			e.NumericValue = &number
+		}
+	case float64:
+		e.ValueType = ValueNumber
+		e.FieldValue = strconv.FormatFloat(value, 'f', -1, 64)
+		e.NumericValue = &value
+	case string:
+		e.ValueType = ValueString
+		e.FieldValue = value
+	default:
+		// Arrays and anything else keep their JSON representation
+		e.ValueType = ValueString
+		marshalled, err := json.Marshal(value)
+		if err != nil {
+			e.FieldValue = fmt.Sprint(value)
+		} else {
+			e.FieldValue = string(marshalled)
+		}
 	}
+	return e
 }
 
 // A partial device object for querying.
 var _ Spreadable = StoreDevice{}
 
 type EventFilter struct {
-	ID                  *string
-	RequestDeviceID     *string
-	EventSourceDeviceID *string
-	ResponseTimestamp   *int64
-	EventTimestamp      *int64
-	FieldName           *string
-	FieldValue          *string
+	ID                  *string         `db:"event_id"`
+	RequestDeviceID     *string         `db:"request_device_id"`
+	EventSourceDeviceID *string         `db:"event_source_device_id"`
+	ResponseTimestamp   *int64          `db:"response_timestamp"`
+	EventTimestamp      *int64          `db:"event_timestamp"`
+	FieldName           *string         `db:"field_name"`
+	FieldValue          *string         `db:"field_value"`
+	ValueType           *string         `db:"value_type"`
+	NumericValue        *Range[float64] `db:"numeric_value"`
+	BooleanValue        *bool           `db:"boolean_value"`
 }
 
 func (e EventFilter) Spread() []any {
-	return []any{
-		e.ID,
-		e.RequestDeviceID,
-		e.EventSourceDeviceID,
-		e.ResponseTimestamp,
-		e.EventTimestamp,
-		e.FieldName,
-		e.FieldValue,
-	}
+	return SpreadColumns(e)
 }
@@ -0,0 +1,277 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File formats exports can be written in.
+type ExportFormat string
+
+const (
+	ExportCSV     ExportFormat = "csv"
+	ExportNDJSON  ExportFormat = "ndjson"
+	ExportParquet ExportFormat = "parquet"
+	ExportXLSX    ExportFormat = "xlsx"
+)
+
+var ExportFormats = []ExportFormat{ExportCSV, ExportNDJSON, ExportParquet, ExportXLSX}
+
+// Validate the format, with empty meaning CSV.
+func ParseExportFormat(format string) (ExportFormat, error) {
+	if format == "" {
+		return ExportCSV, nil
+	}
+	for _, exportFormat := range ExportFormats {
+		if string(exportFormat) == format {
+			return exportFormat, nil
+		}
+	}
+	return "", fmt.Errorf("unknown export format %v, expected one of %v", format, ExportFormats)
+}
+
+// Compression applied to export files.
+type ExportCompression string
+
+const (
+	CompressNone ExportCompression = ""
+	CompressGzip ExportCompression = "gzip"
+	CompressZstd ExportCompression = "zstd"
+)
+
+// Validate the compression, with empty meaning none.
+func ParseExportCompression(compression string) (ExportCompression, error) {
+	switch ExportCompression(compression) {
+	case CompressNone, CompressGzip, CompressZstd:
+		return ExportCompression(compression), nil
+	default:
+		return "", fmt.Errorf("unknown export compression %v, expected gzip or zstd", compression)
+	}
+}
+
+// How date columns are written.
+type TimestampFormat string
+
+const (
+	// Each file format's own: text in CSV, RFC 3339 in NDJSON, timestamps in Parquet and date cells in XLSX.
+	TimestampDefault TimestampFormat = ""
+	// Text such as 2006-01-02 15:04:05, which spreadsheets read as dates.
+	TimestampText TimestampFormat = "text"
+	// Days since 1899-12-30, with the time of day as the fraction, as Excel stores dates.
+	TimestampExcel       TimestampFormat = "excel"
+	TimestampRFC3339     TimestampFormat = "rfc3339"
+	TimestampEpoch       TimestampFormat = "epoch"
+	TimestampEpochMillis TimestampFormat = "epoch_ms"
+)
+
+var TimestampFormats = []TimestampFormat{TimestampText, TimestampExcel, TimestampRFC3339, TimestampEpoch, TimestampEpochMillis}
+
+// Validate the timestamp format, with empty meaning each file format's own.
+func ParseTimestampFormat(format string) (TimestampFormat, error) {
+	if format == "" {
+		return TimestampDefault, nil
+	}
+	for _, timestampFormat := range TimestampFormats {
+		if string(timestampFormat) == format {
+			return timestampFormat, nil
+		}
+	}
+	return "", fmt.Errorf("unknown timestamp format %v, expected one of %v", format, TimestampFormats)
+}
+
+// How date columns, which hold epoch seconds, are exported.
+type DateFormat struct {
+	Format TimestampFormat
+	// Time zone dates are written in, other than as epochs. Defaults to UTC.
+	Location *time.Location
+}
+
+// The timestamp format and IANA time zone, such as America/New_York, with empty values meaning defaults and UTC.
+func ParseDateFormat(format string, timeZone string) (DateFormat, error) {
+	timestampFormat, err := ParseTimestampFormat(format)
+	if err != nil {
+		return DateFormat{}, err
+	}
+	dates := DateFormat{Format: timestampFormat}
+	if timeZone != "" {
+		dates.Location, err = time.LoadLocation(timeZone)
+		if err != nil {
+			return DateFormat{}, fmt.Errorf("error loading export time zone: %w", err)
+		}
+	}
+	return dates, nil
+}
+
+// Days from Excel's epoch to the Unix epoch.
+const excelUnixEpochDays = 25569
+
+// The epoch seconds as a time in the format's time zone.
+func (d DateFormat) Time(seconds int64) time.Time {
+	if d.Location == nil {
+		return time.Unix(seconds, 0).UTC()
+	}
+	return time.Unix(seconds, 0).In(d.Location)
+}
+
+// The epoch seconds in the format: a string for text and RFC 3339, a float64 for Excel, and an int64 for epochs.
+// The default format is text.
+func (d DateFormat) Value(seconds int64) any {
+	t := d.Time(seconds)
+	switch d.Format {
+	case TimestampExcel:
+		_, offset := t.Zone()
+		return float64(seconds+int64(offset))/86400 + excelUnixEpochDays
+	case TimestampRFC3339:
+		return t.Format(time.RFC3339)
+	case TimestampEpoch:
+		return seconds
+	case TimestampEpochMillis:
+		return seconds * 1000
+	default:
+		return t.Format(time.DateTime)
+	}
+}
+
+// Epoch seconds of a date written in the format, as Value writes it. The default format also reads RFC 3339.
+// Text without a time zone is read in the format's time zone.
+func (d DateFormat) Parse(value string) (int64, error) {
+	location := d.Location
+	if location == nil {
+		location = time.UTC
+	}
+	switch d.Format {
+	case TimestampExcel:
+		days, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing Excel date %v: %w", value, err)
+		}
+		wallClock := time.Unix(int64(math.Round((days-excelUnixEpochDays)*86400)), 0).UTC()
+		return time.Date(wallClock.Year(), wallClock.Month(), wallClock.Day(), wallClock.Hour(), wallClock.Minute(), wallClock.Second(), 0, location).Unix(), nil
+	case TimestampEpoch, TimestampEpochMillis:
+		epoch, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing epoch %v: %w", value, err)
+		}
+		if d.Format == TimestampEpochMillis {
+			return epoch / 1000, nil
+		}
+		return epoch, nil
+	case TimestampRFC3339:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing date %v: %w", value, err)
+		}
+		return t.Unix(), nil
+	default:
+		t, err := time.ParseInLocation(time.DateTime, value, location)
+		if err != nil && d.Format == TimestampDefault {
+			t, err = time.Parse(time.RFC3339, value)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error parsing date %v: %w", value, err)
+		}
+		return t.Unix(), nil
+	}
+}
+
+// The kind of the format's values.
+func (d DateFormat) Kind() reflect.Kind {
+	switch d.Format {
+	case TimestampExcel:
+		return reflect.Float64
+	case TimestampEpoch, TimestampEpochMillis:
+		return reflect.Int64
+	default:
+		return reflect.String
+	}
+}
+
+// Where export files are written, such as a local directory or object storage.
+type ExportSink interface {
+	// Create the named file. It is complete once the writer is closed without error.
+	Create(ctx context.Context, name string) (io.WriteCloser, error)
+	// Where the named file is written, for logs and reports.
+	Location(name string) string
+}
+
+// A sink whose files can be appended to, such as for daily files.
+type AppendingExportSink interface {
+	ExportSink
+	// Open the named file for appending, creating it if it does not exist. Returns its size before appending.
+	Append(ctx context.Context, name string) (io.WriteCloser, int64, error)
+}
+
+// How to export items.
+type ExportOptions struct {
+	// Defaults to CSV.
+	Format      ExportFormat
+	Compression ExportCompression
+	// Defaults to the export directory at the root of the project.
+	Sink  ExportSink
+	Dates DateFormat
+	// Devices by ID, whose name, kind and brand are added after device columns. Nil adds no device columns.
+	Devices map[string]StoreDevice
+	// Rows that may fail to be written, and are skipped, before the export fails. Zero fails on the first.
+	ErrorBudget int
+}
+
+// Errors of skipped rows kept in an ExportReport. Later ones are only counted.
+const MaxReportedExportErrors = 10
+
+// The outcome of an export. Failed exports return their report too, with how far they got.
+type ExportReport struct {
+	// Where the file was written. Empty if it was not completed.
+	File        string
+	Rows        int64
+	SkippedRows int64
+	// Errors of the first skipped rows.
+	Errors []string
+	// Size of the export as stored, after compression.
+	Bytes    int64
+	Duration time.Duration
+}
+
+func (r ExportReport) String() string {
+	summary := fmt.Sprintf("%v rows", r.Rows)
+	if r.File != "" {
+		summary += fmt.Sprintf(" to %v (%v bytes)", r.File, r.Bytes)
+	}
+	summary += fmt.Sprintf(" in %v", r.Duration.Round(time.Millisecond))
+	if r.SkippedRows > 0 {
+		summary += fmt.Sprintf(", skipping %v rows: %v", r.SkippedRows, strings.Join(r.Errors, "; "))
+	}
+	return summary
+}
+
+// A column to export, described so that formats with typed columns can keep its type.
+type ExportColumn struct {
+	Name string
+	// Kind of the column's values. Pointers are described by the kind they point to.
+	Kind reflect.Kind
+	// Whether values are pointers, exported as missing when nil.
+	IsNullable bool
+	// Whether values are epoch seconds, exported as dates.
+	IsDate bool
+	// Whether values are device IDs, followed by the device's name, kind and brand when exporting with devices.
+	IsDevice bool
+}
+
+// How to pivot events into a row per device and reading, with a column per field.
+type PivotOptions struct {
+	// Fields to include as columns, in order. Empty includes every field with events in range, sorted by name.
+	Fields []string
+	// Length of time buckets readings are grouped into, keeping the latest value of each field in a bucket.
+	// 0 groups readings with the same timestamp.
+	BucketSeconds int64
+	// Limits the export to one device when set.
+	DeviceID *string
+	// Epoch seconds, inclusive start and exclusive end, unbounded when nil.
+	StartTime *int64
+	EndTime   *int64
+}
@@ -0,0 +1,57 @@
+package data
+
+// An export mark as read from a store. Mutations are not implicitly persisted.
+var _ HasIDGetterAndSpreadable[StoreExportMark] = StoreExportMark{}
+
+type StoreExportMark struct {
+	HasID `db:"export_mark_id"`
+	ExportMark
+}
+
+func (m StoreExportMark) GetID() string {
+	return m.ID
+}
+func (m StoreExportMark) Spread() []any {
+	return SpreadColumns(m)
+}
+func (m StoreExportMark) SpreadForExport(dates DateFormat) []string {
+	return SpreadColumnsForExport(m, dates)
+}
+func (m StoreExportMark) SpreadAddresses() (*StoreExportMark, []any) {
+	return &m, SpreadColumnAddresses(&m)
+}
+
+// How far a named export has got, so that its next run exports only what was added since, not necessarily associated with a Store object.
+var _ Spreadable = ExportMark{}
+
+type ExportMark struct {
+	ExportName string `db:"export_name"`
+	// The last event exported. Event IDs increase as events are added, so later events have greater IDs, though events
+	// are only visible once committed, which may be after events with greater IDs.
+	LastEventID        string `db:"export_last_event_id"`
+	LastEventTimestamp int64  `db:"export_last_event_timestamp" export:"date"`
+	// Events exported over all runs.
+	ExportedCount int64 `db:"export_count"`
+	// Last time the mark moved.
+	Timestamp int64 `db:"export_mark_timestamp" export:"date"`
+}
+
+func (m ExportMark) Spread() []any {
+	return SpreadColumns(m)
+}
+
+// A partial export mark for querying a store.
+var _ Spreadable = ExportMarkFilter{}
+
+type ExportMarkFilter struct {
+	ID                 *string `db:"export_mark_id"`
+	ExportName         *string `db:"export_name"`
+	LastEventID        *string `db:"export_last_event_id"`
+	LastEventTimestamp *int64  `db:"export_last_event_timestamp"`
+	ExportedCount      *int64  `db:"export_count"`
+	Timestamp          *int64  `db:"export_mark_timestamp"`
+}
+
+func (m ExportMarkFilter) Spread() []any {
+	return SpreadColumns(m)
+}
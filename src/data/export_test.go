@@ -0,0 +1,101 @@
+package data
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 2023-11-14 22:13:20 UTC
+const exportTime int64 = 1700000000
+
+func TestDateFormatValue(t *testing.T) {
+	tests := []struct {
+		format   TimestampFormat
+		expected any
+	}{
+		{TimestampDefault, "2023-11-14 22:13:20"},
+		{TimestampText, "2023-11-14 22:13:20"},
+		{TimestampRFC3339, "2023-11-14T22:13:20Z"},
+		{TimestampEpoch, exportTime},
+		{TimestampEpochMillis, exportTime * 1000},
+		{TimestampExcel, float64(exportTime)/86400 + excelUnixEpochDays},
+	}
+	for _, test := range tests {
+		value := DateFormat{Format: test.format}.Value(exportTime)
+		if value != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.format, test.expected, value)
+		}
+	}
+}
+
+// Dates are written and read back in the time zone, except epochs, which have none.
+func TestDateFormatRoundTrip(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable")
+	}
+	for _, format := range append(TimestampFormats, TimestampDefault) {
+		dates := DateFormat{Format: format, Location: location}
+		value := dates.Value(exportTime)
+		var text string
+		switch typed := value.(type) {
+		case string:
+			text = typed
+		case int64:
+			text = strconv.FormatInt(typed, 10)
+		case float64:
+			text = strconv.FormatFloat(typed, 'f', -1, 64)
+		}
+		seconds, err := dates.Parse(text)
+		if err != nil {
+			t.Fatalf("%q: %v", format, err)
+		}
+		if seconds != exportTime {
+			t.Errorf("%q: wrote %v and read back %v, expected %v", format, text, seconds, exportTime)
+		}
+	}
+	if value := (DateFormat{Format: TimestampText, Location: location}).Value(exportTime); value != "2023-11-14 17:13:20" {
+		t.Errorf("expected text in New York time, got %v", value)
+	}
+}
+
+func TestDateFormatParse(t *testing.T) {
+	tests := []struct {
+		format   TimestampFormat
+		value    string
+		expected int64
+	}{
+		{TimestampDefault, "2023-11-14T23:13:20+01:00", exportTime},
+		{TimestampRFC3339, "2023-11-14T22:13:20Z", exportTime},
+		{TimestampEpochMillis, "1700000000999", exportTime},
+		{TimestampExcel, "45244.925925926", exportTime},
+	}
+	for _, test := range tests {
+		seconds, err := DateFormat{Format: test.format}.Parse(test.value)
+		if err != nil {
+			t.Fatalf("%q: %v", test.format, err)
+		}
+		if seconds != test.expected {
+			t.Errorf("%q: expected %v from %v, got %v", test.format, test.expected, test.value, seconds)
+		}
+	}
+	// Only the default format falls back to RFC 3339
+	for _, format := range []TimestampFormat{TimestampText, TimestampEpoch} {
+		_, err := DateFormat{Format: format}.Parse("2023-11-14T22:13:20Z")
+		if err == nil {
+			t.Errorf("%q: expected an error", format)
+		}
+	}
+}
+
+func TestParseDateFormat(t *testing.T) {
+	_, err := ParseDateFormat("unix", "")
+	if err == nil {
+		t.Error("expected an error for an unknown timestamp format")
+	}
+	_, err = ParseDateFormat("", "Mars/Olympus_Mons")
+	if err == nil {
+		t.Error("expected an error for an unknown time zone")
+	}
+}
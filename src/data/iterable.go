@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"fmt"
+	"iter"
 )
 
 const PAGE_SIZE int = 50
@@ -13,56 +14,146 @@ type HasIDGetterAndSpreadableAddresss[T any] interface {
 }
 
 // Typically represents a stream of data from a paginated query response.
+// The page after the current one is fetched in the background while the current one is consumed.
 type IterablePaginatedData[T any] struct {
 	// Gets data at the given page.
 	getPage               func(ctx context.Context, startingID *string) ([]T, *string, error)
 	currentPage           []T
 	currentPositionInPage int
 	currentLastID         *string
+	// Whether an empty page was reached, so there is no more data.
+	isDone bool
+	// The page after the current one while it is being fetched, otherwise nil.
+	nextPage chan page[T]
+	// Identifies the query for cursors, and the position of the last value returned.
+	fingerprint          string
+	lastReturnedPosition *string
+	// The position of a value, which getPage continues after. Defaults to the value's ID.
+	getPosition func(T) string
 }
 
-func NewIterablePaginatedData[T HasIDGetterAndSpreadable[T]](getPage func(ctx context.Context, lastID *string) ([]T, *string, error)) IterablePaginatedData[T] {
-	return IterablePaginatedData[T]{getPage: getPage}
+type page[T any] struct {
+	items  []T
+	lastID *string
+	err    error
+}
+
+// The fingerprint identifies the query, so that cursors are only accepted by the query that issued them.
+func NewIterablePaginatedData[T HasIDGetterAndSpreadable[T]](getPage func(ctx context.Context, lastID *string) ([]T, *string, error), fingerprint string) IterablePaginatedData[T] {
+	return IterablePaginatedData[T]{
+		getPage:     getPage,
+		fingerprint: fingerprint,
+		getPosition: func(item T) string { return item.GetID() },
+	}
+}
+
+// Paginated data continuing after the cursor's position. Errors if the cursor was issued for a different query.
+// An empty cursor starts from the beginning. Pages continue after the position of their last value, which is its ID if
+// position is nil.
+func ResumeIterablePaginatedData[T HasIDGetterAndSpreadable[T]](getPage func(ctx context.Context, lastID *string) ([]T, *string, error), fingerprint string, token string, position func(T) string) (IterablePaginatedData[T], error) {
+	paginator := NewIterablePaginatedData(getPage, fingerprint)
+	if position != nil {
+		paginator.getPosition = position
+	}
+	if token == "" {
+		return paginator, nil
+	}
+	cursor, err := DecodeCursor(token)
+	if err != nil {
+		return IterablePaginatedData[T]{}, err
+	}
+	if cursor.Fingerprint != fingerprint {
+		return IterablePaginatedData[T]{}, ErrCursorMismatch
+	}
+	if cursor.Position != "" {
+		paginator.currentLastID = &cursor.Position
+		paginator.lastReturnedPosition = &cursor.Position
+	}
+	return paginator, nil
+}
+
+// A token for resuming after the last value returned, or from the beginning if none were.
+func (i *IterablePaginatedData[T]) Cursor() string {
+	cursor := Cursor{Fingerprint: i.fingerprint}
+	if i.lastReturnedPosition != nil {
+		cursor.Position = *i.lastReturnedPosition
+	}
+	return cursor.Encode()
 }
 
 // Provide the next value if it exists, otherwise check for more data before returning nil.
 func (i *IterablePaginatedData[T]) Next(ctx context.Context) (*T, error) {
-	// Lazy initialization
-	if i.currentLastID == nil && len(i.currentPage) == 0 {
-		err := i.goToNextPage(ctx)
-		if err != nil {
-			return nil, err
-		}
-	}
 	for {
 		// Within page
 		if i.currentPositionInPage < len(i.currentPage) {
 			value := i.currentPage[i.currentPositionInPage]
 			i.currentPositionInPage++
+			position := i.getPosition(value)
+			i.lastReturnedPosition = &position
 			return &value, nil
 		}
 
+		// No more data
+		if i.isDone {
+			return nil, nil
+		}
+
 		// End of page
 		err := i.goToNextPage(ctx)
 		if err != nil {
 			return nil, err
 		}
+	}
+}
 
-		// No more data
-		if len(i.currentPage) == 0 {
-			return nil, nil
+// Each remaining value in order. Iteration stops after the first error, which is yielded with the zero value.
+func (i *IterablePaginatedData[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			value, err := i.Next(ctx)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if value == nil {
+				return
+			}
+			if !yield(*value, nil) {
+				return
+			}
 		}
 	}
 }
 
-// Get next page and update state for next-next page.
+// Get next page and update state for next-next page, starting to fetch the one after it.
 func (i *IterablePaginatedData[T]) goToNextPage(ctx context.Context) error {
+	if i.nextPage == nil {
+		i.nextPage = i.fetchPage(ctx, i.currentLastID)
+	}
+	nextPage := <-i.nextPage
+	i.nextPage = nil
+	if nextPage.err != nil {
+		return fmt.Errorf("error getting page %v: %w", i.currentLastID, nextPage.err)
+	}
+
 	i.currentPositionInPage = 0
-	nextPage, newLastID, err := i.getPage(ctx, i.currentLastID)
-	if err != nil {
-		return fmt.Errorf("error getting page %v: %w", i.currentLastID, err)
+	i.currentPage = nextPage.items
+	i.currentLastID = nextPage.lastID
+	if len(nextPage.items) == 0 {
+		i.isDone = true
+		return nil
 	}
-	i.currentPage = nextPage
-	i.currentLastID = newLastID
+	i.nextPage = i.fetchPage(ctx, i.currentLastID)
 	return nil
 }
+
+// Fetch the page after lastID in the background. The channel is buffered so abandoned fetches do not block.
+func (i *IterablePaginatedData[T]) fetchPage(ctx context.Context, lastID *string) chan page[T] {
+	result := make(chan page[T], 1)
+	go func() {
+		items, newLastID, err := i.getPage(ctx, lastID)
+		result <- page[T]{items: items, lastID: newLastID, err: err}
+	}()
+	return result
+}
@@ -0,0 +1,110 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// Pages as testDevicePages gives them, sending the last ID each fetch continues after, "" for the first, and failing
+// instead of fetching after failAfter if it is set.
+func recordingDevicePages(fetches chan string, failAfter string) func(ctx context.Context, lastID *string) ([]StoreDevice, *string, error) {
+	getPage := testDevicePages()
+	return func(ctx context.Context, lastID *string) ([]StoreDevice, *string, error) {
+		after := ""
+		if lastID != nil {
+			after = *lastID
+		}
+		fetches <- after
+		if failAfter != "" && after == failAfter {
+			return nil, nil, errors.New("page failed")
+		}
+		return getPage(ctx, lastID)
+	}
+}
+
+// The next page is fetched while the current one is returned, and values come out in order.
+func TestPrefetchOrder(t *testing.T) {
+	fetches := make(chan string, 10)
+	paginator := NewIterablePaginatedData(recordingDevicePages(fetches, ""), "devices")
+	ctx := context.Background()
+	first, err := paginator.Next(ctx)
+	if err != nil || first == nil || first.ID != "00" {
+		t.Fatalf("expected the first device, got %v and %v", first, err)
+	}
+	// The page after the first is fetched before any of it is needed
+	for _, expected := range []string{"", "01"} {
+		if after := <-fetches; after != expected {
+			t.Fatalf("expected a fetch after %q, got one after %q", expected, after)
+		}
+	}
+
+	ids := []string{first.ID}
+	for device, err := range paginator.All(ctx) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, device.ID)
+	}
+	if !slices.Equal(ids, []string{"00", "01", "02", "03", "04", "05", "06", "07", "08", "09"}) {
+		t.Fatalf("expected every device in order, got %v", ids)
+	}
+	close(fetches)
+	after := []string{}
+	for fetch := range fetches {
+		after = append(after, fetch)
+	}
+	if !slices.Equal(after, []string{"03", "05", "07", "09"}) {
+		t.Fatalf("expected each page fetched once, got fetches after %v", after)
+	}
+}
+
+// A failed prefetch surfaces on the Next call that needs its page, and the cursor stays after the last value returned.
+func TestPrefetchError(t *testing.T) {
+	fetches := make(chan string, 10)
+	paginator := NewIterablePaginatedData(recordingDevicePages(fetches, "01"), "devices")
+	ctx := context.Background()
+	ids := collectIDs(t, &paginator, 2)
+	// Wait for the failing prefetch, which has not surfaced yet
+	for range 2 {
+		<-fetches
+	}
+	if !slices.Equal(ids, []string{"00", "01"}) {
+		t.Fatalf("expected the first page, got %v", ids)
+	}
+	cursor := paginator.Cursor()
+	device, err := paginator.Next(ctx)
+	if err == nil || device != nil {
+		t.Fatalf("expected the page's error, got %v and %v", device, err)
+	}
+	if paginator.Cursor() != cursor {
+		t.Errorf("expected the cursor to stay after the last device returned")
+	}
+
+	// Resuming from the cursor continues after the last device returned, not after the page fetched
+	resumed, err := ResumeIterablePaginatedData(testDevicePages(), "devices", cursor, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := collectIDs(t, &resumed, 1); !slices.Equal(ids, []string{"02"}) {
+		t.Fatalf("expected to resume from the second page, got %v", ids)
+	}
+}
+
+// Pages fetched ahead do not move the cursor past values not yet returned.
+func TestCursorExcludesPrefetchedValues(t *testing.T) {
+	fetches := make(chan string, 10)
+	paginator := NewIterablePaginatedData(recordingDevicePages(fetches, ""), "devices")
+	collectIDs(t, &paginator, 1)
+	for range 2 {
+		<-fetches
+	}
+	cursor, err := DecodeCursor(paginator.Cursor())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.Position != "00" {
+		t.Fatalf("expected the cursor after the device returned, got %v", cursor.Position)
+	}
+}
@@ -0,0 +1,99 @@
+package data
+
+import (
+	"iter"
+)
+
+// Helpers for sequences of values that may fail, such as IterablePaginatedData.All.
+// Each stops after the first error, yielding it with the zero value.
+
+// All values of the sequence, or the first error.
+func Collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	values := []T{}
+	for value, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Each value of the sequence transformed by the function, which may fail.
+func Map[T any, U any](seq iter.Seq2[T, error], transform func(T) (U, error)) iter.Seq2[U, error] {
+	return func(yield func(U, error) bool) {
+		for value, err := range seq {
+			var zero U
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			transformed, err := transform(value)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(transformed, nil) {
+				return
+			}
+		}
+	}
+}
+
+// The values of the sequence that the predicate keeps.
+func Filter[T any](seq iter.Seq2[T, error], keep func(T) bool) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for value, err := range seq {
+			if err != nil {
+				yield(value, err)
+				return
+			}
+			if keep(value) && !yield(value, nil) {
+				return
+			}
+		}
+	}
+}
+
+// At most the first count values of the sequence.
+func Take[T any](seq iter.Seq2[T, error], count int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if count <= 0 {
+			return
+		}
+		taken := 0
+		for value, err := range seq {
+			if !yield(value, err) || err != nil {
+				return
+			}
+			taken++
+			if taken >= count {
+				return
+			}
+		}
+	}
+}
+
+// The values of the sequence in slices of the size, the last of which may be smaller.
+func Batch[T any](seq iter.Seq2[T, error], size int) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		size := max(size, 1)
+		batch := make([]T, 0, size)
+		for value, err := range seq {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			batch = append(batch, value)
+			if len(batch) >= size {
+				if !yield(batch, nil) {
+					return
+				}
+				batch = make([]T, 0, size)
+			}
+		}
+		if len(batch) > 0 {
+			yield(batch, nil)
+		}
+	}
+}
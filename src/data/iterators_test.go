@@ -0,0 +1,164 @@
+package data
+
+import (
+	"errors"
+	"iter"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+var errTestSequence = errors.New("sequence failed")
+
+// The values in order, failing in place of the value at failAt if it is not negative. Counts the values pulled.
+func testSequence(values []int, failAt int, pulled *int) iter.Seq2[int, error] {
+	return func(yield func(int, error) bool) {
+		for i, value := range values {
+			*pulled++
+			if i == failAt {
+				yield(0, errTestSequence)
+				return
+			}
+			if !yield(value, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	var pulled int
+	values, err := Collect(testSequence([]int{1, 2, 3}, -1, &pulled))
+	if err != nil || !slices.Equal(values, []int{1, 2, 3}) {
+		t.Errorf("expected every value, got %v and %v", values, err)
+	}
+	values, err = Collect(testSequence([]int{1, 2, 3}, 1, &pulled))
+	if !errors.Is(err, errTestSequence) || values != nil {
+		t.Errorf("expected only the error, got %v and %v", values, err)
+	}
+}
+
+func TestMap(t *testing.T) {
+	var pulled int
+	values, err := Collect(Map(testSequence([]int{1, 2, 3}, -1, &pulled), func(value int) (string, error) {
+		return strconv.Itoa(value * 10), nil
+	}))
+	if err != nil || !slices.Equal(values, []string{"10", "20", "30"}) {
+		t.Errorf("expected every value transformed, got %v and %v", values, err)
+	}
+
+	// Errors from the sequence and from transforming stop it
+	_, err = Collect(Map(testSequence([]int{1, 2, 3}, 1, &pulled), func(value int) (int, error) { return value, nil }))
+	if !errors.Is(err, errTestSequence) {
+		t.Errorf("expected the sequence's error, got %v", err)
+	}
+	errTransform := errors.New("transform failed")
+	pulled = 0
+	_, err = Collect(Map(testSequence([]int{1, 2, 3}, -1, &pulled), func(value int) (int, error) {
+		if value == 2 {
+			return 0, errTransform
+		}
+		return value, nil
+	}))
+	if !errors.Is(err, errTransform) || pulled != 2 {
+		t.Errorf("expected the transform's error after 2 values, got %v after %v", err, pulled)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var pulled int
+	values, err := Collect(Filter(testSequence([]int{1, 2, 3, 4}, -1, &pulled), func(value int) bool { return value%2 == 0 }))
+	if err != nil || !slices.Equal(values, []int{2, 4}) {
+		t.Errorf("expected the even values, got %v and %v", values, err)
+	}
+	_, err = Collect(Filter(testSequence([]int{1, 2, 3, 4}, 2, &pulled), func(value int) bool { return false }))
+	if !errors.Is(err, errTestSequence) {
+		t.Errorf("expected the error even though no values are kept, got %v", err)
+	}
+}
+
+func TestTake(t *testing.T) {
+	tests := []struct {
+		count    int
+		expected []int
+		pulled   int
+	}{
+		{0, []int{}, 0},
+		{2, []int{1, 2}, 2},
+		{5, []int{1, 2, 3}, 3},
+	}
+	for _, test := range tests {
+		var pulled int
+		values, err := Collect(Take(testSequence([]int{1, 2, 3}, -1, &pulled), test.count))
+		if err != nil || !slices.Equal(values, test.expected) || pulled != test.pulled {
+			t.Errorf("%v: expected %v pulling %v, got %v pulling %v and %v", test.count, test.expected, test.pulled, values, pulled, err)
+		}
+	}
+	var pulled int
+	_, err := Collect(Take(testSequence([]int{1, 2, 3}, 1, &pulled), 3))
+	if !errors.Is(err, errTestSequence) {
+		t.Errorf("expected the error, got %v", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		size     int
+		expected [][]int
+	}{
+		{2, [][]int{{1, 2}, {3, 4}, {5}}},
+		{5, [][]int{{1, 2, 3, 4, 5}}},
+		{0, [][]int{{1}, {2}, {3}, {4}, {5}}},
+	}
+	for _, test := range tests {
+		var pulled int
+		batches, err := Collect(Batch(testSequence([]int{1, 2, 3, 4, 5}, -1, &pulled), test.size))
+		if err != nil || !slices.EqualFunc(batches, test.expected, slices.Equal) {
+			t.Errorf("%v: expected %v, got %v and %v", test.size, test.expected, batches, err)
+		}
+	}
+
+	// A failure drops the incomplete batch
+	var pulled int
+	batches := [][]int{}
+	var err error
+	for batch, batchErr := range Batch(testSequence([]int{1, 2, 3, 4, 5}, 3, &pulled), 2) {
+		if batchErr != nil {
+			err = batchErr
+			continue
+		}
+		batches = append(batches, batch)
+	}
+	if !errors.Is(err, errTestSequence) || !slices.EqualFunc(batches, [][]int{{1, 2}}, slices.Equal) {
+		t.Errorf("expected a batch then the error, got %v and %v", batches, err)
+	}
+}
+
+// Breaking out of each helper stops pulling from the sequence.
+func TestEarlyBreak(t *testing.T) {
+	helpers := map[string]func(seq iter.Seq2[int, error]) iter.Seq2[int, error]{
+		"Map": func(seq iter.Seq2[int, error]) iter.Seq2[int, error] {
+			return Map(seq, func(v int) (int, error) { return v, nil })
+		},
+		"Filter": func(seq iter.Seq2[int, error]) iter.Seq2[int, error] {
+			return Filter(seq, func(int) bool { return true })
+		},
+		"Take": func(seq iter.Seq2[int, error]) iter.Seq2[int, error] { return Take(seq, 10) },
+	}
+	for name, helper := range helpers {
+		var pulled int
+		for range helper(testSequence([]int{1, 2, 3, 4}, -1, &pulled)) {
+			break
+		}
+		if pulled != 1 {
+			t.Errorf("%v: expected 1 value pulled, got %v", name, pulled)
+		}
+	}
+	var pulled int
+	for range Batch(testSequence([]int{1, 2, 3, 4, 5}, -1, &pulled), 2) {
+		break
+	}
+	if pulled != 2 {
+		t.Errorf("Batch: expected 2 values pulled, got %v", pulled)
+	}
+}
@@ -4,7 +4,7 @@ package data
 var _ HasIDGetterAndSpreadable[StoreJob] = StoreJob{}
 
 type StoreJob struct {
-	HasID
+	HasID `db:"job_id"`
 	Job
 }
 
@@ -12,68 +12,40 @@ func (j StoreJob) GetID() string {
 	return j.ID
 }
 func (j StoreJob) Spread() []any {
-	return []any{
-		j.ID,
-		j.ParentID,
-		j.Category,
-		j.StartTimestamp,
-		j.EndTimestamp,
-	}
+	return SpreadColumns(j)
 }
-func (j StoreJob) SpreadForExport() []string {
-	return []string{
-		j.ID,
-		j.ParentID,
-		j.Category,
-		EpochSecondsToExcelDate(j.StartTimestamp),
-		EpochSecondsToExcelDate(j.EndTimestamp),
-	}
+func (j StoreJob) SpreadForExport(dates DateFormat) []string {
+	return SpreadColumnsForExport(j, dates)
 }
 func (j StoreJob) SpreadAddresses() (*StoreJob, []any) {
-	return &j, []any{
-		j.ID,
-		j.ParentID,
-		j.Category,
-		j.StartTimestamp,
-		j.EndTimestamp,
-	}
+	return &j, SpreadColumnAddresses(&j)
 }
 
 // A job that is not necessarily associated with a Store object.
 var _ Spreadable = Job{}
 
 type Job struct {
-	ParentID       string
-	Category       string
-	StartTimestamp int64
-	EndTimestamp   int64
+	ParentID       string `db:"parent_job_id"`
+	Category       string `db:"job_category"`
+	StartTimestamp int64  `db:"job_start_timestamp" export:"date"`
+	EndTimestamp   int64  `db:"job_end_timestamp" export:"date"`
 }
 
 func (j Job) Spread() []any {
-	return []any{
-		j.ParentID,
-		j.Category,
-		j.StartTimestamp,
-		j.EndTimestamp,
-	}
+	return SpreadColumns(j)
 }
 
 // A partial job for querying a store.
 var _ Spreadable = JobFilter{}
 
 type JobFilter struct {
-	ID             *string
-	ParentID       *string
-	Category       *string
-	StartTimestamp *int64
-	EndTimestamp   *int64
+	ID             *string `db:"job_id"`
+	ParentID       *string `db:"parent_job_id"`
+	Category       *string `db:"job_category"`
+	StartTimestamp *int64  `db:"job_start_timestamp"`
+	EndTimestamp   *int64  `db:"job_end_timestamp"`
 }
 
 func (j JobFilter) Spread() []any {
-	return []any{
-		j.ParentID,
-		j.Category,
-		j.StartTimestamp,
-		j.EndTimestamp,
-	}
+	return SpreadColumns(j)
 }
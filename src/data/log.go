@@ -1,12 +1,10 @@
 package data
 
-import "strconv"
-
 // A log as read from a store. Mutations are not implicitly persisted.
 var _ HasIDGetterAndSpreadable[StoreLog] = StoreLog{}
 
 type StoreLog struct {
-	HasID
+	HasID `db:"log_id"`
 	Log
 }
 
@@ -14,76 +12,42 @@ func (log StoreLog) GetID() string {
 	return log.ID
 }
 func (l StoreLog) Spread() []any {
-	return []any{
-		l.ID,
-		l.JobID,
-		l.Level,
-		l.StackTrace,
-		l.Description,
-		l.Timestamp,
-	}
+	return SpreadColumns(l)
 }
-func (l StoreLog) SpreadForExport() []string {
-	return []string{
-		l.ID,
-		l.JobID,
-		strconv.Itoa(l.Level),
-		l.StackTrace,
-		l.Description,
-		EpochSecondsToExcelDate(l.Timestamp),
-	}
+func (l StoreLog) SpreadForExport(dates DateFormat) []string {
+	return SpreadColumnsForExport(l, dates)
 }
 func (l StoreLog) SpreadAddresses() (*StoreLog, []any) {
-	return &l, []any{
-		&l.ID,
-		&l.JobID,
-		&l.Level,
-		&l.StackTrace,
-		&l.Description,
-		&l.Timestamp,
-	}
+	return &l, SpreadColumnAddresses(&l)
 }
 
 // A log that is not necessarily associated with a Store object.
 var _ Spreadable = Log{}
 
 type Log struct {
-	JobID       string
-	Level       int
-	StackTrace  string
-	Description string
-	Timestamp   int64
+	JobID       string `db:"job_id"`
+	Level       int    `db:"log_level"`
+	StackTrace  string `db:"log_stack_trace"`
+	Description string `db:"log_description"`
+	Timestamp   int64  `db:"log_timestamp" export:"date"`
 }
 
 func (l Log) Spread() []any {
-	return []any{
-		l.JobID,
-		l.Level,
-		l.StackTrace,
-		l.Description,
-		l.Timestamp,
-	}
+	return SpreadColumns(l)
 }
 
 // A partial log for querying a store.
 var _ Spreadable = LogFilter{}
 
 type LogFilter struct {
-	ID          *string
-	JobID       *string
-	Level       *int
-	StackTrace  *string
-	Description *string
-	Timestamp   *int64
+	ID          *string `db:"log_id"`
+	JobID       *string `db:"job_id"`
+	Level       *int    `db:"log_level"`
+	StackTrace  *string `db:"log_stack_trace"`
+	Description *string `db:"log_description"`
+	Timestamp   *int64  `db:"log_timestamp"`
 }
 
 func (l LogFilter) Spread() []any {
-	return []any{
-		l.ID,
-		l.JobID,
-		l.Level,
-		l.StackTrace,
-		l.Description,
-		l.Timestamp,
-	}
+	return SpreadColumns(l)
 }
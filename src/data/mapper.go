@@ -0,0 +1,163 @@
+package data
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// Row mapping derived from struct tags, so that a type's columns, values, scan targets and export row cannot drift apart.
+//
+// Fields tagged `db:"column"` map to that column, in field order, with embedded structs flattened in place.
+// An embedded HasID maps its ID to the column named by the tag on the embedding, e.g. HasID `db:"device_id"`.
+// Fields tagged `export:"date"` hold epoch seconds, exported as dates, and fields tagged `export:"device"` hold device IDs.
+// Other values export in their plain form, with nil pointers exported as empty strings.
+
+// A column of a type and the path to the field holding it.
+type columnField struct {
+	column    string
+	index     []int
+	isDate    bool
+	isDevice  bool
+	fieldType reflect.Type
+}
+
+var hasIDType = reflect.TypeFor[HasID]()
+
+// Mappings by type, built once per type.
+var mappings sync.Map
+
+func mappingOf(t reflect.Type) []columnField {
+	if cached, ok := mappings.Load(t); ok {
+		return cached.([]columnField)
+	}
+	fields := buildMapping(t, nil)
+	mappings.Store(t, fields)
+	return fields
+}
+
+func buildMapping(t reflect.Type, parentIndex []int) []columnField {
+	fields := []columnField{}
+	for _, field := range reflect.VisibleFields(t) {
+		// Visible fields include those of embedded structs, which are handled with their embedding
+		if len(field.Index) > 1 {
+			continue
+		}
+		index := append(append([]int{}, parentIndex...), field.Index...)
+		column, hasColumn := field.Tag.Lookup("db")
+		switch {
+		case field.Anonymous && field.Type == hasIDType:
+			if hasColumn {
+				idField, _ := hasIDType.FieldByName("ID")
+				fields = append(fields, columnField{column: column, index: append(index, idField.Index...), fieldType: idField.Type})
+			}
+		case field.Anonymous && field.Type.Kind() == reflect.Struct:
+			fields = append(fields, buildMapping(field.Type, index)...)
+		case hasColumn:
+			export := field.Tag.Get("export")
+			fields = append(fields, columnField{column: column, index: index, isDate: export == "date", isDevice: export == "device", fieldType: field.Type})
+		}
+	}
+	return fields
+}
+
+// The columns of the type, in order.
+func Columns[T any]() []string {
+	fields := mappingOf(reflect.TypeFor[T]())
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.column
+	}
+	return columns
+}
+
+// The columns of the type as described for export, in order.
+func ExportColumns[T any]() []ExportColumn {
+	fields := mappingOf(reflect.TypeFor[T]())
+	columns := make([]ExportColumn, len(fields))
+	for i, field := range fields {
+		kind := field.fieldType.Kind()
+		if kind == reflect.Pointer {
+			kind = field.fieldType.Elem().Kind()
+		}
+		columns[i] = ExportColumn{
+			Name:       field.column,
+			Kind:       kind,
+			IsNullable: field.fieldType.Kind() == reflect.Pointer,
+			IsDate:     field.isDate,
+			IsDevice:   field.isDevice,
+		}
+	}
+	return columns
+}
+
+// The values of the item's columns, in order.
+func SpreadColumns[T any](item T) []any {
+	value := reflect.ValueOf(item)
+	fields := mappingOf(value.Type())
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		values[i] = value.FieldByIndex(field.index).Interface()
+	}
+	return values
+}
+
+// Pointers to the item's columns, in order, for scanning into.
+func SpreadColumnAddresses[T any](item *T) []any {
+	value := reflect.ValueOf(item).Elem()
+	fields := mappingOf(value.Type())
+	addresses := make([]any, len(fields))
+	for i, field := range fields {
+		addresses[i] = value.FieldByIndex(field.index).Addr().Interface()
+	}
+	return addresses
+}
+
+// The item's columns formatted for export, in order, with dates in the format.
+func SpreadColumnsForExport[T any](item T, dates DateFormat) []string {
+	value := reflect.ValueOf(item)
+	fields := mappingOf(value.Type())
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = formatForExport(value.FieldByIndex(field.index), field.isDate, dates)
+	}
+	return values
+}
+
+// A column value from Spread formatted as text, as in SpreadForExport.
+func FormatForExport(column ExportColumn, value any, dates DateFormat) string {
+	return formatForExport(reflect.ValueOf(value), column.IsDate, dates)
+}
+
+func formatForExport(value reflect.Value, isDate bool, dates DateFormat) string {
+	if isDate && value.Kind() == reflect.Int64 {
+		return exportValue(reflect.ValueOf(dates.Value(value.Int())))
+	}
+	return exportValue(value)
+}
+
+// The value in its plain form, empty if it is nil or a nil pointer.
+func exportValue(value reflect.Value) string {
+	if !value.IsValid() {
+		return ""
+	}
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
@@ -0,0 +1,81 @@
+package data
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func testEvent() StoreEvent {
+	value := 21.5
+	return StoreEvent{
+		HasID: HasID{ID: "e1"},
+		Event: Event{
+			RequestDeviceID:     "d1",
+			EventSourceDeviceID: "d2",
+			ResponseTimestamp:   1700000060,
+			EventTimestamp:      1700000000,
+			FieldName:           "state.temperature",
+			FieldValue:          "21.5",
+			ValueType:           "number",
+			NumericValue:        &value,
+		},
+	}
+}
+
+// The embedded ID takes the column named on its embedding, and comes first.
+func TestColumns(t *testing.T) {
+	expected := []string{
+		"event_id", "request_device_id", "event_source_device_id", "response_timestamp", "event_timestamp",
+		"field_name", "field_value", "value_type", "numeric_value", "boolean_value",
+	}
+	if columns := Columns[StoreEvent](); !slices.Equal(columns, expected) {
+		t.Fatalf("expected %v, got %v", expected, columns)
+	}
+	if columns := Columns[StoreDevice](); columns[0] != "device_id" {
+		t.Fatalf("expected the device ID first, got %v", columns)
+	}
+}
+
+func TestExportColumns(t *testing.T) {
+	columns := ExportColumns[StoreEvent]()
+	byName := map[string]ExportColumn{}
+	for _, column := range columns {
+		byName[column.Name] = column
+	}
+	expected := map[string]ExportColumn{
+		"event_source_device_id": {Name: "event_source_device_id", Kind: reflect.String, IsDevice: true},
+		"event_timestamp":        {Name: "event_timestamp", Kind: reflect.Int64, IsDate: true},
+		"numeric_value":          {Name: "numeric_value", Kind: reflect.Float64, IsNullable: true},
+		"boolean_value":          {Name: "boolean_value", Kind: reflect.Bool, IsNullable: true},
+	}
+	for name, column := range expected {
+		if byName[name] != column {
+			t.Errorf("expected %+v, got %+v", column, byName[name])
+		}
+	}
+}
+
+// Scanning the spread values into the addresses reproduces the item.
+func TestSpreadRoundTrip(t *testing.T) {
+	event := testEvent()
+	values := event.Spread()
+	if len(values) != len(Columns[StoreEvent]()) || values[0] != "e1" || values[4] != int64(1700000000) {
+		t.Fatalf("unexpected values %v", values)
+	}
+	scanned, addresses := StoreEvent{}.SpreadAddresses()
+	for i, address := range addresses {
+		reflect.ValueOf(address).Elem().Set(reflect.ValueOf(values[i]))
+	}
+	if !reflect.DeepEqual(*scanned, event) {
+		t.Fatalf("expected %+v, got %+v", event, *scanned)
+	}
+}
+
+func TestSpreadForExport(t *testing.T) {
+	exported := testEvent().SpreadForExport(DateFormat{})
+	expected := []string{"e1", "d1", "d2", "2023-11-14 22:14:20", "2023-11-14 22:13:20", "state.temperature", "21.5", "number", "21.5", ""}
+	if !slices.Equal(exported, expected) {
+		t.Fatalf("expected %q, got %q", expected, exported)
+	}
+}
@@ -0,0 +1,96 @@
+package data
+
+// A condition on a store's columns, which stores build into parameterized queries.
+// Columns are named as in the store's table, and must be one of its columns.
+type Expression interface {
+	isExpression()
+}
+
+type Operator string
+
+const (
+	Equal              Operator = "="
+	NotEqual           Operator = "!="
+	LessThan           Operator = "<"
+	LessThanOrEqual    Operator = "<="
+	GreaterThan        Operator = ">"
+	GreaterThanOrEqual Operator = ">="
+)
+
+// The column compared to the value with the operator.
+type Comparison struct {
+	Column   string
+	Operator Operator
+	Value    any
+}
+
+// The column is one of the values. Matches nothing if there are no values.
+type In struct {
+	Column string
+	Values []any
+}
+
+// The column is within the inclusive range. Either end may be nil for an open range.
+type Between struct {
+	Column string
+	Min    any
+	Max    any
+}
+
+// The column matches the pattern, with % and _ as wildcards.
+type Like struct {
+	Column  string
+	Pattern string
+}
+
+// The column starts with the prefix, taken literally.
+type Prefix struct {
+	Column string
+	Prefix string
+}
+
+// The column is NULL.
+type IsNull struct {
+	Column string
+}
+
+// The expression does not hold.
+type Not struct {
+	Expression Expression
+}
+
+// All of the expressions hold. Holds if there are none.
+type And []Expression
+
+// Any of the expressions hold. Matches nothing if there are none.
+type Or []Expression
+
+func (Comparison) isExpression() {}
+func (In) isExpression()         {}
+func (Between) isExpression()    {}
+func (Like) isExpression()       {}
+func (Prefix) isExpression()     {}
+func (IsNull) isExpression()     {}
+func (Not) isExpression()        {}
+func (And) isExpression()        {}
+func (Or) isExpression()         {}
+
+// The column equals the value.
+func Eq(column string, value any) Comparison {
+	return Comparison{Column: column, Operator: Equal, Value: value}
+}
+
+// Sorts by the column, ascending unless Descending.
+type Order struct {
+	Column     string
+	Descending bool
+}
+
+// A query for a store's items. The struct filter and the expression must both match, and either may be empty.
+// Items are ordered by OrderBy and then by ID, and Limit caps how many are returned when positive.
+type Query[F any] struct {
+	Filter  F
+	Where   Expression
+	OrderBy []Order
+	Limit   int
+}
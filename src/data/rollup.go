@@ -0,0 +1,133 @@
+package data
+
+// How finely data is aggregated over time.
+type Resolution string
+
+const (
+	ResolutionRaw  Resolution = "raw"
+	ResolutionHour Resolution = "hour"
+	ResolutionDay  Resolution = "day"
+)
+
+// Length of the resolution's buckets, 0 for raw data.
+func (r Resolution) Seconds() int64 {
+	switch r {
+	case ResolutionHour:
+		return 60 * 60
+	case ResolutionDay:
+		return 24 * 60 * 60
+	}
+	return 0
+}
+
+// Start of the bucket of the resolution containing the timestamp.
+func (r Resolution) BucketStart(timestamp int64) int64 {
+	if r.Seconds() == 0 {
+		return timestamp
+	}
+	return timestamp - timestamp%r.Seconds()
+}
+
+// The finest resolution a time range should be charted at without pulling too many rows.
+// Up to 2 days uses raw data, up to 60 days hourly rollups, and daily rollups beyond that.
+func ResolutionForRange(startTime int64, endTime int64) Resolution {
+	length := endTime - startTime
+	switch {
+	case length <= 2*ResolutionDay.Seconds():
+		return ResolutionRaw
+	case length <= 60*ResolutionDay.Seconds():
+		return ResolutionHour
+	}
+	return ResolutionDay
+}
+
+// A point of a time series of a numeric field. Raw points have a count of 1 and the same value for all aggregates.
+type SeriesPoint struct {
+	Timestamp int64
+	Count     int64
+	Min       float64
+	Max       float64
+	Mean      float64
+	First     float64
+	Last      float64
+}
+
+// A rollup as read from a store. Mutations are not implicitly persisted.
+var _ HasIDGetterAndSpreadable[StoreRollup] = StoreRollup{}
+
+type StoreRollup struct {
+	HasID `db:"rollup_id"`
+	Rollup
+}
+
+func (r StoreRollup) GetID() string {
+	return r.ID
+}
+func (r StoreRollup) Spread() []any {
+	return SpreadColumns(r)
+}
+func (r StoreRollup) SpreadForExport(dates DateFormat) []string {
+	return SpreadColumnsForExport(r, dates)
+}
+func (r StoreRollup) SpreadAddresses() (*StoreRollup, []any) {
+	return &r, SpreadColumnAddresses(&r)
+}
+
+// The point of a rollup's time series.
+func (r StoreRollup) SeriesPoint() SeriesPoint {
+	return SeriesPoint{
+		Timestamp: r.BucketStart,
+		Count:     r.Count,
+		Min:       r.Min,
+		Max:       r.Max,
+		Mean:      r.Mean,
+		First:     r.First,
+		Last:      r.Last,
+	}
+}
+
+// Aggregates of a numeric field of a device over one bucket of time, not necessarily associated with a Store object.
+// Repeated reports of the same reading are counted once.
+var _ Spreadable = Rollup{}
+
+type Rollup struct {
+	DeviceID    string     `db:"device_id" export:"device"`
+	FieldName   string     `db:"field_name"`
+	Resolution  Resolution `db:"rollup_resolution"`
+	BucketStart int64      `db:"bucket_start" export:"date"`
+	Count       int64      `db:"rollup_count"`
+	Min         float64    `db:"rollup_min"`
+	Max         float64    `db:"rollup_max"`
+	Mean        float64    `db:"rollup_mean"`
+	// Earliest and latest readings within the bucket.
+	First float64 `db:"rollup_first"`
+	Last  float64 `db:"rollup_last"`
+	// When the rollup was last computed.
+	Timestamp int64 `db:"rollup_timestamp" export:"date"`
+}
+
+func (r Rollup) Spread() []any {
+	return SpreadColumns(r)
+}
+
+// A partial rollup for querying a store.
+var _ Spreadable = RollupFilter{}
+
+type RollupFilter struct {
+	ID          *string     `db:"rollup_id"`
+	DeviceID    *string     `db:"device_id"`
+	FieldName   *string     `db:"field_name"`
+	Resolution  *Resolution `db:"rollup_resolution"`
+	BucketStart *int64      `db:"bucket_start"`
+	Count       *int64      `db:"rollup_count"`
+	Min         *float64    `db:"rollup_min"`
+	Max         *float64    `db:"rollup_max"`
+	Mean        *float64    `db:"rollup_mean"`
+	First       *float64    `db:"rollup_first"`
+	Last        *float64    `db:"rollup_last"`
+	Timestamp   *int64      `db:"rollup_timestamp"`
+}
+
+func (r RollupFilter) Spread() []any {
+	return SpreadColumns(r)
+}
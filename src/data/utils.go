@@ -1,8 +1,18 @@
 package data
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Epoch seconds into Excel-readable date string.
 func EpochSecondsToExcelDate(seconds int64) string {
 	return time.Unix(seconds, 0).UTC().Format("2006-01-02 15:04:05")
 }
+
+// The lowest ID of items added at or after the time. IDs are UUIDv7s, which start with the milliseconds they were
+// created at, so IDs compare in the order they were created.
+func FirstIDAt(t time.Time) string {
+	milliseconds := fmt.Sprintf("%012x", t.UnixMilli())
+	return milliseconds[:8] + "-" + milliseconds[8:] + "-0000-0000-000000000000"
+}
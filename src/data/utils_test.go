@@ -0,0 +1,24 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samborkent/uuidv7"
+)
+
+func TestFirstIDAt(t *testing.T) {
+	before := FirstIDAt(time.Now().Add(-time.Millisecond))
+	id := uuidv7.New().String()
+	after := FirstIDAt(time.Now().Add(time.Millisecond))
+	if !(before < id && id < after) {
+		t.Fatalf("expected %v < %v < %v", before, id, after)
+	}
+	if FirstIDAt(time.UnixMilli(0x01a1527b50ec)) != "01a1527b-50ec-0000-0000-000000000000" {
+		t.Fatalf("unexpected ID %v", FirstIDAt(time.UnixMilli(0x01a1527b50ec)))
+	}
+	if len(before) != len(id) || strings.Count(before, "-") != 4 {
+		t.Fatalf("%v is not formatted like %v", before, id)
+	}
+}
@@ -0,0 +1,48 @@
+package exports
+
+import (
+	"com/data"
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// Values formatted as in SpreadForExport, with dates readable by spreadsheets.
+type csvWriter struct {
+	writer  *csv.Writer
+	columns []data.ExportColumn
+	dates   data.DateFormat
+}
+
+func newCSVWriter(w io.Writer, columns []data.ExportColumn, dates data.DateFormat, isAppending bool) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	if isAppending {
+		return &csvWriter{writer: writer, columns: columns, dates: dates}, nil
+	}
+	header := []string{}
+	for _, column := range columns {
+		header = append(header, column.Name)
+	}
+	err := writer.Write(header)
+	if err != nil {
+		return nil, fmt.Errorf("error writing CSV header: %w", err)
+	}
+	return &csvWriter{writer: writer, columns: columns, dates: dates}, nil
+}
+
+func (c *csvWriter) Write(values []any) error {
+	err := checkRowLength(c.columns, values)
+	if err != nil {
+		return err
+	}
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = data.FormatForExport(c.columns[i], value, c.dates)
+	}
+	return c.writer.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
@@ -0,0 +1,21 @@
+package exports
+
+import (
+	"com/connections/db"
+	"com/data"
+	"context"
+	"fmt"
+)
+
+// Every device by ID, for ExportOptions.Devices and pivoted exports.
+func Devices(ctx context.Context, dbConnection db.DBConnection) (map[string]data.StoreDevice, error) {
+	devices, err := data.Collect(dbConnection.Devices().Get(ctx, data.DeviceFilter{}).All(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error getting devices: %w", err)
+	}
+	devicesByID := map[string]data.StoreDevice{}
+	for _, device := range devices {
+		devicesByID[device.ID] = device
+	}
+	return devicesByID, nil
+}
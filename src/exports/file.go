@@ -0,0 +1,330 @@
+package exports
+
+import (
+	"com/data"
+	"com/utils"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Suffix of the manifest beside each export file, with a JSON line describing each export written to the file.
+const ManifestSuffix = ".manifest.jsonl"
+
+// A line of a manifest, describing the bytes one export wrote to a file.
+type ManifestEntry struct {
+	File        string                 `json:"file"`
+	Format      data.ExportFormat      `json:"format"`
+	Compression data.ExportCompression `json:"compression,omitempty"`
+	Rows        int64                  `json:"rows"`
+	// Where in the file the export's bytes start, after those of earlier exports when appending.
+	Offset int64 `json:"offset"`
+	Bytes  int64 `json:"bytes"`
+	// SHA-256 of the export's bytes as stored, after compression.
+	SHA256    string `json:"sha256"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// An export file being written in a format, through compression, into a sink.
+// Close completes it and writes its manifest entry. Abort abandons it, and does nothing once closed.
+// Rows that are invalid for the format are skipped until the options' error budget is spent. Report describes the outcome.
+type File struct {
+	name    string
+	options data.ExportOptions
+	sink    data.ExportSink
+	// The sink's writer, beneath the hashing and compression writers.
+	output     io.WriteCloser
+	hashed     *utils.HashingWriter
+	compressor io.WriteCloser
+	writer     Writer
+	offset     int64
+	rows       int64
+	isClosed   bool
+	isComplete bool
+	startTime  time.Time
+	skipped    int64
+	errors     []string
+	// Indexes of the device columns followed by device metadata, when exporting with devices.
+	deviceColumns []int
+}
+
+// Create an export file named after the label and the current time, with the format's and compression's extensions.
+func Create(ctx context.Context, label string, columns []data.ExportColumn, options data.ExportOptions) (*File, error) {
+	sink := sinkOf(options)
+	name := fileName(label, time.Now().Format("2006-01-02_15-04-05"), options)
+	output, err := sink.Create(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("error creating export file %v: %w", sink.Location(name), err)
+	}
+	return newFile(name, sink, output, 0, columns, options, false)
+}
+
+// Export the items into a file named after the label, such as the name of their table.
+// The file only appears once complete. The report is returned with errors too, describing how far the export got.
+func Items[S data.HasIDGetterAndSpreadable[S]](ctx context.Context, label string, items *data.IterablePaginatedData[S], options data.ExportOptions) (data.ExportReport, error) {
+	f, err := Create(ctx, label, data.ExportColumns[S](), options)
+	if err != nil {
+		return data.ExportReport{}, err
+	}
+	defer f.Abort()
+	for item, err := range items.All(ctx) {
+		if err != nil {
+			return f.Report(), fmt.Errorf("error fetching items from %v while exporting, resumable from cursor %v: %w", label, items.Cursor(), err)
+		}
+		err = f.Write(item.Spread())
+		if err != nil {
+			return f.Report(), fmt.Errorf("error writing export row with data %v: %w", item, err)
+		}
+	}
+	err = f.Close(ctx)
+	return f.Report(), err
+}
+
+// Open the export file named after the label and the current day for appending, creating it if it does not exist.
+// The sink must support appending and the format must be one that can be appended to.
+func OpenDaily(ctx context.Context, label string, columns []data.ExportColumn, options data.ExportOptions) (*File, error) {
+	if !IsAppendable(options.Format) {
+		return nil, fmt.Errorf("exports in format %v cannot be appended to", options.Format)
+	}
+	sink, ok := sinkOf(options).(data.AppendingExportSink)
+	if !ok {
+		return nil, fmt.Errorf("export sink %v cannot append to files", sinkOf(options).Location(""))
+	}
+	name := fileName(label, time.Now().Format("2006-01-02"), options)
+	output, size, err := sink.Append(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("error opening export file %v: %w", sink.Location(name), err)
+	}
+	return newFile(name, sink, output, size, columns, options, size > 0)
+}
+
+func newFile(name string, sink data.ExportSink, output io.WriteCloser, offset int64, columns []data.ExportColumn, options data.ExportOptions, isAppending bool) (*File, error) {
+	if options.Format == "" {
+		options.Format = data.ExportCSV
+	}
+	f := &File{name: name, options: options, sink: sink, output: output, hashed: utils.NewHashingWriter(output), offset: offset, startTime: time.Now()}
+	var w io.Writer = f.hashed
+	switch options.Compression {
+	case data.CompressNone:
+	case data.CompressGzip:
+		f.compressor = gzip.NewWriter(f.hashed)
+		w = f.compressor
+	case data.CompressZstd:
+		encoder, err := zstd.NewWriter(f.hashed)
+		if err != nil {
+			f.Abort()
+			return nil, fmt.Errorf("error creating zstd encoder: %w", err)
+		}
+		f.compressor = encoder
+		w = encoder
+	default:
+		f.Abort()
+		return nil, fmt.Errorf("unknown export compression %v", options.Compression)
+	}
+
+	if options.Devices != nil {
+		columns, f.deviceColumns = withDeviceColumns(columns)
+	}
+	var err error
+	if isAppending {
+		f.writer, err = NewAppendingWriter(w, options.Format, columns, options.Dates)
+	} else {
+		f.writer, err = NewWriter(w, options.Format, columns, options.Dates)
+	}
+	if err != nil {
+		f.Abort()
+		return nil, fmt.Errorf("error creating %v export writer: %w", options.Format, err)
+	}
+	return f, nil
+}
+
+// Write a row of the columns' values, as from Spread. Device metadata is added after device columns when exporting with devices.
+func (f *File) Write(values []any) error {
+	if f.deviceColumns != nil {
+		values = f.withDevices(values)
+	}
+	err := f.writer.Write(values)
+	if errors.Is(err, ErrInvalidRow) && f.skipped < int64(f.options.ErrorBudget) {
+		f.skipped++
+		if len(f.errors) < data.MaxReportedExportErrors {
+			f.errors = append(f.errors, err.Error())
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f.rows++
+	return nil
+}
+
+// Finish writing the file, then add an entry for it to its manifest.
+func (f *File) Close(ctx context.Context) error {
+	if f.isClosed {
+		return nil
+	}
+	f.isClosed = true
+	err := f.writer.Close()
+	if err == nil && f.compressor != nil {
+		err = f.compressor.Close()
+	}
+	if err != nil {
+		abort(f.output, err)
+		return fmt.Errorf("error finishing export file %v: %w", f.Location(), err)
+	}
+	err = f.output.Close()
+	if err != nil {
+		return fmt.Errorf("error closing export file %v: %w", f.Location(), err)
+	}
+	f.isComplete = true
+	return f.writeManifest(ctx)
+}
+
+// Abandon the file if it is not closed. Sinks that can, such as object storage, discard it.
+func (f *File) Abort() {
+	if f.isClosed {
+		return
+	}
+	f.isClosed = true
+	abort(f.output, errors.New("export aborted"))
+}
+
+func (f *File) Name() string {
+	return f.name
+}
+
+// Where the file is written, such as its path.
+func (f *File) Location() string {
+	return f.sink.Location(f.name)
+}
+
+// Rows written so far.
+func (f *File) Rows() int64 {
+	return f.rows
+}
+
+// The export so far, with its location once it is complete.
+func (f *File) Report() data.ExportReport {
+	report := data.ExportReport{
+		Rows:        f.rows,
+		SkippedRows: f.skipped,
+		Errors:      f.errors,
+		Bytes:       f.hashed.Count(),
+		Duration:    time.Since(f.startTime),
+	}
+	if f.isComplete {
+		report.File = f.Location()
+	}
+	return report
+}
+
+func (f *File) writeManifest(ctx context.Context) error {
+	entry, err := json.Marshal(ManifestEntry{
+		File:        f.name,
+		Format:      f.options.Format,
+		Compression: f.options.Compression,
+		Rows:        f.rows,
+		Offset:      f.offset,
+		Bytes:       f.hashed.Count(),
+		SHA256:      f.hashed.SHA256(),
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding manifest of %v: %w", f.Location(), err)
+	}
+	manifestName := f.name + ManifestSuffix
+	var manifest io.WriteCloser
+	if appendingSink, ok := f.sink.(data.AppendingExportSink); ok && f.offset > 0 {
+		manifest, _, err = appendingSink.Append(ctx, manifestName)
+	} else {
+		manifest, err = f.sink.Create(ctx, manifestName)
+	}
+	if err != nil {
+		return fmt.Errorf("error opening manifest %v: %w", f.sink.Location(manifestName), err)
+	}
+	_, err = manifest.Write(append(entry, '\n'))
+	if err != nil {
+		abort(manifest, err)
+		return fmt.Errorf("error writing manifest %v: %w", f.sink.Location(manifestName), err)
+	}
+	err = manifest.Close()
+	if err != nil {
+		return fmt.Errorf("error closing manifest %v: %w", f.sink.Location(manifestName), err)
+	}
+	return nil
+}
+
+// The columns with the name, kind and brand of the device after each device column,
+// and the indexes of the device columns among the given ones.
+func withDeviceColumns(columns []data.ExportColumn) ([]data.ExportColumn, []int) {
+	expanded := []data.ExportColumn{}
+	deviceColumns := []int{}
+	for i, column := range columns {
+		expanded = append(expanded, column)
+		if !column.IsDevice {
+			continue
+		}
+		deviceColumns = append(deviceColumns, i)
+		for _, name := range deviceMetadataColumns {
+			expanded = append(expanded, data.ExportColumn{Name: name, Kind: reflect.String, IsNullable: true})
+		}
+	}
+	return expanded, deviceColumns
+}
+
+var deviceMetadataColumns = []string{"device_name", "device_kind", "device_brand"}
+
+// The values with the metadata of the device after each device column, nil for unknown devices.
+func (f *File) withDevices(values []any) []any {
+	expanded := make([]any, 0, len(values)+len(f.deviceColumns)*len(deviceMetadataColumns))
+	previous := 0
+	for _, i := range f.deviceColumns {
+		if i >= len(values) {
+			break
+		}
+		expanded = append(expanded, values[previous:i+1]...)
+		previous = i + 1
+		id, _ := plainValue(values[i]).(string)
+		device, ok := f.options.Devices[id]
+		if !ok {
+			expanded = append(expanded, make([]any, len(deviceMetadataColumns))...)
+			continue
+		}
+		expanded = append(expanded, &device.Name, &device.Kind, &device.Brand)
+	}
+	return append(expanded, values[previous:]...)
+}
+
+// Close the writer, discarding what was written if it supports that, as uploads do.
+func abort(w io.WriteCloser, err error) {
+	if discarding, ok := w.(interface{ CloseWithError(error) error }); ok {
+		_ = discarding.CloseWithError(err)
+		return
+	}
+	_ = w.Close()
+}
+
+func sinkOf(options data.ExportOptions) data.ExportSink {
+	if options.Sink == nil {
+		return DefaultSink()
+	}
+	return options.Sink
+}
+
+func fileName(label string, date string, options data.ExportOptions) string {
+	name := fmt.Sprintf("%s_%v.%s", label, date, Extension(options.Format))
+	switch options.Compression {
+	case data.CompressGzip:
+		name += ".gz"
+	case data.CompressZstd:
+		name += ".zst"
+	}
+	return name
+}
@@ -0,0 +1,133 @@
+package exports
+
+import (
+	"com/connections/db"
+	"com/data"
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Names of incremental exports, which name their files and marks.
+var exportNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,60}$`)
+
+// How old events must be to be exported incrementally. Events are given their IDs before they are committed, so a
+// recent event may become visible after one with a greater ID has already moved the mark past it.
+const incrementalLag = time.Minute
+
+// A named export of events that exports only events added since its previous run, remembered by its mark.
+type IncrementalExport struct {
+	Name    string
+	Options data.ExportOptions
+	// Append to a file per day, named after the export and the day, rather than writing a file per run.
+	// Only CSV and NDJSON can be appended to, in sinks that support appending.
+	IsDaily bool
+}
+
+// Export events added since the export's mark, then move the mark past them. The report's file is empty if there were none.
+// The mark only moves once the file is written, so a failed run is exported again by the next one.
+// Events added within the last minute are left to the next run.
+func ExportIncremental(ctx context.Context, dbConnection db.DBConnection, export IncrementalExport) (data.ExportReport, error) {
+	if !exportNamePattern.MatchString(export.Name) {
+		return data.ExportReport{}, fmt.Errorf("export name %q must be 1 to 60 letters, digits, underscores or dashes", export.Name)
+	}
+	if export.IsDaily && !IsAppendable(export.Options.Format) {
+		return data.ExportReport{}, fmt.Errorf("daily exports cannot be in format %v, which cannot be appended to", export.Options.Format)
+	}
+	mark, err := getMark(ctx, dbConnection, export.Name)
+	if err != nil {
+		return data.ExportReport{}, err
+	}
+
+	// New events, in the order they were added, up to those still possibly being committed
+	where := data.And{data.Comparison{Column: "event_id", Operator: data.LessThan, Value: data.FirstIDAt(time.Now().Add(-incrementalLag))}}
+	if mark != nil {
+		where = append(where, data.Comparison{Column: "event_id", Operator: data.GreaterThan, Value: mark.LastEventID})
+	}
+	query := data.Query[data.EventFilter]{Where: where}
+	events := dbConnection.Events().Find(ctx, query)
+	first, err := events.Next(ctx)
+	if err != nil {
+		return data.ExportReport{}, fmt.Errorf("error getting events after mark %v: %w", mark, err)
+	}
+	if first == nil {
+		return data.ExportReport{}, nil
+	}
+
+	// Write
+	f, err := openIncrementalFile(ctx, export)
+	if err != nil {
+		return data.ExportReport{}, err
+	}
+	defer f.Abort()
+	last := *first
+	err = f.Write(first.Spread())
+	if err != nil {
+		return f.Report(), fmt.Errorf("error writing export row with data %v: %w", first, err)
+	}
+	for event, err := range events.All(ctx) {
+		if err != nil {
+			return f.Report(), fmt.Errorf("error fetching events while exporting %v, resumable from cursor %v: %w", export.Name, events.Cursor(), err)
+		}
+		err = f.Write(event.Spread())
+		if err != nil {
+			return f.Report(), fmt.Errorf("error writing export row with data %v: %w", event, err)
+		}
+		last = event
+	}
+	err = f.Close(ctx)
+	if err != nil {
+		return f.Report(), err
+	}
+
+	// Move the mark
+	err = saveMark(ctx, dbConnection, export.Name, mark, last, f.Rows())
+	if err != nil {
+		return f.Report(), err
+	}
+	return f.Report(), nil
+}
+
+func openIncrementalFile(ctx context.Context, export IncrementalExport) (*File, error) {
+	columns := data.ExportColumns[data.StoreEvent]()
+	if export.IsDaily {
+		return OpenDaily(ctx, export.Name, columns, export.Options)
+	}
+	return Create(ctx, export.Name, columns, export.Options)
+}
+
+// The export's mark, nil if it has never run.
+func getMark(ctx context.Context, dbConnection db.DBConnection, name string) (*data.StoreExportMark, error) {
+	mark, err := dbConnection.ExportMarks().Find(ctx, data.Query[data.ExportMarkFilter]{
+		Filter: data.ExportMarkFilter{ExportName: &name},
+		Limit:  1,
+	}).Next(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting mark of export %v: %w", name, err)
+	}
+	return mark, nil
+}
+
+func saveMark(ctx context.Context, dbConnection db.DBConnection, name string, mark *data.StoreExportMark, last data.StoreEvent, rows int64) error {
+	newMark := data.ExportMark{
+		ExportName:         name,
+		LastEventID:        last.ID,
+		LastEventTimestamp: last.EventTimestamp,
+		ExportedCount:      rows,
+		Timestamp:          time.Now().UTC().Unix(),
+	}
+	if mark == nil {
+		_, err := dbConnection.ExportMarks().Add(ctx, newMark)
+		if err != nil {
+			return fmt.Errorf("error adding mark of export %v: %w", name, err)
+		}
+		return nil
+	}
+	newMark.ExportedCount += mark.ExportedCount
+	err := dbConnection.ExportMarks().Edit(ctx, data.StoreExportMark{HasID: mark.HasID, ExportMark: newMark})
+	if err != nil {
+		return fmt.Errorf("error moving mark of export %v: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package exports
+
+import (
+	"com/connections/db/dbfake"
+	"com/data"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Restore events with IDs of when they were added, the given durations ago, as Add only gives IDs of now.
+func restoreEvents(t *testing.T, dbConnection *dbfake.Connection, ages ...time.Duration) []string {
+	t.Helper()
+	ids := []string{}
+	for i, age := range ages {
+		id := data.FirstIDAt(time.Now().Add(-age))[:24] + fmt.Sprintf("%012x", i)
+		err := dbConnection.Events().Restore(t.Context(), data.StoreEvent{
+			HasID: data.HasID{ID: id},
+			Event: data.Event{EventSourceDeviceID: "device", EventTimestamp: time.Now().Add(-age).Unix(), FieldName: "state"}.WithValue("open"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// The IDs of the events in the CSV export at the path.
+func exportedIDs(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, record := range records[1:] {
+		ids = append(ids, record[0])
+	}
+	return ids
+}
+
+func assertMark(t *testing.T, dbConnection *dbfake.Connection, name string, lastEventID string, exportedCount int64) {
+	t.Helper()
+	mark, err := getMark(t.Context(), dbConnection, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mark == nil || mark.LastEventID != lastEventID || mark.ExportedCount != exportedCount {
+		t.Fatalf("expected the mark at %v after %v events, got %v", lastEventID, exportedCount, mark)
+	}
+}
+
+func TestExportIncrementalMovesMark(t *testing.T) {
+	ctx := t.Context()
+	dbConnection := dbfake.NewConnection()
+	sink := &LocalSink{Dir: t.TempDir()}
+	export := IncrementalExport{Name: "incremental", Options: data.ExportOptions{Sink: sink}}
+
+	report, err := ExportIncremental(ctx, dbConnection, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.File != "" || report.Rows != 0 {
+		t.Fatalf("expected nothing exported without events, got %v", report)
+	}
+	assertDirectory(t, sink.Dir)
+	mark, err := getMark(ctx, dbConnection, export.Name)
+	if err != nil || mark != nil {
+		t.Fatalf("expected no mark without events, got %v, %v", mark, err)
+	}
+
+	// Events from within the lag may still be committing, so are left to a later run
+	ids := restoreEvents(t, dbConnection, 10*time.Minute, 9*time.Minute, 8*time.Minute, 10*time.Second)
+	report, err = ExportIncremental(ctx, dbConnection, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 3 || !slices.Equal(exportedIDs(t, report.File), ids[:3]) {
+		t.Fatalf("expected the events older than the lag exported, got %v in %v", exportedIDs(t, report.File), report)
+	}
+	assertMark(t, dbConnection, export.Name, ids[2], 3)
+
+	report, err = ExportIncremental(ctx, dbConnection, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.File != "" {
+		t.Fatalf("expected nothing exported without new events, got %v", report)
+	}
+	assertMark(t, dbConnection, export.Name, ids[2], 3)
+
+	added := restoreEvents(t, dbConnection, 5*time.Minute)
+	report, err = ExportIncremental(ctx, dbConnection, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 1 || !slices.Equal(exportedIDs(t, report.File), added) {
+		t.Fatalf("expected only the new event exported, got %v", exportedIDs(t, report.File))
+	}
+	assertMark(t, dbConnection, export.Name, added[0], 4)
+}
+
+func TestExportIncrementalDaily(t *testing.T) {
+	ctx := t.Context()
+	dbConnection := dbfake.NewConnection()
+	sink := &LocalSink{Dir: t.TempDir()}
+	export := IncrementalExport{Name: "daily", Options: data.ExportOptions{Sink: sink}, IsDaily: true}
+
+	ids := restoreEvents(t, dbConnection, 10*time.Minute, 9*time.Minute)
+	_, err := ExportIncremental(ctx, dbConnection, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids = append(ids, restoreEvents(t, dbConnection, 5*time.Minute)...)
+	report, err := ExportIncremental(ctx, dbConnection, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(sink.Dir, "daily_*.csv"))
+	if len(files) != 1 || files[0] != report.File {
+		t.Fatalf("expected both runs in one daily file, got %v", files)
+	}
+	if exported := exportedIDs(t, report.File); !slices.Equal(exported, ids) {
+		t.Fatalf("expected %v appended under one header, got %v", ids, exported)
+	}
+	if entries := readManifest(t, report.File+ManifestSuffix); len(entries) != 2 || entries[0].Rows != 2 || entries[1].Rows != 1 {
+		t.Fatalf("expected a manifest entry per run, got %v", entries)
+	}
+	assertMark(t, dbConnection, export.Name, ids[2], 3)
+}
+
+func TestExportIncrementalInvalid(t *testing.T) {
+	sink := &LocalSink{Dir: t.TempDir()}
+	invalid := map[string]IncrementalExport{
+		"name with a slash":        {Name: "a/b", Options: data.ExportOptions{Sink: sink}},
+		"empty name":               {Options: data.ExportOptions{Sink: sink}},
+		"daily in a closed format": {Name: "daily", Options: data.ExportOptions{Sink: sink, Format: data.ExportParquet}, IsDaily: true},
+	}
+	for name, export := range invalid {
+		_, err := ExportIncremental(t.Context(), dbfake.NewConnection(), export)
+		if err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package exports
+
+import (
+	"bufio"
+	"com/data"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A JSON object per line keyed by column, with typed values, nulls, and dates in RFC 3339.
+type ndjsonWriter struct {
+	writer  *bufio.Writer
+	columns []data.ExportColumn
+	dates   data.DateFormat
+	// Column names encoded as JSON keys, so they are encoded once.
+	keys [][]byte
+}
+
+func newNDJSONWriter(w io.Writer, columns []data.ExportColumn, dates data.DateFormat) *ndjsonWriter {
+	keys := [][]byte{}
+	for _, column := range columns {
+		key, _ := json.Marshal(column.Name)
+		keys = append(keys, key)
+	}
+	return &ndjsonWriter{writer: bufio.NewWriter(w), columns: columns, dates: dates, keys: keys}
+}
+
+func (n *ndjsonWriter) Write(values []any) error {
+	err := checkRowLength(n.columns, values)
+	if err != nil {
+		return err
+	}
+	line := []byte{'{'}
+	for i, value := range values {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = append(line, n.keys[i]...)
+		line = append(line, ':')
+		plain := plainValue(value)
+		if n.columns[i].IsDate && plain != nil {
+			plain = dateOf(plain, n.dates).Format(time.RFC3339)
+		}
+		encoded, err := json.Marshal(plain)
+		if err != nil {
+			return fmt.Errorf("%w: error encoding %v of column %v: %w", ErrInvalidRow, value, n.columns[i].Name, err)
+		}
+		line = append(line, encoded...)
+	}
+	line = append(line, '}', '\n')
+	_, err = n.writer.Write(line)
+	return err
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.writer.Flush()
+}
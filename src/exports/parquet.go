@@ -0,0 +1,100 @@
+package exports
+
+import (
+	"com/data"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/snappy"
+)
+
+// Rows per Parquet row group, bounding the memory held while writing and letting readers skip groups.
+const ParquetRowGroupSize = 50_000
+
+// Typed Parquet columns: strings, 64 bit integers, doubles, booleans, and dates as UTC timestamps in milliseconds,
+// which readers show in their own time zone.
+// Nullable columns are optional.
+type parquetWriter struct {
+	writer  *parquet.Writer
+	columns []data.ExportColumn
+	// Index of each column among the schema's leaves, which are ordered by name.
+	leafIndexes []int
+}
+
+func newParquetWriter(w io.Writer, columns []data.ExportColumn) *parquetWriter {
+	group := parquet.Group{}
+	for _, column := range columns {
+		group[column.Name] = parquetNode(column)
+	}
+	schema := parquet.NewSchema("export", group)
+	leafIndexes := []int{}
+	for _, column := range columns {
+		leaf, _ := schema.Lookup(column.Name)
+		leafIndexes = append(leafIndexes, leaf.ColumnIndex)
+	}
+	return &parquetWriter{
+		writer: parquet.NewWriter(w,
+			schema,
+			parquet.MaxRowsPerRowGroup(ParquetRowGroupSize),
+			parquet.Compression(&snappy.Codec{}),
+		),
+		columns:     columns,
+		leafIndexes: leafIndexes,
+	}
+}
+
+func parquetNode(column data.ExportColumn) parquet.Node {
+	var node parquet.Node
+	switch {
+	case column.IsDate:
+		node = parquet.Timestamp(parquet.Millisecond)
+	case column.Kind == reflect.Int || column.Kind == reflect.Int64:
+		node = parquet.Int(64)
+	case column.Kind == reflect.Float64:
+		node = parquet.Leaf(parquet.DoubleType)
+	case column.Kind == reflect.Bool:
+		node = parquet.Leaf(parquet.BooleanType)
+	default:
+		node = parquet.String()
+	}
+	if column.IsNullable {
+		return parquet.Optional(node)
+	}
+	return node
+}
+
+func (p *parquetWriter) Write(values []any) error {
+	err := checkRowLength(p.columns, values)
+	if err != nil {
+		return err
+	}
+	row := make(parquet.Row, len(values))
+	for i, value := range values {
+		column := p.columns[i]
+		plain := plainValue(value)
+		var parquetValue parquet.Value
+		switch {
+		case plain == nil:
+			parquetValue = parquet.NullValue()
+		case column.IsDate:
+			parquetValue = parquet.Int64Value(dateOf(plain, data.DateFormat{}).UnixMilli())
+		case column.Kind == reflect.String:
+			parquetValue = parquet.ByteArrayValue([]byte(fmt.Sprint(plain)))
+		default:
+			parquetValue = parquet.ValueOf(plain)
+		}
+		definitionLevel := 0
+		if column.IsNullable && plain != nil {
+			definitionLevel = 1
+		}
+		row[p.leafIndexes[i]] = parquetValue.Level(0, definitionLevel, p.leafIndexes[i])
+	}
+	_, err = p.writer.WriteRows([]parquet.Row{row})
+	return err
+}
+
+func (p *parquetWriter) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,185 @@
+package exports
+
+import (
+	"com/connections/db"
+	"com/data"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Columns of pivoted exports before the field columns.
+var pivotColumns = []data.ExportColumn{
+	{Name: "device_id", Kind: reflect.String},
+	{Name: "device_name", Kind: reflect.String},
+	{Name: "device_kind", Kind: reflect.String},
+	{Name: "timestamp", Kind: reflect.Int64, IsDate: true},
+}
+
+// Export events pivoted into a row per device and reading, with a column per field, into a file named after events_pivoted.
+// Field columns take the type of the field's first event, so numbers stay numbers. Returns the export's report, also when it fails.
+func ExportPivotedEvents(ctx context.Context, dbConnection db.DBConnection, pivot data.PivotOptions, options data.ExportOptions) (data.ExportReport, error) {
+	if pivot.BucketSeconds < 0 {
+		return data.ExportReport{}, fmt.Errorf("bucket length must not be negative, got %v", pivot.BucketSeconds)
+	}
+	filter := data.EventFilter{EventSourceDeviceID: pivot.DeviceID}
+
+	// Columns
+	fields := pivot.Fields
+	if len(fields) == 0 {
+		var err error
+		fields, err = fieldsInRange(ctx, dbConnection, filter, pivot)
+		if err != nil {
+			return data.ExportReport{}, err
+		}
+	}
+	fieldColumns := []data.ExportColumn{}
+	for _, field := range fields {
+		column, err := fieldColumn(ctx, dbConnection, filter, field)
+		if err != nil {
+			return data.ExportReport{}, err
+		}
+		fieldColumns = append(fieldColumns, column)
+	}
+
+	// Device names and kinds
+	devicesByID, err := Devices(ctx, dbConnection)
+	if err != nil {
+		return data.ExportReport{}, err
+	}
+
+	// Write
+	f, err := Create(ctx, "events_pivoted", append(slices.Clone(pivotColumns), fieldColumns...), options)
+	if err != nil {
+		return data.ExportReport{}, err
+	}
+	defer f.Abort()
+	err = writePivotedRows(ctx, f, dbConnection.Events().Find(ctx, pivotQuery(filter, fields, pivot)), devicesByID, fieldColumns, pivot.BucketSeconds)
+	if err != nil {
+		return f.Report(), err
+	}
+	err = f.Close(ctx)
+	return f.Report(), err
+}
+
+// Events of the fields in range, ordered so that each device's readings are consecutive and in time order.
+func pivotQuery(filter data.EventFilter, fields []string, pivot data.PivotOptions) data.Query[data.EventFilter] {
+	fieldValues := []any{}
+	for _, field := range fields {
+		fieldValues = append(fieldValues, field)
+	}
+	where := data.And{data.In{Column: "field_name", Values: fieldValues}}
+	if pivot.StartTime != nil {
+		where = append(where, data.Comparison{Column: "event_timestamp", Operator: data.GreaterThanOrEqual, Value: *pivot.StartTime})
+	}
+	if pivot.EndTime != nil {
+		where = append(where, data.Comparison{Column: "event_timestamp", Operator: data.LessThan, Value: *pivot.EndTime})
+	}
+	return data.Query[data.EventFilter]{
+		Filter:  filter,
+		Where:   where,
+		OrderBy: []data.Order{{Column: "event_source_device_id"}, {Column: "event_timestamp"}},
+	}
+}
+
+// Names of fields with events in range, sorted.
+func fieldsInRange(ctx context.Context, dbConnection db.DBConnection, filter data.EventFilter, pivot data.PivotOptions) ([]string, error) {
+	counts, err := dbConnection.Events().Aggregate(ctx, filter, pivot.StartTime, pivot.EndTime, data.AggregateQuery{
+		Function: data.AggregateCount,
+		GroupBy:  []data.AggregateGroup{data.GroupByField},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error finding fields to pivot: %w", err)
+	}
+	fields := []string{}
+	for _, count := range counts {
+		fields = append(fields, count.FieldName)
+	}
+	slices.Sort(fields)
+	return fields, nil
+}
+
+// A nullable column typed after the field's first event, as a string if it has none.
+func fieldColumn(ctx context.Context, dbConnection db.DBConnection, filter data.EventFilter, field string) (data.ExportColumn, error) {
+	column := data.ExportColumn{Name: field, Kind: reflect.String, IsNullable: true}
+	filter.FieldName = &field
+	first, err := dbConnection.Events().Find(ctx, data.Query[data.EventFilter]{Filter: filter, Limit: 1}).Next(ctx)
+	if err != nil {
+		return column, fmt.Errorf("error getting type of field %v: %w", field, err)
+	}
+	if first == nil {
+		return column, nil
+	}
+	switch first.ValueType {
+	case data.ValueNumber:
+		column.Kind = reflect.Float64
+	case data.ValueBoolean:
+		column.Kind = reflect.Bool
+	}
+	return column, nil
+}
+
+// Write a row per device and bucket, from events ordered by device and time. Later events in a bucket overwrite earlier ones.
+func writePivotedRows(ctx context.Context, writer *File, events *data.IterablePaginatedData[data.StoreEvent], devices map[string]data.StoreDevice, fieldColumns []data.ExportColumn, bucketSeconds int64) error {
+	fieldIndexes := map[string]int{}
+	for i, column := range fieldColumns {
+		fieldIndexes[column.Name] = i
+	}
+	var row []any
+	var rowDeviceID string
+	var rowBucket int64
+	writeRow := func() error {
+		if row == nil {
+			return nil
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return fmt.Errorf("error writing pivoted row %v: %w", row, err)
+		}
+		return nil
+	}
+
+	for event, err := range events.All(ctx) {
+		if err != nil {
+			return fmt.Errorf("error fetching events while exporting, resumable from cursor %v: %w", events.Cursor(), err)
+		}
+		bucket := event.EventTimestamp
+		if bucketSeconds > 0 {
+			bucket -= bucket % bucketSeconds
+		}
+
+		// Start a new row for each device and bucket
+		if row == nil || event.EventSourceDeviceID != rowDeviceID || bucket != rowBucket {
+			err = writeRow()
+			if err != nil {
+				return err
+			}
+			device := devices[event.EventSourceDeviceID]
+			row = []any{event.EventSourceDeviceID, device.Name, device.Kind, bucket}
+			for range fieldColumns {
+				row = append(row, nil)
+			}
+			rowDeviceID, rowBucket = event.EventSourceDeviceID, bucket
+		}
+
+		index, ok := fieldIndexes[event.FieldName]
+		if !ok {
+			continue
+		}
+		row[len(pivotColumns)+index] = fieldValue(fieldColumns[index], event)
+	}
+	return writeRow()
+}
+
+// The event's value in the column's type, nil if it has none.
+func fieldValue(column data.ExportColumn, event data.StoreEvent) any {
+	switch column.Kind {
+	case reflect.Float64:
+		return event.NumericValue
+	case reflect.Bool:
+		return event.BooleanValue
+	default:
+		return &event.FieldValue
+	}
+}
@@ -0,0 +1,125 @@
+package exports
+
+import (
+	"com/data"
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var pivotFieldColumns = []data.ExportColumn{
+	{Name: "state.temperature", Kind: reflect.Float64, IsNullable: true},
+	{Name: "state.open", Kind: reflect.Bool, IsNullable: true},
+	{Name: "state.mode", Kind: reflect.String, IsNullable: true},
+}
+
+func pivotEvent(deviceID string, timestamp int64, field string, value any) data.StoreEvent {
+	return data.StoreEvent{Event: data.Event{EventSourceDeviceID: deviceID, EventTimestamp: timestamp, FieldName: field}.WithValue(value)}
+}
+
+// Pivot the events, ordered by device and time, into a CSV file, returning its content.
+func pivotEvents(t *testing.T, events []data.StoreEvent, bucketSeconds int64) string {
+	t.Helper()
+	ctx := context.Background()
+	sink := &LocalSink{Dir: t.TempDir()}
+	f, err := Create(ctx, "events_pivoted", append(append([]data.ExportColumn{}, pivotColumns...), pivotFieldColumns...), data.ExportOptions{Sink: sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Abort()
+	iterable := data.NewIterablePaginatedData(func(ctx context.Context, lastID *string) ([]data.StoreEvent, *string, error) {
+		if lastID != nil {
+			return nil, lastID, nil
+		}
+		last := "last"
+		return events, &last, nil
+	}, "")
+	devices := map[string]data.StoreDevice{
+		"d1": {Device: data.Device{Name: "Freezer", Kind: "THSensor"}},
+		"d2": {Device: data.Device{Name: "Back door", Kind: "DoorSensor"}},
+	}
+	err = writePivotedRows(ctx, f, &iterable, devices, pivotFieldColumns, bucketSeconds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Close(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(f.Location())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestPivotGroupsByDeviceAndBucket(t *testing.T) {
+	events := []data.StoreEvent{
+		pivotEvent("d1", 0, "state.temperature", json.Number("-18")),
+		pivotEvent("d1", 0, "state.mode", "auto"),
+		pivotEvent("d1", 30, "state.temperature", json.Number("-17.5")),
+		pivotEvent("d1", 30, "state.battery", json.Number("4")),
+		pivotEvent("d1", 60, "state.temperature", json.Number("-17")),
+		pivotEvent("d2", 10, "state.open", true),
+		pivotEvent("d3", 10, "state.open", false),
+	}
+	header := "device_id,device_name,device_kind,timestamp,state.temperature,state.open,state.mode\n"
+	tests := []struct {
+		bucketSeconds int64
+		expected      string
+	}{
+		{0, header +
+			"d1,Freezer,THSensor,1970-01-01 00:00:00,-18,,auto\n" +
+			"d1,Freezer,THSensor,1970-01-01 00:00:30,-17.5,,\n" +
+			"d1,Freezer,THSensor,1970-01-01 00:01:00,-17,,\n" +
+			"d2,Back door,DoorSensor,1970-01-01 00:00:10,,true,\n" +
+			"d3,,,1970-01-01 00:00:10,,false,\n"},
+		// Later readings in a bucket overwrite earlier ones, and fields not read in it stay empty
+		{60, header +
+			"d1,Freezer,THSensor,1970-01-01 00:00:00,-17.5,,auto\n" +
+			"d1,Freezer,THSensor,1970-01-01 00:01:00,-17,,\n" +
+			"d2,Back door,DoorSensor,1970-01-01 00:00:00,,true,\n" +
+			"d3,,,1970-01-01 00:00:00,,false,\n"},
+	}
+	for _, test := range tests {
+		if content := pivotEvents(t, events, test.bucketSeconds); content != test.expected {
+			t.Errorf("%vs buckets: expected %q, got %q", test.bucketSeconds, test.expected, content)
+		}
+	}
+}
+
+func TestPivotWithoutEvents(t *testing.T) {
+	expected := "device_id,device_name,device_kind,timestamp,state.temperature,state.open,state.mode\n"
+	if content := pivotEvents(t, nil, 60); content != expected {
+		t.Errorf("expected only the header, got %q", content)
+	}
+}
+
+func TestPivotFieldValues(t *testing.T) {
+	temperature, open, mode := pivotFieldColumns[0], pivotFieldColumns[1], pivotFieldColumns[2]
+	tests := []struct {
+		column   data.ExportColumn
+		event    data.StoreEvent
+		expected any
+	}{
+		{temperature, pivotEvent("d1", 0, "state.temperature", json.Number("-18.5")), -18.5},
+		{open, pivotEvent("d1", 0, "state.open", true), true},
+		{mode, pivotEvent("d1", 0, "state.mode", "auto"), "auto"},
+		// Numbers and booleans in text columns keep their text, and values of another type are missing
+		{mode, pivotEvent("d1", 0, "state.mode", json.Number("2")), "2"},
+		{temperature, pivotEvent("d1", 0, "state.temperature", "unknown"), nil},
+		{open, pivotEvent("d1", 0, "state.open", nil), nil},
+	}
+	for _, test := range tests {
+		value := reflect.ValueOf(fieldValue(test.column, test.event))
+		var actual any
+		if !value.IsNil() {
+			actual = value.Elem().Interface()
+		}
+		if actual != test.expected {
+			t.Errorf("%v of %v: expected %v, got %v", test.event.FieldName, test.event.FieldValue, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package exports
+
+import (
+	"com/connections/db"
+	"com/data"
+	"com/logs"
+	"com/utils"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// An export of a table run on a cron schedule, written to a file named after the export and the time it ran.
+type ScheduledExport struct {
+	Name string `json:"name"`
+	// Cron expression of when to run, such as "0 6 * * *" for 6:00 every day.
+	Schedule string `json:"schedule"`
+	Table    string `json:"table"`
+	// Values rows must have, by column, such as {"field_name": "state.temperature"}.
+	Filter map[string]any `json:"filter,omitempty"`
+	// Limits the export to rows from this long before it runs, such as "24h" or "7d", by the table's timestamp.
+	// Empty exports every row.
+	Window      utils.Duration         `json:"window,omitempty"`
+	Format      data.ExportFormat      `json:"format,omitempty"`
+	Compression data.ExportCompression `json:"compression,omitempty"`
+	// Where files are written, as in EXPORT_DESTINATION. Defaults to the export directory.
+	Destination     string               `json:"destination,omitempty"`
+	TimestampFormat data.TimestampFormat `json:"timestampFormat,omitempty"`
+	// IANA time zone of dates, such as America/New_York. Defaults to UTC.
+	TimeZone    string `json:"timeZone,omitempty"`
+	ErrorBudget int    `json:"errorBudget,omitempty"`
+}
+
+type ScheduleConfig struct {
+	Exports []ScheduledExport `json:"exports"`
+}
+
+// A table scheduled exports can export.
+type exportTable struct {
+	columns []string
+	// Column time windows apply to, empty if the table has none.
+	timestampColumn string
+	export          func(ctx context.Context, dbConnection db.DBConnection, label string, where data.Expression, options data.ExportOptions) (data.ExportReport, error)
+}
+
+var exportTables = map[string]exportTable{
+	"events": newExportTable("event_timestamp", func(c db.DBConnection) db.GenericStore[data.Event, data.StoreEvent, data.EventFilter] {
+		return c.Events()
+	}),
+	"devices": newExportTable("", func(c db.DBConnection) db.GenericStore[data.Device, data.StoreDevice, data.DeviceFilter] {
+		return c.Devices()
+	}),
+	"device_state": newExportTable("", func(c db.DBConnection) db.GenericStore[data.DeviceState, data.StoreDeviceState, data.DeviceStateFilter] {
+		return c.DeviceStates()
+	}),
+	"rollups": newExportTable("bucket_start", func(c db.DBConnection) db.GenericStore[data.Rollup, data.StoreRollup, data.RollupFilter] {
+		return c.Rollups()
+	}),
+	"battery_readings": newExportTable("battery_timestamp", func(c db.DBConnection) db.GenericStore[data.BatteryReading, data.StoreBatteryReading, data.BatteryReadingFilter] {
+		return c.Batteries()
+	}),
+	"alert_states": newExportTable("", func(c db.DBConnection) db.GenericStore[data.AlertState, data.StoreAlertState, data.AlertStateFilter] {
+		return c.AlertStates()
+	}),
+	"deliveries": newExportTable("delivery_timestamp", func(c db.DBConnection) db.GenericStore[data.Delivery, data.StoreDelivery, data.DeliveryFilter] {
+		return c.Deliveries()
+	}),
+	"jobs": newExportTable("job_start_timestamp", func(c db.DBConnection) db.GenericStore[data.Job, data.StoreJob, data.JobFilter] {
+		return c.Jobs()
+	}),
+	"logs": newExportTable("log_timestamp", func(c db.DBConnection) db.GenericStore[data.Log, data.StoreLog, data.LogFilter] {
+		return c.Logs()
+	}),
+}
+
+func newExportTable[T any, S data.HasIDGetterAndSpreadable[S], F any](timestampColumn string, store func(db.DBConnection) db.GenericStore[T, S, F]) exportTable {
+	return exportTable{
+		columns:         data.Columns[S](),
+		timestampColumn: timestampColumn,
+		export: func(ctx context.Context, dbConnection db.DBConnection, label string, where data.Expression, options data.ExportOptions) (data.ExportReport, error) {
+			return Items(ctx, label, store(dbConnection).Find(ctx, data.Query[F]{Where: where}), options)
+		},
+	}
+}
+
+// Read scheduled exports from a JSON file of the form {"exports": [...]}.
+func LoadSchedule(path string) (*ScheduleConfig, error) {
+	config, err := utils.ReadJsonFile[ScheduleConfig](path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading scheduled exports: %w", err)
+	}
+	names := map[string]bool{}
+	for _, export := range config.Exports {
+		err = export.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid scheduled export %v: %w", export.Name, err)
+		}
+		if names[export.Name] {
+			return nil, fmt.Errorf("scheduled export %v is defined more than once", export.Name)
+		}
+		names[export.Name] = true
+	}
+	return config, nil
+}
+
+// Check the export's fields, other than its schedule, which is checked when it is scheduled.
+func (e ScheduledExport) Validate() error {
+	if !exportNamePattern.MatchString(e.Name) {
+		return fmt.Errorf("export name %q must be 1 to 60 letters, digits, underscores or dashes", e.Name)
+	}
+	if e.Schedule == "" {
+		return errors.New("export has no schedule")
+	}
+	table, ok := exportTables[e.Table]
+	if !ok {
+		return fmt.Errorf("unknown table %v", e.Table)
+	}
+	for column := range e.Filter {
+		if !slices.Contains(table.columns, column) {
+			return fmt.Errorf("filter column %v is not a column of %v", column, e.Table)
+		}
+	}
+	if e.Window < 0 {
+		return errors.New("window must not be negative")
+	}
+	if e.Window > 0 && table.timestampColumn == "" {
+		return fmt.Errorf("table %v has no timestamp to limit to a window", e.Table)
+	}
+	_, err := e.options(nil)
+	return err
+}
+
+// Export the table's rows matching the filter and window, as of now. Returns the export's report, also when it fails.
+func RunScheduled(ctx context.Context, dbConnection db.DBConnection, export ScheduledExport) (data.ExportReport, error) {
+	table, ok := exportTables[export.Table]
+	if !ok {
+		return data.ExportReport{}, fmt.Errorf("unknown table %v", export.Table)
+	}
+	sink, err := OpenSink(export.Destination)
+	if err != nil {
+		return data.ExportReport{}, fmt.Errorf("error opening destination of export %v: %w", export.Name, err)
+	}
+	defer logs.LogErrorsWithContext(ctx, func() error { return CloseSink(sink) }, fmt.Sprintf("error closing destination of export %v", export.Name))
+	options, err := export.options(sink)
+	if err != nil {
+		return data.ExportReport{}, err
+	}
+	return table.export(ctx, dbConnection, export.Name, export.where(table, time.Now()), options)
+}
+
+// Rows matching the filter, in column order, and from the window before now.
+func (e ScheduledExport) where(table exportTable, now time.Time) data.And {
+	where := data.And{}
+	for _, column := range slices.Sorted(maps.Keys(e.Filter)) {
+		where = append(where, data.Eq(column, e.Filter[column]))
+	}
+	if e.Window > 0 {
+		start := now.Add(-time.Duration(e.Window)).Unix()
+		where = append(where, data.Comparison{Column: table.timestampColumn, Operator: data.GreaterThanOrEqual, Value: start})
+	}
+	return where
+}
+
+// The export's options, writing into the sink.
+func (e ScheduledExport) options(sink data.ExportSink) (data.ExportOptions, error) {
+	format, err := data.ParseExportFormat(string(e.Format))
+	if err != nil {
+		return data.ExportOptions{}, err
+	}
+	compression, err := data.ParseExportCompression(string(e.Compression))
+	if err != nil {
+		return data.ExportOptions{}, err
+	}
+	dates, err := data.ParseDateFormat(string(e.TimestampFormat), e.TimeZone)
+	if err != nil {
+		return data.ExportOptions{}, err
+	}
+	if e.ErrorBudget < 0 {
+		return data.ExportOptions{}, errors.New("error budget must not be negative")
+	}
+	return data.ExportOptions{Format: format, Compression: compression, Sink: sink, Dates: dates, ErrorBudget: e.ErrorBudget}, nil
+}
@@ -0,0 +1,129 @@
+package exports
+
+import (
+	"com/data"
+	"com/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func scheduledExport(name string, table string) ScheduledExport {
+	return ScheduledExport{Name: name, Schedule: "0 6 * * *", Table: table}
+}
+
+func writeSchedule(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "exports.json")
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSchedule(t *testing.T) {
+	path := writeSchedule(t, `{"exports": [
+		{"name": "temperatures", "schedule": "0 6 * * *", "table": "events", "filter": {"field_name": "state.temperature"}, "window": "7d", "format": "ndjson"},
+		{"name": "devices", "schedule": "@daily", "table": "devices"}
+	]}`)
+	config, err := LoadSchedule(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ScheduledExport{
+		{
+			Name: "temperatures", Schedule: "0 6 * * *", Table: "events",
+			Filter: map[string]any{"field_name": "state.temperature"}, Window: utils.Duration(7 * 24 * time.Hour), Format: data.ExportNDJSON,
+		},
+		{Name: "devices", Schedule: "@daily", Table: "devices"},
+	}
+	if !reflect.DeepEqual(config.Exports, expected) {
+		t.Errorf("expected %v, got %v", expected, config.Exports)
+	}
+
+	invalid := map[string]string{
+		"duplicate names": `{"exports": [{"name": "a", "schedule": "@daily", "table": "events"}, {"name": "a", "schedule": "@hourly", "table": "jobs"}]}`,
+		"invalid export":  `{"exports": [{"name": "a", "schedule": "@daily", "table": "readings"}]}`,
+		"invalid window":  `{"exports": [{"name": "a", "schedule": "@daily", "table": "events", "window": "a week"}]}`,
+	}
+	for name, content := range invalid {
+		_, err := LoadSchedule(writeSchedule(t, content))
+		if err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestValidateScheduledExport(t *testing.T) {
+	valid := []ScheduledExport{
+		scheduledExport("events", "events"),
+		{Name: "temperatures", Schedule: "@daily", Table: "events", Filter: map[string]any{"field_name": "state.temperature"}, Window: utils.Duration(time.Hour)},
+		{Name: "jobs", Schedule: "@daily", Table: "jobs", Window: utils.Duration(time.Hour), Format: data.ExportParquet, ErrorBudget: 10},
+	}
+	for _, export := range valid {
+		err := export.Validate()
+		if err != nil {
+			t.Errorf("%v: %v", export.Name, err)
+		}
+	}
+
+	invalid := map[string]ScheduledExport{
+		"name":            scheduledExport("daily events", "events"),
+		"schedule":        {Name: "events", Table: "events"},
+		"table":           scheduledExport("readings", "readings"),
+		"filter column":   {Name: "events", Schedule: "@daily", Table: "events", Filter: map[string]any{"temperature": 20}},
+		"negative window": {Name: "events", Schedule: "@daily", Table: "events", Window: utils.Duration(-time.Hour)},
+		"untimed window":  {Name: "devices", Schedule: "@daily", Table: "devices", Window: utils.Duration(time.Hour)},
+		"format":          {Name: "events", Schedule: "@daily", Table: "events", Format: "xml"},
+		"time zone":       {Name: "events", Schedule: "@daily", Table: "events", TimeZone: "Mars/Olympus_Mons"},
+		"negative budget": {Name: "events", Schedule: "@daily", Table: "events", ErrorBudget: -1},
+	}
+	for name, export := range invalid {
+		err := export.Validate()
+		if err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestScheduledExportWhere(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		export   ScheduledExport
+		expected data.And
+	}{
+		{scheduledExport("events", "events"), data.And{}},
+		// Filter columns in order, then the window
+		{
+			ScheduledExport{Table: "events", Filter: map[string]any{"field_name": "state.temperature", "event_source_device_id": "d1"}, Window: utils.Duration(time.Hour)},
+			data.And{
+				data.Eq("event_source_device_id", "d1"),
+				data.Eq("field_name", "state.temperature"),
+				data.Comparison{Column: "event_timestamp", Operator: data.GreaterThanOrEqual, Value: int64(1700000000 - 3600)},
+			},
+		},
+		{
+			ScheduledExport{Table: "rollups", Window: utils.Duration(24 * time.Hour)},
+			data.And{data.Comparison{Column: "bucket_start", Operator: data.GreaterThanOrEqual, Value: int64(1700000000 - 86400)}},
+		},
+	}
+	for _, test := range tests {
+		where := test.export.where(exportTables[test.export.Table], now)
+		if !reflect.DeepEqual(where, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.export.Table, test.expected, where)
+		}
+	}
+}
+
+// Every table's windows apply to one of its columns.
+func TestExportTables(t *testing.T) {
+	for name, table := range exportTables {
+		if table.timestampColumn != "" && !slices.Contains(table.columns, table.timestampColumn) {
+			t.Errorf("%v: timestamp column %v is not one of %v", name, table.timestampColumn, table.columns)
+		}
+	}
+}
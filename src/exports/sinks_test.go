@@ -0,0 +1,511 @@
+package exports
+
+import (
+	"bufio"
+	"bytes"
+	"com/data"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+var testColumns = []data.ExportColumn{{Name: "name", Kind: reflect.String}, {Name: "value", Kind: reflect.Int64}}
+
+func TestOpenSink(t *testing.T) {
+	tests := map[string]string{
+		"":                  "*exports.LocalSink",
+		"-":                 "exports.StdoutSink",
+		"stdout":            "exports.StdoutSink",
+		"exports/daily":     "*exports.LocalSink",
+		"C:\\exports":       "*exports.LocalSink",
+		"file:///tmp/daily": "*exports.LocalSink",
+		"s3://bucket/prefix?endpoint=localhost:9000&insecure=true": "*exports.S3Sink",
+	}
+	for destination, expected := range tests {
+		sink, err := OpenSink(destination)
+		if err != nil {
+			t.Fatalf("%q: %v", destination, err)
+		}
+		if reflect.TypeOf(sink).String() != expected {
+			t.Errorf("%q: expected %v, got %T", destination, expected, sink)
+		}
+	}
+	_, err := OpenSink("ftp://example.com/exports")
+	if err == nil {
+		t.Error("expected an error for an unknown scheme")
+	}
+}
+
+func TestS3SinkLocation(t *testing.T) {
+	sink := NewS3Sink(nil, "bucket", "exports/daily")
+	if location := sink.Location("events.csv"); location != "s3://bucket/exports/daily/events.csv" {
+		t.Fatalf("unexpected location %v", location)
+	}
+}
+
+func TestLocalSinkRenamesOnceComplete(t *testing.T) {
+	sink := &LocalSink{Dir: filepath.Join(t.TempDir(), "exports")}
+	w, err := sink.Create(context.Background(), "events.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte("a,b\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sink.Location("events.csv")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no file before closing, got %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, sink.Location("events.csv"), "a,b\n")
+	assertDirectory(t, sink.Dir, "events.csv")
+}
+
+func TestLocalSinkAbandonsFailedFiles(t *testing.T) {
+	sink := &LocalSink{Dir: t.TempDir()}
+	w, err := sink.Create(context.Background(), "events.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("a,b\n"))
+	abort(w, errors.New("failed"))
+	assertDirectory(t, sink.Dir)
+}
+
+func TestLocalSinkTruncatesFailedAppends(t *testing.T) {
+	sink := &LocalSink{Dir: t.TempDir()}
+	for i, content := range []string{"first\n", "second\n"} {
+		w, size, err := sink.Append(context.Background(), "events.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && size != int64(len("first\n")) {
+			t.Fatalf("expected the size before appending, got %v", size)
+		}
+		_, _ = w.Write([]byte(content))
+		if i == 0 {
+			err = w.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			abort(w, errors.New("failed"))
+		}
+	}
+	assertFile(t, sink.Location("events.csv"), "first\n")
+}
+
+// A compressed export decompresses to its rows, and its manifest describes the bytes stored.
+func TestFileWritesManifest(t *testing.T) {
+	sink := &LocalSink{Dir: t.TempDir()}
+	ctx := context.Background()
+	f, err := Create(ctx, "events", testColumns, data.ExportOptions{Sink: sink, Compression: data.CompressGzip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range [][]any{{"a", int64(1)}, {"b", int64(2)}} {
+		err = f.Write(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = f.Close(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := f.Report()
+	if report.Rows != 2 || report.File != f.Location() {
+		t.Fatalf("unexpected report %v", report)
+	}
+
+	stored, err := os.ReadFile(f.Location())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(stored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "name,value\na,1\nb,2\n" {
+		t.Fatalf("unexpected content %q", content)
+	}
+
+	entries := readManifest(t, f.Location()+ManifestSuffix)
+	sum := sha256.Sum256(stored)
+	expected := ManifestEntry{File: f.Name(), Format: data.ExportCSV, Compression: data.CompressGzip, Rows: 2, Bytes: int64(len(stored)), SHA256: hex.EncodeToString(sum[:])}
+	entries[0].CreatedAt = ""
+	if len(entries) != 1 || entries[0] != expected {
+		t.Fatalf("expected manifest %v, got %v", expected, entries)
+	}
+}
+
+// Appending to a daily file writes the header once, and a manifest entry per export with its offset.
+func TestDailyFileAppends(t *testing.T) {
+	sink := &LocalSink{Dir: t.TempDir()}
+	ctx := context.Background()
+	for _, value := range []int64{1, 2} {
+		f, err := OpenDaily(ctx, "events", testColumns, data.ExportOptions{Sink: sink})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.Write([]any{"a", value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.Close(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(sink.Dir, "events_*.csv"))
+	if len(files) != 1 {
+		t.Fatalf("expected one daily file, got %v", files)
+	}
+	assertFile(t, files[0], "name,value\na,1\na,2\n")
+	entries := readManifest(t, files[0]+ManifestSuffix)
+	if len(entries) != 2 || entries[1].Offset != int64(len("name,value\na,1\n")) || entries[1].Bytes != int64(len("a,2\n")) {
+		t.Fatalf("unexpected manifest %v", entries)
+	}
+
+	_, err := OpenDaily(ctx, "events", testColumns, data.ExportOptions{Sink: sink, Format: data.ExportParquet})
+	if err == nil {
+		t.Fatal("expected an error appending to Parquet")
+	}
+}
+
+func TestFileSkipsInvalidRowsWithinBudget(t *testing.T) {
+	sink := &LocalSink{Dir: t.TempDir()}
+	ctx := context.Background()
+	f, err := Create(ctx, "events", testColumns, data.ExportOptions{Sink: sink, ErrorBudget: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Abort()
+	err = f.Write([]any{"a"})
+	if err != nil {
+		t.Fatalf("expected the first invalid row to be skipped, got %v", err)
+	}
+	err = f.Write([]any{"b"})
+	if !errors.Is(err, ErrInvalidRow) {
+		t.Fatalf("expected the second invalid row to fail, got %v", err)
+	}
+	if report := f.Report(); report.SkippedRows != 1 || len(report.Errors) != 1 || report.File != "" {
+		t.Fatalf("unexpected report %v", report)
+	}
+}
+
+func readManifest(t *testing.T, path string) []ManifestEntry {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	entries := []ManifestEntry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry ManifestEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func assertFile(t *testing.T, path string, expected string) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expected {
+		t.Fatalf("expected %q in %v, got %q", expected, path, content)
+	}
+}
+
+// The directory holds exactly the named files, so no partial files are left behind.
+func assertDirectory(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := []string{}
+	for _, entry := range entries {
+		found = append(found, entry.Name())
+	}
+	if !reflect.DeepEqual(found, append([]string{}, names...)) {
+		t.Fatalf("expected %v in %v, got %v", names, dir, found)
+	}
+}
+
+// An S3 endpoint keeping multipart uploads in memory, as uploads of unknown size are made.
+type s3Server struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	objects map[string][]byte
+	parts   map[string]map[int][]byte
+	aborted int
+}
+
+func newS3Server(t *testing.T) *s3Server {
+	t.Helper()
+	server := &s3Server{objects: map[string][]byte{}, parts: map[string]map[int][]byte{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *s3Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	query := r.URL.Query()
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	uploadID := query.Get("uploadId")
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID = fmt.Sprint(len(s.parts) + 1)
+		s.parts[uploadID] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, uploadID)
+	case r.Method == http.MethodPut && uploadID != "":
+		part, err := strconv.Atoi(query.Get("partNumber"))
+		body, readErr := readS3Body(r)
+		if err != nil || readErr != nil || s.parts[uploadID] == nil {
+			http.Error(w, "bad part", http.StatusBadRequest)
+			return
+		}
+		s.parts[uploadID][part] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%v"`, part))
+	case r.Method == http.MethodPost && uploadID != "":
+		object := []byte{}
+		for part := 1; part <= len(s.parts[uploadID]); part++ {
+			object = append(object, s.parts[uploadID][part]...)
+		}
+		s.objects[key] = object
+		delete(s.parts, uploadID)
+		bucket, name, _ := strings.Cut(key, "/")
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>\"1\"</ETag></CompleteMultipartUploadResult>", bucket, name)
+	case r.Method == http.MethodDelete && uploadID != "":
+		delete(s.parts, uploadID)
+		s.aborted++
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected request", http.StatusNotImplemented)
+	}
+}
+
+// The request's body, without the signatures of streamed chunks.
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	body := []byte{}
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		length, err := strconv.ParseInt(size, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		chunk := make([]byte, length+int64(len("\r\n")))
+		_, err = io.ReadFull(reader, chunk)
+		if err != nil {
+			return nil, err
+		}
+		if length == 0 {
+			return body, nil
+		}
+		body = append(body, chunk[:length]...)
+	}
+}
+
+func newTestS3Sink(t *testing.T, server *s3Server) *S3Sink {
+	t.Helper()
+	client, err := minio.New(strings.TrimPrefix(server.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("access", "secret", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewS3Sink(client, "bucket", "exports")
+}
+
+func TestS3SinkUploads(t *testing.T) {
+	server := newS3Server(t)
+	sink := newTestS3Sink(t, server)
+	w, err := sink.Create(context.Background(), "events.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte("a,b\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object := string(server.objects["bucket/exports/events.csv"]); object != "a,b\n" {
+		t.Fatalf("expected the object uploaded, got %q in %v", object, server.objects)
+	}
+}
+
+func TestS3SinkAbandonsFailedUploads(t *testing.T) {
+	server := newS3Server(t)
+	sink := newTestS3Sink(t, server)
+	w, err := sink.Create(context.Background(), "events.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("a,b\n"))
+	abort(w, errors.New("failed"))
+	if len(server.objects) != 0 || len(server.parts) != 0 || server.aborted != 1 {
+		t.Fatalf("expected the upload aborted, got objects %v, %v uploads in progress and %v aborted", server.objects, len(server.parts), server.aborted)
+	}
+}
+
+// Serve SFTP over SSH on a local port, accepting any password, with files in the real file system.
+func newSFTPServer(t *testing.T) (string, *ssh.ClientConfig) {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) { return nil, nil }}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(connection, config)
+		}
+	}()
+	clientConfig := &ssh.ClientConfig{
+		User:            "exports",
+		Auth:            []ssh.AuthMethod{ssh.Password("password")},
+		HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+	}
+	return listener.Addr().String(), clientConfig
+}
+
+func serveSFTP(connection net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(connection, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for request := range channelRequests {
+				isSFTP := request.Type == "subsystem" && string(request.Payload[4:]) == "sftp"
+				_ = request.Reply(isSFTP, nil)
+				if !isSFTP {
+					continue
+				}
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					_ = channel.Close()
+					return
+				}
+				_ = server.Serve()
+				_ = server.Close()
+			}
+		}()
+	}
+}
+
+func TestSFTPSinkCreatesAndAppends(t *testing.T) {
+	address, config := newSFTPServer(t)
+	dir := filepath.Join(t.TempDir(), "exports")
+	sink, err := NewSFTPSink(address, config, filepath.ToSlash(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	ctx := context.Background()
+
+	w, err := sink.Create(ctx, "events.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("a,b\n"))
+	if _, err := os.Stat(filepath.Join(dir, "events.csv")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no file before closing, got %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(dir, "events.csv"), "a,b\n")
+
+	for i, content := range []string{"first\n", "second\n"} {
+		w, size, err := sink.Append(ctx, "events.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && size != int64(len("a,b\nfirst\n")) {
+			t.Fatalf("expected the size before appending, got %v", size)
+		}
+		_, _ = w.Write([]byte(content))
+		if i == 0 {
+			err = w.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			abort(w, errors.New("failed"))
+		}
+	}
+	assertFile(t, filepath.Join(dir, "events.csv"), "a,b\nfirst\n")
+	assertDirectory(t, dir, "events.csv")
+}
@@ -0,0 +1,149 @@
+// Writers of exports in each data.ExportFormat.
+package exports
+
+import (
+	"com/data"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Wrapped by writers' errors for rows that cannot be written, such as values that cannot be encoded.
+// Nothing of such rows is written, so they can be skipped.
+var ErrInvalidRow = errors.New("invalid export row")
+
+// Writes the rows of an export. Rows are the values of an item's columns, as from Spread.
+type Writer interface {
+	Write(values []any) error
+	// Finish the export. The underlying writer is not closed.
+	Close() error
+}
+
+// A writer of the format into w, with a header of the columns where the format has one, and dates in the date format.
+func NewWriter(w io.Writer, format data.ExportFormat, columns []data.ExportColumn, dates data.DateFormat) (Writer, error) {
+	if dates.Format != data.TimestampDefault {
+		return newDateConvertingWriter(columns, dates, func(columns []data.ExportColumn) (Writer, error) {
+			return NewWriter(w, format, columns, data.DateFormat{Location: dates.Location})
+		})
+	}
+	switch format {
+	case data.ExportCSV, "":
+		return newCSVWriter(w, columns, dates, false)
+	case data.ExportNDJSON:
+		return newNDJSONWriter(w, columns, dates), nil
+	case data.ExportParquet:
+		return newParquetWriter(w, columns), nil
+	case data.ExportXLSX:
+		return newXLSXWriter(w, columns, dates)
+	default:
+		return nil, fmt.Errorf("unknown export format %v", format)
+	}
+}
+
+// Like NewWriter, appending rows to an existing export of the format without writing a header.
+// Only formats without a footer, CSV and NDJSON, can be appended to.
+func NewAppendingWriter(w io.Writer, format data.ExportFormat, columns []data.ExportColumn, dates data.DateFormat) (Writer, error) {
+	if dates.Format != data.TimestampDefault {
+		return newDateConvertingWriter(columns, dates, func(columns []data.ExportColumn) (Writer, error) {
+			return NewAppendingWriter(w, format, columns, data.DateFormat{Location: dates.Location})
+		})
+	}
+	switch format {
+	case data.ExportCSV, "":
+		return newCSVWriter(w, columns, dates, true)
+	case data.ExportNDJSON:
+		return newNDJSONWriter(w, columns, dates), nil
+	default:
+		return nil, fmt.Errorf("exports in format %v cannot be appended to", format)
+	}
+}
+
+// Writes date columns as plain columns of the date format's values, such as numbers for Excel serial dates and epochs.
+type dateConvertingWriter struct {
+	Writer
+	dates data.DateFormat
+	// Whether each column is a date column being converted.
+	isConverted []bool
+}
+
+func newDateConvertingWriter(columns []data.ExportColumn, dates data.DateFormat, newWriter func([]data.ExportColumn) (Writer, error)) (Writer, error) {
+	converted := slices.Clone(columns)
+	isConverted := make([]bool, len(columns))
+	for i, column := range columns {
+		if column.IsDate {
+			converted[i].IsDate = false
+			converted[i].Kind = dates.Kind()
+			isConverted[i] = true
+		}
+	}
+	writer, err := newWriter(converted)
+	if err != nil {
+		return nil, err
+	}
+	return &dateConvertingWriter{Writer: writer, dates: dates, isConverted: isConverted}, nil
+}
+
+func (d *dateConvertingWriter) Write(values []any) error {
+	converted := slices.Clone(values)
+	for i, isConverted := range d.isConverted {
+		if !isConverted || i >= len(values) {
+			continue
+		}
+		if seconds, ok := plainValue(values[i]).(int64); ok {
+			converted[i] = d.dates.Value(seconds)
+		}
+	}
+	return d.Writer.Write(converted)
+}
+
+// Whether exports of the format can be appended to with NewAppendingWriter.
+func IsAppendable(format data.ExportFormat) bool {
+	return format == data.ExportCSV || format == data.ExportNDJSON || format == ""
+}
+
+// File extension for exports of the format, without a dot.
+func Extension(format data.ExportFormat) string {
+	if format == "" {
+		return string(data.ExportCSV)
+	}
+	return string(format)
+}
+
+// The value as a string, int64, float64 or bool, or nil if it is a nil pointer.
+func plainValue(value any) any {
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() == reflect.Pointer {
+		if reflected.IsNil() {
+			return nil
+		}
+		reflected = reflected.Elem()
+	}
+	switch reflected.Kind() {
+	case reflect.String:
+		return reflected.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflected.Int()
+	case reflect.Float32, reflect.Float64:
+		return reflected.Float()
+	case reflect.Bool:
+		return reflected.Bool()
+	default:
+		return value
+	}
+}
+
+// The time of a date column's value in epoch seconds, in the date format's time zone.
+func dateOf(value any, dates data.DateFormat) time.Time {
+	seconds, _ := value.(int64)
+	return dates.Time(seconds)
+}
+
+func checkRowLength(columns []data.ExportColumn, values []any) error {
+	if len(values) != len(columns) {
+		return fmt.Errorf("%w: row has %v values for %v columns", ErrInvalidRow, len(values), len(columns))
+	}
+	return nil
+}
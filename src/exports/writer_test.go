@@ -0,0 +1,187 @@
+package exports
+
+import (
+	"bytes"
+	"com/data"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/xuri/excelize/v2"
+)
+
+var eventColumns = []data.ExportColumn{
+	{Name: "device_id", Kind: reflect.String, IsDevice: true},
+	{Name: "event_timestamp", Kind: reflect.Int64, IsDate: true},
+	{Name: "field_value", Kind: reflect.String, IsNullable: true},
+	{Name: "battery", Kind: reflect.Int64},
+	{Name: "temperature", Kind: reflect.Float64, IsNullable: true},
+	{Name: "online", Kind: reflect.Bool},
+}
+
+// 2023-11-14 22:13:20 UTC
+const eventTime int64 = 1700000000
+
+func eventRows() [][]any {
+	value := "say \"hi\", then leave"
+	temperature := 21.5
+	return [][]any{
+		{"d1", eventTime, &value, int64(4), &temperature, true},
+		{"d2", eventTime + 60, (*string)(nil), int64(0), (*float64)(nil), false},
+	}
+}
+
+func writeRows(t *testing.T, format data.ExportFormat, dates data.DateFormat) []byte {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	writer, err := NewWriter(buffer, format, eventColumns, dates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range eventRows() {
+		err = writer.Write(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	expected := "device_id,event_timestamp,field_value,battery,temperature,online\n" +
+		"d1,2023-11-14 22:13:20,\"say \"\"hi\"\", then leave\",4,21.5,true\n" +
+		"d2,2023-11-14 22:14:20,,0,,false\n"
+	if written := string(writeRows(t, data.ExportCSV, data.DateFormat{})); written != expected {
+		t.Fatalf("expected %q, got %q", expected, written)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	expected := `{"device_id":"d1","event_timestamp":"2023-11-14T22:13:20Z","field_value":"say \"hi\", then leave","battery":4,"temperature":21.5,"online":true}` + "\n" +
+		`{"device_id":"d2","event_timestamp":"2023-11-14T22:14:20Z","field_value":null,"battery":0,"temperature":null,"online":false}` + "\n"
+	if written := string(writeRows(t, data.ExportNDJSON, data.DateFormat{})); written != expected {
+		t.Fatalf("expected %q, got %q", expected, written)
+	}
+}
+
+func TestNDJSONDatesInTimeZone(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable")
+	}
+	written := writeRows(t, data.ExportNDJSON, data.DateFormat{Location: location})
+	if !bytes.Contains(written, []byte(`"event_timestamp":"2023-11-14T17:13:20-05:00"`)) {
+		t.Fatalf("expected dates in New York time, got %s", written)
+	}
+}
+
+// Date formats other than the default write dates as plain columns of the format's values.
+func TestConvertedDates(t *testing.T) {
+	tests := map[data.TimestampFormat]string{
+		data.TimestampEpoch:       `"event_timestamp":1700000000,`,
+		data.TimestampEpochMillis: `"event_timestamp":1700000000000,`,
+		data.TimestampText:        `"event_timestamp":"2023-11-14 22:13:20",`,
+		data.TimestampExcel:       `"event_timestamp":45244.92592592593,`,
+	}
+	for format, expected := range tests {
+		written := writeRows(t, data.ExportNDJSON, data.DateFormat{Format: format})
+		if !bytes.Contains(written, []byte(expected)) {
+			t.Errorf("%v: expected %s in %s", format, expected, written)
+		}
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	written := writeRows(t, data.ExportParquet, data.DateFormat{})
+	file, err := parquet.OpenFile(bytes.NewReader(written), int64(len(written)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := file.NumRows(); rows != 2 {
+		t.Fatalf("expected 2 rows, got %v", rows)
+	}
+	rows := make([]parquet.Row, 2)
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+	_, err = reader.ReadRows(rows)
+	if err != nil && !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+	values := map[string][]parquet.Value{}
+	for _, row := range rows {
+		for _, value := range row {
+			name := file.Schema().Columns()[value.Column()][0]
+			values[name] = append(values[name], value)
+		}
+	}
+	if values["event_timestamp"][0].Int64() != eventTime*1000 {
+		t.Errorf("expected the date in milliseconds, got %v", values["event_timestamp"][0])
+	}
+	if values["temperature"][0].Double() != 21.5 || !values["temperature"][1].IsNull() {
+		t.Errorf("unexpected temperatures %v", values["temperature"])
+	}
+	if !values["field_value"][1].IsNull() || values["battery"][1].Int64() != 0 || values["online"][0].Boolean() != true {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	written := writeRows(t, data.ExportXLSX, data.DateFormat{})
+	file, err := excelize.OpenReader(bytes.NewReader(written))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := file.GetRows(xlsxSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][1] != "event_timestamp" || rows[1][1] != "2023-11-14 22:13:20" || rows[1][4] != "21.5" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+}
+
+func TestAppendingWriter(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer, err := NewAppendingWriter(buffer, data.ExportCSV, testColumns, data.DateFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Write([]any{"a", int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "a,1\n" {
+		t.Fatalf("expected no header when appending, got %q", buffer.String())
+	}
+	for _, format := range []data.ExportFormat{data.ExportParquet, data.ExportXLSX} {
+		_, err = NewAppendingWriter(buffer, format, testColumns, data.DateFormat{})
+		if err == nil {
+			t.Errorf("expected an error appending to %v", format)
+		}
+	}
+}
+
+func TestInvalidRows(t *testing.T) {
+	for _, format := range []data.ExportFormat{data.ExportCSV, data.ExportNDJSON, data.ExportParquet, data.ExportXLSX} {
+		writer, err := NewWriter(&bytes.Buffer{}, format, testColumns, data.DateFormat{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writer.Write([]any{"a"})
+		if !errors.Is(err, ErrInvalidRow) {
+			t.Errorf("%v: expected an invalid row error, got %v", format, err)
+		}
+	}
+}
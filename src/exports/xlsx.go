@@ -0,0 +1,90 @@
+package exports
+
+import (
+	"com/data"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxSheet = "Sheet1"
+
+// Date format of date cells, so dates open as dates rather than as text or serial numbers.
+const xlsxDateFormat = "yyyy-mm-dd hh:mm:ss"
+
+// A worksheet with a header row, numbers and booleans as typed cells, and dates as date cells in the date format's time zone.
+// The workbook is streamed to a temporary file as rows are written, and copied to the writer on Close.
+type xlsxWriter struct {
+	output      io.Writer
+	file        *excelize.File
+	stream      *excelize.StreamWriter
+	columns     []data.ExportColumn
+	dates       data.DateFormat
+	dateStyleID int
+	// Row of the next write, counting from 1 for the header.
+	row int
+}
+
+func newXLSXWriter(w io.Writer, columns []data.ExportColumn, dates data.DateFormat) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		return nil, fmt.Errorf("error creating XLSX stream: %w", err)
+	}
+	dateFormat := xlsxDateFormat
+	dateStyleID, err := file.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		return nil, fmt.Errorf("error creating XLSX date style: %w", err)
+	}
+	header := []any{}
+	for _, column := range columns {
+		header = append(header, column.Name)
+	}
+	err = stream.SetRow("A1", header)
+	if err != nil {
+		return nil, fmt.Errorf("error writing XLSX header: %w", err)
+	}
+	return &xlsxWriter{output: w, file: file, stream: stream, columns: columns, dates: dates, dateStyleID: dateStyleID, row: 2}, nil
+}
+
+func (x *xlsxWriter) Write(values []any) error {
+	err := checkRowLength(x.columns, values)
+	if err != nil {
+		return err
+	}
+	if x.row > excelize.TotalRows {
+		return fmt.Errorf("XLSX sheets hold at most %v rows", excelize.TotalRows)
+	}
+	cells := make([]any, len(values))
+	for i, value := range values {
+		plain := plainValue(value)
+		if x.columns[i].IsDate && plain != nil {
+			// Cells hold no time zone, so dates are written as their wall clock time
+			date := dateOf(plain, x.dates)
+			cells[i] = excelize.Cell{StyleID: x.dateStyleID, Value: time.Date(date.Year(), date.Month(), date.Day(), date.Hour(), date.Minute(), date.Second(), 0, time.UTC)}
+			continue
+		}
+		cells[i] = plain
+	}
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	err = x.stream.SetRow(cell, cells)
+	if err != nil {
+		return err
+	}
+	x.row++
+	return nil
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+	err := x.stream.Flush()
+	if err != nil {
+		return fmt.Errorf("error flushing XLSX stream: %w", err)
+	}
+	return x.file.Write(x.output)
+}
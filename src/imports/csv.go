@@ -0,0 +1,318 @@
+package imports
+
+import (
+	"com/connections/db"
+	"com/data"
+	"com/logs"
+	"com/utils"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Device ID events are recorded as requested by when imported.
+const ImportRequestDeviceID = "import"
+
+// Errors kept in a report. Further errors are only counted.
+const MaxReportedImportErrors = 10
+
+// How often progress is logged, in rows.
+const progressInterval = 10000
+
+const secondsPerDay = 24 * 60 * 60
+
+// The outcome of an import.
+type Report struct {
+	Name           string
+	Rows           int64
+	Events         int64
+	Duplicates     int64
+	SkippedRows    int64
+	DevicesCreated int64
+	// Earliest timestamp of an added event, nil if none were added.
+	Earliest *int64
+	Errors   []string
+	Duration time.Duration
+}
+
+func (r Report) String() string {
+	description := fmt.Sprintf("%v: %v rows, %v events added, %v duplicates, %v rows skipped, %v devices created in %v",
+		r.Name, r.Rows, r.Events, r.Duplicates, r.SkippedRows, r.DevicesCreated, r.Duration.Round(time.Millisecond))
+	if len(r.Errors) > 0 {
+		description += fmt.Sprintf(". Errors: %v", strings.Join(r.Errors, "; "))
+	}
+	return description
+}
+
+// Add events read from a CSV file to the store, creating devices not yet known.
+// Events already stored for the device, field and timestamp are skipped, so files can be imported again.
+// Rows that cannot be read are skipped and reported. Returns the report, also when the import fails.
+func ImportCSV(ctx context.Context, dbConnection db.DBConnection, r io.Reader, mapping Mapping, name string) (Report, error) {
+	i := importer{
+		dbConnection: dbConnection,
+		mapping:      mapping,
+		report:       Report{Name: name},
+		devices:      map[string]string{},
+		stored:       map[string]map[int64]map[string]bool{},
+	}
+	startTime := time.Now()
+	err := i.run(ctx, r)
+	i.report.Duration = time.Since(startTime)
+	return i.report, err
+}
+
+type importer struct {
+	dbConnection db.DBConnection
+	mapping      Mapping
+	parseDate    func(string) (int64, error)
+	// Index of each mapped column in the header
+	columns map[string]int
+	report  Report
+	// Stored device IDs by the device columns of rows
+	devices map[string]string
+	// Field and timestamp keys of stored events, by device ID and UTC day
+	stored map[string]map[int64]map[string]bool
+}
+
+func (i *importer) run(ctx context.Context, r io.Reader) error {
+	var err error
+	i.parseDate, err = i.mapping.dateParser()
+	if err != nil {
+		return err
+	}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading header of %v: %w", i.report.Name, err)
+	}
+	i.columns = map[string]int{}
+	for index, column := range header {
+		// Spreadsheets often save a byte order mark before the header
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if _, ok := i.columns[column]; !ok {
+			i.columns[column] = index
+		}
+	}
+	for _, column := range i.mapping.columns() {
+		if _, ok := i.columns[column]; !ok {
+			return fmt.Errorf("%v has no column %v", i.report.Name, column)
+		}
+	}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		i.report.Rows++
+		if err != nil {
+			// Malformed rows are skipped, like rows with unreadable values
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return fmt.Errorf("error reading %v: %w", i.report.Name, err)
+			}
+			i.skip(fmt.Errorf("line %v: %w", parseErr.Line, parseErr.Err))
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		err = i.importRow(ctx, row)
+		if errors.Is(err, errInvalidRow) {
+			i.skip(fmt.Errorf("line %v: %w", line, err))
+		} else if err != nil {
+			return fmt.Errorf("error importing line %v of %v: %w", line, i.report.Name, err)
+		}
+		if i.report.Rows%progressInterval == 0 {
+			logs.InfoWithContext(ctx, "Imported %v rows of %v, adding %v events", i.report.Rows, i.report.Name, i.report.Events)
+		}
+	}
+	return nil
+}
+
+var errInvalidRow = errors.New("invalid row")
+
+func (i *importer) skip(err error) {
+	i.report.SkippedRows++
+	if len(i.report.Errors) < MaxReportedImportErrors {
+		i.report.Errors = append(i.report.Errors, err.Error())
+	}
+}
+
+func (i *importer) value(row []string, column string) string {
+	if column == "" {
+		return ""
+	}
+	index := i.columns[column]
+	if index >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[index])
+}
+
+func (i *importer) importRow(ctx context.Context, row []string) error {
+	timestamp, err := i.parseDate(i.value(row, i.mapping.TimestampColumn))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidRow, err)
+	}
+	deviceID, err := i.device(ctx, row)
+	if err != nil {
+		return err
+	}
+
+	if i.mapping.FieldColumn != "" {
+		fieldName := i.value(row, i.mapping.FieldColumn)
+		if fieldName == "" {
+			return fmt.Errorf("%w: no field name", errInvalidRow)
+		}
+		return i.add(ctx, deviceID, timestamp, fieldName, i.value(row, i.mapping.ValueColumn))
+	}
+	for column, fieldName := range i.mapping.Fields {
+		err = i.add(ctx, deviceID, timestamp, fieldName, i.value(row, column))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add the event unless its value is empty or it is already stored.
+func (i *importer) add(ctx context.Context, deviceID string, timestamp int64, fieldName string, value string) error {
+	if value == "" {
+		return nil
+	}
+	stored, err := i.storedEvents(ctx, deviceID, timestamp)
+	if err != nil {
+		return err
+	}
+	key := eventKey(fieldName, timestamp)
+	if stored[key] {
+		i.report.Duplicates++
+		return nil
+	}
+	event := data.Event{
+		RequestDeviceID:     ImportRequestDeviceID,
+		EventSourceDeviceID: deviceID,
+		ResponseTimestamp:   timestamp,
+		EventTimestamp:      timestamp,
+		FieldName:           fieldName,
+	}.WithValue(parseValue(value))
+	_, err = i.dbConnection.Events().Add(ctx, event)
+	if err != nil {
+		return fmt.Errorf("error adding event: %w", err)
+	}
+	stored[key] = true
+	i.report.Events++
+	if i.report.Earliest == nil || timestamp < *i.report.Earliest {
+		i.report.Earliest = &timestamp
+	}
+	return nil
+}
+
+// The keys of the device's stored events on the UTC day of the timestamp, read once per day.
+func (i *importer) storedEvents(ctx context.Context, deviceID string, timestamp int64) (map[string]bool, error) {
+	day := timestamp - ((timestamp%secondsPerDay)+secondsPerDay)%secondsPerDay
+	days, ok := i.stored[deviceID]
+	if !ok {
+		days = map[int64]map[string]bool{}
+		i.stored[deviceID] = days
+	}
+	if stored, ok := days[day]; ok {
+		return stored, nil
+	}
+	end := day + secondsPerDay
+	stored := map[string]bool{}
+	events := i.dbConnection.Events().GetInTimeRange(ctx, data.EventFilter{EventSourceDeviceID: &deviceID}, &day, &end)
+	for event, err := range events.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("error reading stored events of device %v: %w", deviceID, err)
+		}
+		stored[eventKey(event.FieldName, event.EventTimestamp)] = true
+	}
+	days[day] = stored
+	return stored, nil
+}
+
+func eventKey(fieldName string, timestamp int64) string {
+	return fieldName + "@" + strconv.FormatInt(timestamp, 10)
+}
+
+// The stored ID of the row's device, matched by its stored or brand ID and then by name, or created.
+func (i *importer) device(ctx context.Context, row []string) (string, error) {
+	id := i.value(row, i.mapping.DeviceIDColumn)
+	name := i.value(row, i.mapping.DeviceNameColumn)
+	if name == "" {
+		name = i.mapping.DeviceName
+	}
+	if id == "" && name == "" {
+		return "", fmt.Errorf("%w: no device ID or name", errInvalidRow)
+	}
+	key := id + "\x00" + name
+	if deviceID, ok := i.devices[key]; ok {
+		return deviceID, nil
+	}
+
+	brand := i.mapping.Brand
+	filters := []data.DeviceFilter{}
+	if id != "" {
+		filters = append(filters, data.DeviceFilter{ID: &id}, data.DeviceFilter{BrandID: &id, Brand: &brand})
+	}
+	if name != "" {
+		filters = append(filters, data.DeviceFilter{Name: &name, Brand: &brand})
+	}
+	for _, filter := range filters {
+		device, err := i.dbConnection.Devices().Get(ctx, filter).Next(ctx)
+		if err != nil {
+			return "", fmt.Errorf("error finding device: %w", err)
+		}
+		if device != nil {
+			i.devices[key] = device.ID
+			return device.ID, nil
+		}
+	}
+
+	kind := i.value(row, i.mapping.DeviceKindColumn)
+	if kind == "" {
+		kind = i.mapping.DeviceKind
+	}
+	if name == "" {
+		name = id
+	}
+	deviceID, err := i.dbConnection.Devices().Add(ctx, data.Device{
+		BrandID:   id,
+		Brand:     brand,
+		Kind:      kind,
+		Name:      name,
+		Timestamp: utils.TimeSeconds(),
+		Status:    data.DeviceUnknown,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error creating device %v: %w", name, err)
+	}
+	logs.InfoWithContext(ctx, "Created device %v (%v) for imported events", name, deviceID)
+	i.devices[key] = deviceID
+	i.report.DevicesCreated++
+	return deviceID, nil
+}
+
+// The typed value of a cell: booleans, numbers and null as in JSON, anything else as text.
+func parseValue(value string) any {
+	switch strings.ToLower(value) {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) {
+		return json.Number(value)
+	}
+	return value
+}
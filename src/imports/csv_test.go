@@ -0,0 +1,174 @@
+package imports
+
+import (
+	"com/connections/db/dbfake"
+	"com/data"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const timestamp int64 = 1700000000
+
+// Add a device to the store, returning its ID.
+func addDevice(t *testing.T, dbConnection *dbfake.Connection, device data.Device) string {
+	t.Helper()
+	id, err := dbConnection.Devices().Add(t.Context(), device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// The stored events of each device as "field=value@timestamp", sorted.
+func storedEvents(t *testing.T, dbConnection *dbfake.Connection) map[string][]string {
+	t.Helper()
+	ctx := t.Context()
+	events, err := data.Collect(dbConnection.Events().Get(ctx, data.EventFilter{}).All(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := map[string][]string{}
+	for _, event := range events {
+		stored[event.EventSourceDeviceID] = append(stored[event.EventSourceDeviceID], fmt.Sprintf("%v=%v@%v", event.FieldName, event.FieldValue, event.EventTimestamp))
+	}
+	for _, events := range stored {
+		slices.Sort(events)
+	}
+	return stored
+}
+
+func TestImportCSV(t *testing.T) {
+	ctx := t.Context()
+	dbConnection := dbfake.NewConnection()
+	freezerID := addDevice(t, dbConnection, data.Device{BrandID: "brand-freezer", Brand: "yolink", Name: "Freezer"})
+	fridgeID := addDevice(t, dbConnection, data.Device{BrandID: "brand-fridge", Brand: "yolink", Name: "Fridge"})
+	// Devices of other brands are not matched
+	addDevice(t, dbConnection, data.Device{BrandID: "brand-garage", Brand: "other", Name: "Garage"})
+	_, err := dbConnection.Events().Add(ctx, data.Event{EventSourceDeviceID: freezerID, EventTimestamp: timestamp, FieldName: "state.temperature"}.WithValue("-18"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapping := Mapping{
+		Brand: "yolink", DeviceIDColumn: "id", DeviceNameColumn: "name", DeviceKind: "THSensor",
+		TimestampColumn: "time", TimestampFormat: "epoch", FieldColumn: "field", ValueColumn: "value",
+	}
+	file := strings.Join([]string{
+		"\ufefftime,id,name,field,value",
+		// Matched by stored ID, and already stored
+		fmt.Sprintf("%v,%v,,state.temperature,-18", timestamp, freezerID),
+		// Matched by brand ID
+		fmt.Sprintf("%v,brand-freezer,,state.humidity,40", timestamp),
+		// Matched by name when the ID matches nothing
+		fmt.Sprintf("%v,unknown,Fridge,state.open,true", timestamp+60),
+		// Created, once
+		fmt.Sprintf("%v,brand-garage,Garage,state.temperature,12.5", timestamp-60),
+		fmt.Sprintf("%v,brand-garage,Garage,state.battery,full", timestamp-60),
+		// Repeated in the file
+		fmt.Sprintf("%v,brand-freezer,,state.humidity,40", timestamp),
+		// Empty values are not events
+		fmt.Sprintf("%v,brand-fridge,,state.humidity,", timestamp),
+		"yesterday,brand-fridge,,state.humidity,50",
+		fmt.Sprintf("%v,,,state.humidity,50", timestamp),
+		fmt.Sprintf("%v,brand-fridge,,,50", timestamp),
+		fmt.Sprintf(`%v,brand-fridge,,state.humidity,5"0`, timestamp),
+		// Short rows read missing columns as empty
+		fmt.Sprintf("%v,brand-fridge,,state.alarm", timestamp),
+	}, "\n")
+
+	report, err := ImportCSV(ctx, dbConnection, strings.NewReader(file), mapping, "history.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 12 || report.Events != 4 || report.Duplicates != 2 || report.SkippedRows != 4 || report.DevicesCreated != 1 {
+		t.Errorf("unexpected counts in %v", report)
+	}
+	if report.Earliest == nil || *report.Earliest != timestamp-60 {
+		t.Errorf("expected the earliest added event at %v, got %v", timestamp-60, report.Earliest)
+	}
+	if len(report.Errors) != 4 || !strings.HasPrefix(report.Errors[0], "line 9: ") || !strings.HasPrefix(report.Errors[3], "line 12: ") {
+		t.Errorf("expected the skipped rows' errors by line, got %v", report.Errors)
+	}
+
+	devices, err := data.Collect(dbConnection.Devices().Get(ctx, data.DeviceFilter{}).All(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var garageID string
+	for _, device := range devices {
+		if device.Brand == "yolink" && device.Name == "Garage" {
+			garageID = device.ID
+			if device.BrandID != "brand-garage" || device.Kind != "THSensor" || device.Status != data.DeviceUnknown {
+				t.Errorf("unexpected created device %v", device)
+			}
+		}
+	}
+	expected := map[string][]string{
+		freezerID: {"state.humidity=40@1700000000", "state.temperature=-18@1700000000"},
+		fridgeID:  {"state.open=true@1700000060"},
+		garageID:  {"state.battery=full@1699999940", "state.temperature=12.5@1699999940"},
+	}
+	stored := storedEvents(t, dbConnection)
+	if len(stored) != len(expected) {
+		t.Errorf("expected events of %v devices, got %v", len(expected), stored)
+	}
+	for deviceID, events := range expected {
+		if !slices.Equal(stored[deviceID], events) {
+			t.Errorf("expected events %v of device %v, got %v", events, deviceID, stored[deviceID])
+		}
+	}
+
+	// Importing the file again adds nothing
+	report, err = ImportCSV(ctx, dbConnection, strings.NewReader(file), mapping, "history.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Events != 0 || report.Duplicates != 6 || report.DevicesCreated != 0 || report.Earliest != nil {
+		t.Errorf("expected only duplicates importing again, got %v", report)
+	}
+}
+
+func TestImportCSVFields(t *testing.T) {
+	ctx := t.Context()
+	dbConnection := dbfake.NewConnection()
+	freezerID := addDevice(t, dbConnection, data.Device{Brand: "yolink", Name: "Freezer"})
+	mapping := Mapping{
+		Brand: "yolink", DeviceName: "Freezer", TimestampColumn: "Time",
+		Fields: map[string]string{"Temperature": "state.temperature", "Humidity": "state.humidity"},
+	}
+	file := "Time,Temperature,Humidity\n2023-11-14 22:13:20,-18.5,40\n2023-11-14 22:14:20,-18,\n"
+
+	report, err := ImportCSV(ctx, dbConnection, strings.NewReader(file), mapping, "freezer.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 2 || report.Events != 3 || report.DevicesCreated != 0 {
+		t.Errorf("unexpected counts in %v", report)
+	}
+	expected := []string{"state.humidity=40@1700000000", "state.temperature=-18.5@1700000000", "state.temperature=-18@1700000060"}
+	if stored := storedEvents(t, dbConnection)[freezerID]; !slices.Equal(stored, expected) {
+		t.Errorf("expected %v, got %v", expected, stored)
+	}
+}
+
+func TestImportCSVReportsSomeErrors(t *testing.T) {
+	mapping := Mapping{DeviceName: "Freezer", TimestampColumn: "Time", Fields: map[string]string{"Temperature": "state.temperature"}}
+	file := "Time,Temperature\n" + strings.Repeat("never,1\n", MaxReportedImportErrors+5)
+	report, err := ImportCSV(t.Context(), dbfake.NewConnection(), strings.NewReader(file), mapping, "invalid.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.SkippedRows != MaxReportedImportErrors+5 || len(report.Errors) != MaxReportedImportErrors {
+		t.Errorf("expected every row skipped and %v errors reported, got %v", MaxReportedImportErrors, report)
+	}
+}
+
+func TestImportCSVMissingColumn(t *testing.T) {
+	mapping := Mapping{DeviceName: "Freezer", TimestampColumn: "Time", Fields: map[string]string{"Temperature": "state.temperature"}}
+	_, err := ImportCSV(t.Context(), dbfake.NewConnection(), strings.NewReader("Time,Humidity\n"), mapping, "humidity.csv")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,116 @@
+// Imports of historical readings into the stores, such as history downloaded from the YoLink app or earlier exports.
+package imports
+
+import (
+	"com/connections/sensors"
+	"com/data"
+	"com/utils"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// How the columns of a CSV file map to devices and events. Columns are named as in the file's header.
+type Mapping struct {
+	// Brand of devices, used to match and create them. Defaults to yolink.
+	Brand string `json:"brand,omitempty"`
+	// Column of device IDs, matched against stored and then brand device IDs.
+	DeviceIDColumn string `json:"deviceIdColumn,omitempty"`
+	// Column of device names, matched when there is no ID or it matches no device.
+	DeviceNameColumn string `json:"deviceNameColumn,omitempty"`
+	// Name of the device of every row, for files of a single device such as YoLink app history downloads.
+	DeviceName string `json:"deviceName,omitempty"`
+	// Kind given to created devices, such as THSensor, or the column it is read from.
+	DeviceKind       string `json:"deviceKind,omitempty"`
+	DeviceKindColumn string `json:"deviceKindColumn,omitempty"`
+
+	TimestampColumn string `json:"timestampColumn"`
+	// How timestamps are written: one of text, excel, rfc3339, epoch and epoch_ms as in exports,
+	// or a Go time layout such as "01/02/2006 15:04". Defaults to text, also reading RFC 3339.
+	TimestampFormat string `json:"timestampFormat,omitempty"`
+	// IANA time zone of timestamps written without one, such as America/New_York. Defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Event fields by column, for files with a column per field, e.g. {"Temperature": "state.temperature"}.
+	Fields map[string]string `json:"fields,omitempty"`
+	// Columns of field names and values, for files with a row per field such as event exports.
+	FieldColumn string `json:"fieldColumn,omitempty"`
+	ValueColumn string `json:"valueColumn,omitempty"`
+}
+
+// Read a mapping from a JSON file.
+func LoadMapping(path string) (*Mapping, error) {
+	mapping, err := utils.ReadJsonFile[Mapping](path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading import mapping: %w", err)
+	}
+	if mapping.Brand == "" {
+		mapping.Brand = sensors.YOLINK_BRAND_NAME
+	}
+	err = mapping.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid import mapping: %w", err)
+	}
+	return mapping, nil
+}
+
+func (m Mapping) Validate() error {
+	if m.DeviceIDColumn == "" && m.DeviceNameColumn == "" && m.DeviceName == "" {
+		return errors.New("mapping has no device ID column, device name column or device name")
+	}
+	if m.TimestampColumn == "" {
+		return errors.New("mapping has no timestamp column")
+	}
+	isWide := len(m.Fields) > 0
+	isLong := m.FieldColumn != "" || m.ValueColumn != ""
+	if isWide == isLong {
+		return errors.New("mapping must have either fields, or a field column and a value column")
+	}
+	if isLong && (m.FieldColumn == "" || m.ValueColumn == "") {
+		return errors.New("mapping must have both a field column and a value column")
+	}
+	_, err := m.dateParser()
+	return err
+}
+
+// The columns the mapping reads, which the file's header must have.
+func (m Mapping) columns() []string {
+	columns := []string{m.TimestampColumn}
+	for _, column := range []string{m.DeviceIDColumn, m.DeviceNameColumn, m.DeviceKindColumn, m.FieldColumn, m.ValueColumn} {
+		if column != "" {
+			columns = append(columns, column)
+		}
+	}
+	for column := range m.Fields {
+		columns = append(columns, column)
+	}
+	slices.Sort(columns)
+	return slices.Compact(columns)
+}
+
+// Reads timestamps into epoch seconds.
+func (m Mapping) dateParser() (func(string) (int64, error), error) {
+	dates, err := data.ParseDateFormat("", m.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	timestampFormat, err := data.ParseTimestampFormat(m.TimestampFormat)
+	if err == nil {
+		dates.Format = timestampFormat
+		return dates.Parse, nil
+	}
+
+	// A layout
+	location := dates.Location
+	if location == nil {
+		location = time.UTC
+	}
+	return func(value string) (int64, error) {
+		t, err := time.ParseInLocation(m.TimestampFormat, value, location)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing date %v: %w", value, err)
+		}
+		return t.Unix(), nil
+	}, nil
+}
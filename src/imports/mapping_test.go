@@ -0,0 +1,97 @@
+package imports
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestMappingValidate(t *testing.T) {
+	wide := Mapping{DeviceName: "Freezer", TimestampColumn: "Time", Fields: map[string]string{"Temperature": "state.temperature"}}
+	long := Mapping{DeviceIDColumn: "event_source_device_id", TimestampColumn: "event_timestamp", FieldColumn: "field_name", ValueColumn: "field_value"}
+	for name, mapping := range map[string]Mapping{"wide": wide, "long": long} {
+		err := mapping.Validate()
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+	}
+
+	invalid := map[string]func(m *Mapping){
+		"no device":           func(m *Mapping) { m.DeviceName = "" },
+		"no timestamp":        func(m *Mapping) { m.TimestampColumn = "" },
+		"no fields":           func(m *Mapping) { m.Fields = nil },
+		"fields and columns":  func(m *Mapping) { m.FieldColumn, m.ValueColumn = "field", "value" },
+		"only a field column": func(m *Mapping) { m.Fields, m.FieldColumn = nil, "field" },
+		"unknown time zone":   func(m *Mapping) { m.TimeZone = "Mars/Olympus_Mons" },
+	}
+	for name, change := range invalid {
+		mapping := wide
+		change(&mapping)
+		if mapping.Validate() == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestMappingColumns(t *testing.T) {
+	mapping := Mapping{
+		DeviceNameColumn: "Device",
+		TimestampColumn:  "Time",
+		Fields:           map[string]string{"Temperature": "state.temperature", "Humidity": "state.humidity", "Time": "reportAt"},
+	}
+	expected := []string{"Device", "Humidity", "Temperature", "Time"}
+	if columns := mapping.columns(); !slices.Equal(columns, expected) {
+		t.Fatalf("expected %v, got %v", expected, columns)
+	}
+}
+
+func TestMappingDates(t *testing.T) {
+	tests := []struct {
+		format   string
+		timeZone string
+		value    string
+		expected int64
+	}{
+		{"", "", "2023-11-14 22:13:20", 1700000000},
+		{"", "", "2023-11-14T22:13:20Z", 1700000000},
+		{"", "America/New_York", "2023-11-14 17:13:20", 1700000000},
+		{"epoch_ms", "", "1700000000000", 1700000000},
+		{"01/02/2006 15:04", "America/New_York", "11/14/2023 17:13", 1700000000 - 20},
+	}
+	for _, test := range tests {
+		parse, err := Mapping{TimestampFormat: test.format, TimeZone: test.timeZone}.dateParser()
+		if err != nil {
+			t.Fatalf("%q: %v", test.format, err)
+		}
+		seconds, err := parse(test.value)
+		if err != nil {
+			t.Fatalf("%q: %v", test.format, err)
+		}
+		if seconds != test.expected {
+			t.Errorf("%q: expected %v from %v, got %v", test.format, test.expected, test.value, seconds)
+		}
+	}
+	parse, _ := Mapping{TimestampFormat: "01/02/2006"}.dateParser()
+	_, err := parse("2023-11-14")
+	if err == nil {
+		t.Error("expected an error for a date in another layout")
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := map[string]any{
+		"TRUE":   true,
+		"false":  false,
+		"null":   nil,
+		"21.5":   json.Number("21.5"),
+		"-3":     json.Number("-3"),
+		"0x1F":   "0x1F",
+		"NaN":    "NaN",
+		"normal": "normal",
+	}
+	for value, expected := range tests {
+		if parsed := parseValue(value); parsed != expected {
+			t.Errorf("%v: expected %#v, got %#v", value, expected, parsed)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package jobs
+
+import (
+	"com/data"
+	"context"
+)
+
+// Receives events as they are written by collection jobs.
+type EventHandler interface {
+	// Called once per device with every event from that device that was stored successfully.
+	HandleEvents(ctx context.Context, device *data.StoreDevice, events []data.StoreEvent) error
+}
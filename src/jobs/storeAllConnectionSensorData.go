@@ -10,7 +10,8 @@ import (
 	"fmt"
 )
 
-func StoreAllConnectionSensorData(ctx context.Context, dbConnection db.DBConnection, sensorConnection sensors.SensorConnection) error {
+// Store the current state of every device managed by the connection, passing stored events on to each handler.
+func StoreAllConnectionSensorData(ctx context.Context, dbConnection db.DBConnection, sensorConnection sensors.SensorConnection, handlers ...EventHandler) error {
 	// Get all devices
 	devices, err := utils.Retry2(3, func() (*data.IterablePaginatedData[data.StoreDevice], error) {
 		return sensorConnection.GetManagedDevices(ctx, dbConnection)
@@ -19,18 +20,14 @@ func StoreAllConnectionSensorData(ctx context.Context, dbConnection db.DBConnect
 		return fmt.Errorf("error while searching for devices: %w", err)
 	}
 
-	for {
-		device, err := devices.Next(ctx)
+	for device, err := range devices.All(ctx) {
 		if err != nil {
 			return fmt.Errorf("error getting next item: %w", err)
 		}
-		if device == nil {
-			break
-		}
 
 		// Get device data
 		events, err := utils.Retry2(3, func() ([]data.Event, error) {
-			return sensorConnection.GetDeviceState(ctx, device)
+			return sensorConnection.GetDeviceState(ctx, &device)
 		}, []any{sensors.ErrYoLinkAPIError})
 		if err != nil {
 			logs.ErrorWithContext(ctx, "error getting events from device %v: %v", device, err)
@@ -38,14 +35,24 @@ func StoreAllConnectionSensorData(ctx context.Context, dbConnection db.DBConnect
 		}
 
 		// Store device data
+		storedEvents := []data.StoreEvent{}
 		for _, event := range events {
-			_, err = utils.Retry2(3, func() (string, error) {
+			id, err := utils.Retry2(3, func() (string, error) {
 				return dbConnection.Events().Add(ctx, event)
 			}, nil)
 			if err != nil {
 				logs.ErrorWithContext(ctx, "error adding event to DB %v: %v", event, err)
 				continue
 			}
+			storedEvents = append(storedEvents, data.StoreEvent{HasID: data.HasID{ID: id}, Event: event})
+		}
+
+		// Pass on to handlers
+		for _, handler := range handlers {
+			err = handler.HandleEvents(ctx, &device, storedEvents)
+			if err != nil {
+				logs.ErrorWithContext(ctx, "error handling events from device %v: %v", device, err)
+			}
 		}
 	}
 	return nil
@@ -2,26 +2,65 @@ package jobs
 
 import (
 	"com/logs"
+	"com/notifications"
 	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
 )
 
-func CreateJob(ctx context.Context, jobFunction func(ctx context.Context) error, jobDescription string) func() {
+// Consecutive failures of a scheduled job before a notification is sent.
+const failureNotificationThreshold = 3
+
+// Wrap the job function for scheduling, running each call as a child job of the context's job with its own logger.
+// Calls may overlap, such as a scheduled run alongside a direct call.
+func CreateJob(ctx context.Context, category logs.JobCategory, jobFunction func(ctx context.Context) error, jobDescription string) func() {
+	var consecutiveFailures atomic.Int64
 	return func() {
-		logger, err := logs.Logger(ctx).CreateChildJob(ctx, logs.Import)
+		logger, err := logs.Logger(ctx).CreateChildJob(ctx, category)
 		if err != nil {
 			logs.ErrorWithContext(ctx, "unable to create child job: %v", err)
 			return
 		}
 		jobctx := logs.ContextWithLogger(ctx, logger)
-		logger.Info(jobctx, "storing all data from YoLink connection...")
-		
+		logger.Info(jobctx, "%v starting...", jobDescription)
+
 		err = jobFunction(jobctx)
 		if err != nil {
-			logger.Error(jobctx, "error while storing sensor data: %v", err)
+			logger.Error(jobctx, "error while running %v: %v", jobDescription, err)
+			failures := consecutiveFailures.Add(1)
+			if failures == failureNotificationThreshold {
+				notifications.NotifyWithContext(jobctx, jobNotification(jobDescription, notifications.Critical,
+					fmt.Sprintf("Job %v is failing repeatedly", jobDescription),
+					fmt.Sprintf("The last %v runs failed, most recently with: %v", failures, err),
+					failures,
+				))
+			}
 			logger.End(jobctx)
 			return
 		}
+		failures := consecutiveFailures.Swap(0)
+		if failures >= failureNotificationThreshold {
+			notifications.NotifyWithContext(jobctx, jobNotification(jobDescription, notifications.Info,
+				fmt.Sprintf("Job %v recovered", jobDescription),
+				fmt.Sprintf("The job succeeded after %v consecutive failures", failures),
+				failures,
+			))
+		}
 		logger.Info(jobctx, "job ending normally")
 		logger.End(jobctx)
 	}
-}
\ No newline at end of file
+}
+
+func jobNotification(jobDescription string, severity notifications.Severity, title string, message string, consecutiveFailures int64) notifications.Notification {
+	return notifications.Notification{
+		Title:    title,
+		Message:  message,
+		Severity: severity,
+		Source:   "jobs",
+		Fields: map[string]string{
+			"job":                  jobDescription,
+			"consecutive_failures": strconv.FormatInt(consecutiveFailures, 10),
+		},
+	}
+}
@@ -36,9 +36,10 @@ func Logger(ctx context.Context) *JobLogger {
 type JobCategory string
 
 const (
-	Main   JobCategory = "MAIN"
-	Export JobCategory = "EXPORT"
-	Import JobCategory = "IMPORT"
+	Main      JobCategory = "MAIN"
+	Export    JobCategory = "EXPORT"
+	Import    JobCategory = "IMPORT"
+	Retention JobCategory = "RETENTION"
 )
 
 func CreateJob(ctx context.Context, db db.DBConnection, category JobCategory) (*JobLogger, error) {
@@ -97,6 +98,9 @@ type JobLogger struct {
 	fileMutex       *sync.Mutex
 }
 
+func (l *JobLogger) JobID() string {
+	return l.job.ID
+}
 func (l *JobLogger) End(ctx context.Context) {
 	err := l.db.Jobs().Close(ctx, l.job)
 	if err != nil {
@@ -47,7 +47,7 @@ func LogWithContext(ctx context.Context, level int, fstring string, args ...any)
 		if !ok {
 			FDefaultLog("Unable to log, cannot cast %v from context %v into logger. intended message %v:", logger, ctx, fmt.Sprintf(fstring, args...))
 		}
-		logger.Debug(ctx, fstring, args...)
+		logger.log(ctx, level, fstring, args...)
 	}
 }
 
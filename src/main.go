@@ -1,38 +1,78 @@
 package main
 
 import (
-	"com/connections/db/mysql"
+	"com/alerts"
+	"com/api"
+	"com/battery"
 	"com/connections/sensors"
+	"com/connections/sensors/yolinkfake"
 	"com/data"
+	"com/exports"
 	"com/jobs"
 	"com/logs"
+	"com/notifications"
+	"com/outputs"
+	"com/retention"
+	"com/rollups"
+	"com/staleness"
 	"com/utils"
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+	// Time zones for exports on hosts without a zone database
+	_ "time/tzdata"
 
 	"github.com/go-co-op/gocron/v2"
 	"github.com/joho/godotenv"
 )
 
+// Collect from a fake YoLink API into a separate database, to run without YoLink devices or credentials.
+var simulate = flag.Bool("simulate", false, "collect from a fake YoLink API with simulated devices, into the "+simulationDatabaseName+" database")
+
 func main() {
+	flag.Parse()
 	err := godotenv.Load("../.env")
 	if err != nil {
 		log.Fatal("fatal:", err)
 	}
-	err = run(context.Background())
+	command, ok := commands[flag.Arg(0)]
+	if !ok {
+		log.Fatalf("fatal: unknown command %v", flag.Arg(0))
+	}
+	err = command(context.Background(), flag.Args()[min(1, flag.NArg()):])
 	if err != nil {
 		log.Fatal("fatal:", err)
 	}
 }
-func run(ctx context.Context) error {
+func run(ctx context.Context, _ []string) error {
+	exportOptions, err := exportOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+	exportDestination := strings.TrimSpace(os.Getenv("EXPORT_DESTINATION"))
+	newEventsExport := exports.IncrementalExport{
+		Name:    "events",
+		Options: exportOptions,
+		IsDaily: strings.TrimSpace(os.Getenv("EXPORT_DAILY_FILES")) == "true",
+	}
+	if newEventsExport.IsDaily && !exports.IsAppendable(exportOptions.Format) {
+		return fmt.Errorf("daily export files cannot be in format %v, which cannot be appended to", exportOptions.Format)
+	}
+	includeDevices := strings.TrimSpace(os.Getenv("EXPORT_DEVICE_METADATA")) == "true"
+	err = checkExportDestination(exportDestination, newEventsExport.IsDaily)
+	if err != nil {
+		return err
+	}
+
 	// Connect to DB
-	dbConnection, err := mysql.NewMySQLConnection(ctx, strings.TrimSpace(os.Getenv("MYSQL_CONNECTION_STRING")), false)
+	dbConnection, err := connectDB(ctx)
 	if err != nil {
-		return fmt.Errorf("error connecting to DB: %w", err)
+		return err
 	}
 	defer logs.LogErrorsWithContext(ctx, dbConnection.Close, fmt.Sprintf("error closing db connection %v", dbConnection))
 
@@ -43,12 +83,32 @@ func run(ctx context.Context) error {
 	}
 	ctx = logs.ContextWithLogger(ctx, jobLogger)
 
+	// Set up notifications
+	notificationsFile := strings.TrimSpace(os.Getenv("NOTIFICATIONS_FILE"))
+	if notificationsFile != "" {
+		config, err := notifications.LoadConfig(notificationsFile)
+		if err != nil {
+			return fmt.Errorf("error loading notifications config: %w", err)
+		}
+		notifier, err := notifications.NewNotifier(dbConnection, *config)
+		if err != nil {
+			return fmt.Errorf("error creating notifier: %w", err)
+		}
+		ctx = notifications.ContextWithNotifier(ctx, notifier)
+	}
+
 	// Connect to YoLink
+	yoLinkBaseURL := sensors.DEFAULT_BASE_URL
+	yoLinkUAID := strings.TrimSpace(os.Getenv("YOLINK_UAID"))
+	yoLinkSecretKey := strings.TrimSpace(os.Getenv("YOLINK_SECRET_KEY"))
+	if *simulate {
+		server := yolinkfake.NewServer(yolinkfake.Config{Devices: yolinkfake.DefaultDevices(), Live: true})
+		defer server.Close()
+		jobLogger.Info(ctx, "Simulating YoLink devices at %v", server.URL())
+		yoLinkBaseURL, yoLinkUAID, yoLinkSecretKey = server.URL(), server.ClientID(), server.ClientSecret()
+	}
 	yoLinkConnection, err := utils.Retry2(3, func() (*sensors.YoLinkConnection, error) {
-		return sensors.NewYoLinkConnection(ctx,
-			strings.TrimSpace(os.Getenv("YOLINK_UAID")),
-			strings.TrimSpace(os.Getenv("YOLINK_SECRET_KEY")),
-		)
+		return sensors.NewYoLinkConnectionWithBaseURL(ctx, yoLinkBaseURL, yoLinkUAID, yoLinkSecretKey)
 	}, nil)
 	if err != nil {
 		return fmt.Errorf("error while creating new YoLink connection: %w", err)
@@ -58,48 +118,197 @@ func run(ctx context.Context) error {
 		return fmt.Errorf("error while updating YoLink device data: %w", err)
 	}
 
+	// Serve API
+	apiAddress := strings.TrimSpace(os.Getenv("API_ADDRESS"))
+	if apiAddress != "" {
+		go func() {
+			err := api.Serve(ctx, apiAddress, api.NewHandler(ctx, dbConnection))
+			if err != nil {
+				logs.ErrorWithContext(ctx, "error serving API: %v", err)
+			}
+		}()
+	}
+
+	// Set up event handlers
+	monitor := staleness.NewMonitor(dbConnection)
+	handlers := []jobs.EventHandler{monitor, battery.NewTracker(dbConnection)}
+	alertRulesFile := strings.TrimSpace(os.Getenv("ALERT_RULES_FILE"))
+	if alertRulesFile != "" {
+		rules, err := alerts.LoadRules(alertRulesFile)
+		if err != nil {
+			return fmt.Errorf("error loading alert rules: %w", err)
+		}
+		evaluator, err := alerts.NewEvaluator(dbConnection, rules)
+		if err != nil {
+			return fmt.Errorf("error creating alert evaluator: %w", err)
+		}
+		handlers = append(handlers, evaluator)
+	}
+	outputsFile := strings.TrimSpace(os.Getenv("OUTPUTS_FILE"))
+	if outputsFile != "" {
+		config, err := outputs.LoadConfig(outputsFile)
+		if err != nil {
+			return fmt.Errorf("error loading outputs: %w", err)
+		}
+		mirror, err := outputs.NewMirror(ctx, *config)
+		if err != nil {
+			return fmt.Errorf("error creating outputs: %w", err)
+		}
+		// Write what is still buffered before exiting, without waiting long on outputs that are down
+		defer logs.LogErrorsWithContext(ctx, func() error {
+			flushCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+			return mirror.Flush(flushCtx)
+		}, "error flushing outputs")
+		handlers = append(handlers, mirror)
+	}
+
 	// Store sensor data
 	jobLogger.Info(ctx, "Initial run starting...")
-	err = jobs.StoreAllConnectionSensorData(ctx, dbConnection, yoLinkConnection)
+	err = jobs.StoreAllConnectionSensorData(ctx, dbConnection, yoLinkConnection, handlers...)
 	if err != nil {
 		return fmt.Errorf("error while storing sensor data: %w", err)
 	}
+	err = monitor.Check(ctx)
+	if err != nil {
+		return fmt.Errorf("error while checking for stale devices: %w", err)
+	}
+	err = rollups.Update(ctx, dbConnection)
+	if err != nil {
+		return fmt.Errorf("error while updating rollups: %w", err)
+	}
 
-	// Schedule job
-	logs.FDefaultLog("Scheduling starting...")
-	err = scheduleJob(
-		jobs.CreateJob(ctx,
+	// Export events added since the last export
+	exportNewEvents := jobs.CreateJob(ctx, logs.Export,
+		func(ctx context.Context) error {
+			export := newEventsExport
+			// Connect to the destination for each run, rather than holding a connection that may drop between them
+			sink, err := exports.OpenSink(exportDestination)
+			if err != nil {
+				return fmt.Errorf("error opening export destination: %w", err)
+			}
+			defer logs.LogErrorsWithContext(ctx, func() error { return exports.CloseSink(sink) }, "error closing export destination")
+			export.Options.Sink = sink
+			if includeDevices {
+				devices, err := exports.Devices(ctx, dbConnection)
+				if err != nil {
+					return err
+				}
+				export.Options.Devices = devices
+			}
+			report, err := exports.ExportIncremental(ctx, dbConnection, export)
+			if err != nil {
+				if report.Rows > 0 || report.SkippedRows > 0 {
+					logs.WarnWithContext(ctx, "Export of new events failed after %v", report)
+				}
+				return err
+			}
+			if report.SkippedRows > 0 {
+				logs.WarnWithContext(ctx, "Exported new events: %v", report)
+				return nil
+			}
+			logs.InfoWithContext(ctx, "Exported new events: %v", report)
+			return nil
+		},
+		"Export new events",
+	)
+	exportNewEvents()
+
+	// Schedule jobs
+	scheduledJobs := []scheduledJob{{
+		definition: gocron.DurationJob(20 * time.Minute),
+		function: jobs.CreateJob(ctx, logs.Import,
 			func(ctx context.Context) error {
-				return jobs.StoreAllConnectionSensorData(ctx, dbConnection, yoLinkConnection)
+				err := jobs.StoreAllConnectionSensorData(ctx, dbConnection, yoLinkConnection, handlers...)
+				if err != nil {
+					return err
+				}
+				err = monitor.Check(ctx)
+				if err != nil {
+					return err
+				}
+				return rollups.Update(ctx, dbConnection)
 			},
 			"Store all YoLinkSensor data",
 		),
-		20*time.Minute,
-	)
-	if err != nil {
-		return fmt.Errorf("error scheduling job: %w", err)
+	}, {
+		definition: gocron.DurationJob(20 * time.Minute),
+		function:   exportNewEvents,
+	}}
+	retentionFile := strings.TrimSpace(os.Getenv("RETENTION_FILE"))
+	if retentionFile != "" {
+		config, err := retention.LoadConfig(retentionFile)
+		if err != nil {
+			return fmt.Errorf("error loading retention policies: %w", err)
+		}
+		scheduledJobs = append(scheduledJobs, scheduledJob{
+			definition: gocron.DurationJob(time.Duration(config.Interval)),
+			function: jobs.CreateJob(ctx, logs.Retention,
+				func(ctx context.Context) error {
+					return retention.Run(ctx, dbConnection, *config)
+				},
+				"Apply retention policies",
+			),
+		})
 	}
-
-	// Export
-	err = utils.Retry1(3, func() error {
-		items := dbConnection.Events().Get(ctx, data.EventFilter{})
-		return dbConnection.Events().Export(ctx, items)
-	}, nil)
+	exportsFile := strings.TrimSpace(os.Getenv("EXPORTS_FILE"))
+	if exportsFile != "" {
+		config, err := exports.LoadSchedule(exportsFile)
+		if err != nil {
+			return fmt.Errorf("error loading scheduled exports: %w", err)
+		}
+		for _, export := range config.Exports {
+			scheduledJobs = append(scheduledJobs, scheduledJob{
+				definition: gocron.CronJob(export.Schedule, false),
+				function: jobs.CreateJob(ctx, logs.Export,
+					func(ctx context.Context) error {
+						report, err := exports.RunScheduled(ctx, dbConnection, export)
+						if err != nil {
+							if report.Rows > 0 || report.SkippedRows > 0 {
+								logs.WarnWithContext(ctx, "Export %v failed after %v", export.Name, report)
+							}
+							return err
+						}
+						if report.SkippedRows > 0 {
+							logs.WarnWithContext(ctx, "Exported %v: %v", export.Name, report)
+							return nil
+						}
+						logs.InfoWithContext(ctx, "Exported %v: %v", export.Name, report)
+						return nil
+					},
+					fmt.Sprintf("Export %v", export.Name),
+				),
+			})
+		}
+	}
+	logs.FDefaultLog("Scheduling starting...")
+	err = scheduleJobs(scheduledJobs)
 	if err != nil {
-		return fmt.Errorf("error exporting: %w", err)
+		return fmt.Errorf("error scheduling jobs: %w", err)
 	}
 
+	// Export events added since the last scheduled export
+	exportNewEvents()
 	return nil
 }
 
-func scheduleJob(function any, interval time.Duration) error {
+type scheduledJob struct {
+	definition gocron.JobDefinition
+	function   func()
+}
+
+// Run the jobs on their schedules, returning after 72 hours.
+func scheduleJobs(scheduledJobs []scheduledJob) error {
 	s, err := gocron.NewScheduler()
 	if err != nil {
 		return fmt.Errorf("error creating scheduler: %w", err)
 	}
-	_, err = s.NewJob(gocron.DurationJob(interval), gocron.NewTask(function))
-	if err != nil {
-		return fmt.Errorf("error creating job: %w", err)
+	for _, job := range scheduledJobs {
+		// Skip a run while the previous one is still going, so slow imports or exports never overlap
+		_, err = s.NewJob(job.definition, gocron.NewTask(job.function), gocron.WithSingletonMode(gocron.LimitModeReschedule))
+		if err != nil {
+			return fmt.Errorf("error creating job: %w", err)
+		}
 	}
 	s.Start()
 	time.Sleep(72 * time.Hour)
@@ -109,3 +318,41 @@ func scheduleJob(function any, interval time.Duration) error {
 	}
 	return nil
 }
+
+// Export format, compression, dates and error budget from EXPORT_FORMAT, EXPORT_COMPRESSION,
+// EXPORT_TIMESTAMP_FORMAT, EXPORT_TIME_ZONE and EXPORT_ERROR_BUDGET. The sink is left to each export run.
+func exportOptionsFromEnv() (data.ExportOptions, error) {
+	format, err := data.ParseExportFormat(strings.TrimSpace(os.Getenv("EXPORT_FORMAT")))
+	if err != nil {
+		return data.ExportOptions{}, err
+	}
+	compression, err := data.ParseExportCompression(strings.TrimSpace(os.Getenv("EXPORT_COMPRESSION")))
+	if err != nil {
+		return data.ExportOptions{}, err
+	}
+	dates, err := data.ParseDateFormat(strings.TrimSpace(os.Getenv("EXPORT_TIMESTAMP_FORMAT")), strings.TrimSpace(os.Getenv("EXPORT_TIME_ZONE")))
+	if err != nil {
+		return data.ExportOptions{}, err
+	}
+	errorBudget := 0
+	if budget := strings.TrimSpace(os.Getenv("EXPORT_ERROR_BUDGET")); budget != "" {
+		errorBudget, err = strconv.Atoi(budget)
+		if err != nil || errorBudget < 0 {
+			return data.ExportOptions{}, fmt.Errorf("export error budget must be a number of rows, got %q", budget)
+		}
+	}
+	return data.ExportOptions{Format: format, Compression: compression, Dates: dates, ErrorBudget: errorBudget}, nil
+}
+
+// Check the export destination can be opened, and appended to if exports go into daily files.
+func checkExportDestination(destination string, isDaily bool) error {
+	sink, err := exports.OpenSink(destination)
+	if err != nil {
+		return fmt.Errorf("error opening export destination: %w", err)
+	}
+	defer exports.CloseSink(sink)
+	if _, ok := sink.(data.AppendingExportSink); isDaily && !ok {
+		return fmt.Errorf("daily export files cannot be written to %v, which cannot be appended to", sink.Location(""))
+	}
+	return nil
+}
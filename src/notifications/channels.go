@@ -0,0 +1,158 @@
+package notifications
+
+import (
+	"com/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A destination notifications can be sent to.
+type Channel interface {
+	// Unique name of the channel, used when recording deliveries.
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+type ChannelType string
+
+const (
+	Webhook ChannelType = "webhook"
+	Slack   ChannelType = "slack"
+	Discord ChannelType = "discord"
+	SMTP    ChannelType = "smtp"
+)
+
+// Configuration of a single channel as read from the notifications file.
+type ChannelConfig struct {
+	Name string      `json:"name"`
+	Type ChannelType `json:"type"`
+	// Optional text/template overrides. The Notification is the template data.
+	TitleTemplate string `json:"titleTemplate,omitempty"`
+	BodyTemplate  string `json:"bodyTemplate,omitempty"`
+	// Only notifications at or above this severity are sent. Defaults to all.
+	MinSeverity Severity `json:"minSeverity,omitempty"`
+
+	// Webhook, Slack and Discord
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// SMTP
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+func NewChannel(config ChannelConfig) (Channel, error) {
+	if config.Name == "" {
+		return nil, errors.New("channel has no name")
+	}
+	messageTemplate, err := newMessageTemplate(config.TitleTemplate, config.BodyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error creating templates for channel %v: %w", config.Name, err)
+	}
+	switch config.Type {
+	case Webhook, Slack, Discord:
+		if config.URL == "" {
+			return nil, fmt.Errorf("%v channel %v has no url", config.Type, config.Name)
+		}
+		return &WebhookChannel{config: config, template: messageTemplate}, nil
+	case SMTP:
+		if config.Host == "" || config.From == "" || len(config.To) == 0 {
+			return nil, fmt.Errorf("smtp channel %v requires host, from and to", config.Name)
+		}
+		return &SMTPChannel{config: config, template: messageTemplate}, nil
+	}
+	return nil, fmt.Errorf("unknown type %v for channel %v", config.Type, config.Name)
+}
+
+// Posts notifications as JSON. Generic webhooks receive the notification itself along with the rendered text,
+// Slack and Discord compatible webhooks receive their incoming webhook payloads.
+type WebhookChannel struct {
+	config   ChannelConfig
+	template *messageTemplate
+}
+
+func (c *WebhookChannel) Name() string {
+	return c.config.Name
+}
+func (c *WebhookChannel) Send(ctx context.Context, n Notification) error {
+	title, body, err := c.template.render(n)
+	if err != nil {
+		return err
+	}
+
+	var payload any
+	switch c.config.Type {
+	case Slack:
+		payload = map[string]any{"text": fmt.Sprintf("*%s*\n%s", title, body)}
+	case Discord:
+		payload = map[string]any{"content": fmt.Sprintf("**%s**\n%s", title, body)}
+	default:
+		payload = map[string]any{
+			"notification": n,
+			"title":        title,
+			"text":         body,
+		}
+	}
+
+	err = utils.PostJsonIgnoringResponse(ctx, c.config.URL, c.config.Headers, payload)
+	if err != nil {
+		return fmt.Errorf("error posting to %v channel %v: %w", c.config.Type, c.config.Name, err)
+	}
+	return nil
+}
+
+// Emails notifications as plain text.
+type SMTPChannel struct {
+	config   ChannelConfig
+	template *messageTemplate
+}
+
+func (c *SMTPChannel) Name() string {
+	return c.config.Name
+}
+func (c *SMTPChannel) Send(ctx context.Context, n Notification) error {
+	title, body, err := c.template.render(n)
+	if err != nil {
+		return err
+	}
+
+	port := c.config.Port
+	if port == 0 {
+		port = 25
+	}
+	address := net.JoinHostPort(c.config.Host, strconv.Itoa(port))
+	var auth smtp.Auth
+	if c.config.Username != "" {
+		auth = smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)
+	}
+	message := strings.Join([]string{
+		"From: " + c.config.From,
+		"To: " + strings.Join(c.config.To, ", "),
+		"Subject: " + title,
+		"Date: " + time.Unix(n.Timestamp, 0).UTC().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	// net/smtp has no context support, so honour cancellation before connecting at least.
+	if ctx.Err() != nil {
+		return fmt.Errorf("not sending email over channel %v: %w", c.config.Name, ctx.Err())
+	}
+	err = smtp.SendMail(address, auth, c.config.From, c.config.To, []byte(message))
+	if err != nil {
+		return fmt.Errorf("error sending email via %v over channel %v: %w", address, c.config.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var testNotification = Notification{
+	Title:     "Freezer too warm",
+	Message:   "state.temperature is -5",
+	Severity:  Critical,
+	Source:    "alerts",
+	Fields:    map[string]string{"device": "Freezer"},
+	Timestamp: 1700000000,
+}
+
+// A server recording the JSON body and headers of each request, responding with the status.
+func newRecordingServer(t *testing.T, status int) (*httptest.Server, *[]map[string]any, *[]http.Header) {
+	t.Helper()
+	bodies := []map[string]any{}
+	headers := []http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			t.Errorf("error decoding request body: %v", err)
+		}
+		bodies = append(bodies, body)
+		headers = append(headers, r.Header.Clone())
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &bodies, &headers
+}
+
+func TestWebhookPayloads(t *testing.T) {
+	tests := []struct {
+		channelType ChannelType
+		check       func(t *testing.T, body map[string]any)
+	}{
+		{Webhook, func(t *testing.T, body map[string]any) {
+			if body["title"] != "[CRITICAL] Freezer too warm" {
+				t.Errorf("unexpected title %v", body["title"])
+			}
+			notification, ok := body["notification"].(map[string]any)
+			if !ok || notification["source"] != "alerts" {
+				t.Errorf("expected the notification in the payload, got %v", body["notification"])
+			}
+		}},
+		{Slack, func(t *testing.T, body map[string]any) {
+			text, _ := body["text"].(string)
+			if !strings.HasPrefix(text, "*[CRITICAL] Freezer too warm*\nstate.temperature is -5") || !strings.Contains(text, "device: Freezer") {
+				t.Errorf("unexpected Slack text %q", text)
+			}
+		}},
+		{Discord, func(t *testing.T, body map[string]any) {
+			content, _ := body["content"].(string)
+			if !strings.HasPrefix(content, "**[CRITICAL] Freezer too warm**\n") {
+				t.Errorf("unexpected Discord content %q", content)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(string(test.channelType), func(t *testing.T) {
+			server, bodies, headers := newRecordingServer(t, http.StatusNoContent)
+			channel, err := NewChannel(ChannelConfig{
+				Name: "test", Type: test.channelType, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = channel.Send(context.Background(), testNotification)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(*bodies) != 1 {
+				t.Fatalf("expected 1 request, got %v", len(*bodies))
+			}
+			if (*headers)[0].Get("Authorization") != "Bearer token" || (*headers)[0].Get("Content-Type") != "application/json" {
+				t.Errorf("unexpected headers %v", (*headers)[0])
+			}
+			test.check(t, (*bodies)[0])
+		})
+	}
+}
+
+func TestWebhookTemplates(t *testing.T) {
+	server, bodies, _ := newRecordingServer(t, http.StatusOK)
+	channel, err := NewChannel(ChannelConfig{
+		Name: "test", Type: Webhook, URL: server.URL,
+		TitleTemplate: "{{.Source}}: {{.Title}}", BodyTemplate: "{{index .Fields \"device\"}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = channel.Send(context.Background(), testNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := (*bodies)[0]
+	if body["title"] != "alerts: Freezer too warm" || body["text"] != "Freezer" {
+		t.Errorf("unexpected rendering %v and %v", body["title"], body["text"])
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	server, _, _ := newRecordingServer(t, http.StatusInternalServerError)
+	channel, err := NewChannel(ChannelConfig{Name: "test", Type: Slack, URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = channel.Send(context.Background(), testNotification)
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("expected an error with the status, got %v", err)
+	}
+}
+
+// An email received by the SMTP server.
+type receivedEmail struct {
+	from    string
+	to      []string
+	message string
+}
+
+// An SMTP server accepting a single connection, rejecting recipients with the reply code if it is not 0.
+// The email is sent on the channel once the connection ends.
+func newSMTPServer(t *testing.T, rejectCode int) (string, int, <-chan receivedEmail) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	emails := make(chan receivedEmail, 1)
+	go func() {
+		defer close(emails)
+		connection, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn := textproto.NewConn(connection)
+		defer conn.Close()
+		email := receivedEmail{}
+		defer func() { emails <- email }()
+		err = conn.PrintfLine("220 localhost ready")
+		for err == nil {
+			var line string
+			line, err = conn.ReadLine()
+			if err != nil {
+				return
+			}
+			command, argument, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(command) {
+			case "EHLO", "HELO":
+				err = conn.PrintfLine("250 localhost")
+			case "MAIL":
+				email.from = strings.Trim(strings.TrimPrefix(argument, "FROM:"), "<>")
+				err = conn.PrintfLine("250 OK")
+			case "RCPT":
+				if rejectCode != 0 {
+					err = conn.PrintfLine("%v mailbox unavailable", rejectCode)
+					continue
+				}
+				email.to = append(email.to, strings.Trim(strings.TrimPrefix(argument, "TO:"), "<>"))
+				err = conn.PrintfLine("250 OK")
+			case "DATA":
+				err = conn.PrintfLine("354 end with .")
+				if err != nil {
+					return
+				}
+				var message []byte
+				message, err = io.ReadAll(conn.DotReader())
+				email.message = string(message)
+				if err == nil {
+					err = conn.PrintfLine("250 OK")
+				}
+			case "QUIT":
+				conn.PrintfLine("221 bye")
+				return
+			default:
+				err = conn.PrintfLine("502 unknown command")
+			}
+		}
+	}()
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, portNumber, emails
+}
+
+func TestSMTPSend(t *testing.T) {
+	host, port, emails := newSMTPServer(t, 0)
+	channel, err := NewChannel(ChannelConfig{
+		Name: "mail", Type: SMTP, Host: host, Port: port, From: "alerts@example.com", To: []string{"a@example.com", "b@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = channel.Send(context.Background(), testNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := <-emails
+	if email.from != "alerts@example.com" || strings.Join(email.to, ",") != "a@example.com,b@example.com" {
+		t.Errorf("unexpected envelope from %v to %v", email.from, email.to)
+	}
+	for _, expected := range []string{
+		"From: alerts@example.com\n",
+		"To: a@example.com, b@example.com\n",
+		"Subject: [CRITICAL] Freezer too warm\n",
+		"Date: Tue, 14 Nov 2023 22:13:20 +0000\n",
+		"\n\nstate.temperature is -5\n",
+		"device: Freezer",
+	} {
+		if !strings.Contains(email.message, expected) {
+			t.Errorf("expected %q in the email, got %q", expected, email.message)
+		}
+	}
+}
+
+func TestSMTPRejected(t *testing.T) {
+	host, port, emails := newSMTPServer(t, 550)
+	channel, err := NewChannel(ChannelConfig{Name: "mail", Type: SMTP, Host: host, Port: port, From: "alerts@example.com", To: []string{"a@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = channel.Send(context.Background(), testNotification)
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("expected an error with the reply code, got %v", err)
+	}
+	if email := <-emails; email.message != "" {
+		t.Errorf("expected no email to be sent, got %q", email.message)
+	}
+}
+
+func TestInvalidChannels(t *testing.T) {
+	configs := map[string]ChannelConfig{
+		"no name":         {Type: Webhook, URL: "http://localhost"},
+		"no url":          {Name: "test", Type: Slack},
+		"unknown type":    {Name: "test", Type: "pager", URL: "http://localhost"},
+		"incomplete smtp": {Name: "test", Type: SMTP, Host: "localhost"},
+		"bad template":    {Name: "test", Type: Webhook, URL: "http://localhost", TitleTemplate: "{{.Title"},
+	}
+	for name, config := range configs {
+		_, err := NewChannel(config)
+		if err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestSeverityRank(t *testing.T) {
+	if !(severityRank("") < severityRank(Info) && severityRank(Info) < severityRank(Warning) && severityRank(Warning) < severityRank(Critical)) {
+		t.Fatal("severities are not ranked in order")
+	}
+}
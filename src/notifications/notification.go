@@ -0,0 +1,70 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+type Severity string
+
+const (
+	Info     Severity = "INFO"
+	Warning  Severity = "WARNING"
+	Critical Severity = "CRITICAL"
+)
+
+// Something notable that happened, such as an alert firing or a device going offline.
+type Notification struct {
+	Title    string            `json:"title"`
+	Message  string            `json:"message"`
+	Severity Severity          `json:"severity"`
+	Source   string            `json:"source"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	// Epoch seconds.
+	Timestamp int64 `json:"timestamp"`
+}
+
+const defaultTitleTemplate = "[{{.Severity}}] {{.Title}}"
+const defaultBodyTemplate = `{{.Message}}
+{{range $k, $v := .Fields}}
+{{$k}}: {{$v}}{{end}}`
+
+// Renders notifications into text using text/template, with the Notification as the template data.
+type messageTemplate struct {
+	title *template.Template
+	body  *template.Template
+}
+
+// Empty templates use the defaults.
+func newMessageTemplate(titleTemplate string, bodyTemplate string) (*messageTemplate, error) {
+	if titleTemplate == "" {
+		titleTemplate = defaultTitleTemplate
+	}
+	if bodyTemplate == "" {
+		bodyTemplate = defaultBodyTemplate
+	}
+	title, err := template.New("title").Parse(titleTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing title template %v: %w", titleTemplate, err)
+	}
+	body, err := template.New("body").Parse(bodyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing body template %v: %w", bodyTemplate, err)
+	}
+	return &messageTemplate{title: title, body: body}, nil
+}
+
+func (t *messageTemplate) render(n Notification) (string, string, error) {
+	var title bytes.Buffer
+	err := t.title.Execute(&title, n)
+	if err != nil {
+		return "", "", fmt.Errorf("error rendering title of %v: %w", n, err)
+	}
+	var body bytes.Buffer
+	err = t.body.Execute(&body, n)
+	if err != nil {
+		return "", "", fmt.Errorf("error rendering body of %v: %w", n, err)
+	}
+	return title.String(), body.String(), nil
+}
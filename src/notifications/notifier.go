@@ -0,0 +1,119 @@
+package notifications
+
+import (
+	"com/connections/db"
+	"com/data"
+	"com/logs"
+	"com/utils"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Configuration of all channels as read from the notifications file.
+type Config struct {
+	Channels []ChannelConfig `json:"channels"`
+	// Attempts per channel before giving up. Defaults to utils.DefaultRetries.
+	Retries int `json:"retries,omitempty"`
+}
+
+// Read a notifications config from a JSON file of the form {"channels": [...]}.
+func LoadConfig(path string) (*Config, error) {
+	config, err := utils.ReadJsonFile[Config](path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading notifications config: %w", err)
+	}
+	return config, nil
+}
+
+// Sends notifications over every channel, retrying failures and recording each attempt in the store.
+type Notifier struct {
+	dbConnection db.DBConnection
+	channels     []Channel
+	minSeverity  map[string]Severity
+	retries      int
+}
+
+func NewNotifier(dbConnection db.DBConnection, config Config) (*Notifier, error) {
+	n := &Notifier{
+		dbConnection: dbConnection,
+		minSeverity:  map[string]Severity{},
+		retries:      config.Retries,
+	}
+	if n.retries <= 0 {
+		n.retries = utils.DefaultRetries
+	}
+	for _, channelConfig := range config.Channels {
+		channel, err := NewChannel(channelConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error creating channel: %w", err)
+		}
+		if _, exists := n.minSeverity[channel.Name()]; exists {
+			return nil, fmt.Errorf("duplicate channel name %v", channel.Name())
+		}
+		n.channels = append(n.channels, channel)
+		n.minSeverity[channel.Name()] = channelConfig.MinSeverity
+	}
+	return n, nil
+}
+
+// Send the notification over every channel accepting its severity. Channels are independent, so one failing does not stop the others.
+func (n *Notifier) Notify(ctx context.Context, notification Notification) error {
+	if notification.Timestamp == 0 {
+		notification.Timestamp = utils.TimeSeconds()
+	}
+	var jobID string
+	if logger := logs.Logger(ctx); logger != nil {
+		jobID = logger.JobID()
+	}
+
+	errs := []error{}
+	for _, channel := range n.channels {
+		if severityRank(notification.Severity) < severityRank(n.minSeverity[channel.Name()]) {
+			continue
+		}
+		attempt := 0
+		err := utils.Retry1(n.retries, func() error {
+			attempt++
+			err := channel.Send(ctx, notification)
+			n.recordDelivery(ctx, jobID, channel, notification, attempt, err)
+			return err
+		}, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error notifying over channel %v after %v attempts: %w", channel.Name(), attempt, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) recordDelivery(ctx context.Context, jobID string, channel Channel, notification Notification, attempt int, sendErr error) {
+	delivery := data.Delivery{
+		JobID:     jobID,
+		Channel:   channel.Name(),
+		Title:     notification.Title,
+		Attempt:   attempt,
+		Status:    data.DeliverySucceeded,
+		Timestamp: utils.TimeSeconds(),
+	}
+	if sendErr != nil {
+		delivery.Status = data.DeliveryFailed
+		delivery.Error = sendErr.Error()
+	}
+	_, err := n.dbConnection.Deliveries().Add(ctx, delivery)
+	if err != nil {
+		logs.ErrorWithContext(ctx, "error recording delivery %v: %v", delivery, err)
+	}
+}
+
+// Unknown and empty severities rank lowest.
+func severityRank(severity Severity) int {
+	switch severity {
+	case Info:
+		return 1
+	case Warning:
+		return 2
+	case Critical:
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package notifications
+
+import (
+	"com/connections/db/dbfake"
+	"com/data"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// A server failing the given number of requests with a 500 before succeeding, and the number of requests it received.
+func newFlakyServer(t *testing.T, failures int) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// The deliveries recorded over the channel, by attempt.
+func deliveries(t *testing.T, dbConnection *dbfake.Connection, channel string) []data.StoreDelivery {
+	t.Helper()
+	ctx := context.Background()
+	deliveries, err := data.Collect(dbConnection.Deliveries().Get(ctx, data.DeliveryFilter{Channel: &channel}).All(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(deliveries, func(a, b data.StoreDelivery) int { return a.Attempt - b.Attempt })
+	return deliveries
+}
+
+func TestNotifyRecordsDeliveries(t *testing.T) {
+	succeeding, _ := newFlakyServer(t, 0)
+	flaky, flakyRequests := newFlakyServer(t, 1)
+	failing, failingRequests := newFlakyServer(t, 100)
+	dbConnection := dbfake.NewConnection()
+	notifier, err := NewNotifier(dbConnection, Config{
+		Channels: []ChannelConfig{
+			{Name: "succeeding", Type: Webhook, URL: succeeding.URL},
+			{Name: "flaky", Type: Webhook, URL: flaky.URL},
+			{Name: "failing", Type: Webhook, URL: failing.URL},
+		},
+		Retries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Failing channels do not stop the others
+	err = notifier.Notify(context.Background(), testNotification)
+	if err == nil || !strings.Contains(err.Error(), "failing after 2 attempts") || strings.Contains(err.Error(), "flaky") {
+		t.Fatalf("expected only the failing channel's error, got %v", err)
+	}
+	if *flakyRequests != 2 || *failingRequests != 2 {
+		t.Errorf("expected a retry of the flaky channel and every retry of the failing one, got %v and %v requests", *flakyRequests, *failingRequests)
+	}
+
+	expected := map[string][]string{
+		"succeeding": {data.DeliverySucceeded},
+		"flaky":      {data.DeliveryFailed, data.DeliverySucceeded},
+		"failing":    {data.DeliveryFailed, data.DeliveryFailed},
+	}
+	for channel, statuses := range expected {
+		recorded := deliveries(t, dbConnection, channel)
+		if len(recorded) != len(statuses) {
+			t.Errorf("expected %v deliveries over %v, got %v", len(statuses), channel, recorded)
+			continue
+		}
+		for i, delivery := range recorded {
+			if delivery.Attempt != i+1 || delivery.Status != statuses[i] || delivery.Title != testNotification.Title {
+				t.Errorf("expected attempt %v over %v to be %v, got %v", i+1, channel, statuses[i], delivery)
+			}
+			if (delivery.Status == data.DeliveryFailed) != strings.Contains(delivery.Error, "500") {
+				t.Errorf("expected the error of failed deliveries only, got %v", delivery)
+			}
+		}
+	}
+}
+
+func TestNotifyMinSeverity(t *testing.T) {
+	server, requests := newFlakyServer(t, 0)
+	dbConnection := dbfake.NewConnection()
+	notifier, err := NewNotifier(dbConnection, Config{
+		Channels: []ChannelConfig{{Name: "critical", Type: Webhook, URL: server.URL, MinSeverity: Critical}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warning := testNotification
+	warning.Severity = Warning
+	for _, notification := range []Notification{warning, testNotification} {
+		err = notifier.Notify(context.Background(), notification)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *requests != 1 || len(deliveries(t, dbConnection, "critical")) != 1 {
+		t.Errorf("expected only the critical notification delivered, got %v requests", *requests)
+	}
+}
+
+func TestNewNotifierDuplicateChannels(t *testing.T) {
+	_, err := NewNotifier(dbfake.NewConnection(), Config{
+		Channels: []ChannelConfig{
+			{Name: "hook", Type: Webhook, URL: "http://localhost"},
+			{Name: "hook", Type: Slack, URL: "http://localhost"},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,39 @@
+package notifications
+
+import (
+	"com/logs"
+	"context"
+	"log"
+)
+
+// Allow contexts to provide and get notifiers.
+type notifierKey struct{}
+
+func ContextWithNotifier(ctx context.Context, n *Notifier) context.Context {
+	return context.WithValue(ctx, notifierKey{}, n)
+}
+func NotifierFromContext(ctx context.Context) *Notifier {
+	v := ctx.Value(notifierKey{})
+	if v == nil {
+		return nil
+	}
+	notifier, ok := v.(*Notifier)
+	if !ok {
+		log.Panic("Non notifier value found in notifier context key. notifierKey is package private. How?")
+	}
+	return notifier
+}
+
+// Send a notification with the notifier in the context, if there is one. Failures are logged rather than returned,
+// as notifying is secondary to whatever prompted the notification.
+func NotifyWithContext(ctx context.Context, n Notification) {
+	notifier := NotifierFromContext(ctx)
+	if notifier == nil {
+		logs.DebugWithContext(ctx, "no notifier configured, dropping notification %v", n.Title)
+		return
+	}
+	err := notifier.Notify(ctx, n)
+	if err != nil {
+		logs.ErrorWithContext(ctx, "error sending notification %v: %v", n.Title, err)
+	}
+}
@@ -0,0 +1,83 @@
+// Outputs mirroring stored events to time series databases, such as InfluxDB and Prometheus, for dashboards like Grafana.
+package outputs
+
+import (
+	"com/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+type OutputType string
+
+const (
+	InfluxDB   OutputType = "influxdb"
+	Prometheus OutputType = "prometheus"
+)
+
+// Points buffered per output while it is down, after which the oldest are dropped.
+const DefaultBufferSize = 100000
+
+// Wait after an output first fails before trying it again, doubling with each failure up to MaxRetryInterval.
+const DefaultRetryInterval = utils.Duration(10 * time.Second)
+const MaxRetryInterval = utils.Duration(10 * time.Minute)
+
+// Configuration of all outputs as read from the outputs file.
+type Config struct {
+	Outputs []OutputConfig `json:"outputs"`
+	// Attempts per write before the output counts as down. Defaults to utils.DefaultRetries.
+	Retries int `json:"retries,omitempty"`
+	// Defaults to DefaultBufferSize.
+	BufferSize int `json:"bufferSize,omitempty"`
+	// Defaults to DefaultRetryInterval.
+	RetryInterval utils.Duration `json:"retryInterval,omitempty"`
+}
+
+// Configuration of a single output.
+type OutputConfig struct {
+	Name string     `json:"name"`
+	Type OutputType `json:"type"`
+	// InfluxDB write endpoint, such as http://localhost:8086/api/v2/write?org=home&bucket=sensors,
+	// or Prometheus remote write endpoint, such as http://localhost:9090/api/v1/write.
+	URL string `json:"url"`
+	// Such as {"Authorization": "Token ..."} for InfluxDB.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Read an outputs config from a JSON file of the form {"outputs": [...]}.
+func LoadConfig(path string) (*Config, error) {
+	config, err := utils.ReadJsonFile[Config](path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading outputs config: %w", err)
+	}
+	err = config.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid outputs config: %w", err)
+	}
+	return config, nil
+}
+
+func (c Config) Validate() error {
+	if c.Retries < 0 || c.BufferSize < 0 || c.RetryInterval < 0 {
+		return errors.New("retries, buffer size and retry interval must not be negative")
+	}
+	names := map[string]bool{}
+	for _, output := range c.Outputs {
+		if output.Name == "" {
+			return errors.New("output has no name")
+		}
+		if names[output.Name] {
+			return fmt.Errorf("duplicate output name %v", output.Name)
+		}
+		names[output.Name] = true
+		if output.Type != InfluxDB && output.Type != Prometheus {
+			return fmt.Errorf("unknown type %v for output %v", output.Type, output.Name)
+		}
+		parsed, err := url.Parse(output.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("output %v must have an http or https url", output.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package outputs
+
+import (
+	"com/utils"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Writes points to InfluxDB in line protocol: a line per point, measured as the device's kind,
+// tagged with the device's name and brand, with the event's field name as its field.
+type InfluxDBOutput struct {
+	config OutputConfig
+}
+
+func (o *InfluxDBOutput) Name() string {
+	return o.config.Name
+}
+func (o *InfluxDBOutput) Write(ctx context.Context, points []Point) error {
+	var body strings.Builder
+	for _, point := range points {
+		writeLine(&body, point)
+	}
+	err := utils.PostBytes(ctx, o.config.URL, "text/plain; charset=utf-8", o.config.Headers, []byte(body.String()))
+	if err != nil {
+		return fmt.Errorf("error writing %v points to influxdb output %v: %w", len(points), o.config.Name, err)
+	}
+	return nil
+}
+
+// Write the point as a line, with its timestamp in nanoseconds, InfluxDB's default precision.
+func writeLine(b *strings.Builder, point Point) {
+	b.WriteString(lineEscaper.Replace(point.Measurement))
+	writeTag(b, "device_name", point.DeviceName)
+	writeTag(b, "device_brand", point.DeviceBrand)
+	b.WriteByte(' ')
+	b.WriteString(keyEscaper.Replace(point.Field))
+	b.WriteByte('=')
+	switch value := point.Value.(type) {
+	case float64:
+		b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	case bool:
+		b.WriteString(strconv.FormatBool(value))
+	case string:
+		b.WriteByte('"')
+		b.WriteString(stringEscaper.Replace(value))
+		b.WriteByte('"')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(point.Timestamp*1_000_000_000, 10))
+	b.WriteByte('\n')
+}
+
+// Tags with empty values are left out, as line protocol does not allow them.
+func writeTag(b *strings.Builder, key string, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteByte(',')
+	b.WriteString(key)
+	b.WriteByte('=')
+	b.WriteString(keyEscaper.Replace(value))
+}
+
+var (
+	lineEscaper   = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	keyEscaper    = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
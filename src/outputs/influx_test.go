@@ -0,0 +1,86 @@
+package outputs
+
+import (
+	"com/utils"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInfluxDBLines(t *testing.T) {
+	tests := []struct {
+		point    Point
+		expected string
+	}{
+		{
+			Point{Measurement: "THSensor", DeviceName: "Freezer", DeviceBrand: "yolink", Field: "state.temperature", Value: -18.5, Timestamp: 1700000000},
+			"THSensor,device_name=Freezer,device_brand=yolink state.temperature=-18.5 1700000000000000000\n",
+		},
+		{
+			Point{Measurement: "Door Sensor", DeviceName: "Back door, garage", DeviceBrand: "yolink", Field: "state open", Value: true, Timestamp: 1},
+			"Door\\ Sensor,device_name=Back\\ door\\,\\ garage,device_brand=yolink state\\ open=true 1000000000\n",
+		},
+		{
+			Point{Measurement: "device", DeviceName: "a=b", Field: "state.mode", Value: "say \"hi\"\\\nbye", Timestamp: 2},
+			"device,device_name=a\\=b state.mode=\"say \\\"hi\\\"\\\\\\nbye\" 2000000000\n",
+		},
+	}
+	for _, test := range tests {
+		var b strings.Builder
+		writeLine(&b, test.point)
+		if b.String() != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, b.String())
+		}
+	}
+}
+
+func TestInfluxDBWrite(t *testing.T) {
+	var body string
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("error reading request body: %v", err)
+		}
+		body, header = string(read), r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	output := &InfluxDBOutput{config: OutputConfig{Name: "influx", Type: InfluxDB, URL: server.URL, Headers: map[string]string{"Authorization": "Token secret"}}}
+	err := output.Write(context.Background(), []Point{
+		{Measurement: "THSensor", DeviceName: "Freezer", Field: "state.temperature", Value: -18.0, Timestamp: 1},
+		{Measurement: "THSensor", DeviceName: "Freezer", Field: "state.humidity", Value: 40.0, Timestamp: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "THSensor,device_name=Freezer state.temperature=-18 1000000000\nTHSensor,device_name=Freezer state.humidity=40 1000000000\n"
+	if body != expected {
+		t.Errorf("expected %q, got %q", expected, body)
+	}
+	if header.Get("Authorization") != "Token secret" || !strings.HasPrefix(header.Get("Content-Type"), "text/plain") {
+		t.Errorf("unexpected headers %v", header)
+	}
+}
+
+func TestInfluxDBWriteFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":"invalid","message":"unable to parse points"}`, http.StatusBadRequest)
+	}))
+	t.Cleanup(server.Close)
+
+	output := &InfluxDBOutput{config: OutputConfig{Name: "influx", Type: InfluxDB, URL: server.URL}}
+	err := output.Write(context.Background(), []Point{{Measurement: "device", Field: "state", Value: 1.0}})
+	var statusErr *utils.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest || !strings.Contains(statusErr.Message, "unable to parse points") {
+		t.Fatalf("expected a status error with the response's message, got %v", err)
+	}
+	if !isRejected(err) || isSampleRejected(err) {
+		t.Errorf("expected %v to be rejected as a whole", err)
+	}
+}
@@ -0,0 +1,343 @@
+package outputs
+
+import (
+	"com/data"
+	"com/jobs"
+	"com/logs"
+	"com/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Points written per request, so a long outage does not build requests too large to send.
+const batchSize = 5000
+
+// Batches of events queued per output while it is being written to, beyond which new ones are dropped.
+const queueSize = 1000
+
+// An event's value, ready for time series outputs.
+type Point struct {
+	// The device's kind, or "device" for devices without one.
+	Measurement string
+	DeviceName  string
+	DeviceBrand string
+	Field       string
+	// A float64, bool or string.
+	Value any
+	// In epoch seconds.
+	Timestamp int64
+}
+
+// A time series database points are written to.
+type Output interface {
+	// Unique name of the output, used in logs.
+	Name() string
+	Write(ctx context.Context, points []Point) error
+}
+
+func NewOutput(config OutputConfig) (Output, error) {
+	switch config.Type {
+	case InfluxDB:
+		return &InfluxDBOutput{config: config}, nil
+	case Prometheus:
+		return &PrometheusOutput{config: config}, nil
+	}
+	return nil, fmt.Errorf("unknown type %v for output %v", config.Type, config.Name)
+}
+
+var _ jobs.EventHandler = (*Mirror)(nil)
+
+// Mirrors stored events to every output. Each output is written to in the background, so collecting events is never held up by it.
+// Points are buffered while an output is down, and written once it is back, trying again after a wait that doubles with each failure.
+// Outputs are independent, so one being down does not hold up the others.
+type Mirror struct {
+	// Guards closed, so no points are queued once flushed
+	mutex   sync.Mutex
+	closed  bool
+	outputs []*bufferedOutput
+}
+
+// An output with the points not yet written to it.
+type bufferedOutput struct {
+	output        Output
+	queue         chan []Point
+	stopped       chan struct{}
+	bufferSize    int
+	retries       int
+	retryInterval time.Duration
+	// Only used by the output's writer
+	failures    int
+	nextAttempt time.Time
+	// The error of the last write before stopping
+	err error
+
+	// Guards points and dropped, which are only changed by the output's writer, so it reads them without locking
+	mutex   sync.Mutex
+	points  []Point
+	dropped int64
+}
+
+// Start writing to the outputs in the background, until flushed.
+func NewMirror(ctx context.Context, config Config) (*Mirror, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	bufferSize := config.BufferSize
+	if bufferSize == 0 {
+		bufferSize = DefaultBufferSize
+	}
+	retries := config.Retries
+	if retries == 0 {
+		retries = utils.DefaultRetries
+	}
+	retryInterval := config.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = DefaultRetryInterval
+	}
+	m := &Mirror{}
+	for _, outputConfig := range config.Outputs {
+		output, err := NewOutput(outputConfig)
+		if err != nil {
+			return nil, err
+		}
+		m.outputs = append(m.outputs, &bufferedOutput{
+			output:        output,
+			queue:         make(chan []Point, queueSize),
+			stopped:       make(chan struct{}),
+			bufferSize:    bufferSize,
+			retries:       retries,
+			retryInterval: time.Duration(retryInterval),
+		})
+	}
+	for _, output := range m.outputs {
+		go output.run(ctx)
+	}
+	return m, nil
+}
+
+// Queue the events' points for every output, dropping them for outputs too far behind to take them.
+func (m *Mirror) HandleEvents(ctx context.Context, device *data.StoreDevice, events []data.StoreEvent) error {
+	points := []Point{}
+	for _, event := range events {
+		point, ok := newPoint(device, event)
+		if ok {
+			points = append(points, point)
+		}
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return fmt.Errorf("outputs are flushed, dropping %v points", len(points))
+	}
+	for _, output := range m.outputs {
+		select {
+		case output.queue <- points:
+		default:
+			output.drop(ctx, len(points), "its queue is full")
+		}
+	}
+	return nil
+}
+
+// Stop taking points, and write those buffered to every output, even ones waiting to be tried again, such as before shutting down.
+// Returns early once ctx is done, leaving the rest unwritten.
+func (m *Mirror) Flush(ctx context.Context) error {
+	m.mutex.Lock()
+	if !m.closed {
+		m.closed = true
+		for _, output := range m.outputs {
+			close(output.queue)
+		}
+	}
+	m.mutex.Unlock()
+	errs := []error{}
+	for _, output := range m.outputs {
+		select {
+		case <-output.stopped:
+			if output.err != nil {
+				errs = append(errs, output.err)
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("error flushing output %v: %w", output.output.Name(), ctx.Err())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Points buffered for each output, by name.
+func (m *Mirror) Buffered() map[string]int {
+	buffered := map[string]int{}
+	for _, output := range m.outputs {
+		output.mutex.Lock()
+		buffered[output.output.Name()] = len(output.points)
+		output.mutex.Unlock()
+	}
+	return buffered
+}
+
+// The event as a point, unless it has no value.
+func newPoint(device *data.StoreDevice, event data.StoreEvent) (Point, bool) {
+	point := Point{
+		Measurement: device.Kind,
+		DeviceName:  device.Name,
+		DeviceBrand: device.Brand,
+		Field:       event.FieldName,
+		Timestamp:   event.EventTimestamp,
+	}
+	if point.Measurement == "" {
+		point.Measurement = "device"
+	}
+	switch event.ValueType {
+	case data.ValueNull:
+		return point, false
+	case data.ValueNumber:
+		if event.NumericValue != nil {
+			point.Value = *event.NumericValue
+			return point, true
+		}
+		number, err := strconv.ParseFloat(event.FieldValue, 64)
+		if err != nil {
+			return point, false
+		}
+		point.Value = number
+	case data.ValueBoolean:
+		point.Value = event.BooleanValue != nil && *event.BooleanValue
+	default:
+		point.Value = event.FieldValue
+	}
+	return point, true
+}
+
+// Write queued points as they come, and try again once due while the output is down, until the queue is closed.
+func (o *bufferedOutput) run(ctx context.Context) {
+	defer close(o.stopped)
+	ticker := time.NewTicker(o.retryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case points, ok := <-o.queue:
+			if !ok {
+				// Last chance before shutting down, so no waiting
+				o.nextAttempt = time.Time{}
+				o.err = o.flush(ctx)
+				return
+			}
+			o.buffer(ctx, points)
+		case <-ticker.C:
+		}
+		err := o.flush(ctx)
+		if err != nil {
+			logs.ErrorWithContext(ctx, "%v", err)
+		}
+	}
+}
+
+// Add the points, dropping the oldest beyond the buffer's size.
+func (o *bufferedOutput) buffer(ctx context.Context, points []Point) {
+	o.mutex.Lock()
+	o.points = append(o.points, points...)
+	overflow := len(o.points) - o.bufferSize
+	if overflow > 0 {
+		o.points = o.points[overflow:]
+	}
+	o.mutex.Unlock()
+	if overflow > 0 {
+		o.drop(ctx, overflow, "it is full")
+	}
+}
+
+func (o *bufferedOutput) drop(ctx context.Context, count int, reason string) {
+	o.mutex.Lock()
+	o.dropped += int64(count)
+	dropped := o.dropped
+	o.mutex.Unlock()
+	logs.WarnWithContext(ctx, "Output %v dropped %v points as %v, %v in total", o.output.Name(), count, reason, dropped)
+}
+
+// Write the buffered points in batches, unless the output is waiting to be tried again after failing.
+func (o *bufferedOutput) flush(ctx context.Context) error {
+	if len(o.points) == 0 || time.Now().Before(o.nextAttempt) {
+		return nil
+	}
+	for len(o.points) > 0 {
+		batch := o.points[:min(batchSize, len(o.points))]
+		written, err := o.write(ctx, batch)
+		o.mutex.Lock()
+		o.points = o.points[written:]
+		if len(o.points) == 0 {
+			o.points = nil
+		}
+		o.mutex.Unlock()
+		if err != nil {
+			o.failures++
+			wait := time.Duration(MaxRetryInterval)
+			// Shifting further could overflow, long after reaching the maximum
+			if o.failures <= 16 {
+				wait = min(o.retryInterval<<(o.failures-1), wait)
+			}
+			o.nextAttempt = time.Now().Add(wait)
+			return fmt.Errorf("output %v is down, keeping %v points to try again in %v: %w", o.output.Name(), len(o.points), wait, err)
+		}
+	}
+	if o.failures > 0 {
+		logs.InfoWithContext(ctx, "Output %v is back after %v failures", o.output.Name(), o.failures)
+		o.failures = 0
+	}
+	return nil
+}
+
+// Write the points, returning how many of them are done with, written or dropped, before any error.
+// Points the output rejects as invalid are dropped rather than tried again. When it rejects only some samples of a batch,
+// such as out of order ones, the batch is split in halves until those are found, so the rest are still written.
+func (o *bufferedOutput) write(ctx context.Context, points []Point) (int, error) {
+	var rejectedErr error
+	err := utils.Retry1(o.retries, func() error {
+		rejectedErr = nil
+		err := o.output.Write(ctx, points)
+		if isRejected(err) {
+			rejectedErr = err
+			return nil
+		}
+		return err
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	if rejectedErr == nil {
+		return len(points), nil
+	}
+	if !isSampleRejected(rejectedErr) {
+		logs.ErrorWithContext(ctx, "Output %v rejected %v points, dropping them: %v", o.output.Name(), len(points), rejectedErr)
+		return len(points), nil
+	}
+	if len(points) == 1 {
+		logs.WarnWithContext(ctx, "Output %v no longer takes a point, such as one older than it already has, dropping it: %v", o.output.Name(), rejectedErr)
+		return 1, nil
+	}
+	half := len(points) / 2
+	written, err := o.write(ctx, points[:half])
+	if err != nil {
+		return written, err
+	}
+	rest, err := o.write(ctx, points[half:])
+	return written + rest, err
+}
+
+// Whether the output rejected the request itself, so sending it again would fail again.
+func isRejected(err error) bool {
+	var statusErr *utils.StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+		statusErr.StatusCode != http.StatusRequestTimeout && statusErr.StatusCode != http.StatusTooManyRequests
+}
@@ -0,0 +1,227 @@
+package outputs
+
+import (
+	"com/data"
+	"com/utils"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testDevice = data.StoreDevice{HasID: data.HasID{ID: "1"}, Device: data.Device{Name: "Freezer", Kind: "THSensor", Brand: "yolink"}}
+
+// A temperature reading per timestamp.
+func temperatureEvents(timestamps ...int64) []data.StoreEvent {
+	events := []data.StoreEvent{}
+	for _, timestamp := range timestamps {
+		event := data.Event{EventSourceDeviceID: testDevice.ID, EventTimestamp: timestamp, FieldName: "state.temperature"}.WithValue(json.Number("-18"))
+		events = append(events, data.StoreEvent{Event: event})
+	}
+	return events
+}
+
+// A mirror to a single Prometheus output, trying it once per write and only again when flushed.
+func newTestMirror(t *testing.T, server *remoteWriteServer) *Mirror {
+	t.Helper()
+	mirror, err := NewMirror(context.Background(), Config{
+		Outputs:       []OutputConfig{{Name: "prometheus", Type: Prometheus, URL: server.URL}},
+		Retries:       1,
+		RetryInterval: utils.Duration(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mirror
+}
+
+// Wait for the condition, failing the test if it does not hold within a few seconds.
+func eventually(t *testing.T, condition func() bool, description string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", description)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func timestampsMs(samples []sample) []int64 {
+	timestamps := []int64{}
+	for _, s := range samples {
+		timestamps = append(timestamps, s.timestampMs)
+	}
+	return timestamps
+}
+
+func TestMirrorPoints(t *testing.T) {
+	events := []data.StoreEvent{
+		{Event: data.Event{EventTimestamp: 1, FieldName: "state.temperature"}.WithValue(json.Number("-18.5"))},
+		{Event: data.Event{EventTimestamp: 1, FieldName: "state.open"}.WithValue(true)},
+		{Event: data.Event{EventTimestamp: 1, FieldName: "state.mode"}.WithValue("auto")},
+		{Event: data.Event{EventTimestamp: 1, FieldName: "state.alarm"}.WithValue(nil)},
+	}
+	device := data.StoreDevice{Device: data.Device{Name: "Freezer"}}
+	expected := []any{-18.5, true, "auto"}
+	values := []any{}
+	for _, event := range events {
+		point, ok := newPoint(&device, event)
+		if !ok {
+			continue
+		}
+		if point.Measurement != "device" || point.DeviceName != "Freezer" || point.Field != event.FieldName {
+			t.Errorf("unexpected point %v", point)
+		}
+		values = append(values, point.Value)
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+}
+
+func TestMirrorWritesInBackground(t *testing.T) {
+	release := make(chan struct{})
+	server := newRemoteWriteServer(t, func(series []testSeries) (int, string) {
+		<-release
+		return http.StatusNoContent, ""
+	})
+	mirror := newTestMirror(t, server)
+
+	// Collecting goes on while the output is slow to respond
+	for timestamp := range int64(5) {
+		err := mirror.HandleEvents(context.Background(), &testDevice, temperatureEvents(timestamp))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+	err := mirror.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := server.samples()["state_temperature"]
+	if timestamps := timestampsMs(samples); !reflect.DeepEqual(timestamps, []int64{0, 1000, 2000, 3000, 4000}) {
+		t.Errorf("expected every sample written in order, got %v", timestamps)
+	}
+	if buffered := mirror.Buffered()["prometheus"]; buffered != 0 {
+		t.Errorf("expected nothing buffered, got %v", buffered)
+	}
+	err = mirror.HandleEvents(context.Background(), &testDevice, temperatureEvents(5))
+	if err == nil {
+		t.Error("expected an error handling events once flushed")
+	}
+}
+
+func TestMirrorKeepsPointsWhileDown(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	server := newRemoteWriteServer(t, func(series []testSeries) (int, string) {
+		if down.Load() {
+			return http.StatusServiceUnavailable, "starting up"
+		}
+		return http.StatusNoContent, ""
+	})
+	mirror := newTestMirror(t, server)
+
+	err := mirror.HandleEvents(context.Background(), &testDevice, temperatureEvents(1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return server.requestCount() == 1 }, "the first write")
+	if buffered := mirror.Buffered()["prometheus"]; buffered != 2 {
+		t.Errorf("expected 2 points kept, got %v", buffered)
+	}
+
+	// Flushing tries again without waiting
+	down.Store(false)
+	err = mirror.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamps := timestampsMs(server.samples()["state_temperature"]); !reflect.DeepEqual(timestamps, []int64{1000, 2000}) {
+		t.Errorf("expected the kept samples written, got %v", timestamps)
+	}
+}
+
+func TestMirrorFlushWhileDown(t *testing.T) {
+	server := newRemoteWriteServer(t, func(series []testSeries) (int, string) {
+		return http.StatusServiceUnavailable, "down"
+	})
+	mirror := newTestMirror(t, server)
+	err := mirror.HandleEvents(context.Background(), &testDevice, temperatureEvents(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mirror.Flush(context.Background())
+	if err == nil {
+		t.Fatal("expected an error flushing to an output that is down")
+	}
+	if buffered := mirror.Buffered()["prometheus"]; buffered != 1 {
+		t.Errorf("expected the point kept, got %v", buffered)
+	}
+}
+
+func TestMirrorSplitsRejectedSamples(t *testing.T) {
+	// Prometheus already has a newer sample than 3s, so rejects any request with it
+	server := newRemoteWriteServer(t, func(series []testSeries) (int, string) {
+		for _, s := range series {
+			for _, sample := range s.samples {
+				if sample.timestampMs == 3000 {
+					return http.StatusBadRequest, "out of order sample"
+				}
+			}
+		}
+		return http.StatusNoContent, ""
+	})
+	mirror := newTestMirror(t, server)
+	err := mirror.HandleEvents(context.Background(), &testDevice, temperatureEvents(1, 2, 3, 4, 5, 6, 7, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mirror.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int64{1000, 2000, 4000, 5000, 6000, 7000, 8000}
+	if timestamps := timestampsMs(server.samples()["state_temperature"]); !reflect.DeepEqual(timestamps, expected) {
+		t.Errorf("expected all but the rejected sample written, %v, got %v", expected, timestamps)
+	}
+	// Halved down to the rejected sample: 8, then 4, its halves of 2, the rejected half's halves of 1, then the other 4
+	if count := server.requestCount(); count != 7 {
+		t.Errorf("expected 7 requests, got %v", count)
+	}
+}
+
+func TestMirrorDropsRejectedRequests(t *testing.T) {
+	server := newRemoteWriteServer(t, func(series []testSeries) (int, string) {
+		return http.StatusBadRequest, "invalid labels"
+	})
+	mirror := newTestMirror(t, server)
+	err := mirror.HandleEvents(context.Background(), &testDevice, temperatureEvents(1, 2, 3, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mirror.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := server.requestCount(); count != 1 {
+		t.Errorf("expected the request to be neither split nor tried again, got %v requests", count)
+	}
+	if buffered := mirror.Buffered()["prometheus"]; buffered != 0 {
+		t.Errorf("expected the rejected points dropped, got %v kept", buffered)
+	}
+}
+
+func TestMirrorBufferSize(t *testing.T) {
+	output := &bufferedOutput{output: &PrometheusOutput{config: OutputConfig{Name: "prometheus"}}, bufferSize: 3}
+	output.buffer(context.Background(), []Point{{Timestamp: 1}, {Timestamp: 2}})
+	output.buffer(context.Background(), []Point{{Timestamp: 3}, {Timestamp: 4}, {Timestamp: 5}})
+	if !reflect.DeepEqual(output.points, []Point{{Timestamp: 3}, {Timestamp: 4}, {Timestamp: 5}}) || output.dropped != 2 {
+		t.Errorf("expected the oldest 2 points dropped, got %v, %v dropped", output.points, output.dropped)
+	}
+}
@@ -0,0 +1,156 @@
+package outputs
+
+import (
+	"cmp"
+	"com/utils"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Writes points to Prometheus with remote write 1.0: a snappy compressed protobuf WriteRequest.
+// Each series is named after the event's field, such as state_temperature, labelled with the device's kind, name and brand.
+// Booleans are written as 1 and 0, and text values are left out, as Prometheus only stores numbers.
+type PrometheusOutput struct {
+	config OutputConfig
+}
+
+func (o *PrometheusOutput) Name() string {
+	return o.config.Name
+}
+func (o *PrometheusOutput) Write(ctx context.Context, points []Point) error {
+	request := encodeWriteRequest(points)
+	if request == nil {
+		return nil
+	}
+	headers := map[string]string{
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	}
+	for k, v := range o.config.Headers {
+		headers[k] = v
+	}
+	err := utils.PostBytes(ctx, o.config.URL, "application/x-protobuf", headers, snappy.Encode(nil, request))
+	if err != nil {
+		return fmt.Errorf("error writing %v points to prometheus output %v: %w", len(points), o.config.Name, err)
+	}
+	return nil
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	value       float64
+	timestampMs int64
+}
+
+// The WriteRequest of the points' numeric values, nil if there are none.
+// Series hold their samples in time order, and their labels sorted by name, as Prometheus requires.
+func encodeWriteRequest(points []Point) []byte {
+	seriesKeys := []string{}
+	labels := map[string][]label{}
+	samples := map[string][]sample{}
+	for _, point := range points {
+		value, ok := sampleValue(point.Value)
+		if !ok {
+			continue
+		}
+		seriesLabels := []label{{"__name__", metricName(point.Field)}}
+		for _, l := range []label{{"device_brand", point.DeviceBrand}, {"device_kind", point.Measurement}, {"device_name", point.DeviceName}} {
+			// Empty labels are the same as missing ones
+			if l.value != "" {
+				seriesLabels = append(seriesLabels, l)
+			}
+		}
+		keyParts := []string{}
+		for _, l := range seriesLabels {
+			keyParts = append(keyParts, l.name+"="+l.value)
+		}
+		key := strings.Join(keyParts, "\x00")
+		if _, ok := labels[key]; !ok {
+			seriesKeys = append(seriesKeys, key)
+			labels[key] = seriesLabels
+		}
+		samples[key] = append(samples[key], sample{value: value, timestampMs: point.Timestamp * 1000})
+	}
+	if len(seriesKeys) == 0 {
+		return nil
+	}
+
+	var request []byte
+	for _, key := range seriesKeys {
+		var series []byte
+		for _, l := range labels[key] {
+			var encodedLabel []byte
+			encodedLabel = protowire.AppendTag(encodedLabel, 1, protowire.BytesType)
+			encodedLabel = protowire.AppendString(encodedLabel, l.name)
+			encodedLabel = protowire.AppendTag(encodedLabel, 2, protowire.BytesType)
+			encodedLabel = protowire.AppendString(encodedLabel, l.value)
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, encodedLabel)
+		}
+		seriesSamples := samples[key]
+		slices.SortStableFunc(seriesSamples, func(a, b sample) int { return cmp.Compare(a.timestampMs, b.timestampMs) })
+		for _, s := range seriesSamples {
+			var encodedSample []byte
+			encodedSample = protowire.AppendTag(encodedSample, 1, protowire.Fixed64Type)
+			encodedSample = protowire.AppendFixed64(encodedSample, math.Float64bits(s.value))
+			encodedSample = protowire.AppendTag(encodedSample, 2, protowire.VarintType)
+			encodedSample = protowire.AppendVarint(encodedSample, uint64(s.timestampMs))
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, encodedSample)
+		}
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, series)
+	}
+	return request
+}
+
+func sampleValue(value any) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case bool:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Prometheus rejects a whole request for the samples it cannot append, such as ones older than those it already has for their series,
+// naming them in its response.
+var sampleRejections = []string{"out of order sample", "out-of-order sample", "too old sample", "sample timestamp too old", "out of bounds", "duplicate sample for timestamp"}
+
+// Whether the output rejected some of the request's samples rather than the request itself, so the rest could still be written.
+func isSampleRejected(err error) bool {
+	var statusErr *utils.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	message := strings.ToLower(statusErr.Message)
+	return slices.ContainsFunc(sampleRejections, func(rejection string) bool { return strings.Contains(message, rejection) })
+}
+
+var invalidMetricCharacters = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// The field name as a valid Prometheus metric name, such as state_temperature for state.temperature.
+func metricName(field string) string {
+	name := invalidMetricCharacters.ReplaceAllString(field, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,256 @@
+package outputs
+
+import (
+	"com/utils"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// A decoded remote write series.
+type testSeries struct {
+	labels  map[string]string
+	samples []sample
+}
+
+// Decode a WriteRequest, failing the test if it is malformed.
+func decodeWriteRequest(t *testing.T, request []byte) []testSeries {
+	t.Helper()
+	series := []testSeries{}
+	for _, encodedSeries := range decodeFields(t, request, 1) {
+		s := testSeries{labels: map[string]string{}}
+		for _, encodedLabel := range decodeFields(t, encodedSeries, 1) {
+			name := decodeFields(t, encodedLabel, 1)
+			value := decodeFields(t, encodedLabel, 2)
+			if len(name) != 1 || len(value) != 1 {
+				t.Fatalf("expected a label name and value, got %v and %v", name, value)
+			}
+			s.labels[string(name[0])] = string(value[0])
+		}
+		for _, encodedSample := range decodeFields(t, encodedSeries, 2) {
+			var decoded sample
+			for len(encodedSample) > 0 {
+				number, fieldType, n := protowire.ConsumeTag(encodedSample)
+				if n < 0 {
+					t.Fatalf("error decoding sample: %v", protowire.ParseError(n))
+				}
+				encodedSample = encodedSample[n:]
+				switch {
+				case number == 1 && fieldType == protowire.Fixed64Type:
+					var bits uint64
+					bits, n = protowire.ConsumeFixed64(encodedSample)
+					decoded.value = math.Float64frombits(bits)
+				case number == 2 && fieldType == protowire.VarintType:
+					var timestamp uint64
+					timestamp, n = protowire.ConsumeVarint(encodedSample)
+					decoded.timestampMs = int64(timestamp)
+				default:
+					t.Fatalf("unexpected sample field %v of type %v", number, fieldType)
+				}
+				if n < 0 {
+					t.Fatalf("error decoding sample: %v", protowire.ParseError(n))
+				}
+				encodedSample = encodedSample[n:]
+			}
+			s.samples = append(s.samples, decoded)
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+// The values of the message's length delimited fields with the number, in order.
+func decodeFields(t *testing.T, message []byte, field protowire.Number) [][]byte {
+	t.Helper()
+	values := [][]byte{}
+	for len(message) > 0 {
+		number, fieldType, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			t.Fatalf("error decoding tag: %v", protowire.ParseError(n))
+		}
+		message = message[n:]
+		if fieldType != protowire.BytesType {
+			t.Fatalf("unexpected field %v of type %v", number, fieldType)
+		}
+		value, n := protowire.ConsumeBytes(message)
+		if n < 0 {
+			t.Fatalf("error decoding field %v: %v", number, protowire.ParseError(n))
+		}
+		message = message[n:]
+		if number == field {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// A remote write endpoint recording the series of every request, responding with what respond returns, or 204.
+type remoteWriteServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests [][]testSeries
+	headers  []http.Header
+	statuses []int
+}
+
+func newRemoteWriteServer(t *testing.T, respond func(series []testSeries) (int, string)) *remoteWriteServer {
+	t.Helper()
+	server := &remoteWriteServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("error reading request body: %v", err)
+		}
+		request, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("error decompressing request body: %v", err)
+		}
+		series := decodeWriteRequest(t, request)
+		status, message := http.StatusNoContent, ""
+		if respond != nil {
+			status, message = respond(series)
+		}
+		server.mutex.Lock()
+		server.requests = append(server.requests, series)
+		server.headers = append(server.headers, r.Header.Clone())
+		server.statuses = append(server.statuses, status)
+		server.mutex.Unlock()
+		if message != "" {
+			http.Error(w, message, status)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *remoteWriteServer) requestCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.requests)
+}
+
+// Samples of every successful request, by series name.
+func (s *remoteWriteServer) samples() map[string][]sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	samples := map[string][]sample{}
+	for i, request := range s.requests {
+		if s.statuses[i] >= 300 {
+			continue
+		}
+		for _, series := range request {
+			samples[series.labels["__name__"]] = append(samples[series.labels["__name__"]], series.samples...)
+		}
+	}
+	return samples
+}
+
+func TestPrometheusWrite(t *testing.T) {
+	server := newRemoteWriteServer(t, nil)
+	output := &PrometheusOutput{config: OutputConfig{Name: "prometheus", Type: Prometheus, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}}}
+	err := output.Write(context.Background(), []Point{
+		{Measurement: "THSensor", DeviceName: "Freezer", DeviceBrand: "yolink", Field: "state.temperature", Value: -18.5, Timestamp: 20},
+		{Measurement: "THSensor", DeviceName: "Freezer", DeviceBrand: "yolink", Field: "state.mode", Value: "fahrenheit", Timestamp: 20},
+		{Measurement: "DoorSensor", DeviceName: "Back door", Field: "state.open", Value: true, Timestamp: 10},
+		{Measurement: "THSensor", DeviceName: "Freezer", DeviceBrand: "yolink", Field: "state.temperature", Value: -19.0, Timestamp: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.requests) != 1 {
+		t.Fatalf("expected a request, got %v", len(server.requests))
+	}
+	expected := []testSeries{
+		{
+			labels:  map[string]string{"__name__": "state_temperature", "device_brand": "yolink", "device_kind": "THSensor", "device_name": "Freezer"},
+			samples: []sample{{value: -19, timestampMs: 10000}, {value: -18.5, timestampMs: 20000}},
+		},
+		{
+			labels:  map[string]string{"__name__": "state_open", "device_kind": "DoorSensor", "device_name": "Back door"},
+			samples: []sample{{value: 1, timestampMs: 10000}},
+		},
+	}
+	if !reflect.DeepEqual(server.requests[0], expected) {
+		t.Errorf("expected %v, got %v", expected, server.requests[0])
+	}
+	header := server.headers[0]
+	if header.Get("Content-Encoding") != "snappy" || header.Get("Content-Type") != "application/x-protobuf" ||
+		header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" || header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("unexpected headers %v", header)
+	}
+}
+
+func TestPrometheusLabelOrder(t *testing.T) {
+	request := encodeWriteRequest([]Point{{Measurement: "THSensor", DeviceName: "Freezer", DeviceBrand: "yolink", Field: "state.battery", Value: 4.0}})
+	series := decodeFields(t, request, 1)
+	if len(series) != 1 {
+		t.Fatalf("expected a series, got %v", len(series))
+	}
+	names := []string{}
+	for _, label := range decodeFields(t, series[0], 1) {
+		names = append(names, string(decodeFields(t, label, 1)[0]))
+	}
+	expected := []string{"__name__", "device_brand", "device_kind", "device_name"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected labels sorted as %v, got %v", expected, names)
+	}
+}
+
+func TestPrometheusSkipsText(t *testing.T) {
+	if request := encodeWriteRequest([]Point{{Measurement: "device", Field: "state.mode", Value: "auto"}}); request != nil {
+		t.Errorf("expected no request for text values, got %v", request)
+	}
+	server := newRemoteWriteServer(t, nil)
+	output := &PrometheusOutput{config: OutputConfig{Name: "prometheus", Type: Prometheus, URL: server.URL}}
+	err := output.Write(context.Background(), []Point{{Measurement: "device", Field: "state.mode", Value: "auto"}})
+	if err != nil || len(server.requests) != 0 {
+		t.Errorf("expected nothing written, got %v and %v requests", err, len(server.requests))
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	tests := map[string]string{
+		"state.temperature": "state_temperature",
+		"state.alertType":   "state_alertType",
+		"battery-level %":   "battery_level__",
+		"1st":               "_1st",
+		"":                  "_",
+	}
+	for field, expected := range tests {
+		if name := metricName(field); name != expected {
+			t.Errorf("%q: expected %v, got %v", field, expected, name)
+		}
+	}
+}
+
+func TestSampleRejections(t *testing.T) {
+	tests := []struct {
+		err      error
+		rejected bool
+		samples  bool
+	}{
+		{&utils.StatusError{StatusCode: http.StatusBadRequest, Message: "out of order sample"}, true, true},
+		{&utils.StatusError{StatusCode: http.StatusBadRequest, Message: `too old sample for series {__name__="state_temperature"}`}, true, true},
+		{&utils.StatusError{StatusCode: http.StatusBadRequest, Message: "duplicate sample for timestamp"}, true, true},
+		{&utils.StatusError{StatusCode: http.StatusBadRequest, Message: "snappy: corrupt input"}, true, false},
+		{&utils.StatusError{StatusCode: http.StatusUnauthorized}, true, false},
+		{&utils.StatusError{StatusCode: http.StatusTooManyRequests}, false, false},
+		{&utils.StatusError{StatusCode: http.StatusServiceUnavailable, Message: "out of order sample"}, false, false},
+		{io.ErrUnexpectedEOF, false, false},
+	}
+	for _, test := range tests {
+		if isRejected(test.err) != test.rejected || isSampleRejected(test.err) != test.samples {
+			t.Errorf("%v: expected rejected %v and samples rejected %v", test.err, test.rejected, test.samples)
+		}
+	}
+}
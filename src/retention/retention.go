@@ -0,0 +1,241 @@
+package retention
+
+import (
+	"com/connections/db"
+	"com/data"
+	"com/logs"
+	"com/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Tables policies can apply to.
+const (
+	Events  = "events"
+	Rollups = "rollups"
+)
+
+const defaultBatchSize = 1000
+const defaultInterval = 24 * time.Hour
+
+// How long to keep rows of a table, optionally only those of a device kind, field or rollup resolution.
+// Rows matched by several policies are kept as long as the most specific one says, whether that is longer or shorter.
+type Policy struct {
+	Name       string          `json:"name"`
+	Table      string          `json:"table"`
+	Resolution data.Resolution `json:"resolution,omitempty"`
+	DeviceKind string          `json:"deviceKind,omitempty"`
+	Field      string          `json:"field,omitempty"`
+	KeepFor    utils.Duration  `json:"keepFor"`
+}
+
+type Config struct {
+	Policies []Policy `json:"policies"`
+	// Rows deleted per statement. Defaults to 1000.
+	BatchSize int `json:"batchSize,omitempty"`
+	// Time between runs. Defaults to a day.
+	Interval utils.Duration `json:"interval,omitempty"`
+}
+
+// Read retention policies from a JSON file of the form {"policies": [...]}.
+func LoadConfig(path string) (*Config, error) {
+	config, err := utils.ReadJsonFile[Config](path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading retention config: %w", err)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.Interval <= 0 {
+		config.Interval = utils.Duration(defaultInterval)
+	}
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Policies must be valid, and policies matching the same rows must be more specific than one another.
+func (c Config) Validate() error {
+	for i, policy := range c.Policies {
+		err := policy.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid retention policy %v: %w", policy.Name, err)
+		}
+		for _, other := range c.Policies[:i] {
+			if policy.overlaps(other) && !policy.isNarrowerThan(other) && !other.isNarrowerThan(policy) {
+				return fmt.Errorf("retention policies %v and %v match the same rows without either being more specific", other.Name, policy.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (p Policy) Validate() error {
+	if p.Name == "" {
+		return errors.New("policy has no name")
+	}
+	if p.KeepFor <= 0 {
+		return errors.New("keepFor must be positive")
+	}
+	switch p.Table {
+	case Events:
+		if p.Resolution != "" {
+			return errors.New("resolution only applies to rollups")
+		}
+	case Rollups:
+		if p.Resolution != "" && p.Resolution != data.ResolutionHour && p.Resolution != data.ResolutionDay {
+			return fmt.Errorf("unknown resolution %v", p.Resolution)
+		}
+	default:
+		return fmt.Errorf("unknown table %v", p.Table)
+	}
+	return nil
+}
+
+// The policy's device kind, field and resolution, empty if it applies to all.
+func (p Policy) selectors() [3]string {
+	return [3]string{p.DeviceKind, p.Field, string(p.Resolution)}
+}
+
+// Whether some rows could be matched by both policies.
+func (p Policy) overlaps(other Policy) bool {
+	if p.Table != other.Table {
+		return false
+	}
+	selectors, otherSelectors := p.selectors(), other.selectors()
+	for i := range selectors {
+		if selectors[i] != "" && otherSelectors[i] != "" && selectors[i] != otherSelectors[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether the policy only matches rows the other policy matches, and not all of them.
+func (p Policy) isNarrowerThan(other Policy) bool {
+	if !p.overlaps(other) || p.selectors() == other.selectors() {
+		return false
+	}
+	selectors, otherSelectors := p.selectors(), other.selectors()
+	for i := range selectors {
+		if otherSelectors[i] != "" && selectors[i] != otherSelectors[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply every policy, deleting expired rows in batches. Meant to be run as its own job.
+func Run(ctx context.Context, dbConnection db.DBConnection, config Config) error {
+	for _, policy := range config.Policies {
+		narrower := []Policy{}
+		for _, other := range config.Policies {
+			if other.isNarrowerThan(policy) {
+				narrower = append(narrower, other)
+			}
+		}
+		deleted, err := Apply(ctx, dbConnection, policy, narrower, config.BatchSize)
+		if err != nil {
+			return fmt.Errorf("error applying retention policy %v: %w", policy.Name, err)
+		}
+		logs.InfoWithContext(ctx, "Retention policy %v removed %v rows from %v older than %v", policy.Name, deleted, policy.Table, time.Duration(policy.KeepFor))
+	}
+	return nil
+}
+
+// Delete rows older than the policy allows, except those matched by narrower policies, returning how many were deleted.
+func Apply(ctx context.Context, dbConnection db.DBConnection, policy Policy, narrower []Policy, batchSize int) (int64, error) {
+	cutoff := utils.TimeSeconds() - policy.KeepFor.Seconds()
+	deviceColumn := "event_source_device_id"
+	if policy.Table == Rollups {
+		deviceColumn = "device_id"
+	}
+
+	// Limit to devices of the kind, if any
+	deviceIDs := []*string{nil}
+	if policy.DeviceKind != "" {
+		deviceIDs = []*string{}
+		ids, err := deviceIDsOfKind(ctx, dbConnection, policy.DeviceKind)
+		if err != nil {
+			return 0, err
+		}
+		for _, id := range ids {
+			deviceIDs = append(deviceIDs, &id)
+		}
+	}
+
+	var field *string
+	if policy.Field != "" {
+		field = &policy.Field
+	}
+	var resolution *data.Resolution
+	if policy.Resolution != "" {
+		resolution = &policy.Resolution
+	}
+
+	// Leave rows of narrower policies to them
+	exclusions := data.Or{}
+	for _, other := range narrower {
+		conditions := data.And{}
+		if other.DeviceKind != "" && other.DeviceKind != policy.DeviceKind {
+			ids, err := deviceIDsOfKind(ctx, dbConnection, other.DeviceKind)
+			if err != nil {
+				return 0, err
+			}
+			values := []any{}
+			for _, id := range ids {
+				values = append(values, id)
+			}
+			conditions = append(conditions, data.In{Column: deviceColumn, Values: values})
+		}
+		if other.Field != "" && other.Field != policy.Field {
+			conditions = append(conditions, data.Eq("field_name", other.Field))
+		}
+		if other.Resolution != "" && other.Resolution != policy.Resolution {
+			conditions = append(conditions, data.Eq("rollup_resolution", other.Resolution))
+		}
+		exclusions = append(exclusions, conditions)
+	}
+	var where data.Expression
+	if len(exclusions) > 0 {
+		where = data.Not{Expression: exclusions}
+	}
+
+	var total int64
+	for _, deviceID := range deviceIDs {
+		deleteBatch := func() (int64, error) {
+			if policy.Table == Rollups {
+				return dbConnection.Rollups().DeleteBefore(ctx, data.RollupFilter{DeviceID: deviceID, FieldName: field, Resolution: resolution}, where, cutoff, batchSize)
+			}
+			return dbConnection.Events().DeleteBefore(ctx, data.EventFilter{EventSourceDeviceID: deviceID, FieldName: field}, where, cutoff, batchSize)
+		}
+		for {
+			deleted, err := utils.Retry2(utils.DefaultRetries, deleteBatch, nil)
+			if err != nil {
+				return total, err
+			}
+			total += deleted
+			if deleted < int64(batchSize) {
+				break
+			}
+			logs.DebugWithContext(ctx, "Retention policy %v has removed %v rows so far", policy.Name, total)
+		}
+	}
+	return total, nil
+}
+
+func deviceIDsOfKind(ctx context.Context, dbConnection db.DBConnection, kind string) ([]string, error) {
+	ids := []string{}
+	devices := dbConnection.Devices().Get(ctx, data.DeviceFilter{Kind: &kind})
+	for device, err := range devices.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("error getting devices of kind %v: %w", kind, err)
+		}
+		ids = append(ids, device.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,69 @@
+package retention
+
+import (
+	"com/data"
+	"com/utils"
+	"testing"
+	"time"
+)
+
+func policy(name string, table string, kind string, field string, resolution data.Resolution) Policy {
+	return Policy{Name: name, Table: table, DeviceKind: kind, Field: field, Resolution: resolution, KeepFor: utils.Duration(24 * time.Hour)}
+}
+
+func TestIsNarrowerThan(t *testing.T) {
+	all := policy("all", Events, "", "", "")
+	sensors := policy("sensors", Events, "THSensor", "", "")
+	temperatures := policy("temperatures", Events, "", "state.temperature", "")
+	sensorTemperatures := policy("sensor temperatures", Events, "THSensor", "state.temperature", "")
+	hourly := policy("hourly", Rollups, "", "", data.ResolutionHour)
+
+	tests := []struct {
+		policy   Policy
+		other    Policy
+		expected bool
+	}{
+		{sensors, all, true},
+		{sensorTemperatures, all, true},
+		{sensorTemperatures, sensors, true},
+		{sensorTemperatures, temperatures, true},
+		{all, sensors, false},
+		{sensors, sensors, false},
+		{sensors, temperatures, false},
+		{hourly, all, false},
+		{policy("leak sensors", Events, "LeakSensor", "", ""), sensors, false},
+	}
+	for _, test := range tests {
+		if test.policy.isNarrowerThan(test.other) != test.expected {
+			t.Errorf("%v narrower than %v: expected %v", test.policy.Name, test.other.Name, test.expected)
+		}
+	}
+}
+
+func TestValidateRejectsAmbiguousOverlaps(t *testing.T) {
+	valid := Config{Policies: []Policy{
+		policy("all", Events, "", "", ""),
+		policy("sensors", Events, "THSensor", "", ""),
+		policy("sensor temperatures", Events, "THSensor", "state.temperature", ""),
+		policy("leak sensors", Events, "LeakSensor", "", ""),
+		policy("rollups", Rollups, "", "", ""),
+		policy("hourly", Rollups, "", "", data.ResolutionHour),
+		policy("daily", Rollups, "", "", data.ResolutionDay),
+	}}
+	err := valid.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := map[string]Config{
+		"same rows": {Policies: []Policy{policy("a", Events, "THSensor", "", ""), policy("b", Events, "THSensor", "", "")}},
+		"crossing":  {Policies: []Policy{policy("a", Events, "THSensor", "", ""), policy("b", Events, "", "state.temperature", "")}},
+		"invalid":   {Policies: []Policy{policy("a", Events, "", "", data.ResolutionHour)}},
+	}
+	for name, config := range invalid {
+		err := config.Validate()
+		if err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package rollups
+
+import (
+	"com/connections/db"
+	"com/data"
+	"com/logs"
+	"com/utils"
+	"context"
+	"fmt"
+)
+
+// Resolutions rollups are maintained at.
+var Resolutions = []data.Resolution{data.ResolutionHour, data.ResolutionDay}
+
+// How many buckets of each resolution are recomputed after a collection job. Devices report their latest reading,
+// which may be hours old, so recent buckets keep changing after they end.
+var updateBuckets = map[data.Resolution]int64{
+	data.ResolutionHour: 24,
+	data.ResolutionDay:  2,
+}
+
+// Recompute the recent rollups of every resolution. Meant to run after each collection job.
+func Update(ctx context.Context, dbConnection db.DBConnection) error {
+	now := utils.TimeSeconds()
+	for _, resolution := range Resolutions {
+		start := resolution.BucketStart(now) - (updateBuckets[resolution]-1)*resolution.Seconds()
+		rows, err := dbConnection.Rollups().Recompute(ctx, resolution, &start, nil)
+		if err != nil {
+			return fmt.Errorf("error updating %v rollups: %w", resolution, err)
+		}
+		logs.DebugWithContext(ctx, "Updated %v rollups since %v, %v rows affected", resolution, data.EpochSecondsToExcelDate(start), rows)
+	}
+	return nil
+}
+
+// Recompute rollups of every resolution from all events since startTime, or all events if nil.
+func Backfill(ctx context.Context, dbConnection db.DBConnection, startTime *int64) error {
+	for _, resolution := range Resolutions {
+		logs.InfoWithContext(ctx, "Backfilling %v rollups...", resolution)
+		rows, err := dbConnection.Rollups().Recompute(ctx, resolution, startTime, nil)
+		if err != nil {
+			return fmt.Errorf("error backfilling %v rollups: %w", resolution, err)
+		}
+		logs.InfoWithContext(ctx, "Backfilled %v rollups, %v rows affected", resolution, rows)
+	}
+	return nil
+}
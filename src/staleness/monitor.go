@@ -0,0 +1,171 @@
+package staleness
+
+import (
+	"com/connections/db"
+	"com/data"
+	"com/jobs"
+	"com/logs"
+	"com/notifications"
+	"com/utils"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// How often devices of each kind report when nothing changes, used until enough reports have been observed.
+var DefaultReportIntervals = map[string]time.Duration{
+	"THSensor":     time.Hour,
+	"LeakSensor":   4 * time.Hour,
+	"DoorSensor":   4 * time.Hour,
+	"MotionSensor": 4 * time.Hour,
+	"Hub":          4 * time.Hour,
+}
+
+const defaultReportInterval = time.Hour
+
+// Multiples of the expected report interval after which a silent device is stale or offline.
+const staleFactor = 2
+const offlineFactor = 6
+
+// Observed report intervals kept per device kind, and how many are needed before they replace the default.
+const maxObservedIntervals = 200
+const minObservedIntervals = 5
+
+var _ jobs.EventHandler = (*Monitor)(nil)
+
+// Tracks when devices last reported, flagging devices that go silent as stale or offline and noting when they recover.
+type Monitor struct {
+	dbConnection db.DBConnection
+	// Gaps in seconds between consecutive reports, per device kind.
+	observedIntervals map[string][]int64
+	mutex             *sync.Mutex
+}
+
+func NewMonitor(dbConnection db.DBConnection) *Monitor {
+	return &Monitor{
+		dbConnection:      dbConnection,
+		observedIntervals: map[string][]int64{},
+		mutex:             &sync.Mutex{},
+	}
+}
+
+// Record the latest report of the device, marking it online if it was not.
+func (m *Monitor) HandleEvents(ctx context.Context, device *data.StoreDevice, events []data.StoreEvent) error {
+	var latest int64
+	for _, event := range events {
+		latest = max(latest, event.EventTimestamp)
+	}
+	if latest <= device.LastEventTimestamp {
+		return nil
+	}
+
+	if device.LastEventTimestamp != 0 {
+		m.observeInterval(device.Kind, latest-device.LastEventTimestamp)
+	}
+	previousStatus := device.Status
+	device.LastEventTimestamp = latest
+	device.Status = data.DeviceOnline
+	err := m.dbConnection.Devices().Edit(ctx, *device)
+	if err != nil {
+		return fmt.Errorf("error updating last report of device %v: %w", device.ID, err)
+	}
+
+	if previousStatus == data.DeviceStale || previousStatus == data.DeviceOffline {
+		logs.InfoWithContext(ctx, "Device %v (%v) recovered after being %v", device.Name, device.ID, previousStatus)
+		notifications.NotifyWithContext(ctx, deviceNotification(device, notifications.Info,
+			fmt.Sprintf("Device %v is reporting again", device.Name),
+			fmt.Sprintf("%v was %v and reported at %v", device.Name, previousStatus, data.EpochSecondsToExcelDate(latest)),
+		))
+	}
+	return nil
+}
+
+// Check every device for how long it has been silent, updating statuses that changed.
+func (m *Monitor) Check(ctx context.Context) error {
+	now := utils.TimeSeconds()
+	// Devices that never reported have nothing to compare against
+	devices := m.dbConnection.Devices().Find(ctx, data.Query[data.DeviceFilter]{
+		Where: data.Comparison{Column: "device_last_event_timestamp", Operator: data.GreaterThan, Value: 0},
+	})
+	for device, err := range devices.All(ctx) {
+		if err != nil {
+			return fmt.Errorf("error getting next device: %w", err)
+		}
+		expected := m.ExpectedInterval(device.Kind)
+		silence := time.Duration(now-device.LastEventTimestamp) * time.Second
+		status := data.DeviceOnline
+		if silence > offlineFactor*expected {
+			status = data.DeviceOffline
+		} else if silence > staleFactor*expected {
+			status = data.DeviceStale
+		}
+		if status == device.Status {
+			continue
+		}
+
+		previousStatus := device.Status
+		device.Status = status
+		err = m.dbConnection.Devices().Edit(ctx, device)
+		if err != nil {
+			return fmt.Errorf("error updating status of device %v: %w", device.ID, err)
+		}
+		if status == data.DeviceOnline {
+			continue
+		}
+		logs.WarnWithContext(ctx, "Device %v (%v) is %v, previously %v. Last report %v ago, expected every %v",
+			device.Name, device.ID, status, previousStatus, silence, expected)
+		severity := notifications.Warning
+		if status == data.DeviceOffline {
+			severity = notifications.Critical
+		}
+		notifications.NotifyWithContext(ctx, deviceNotification(&device, severity,
+			fmt.Sprintf("Device %v is %v", device.Name, status),
+			fmt.Sprintf("%v last reported at %v, %v ago. It usually reports every %v.",
+				device.Name, data.EpochSecondsToExcelDate(device.LastEventTimestamp), silence, expected),
+		))
+	}
+	return nil
+}
+
+// How often devices of the kind are expected to report: the median of observed intervals if enough have been seen, otherwise a default.
+func (m *Monitor) ExpectedInterval(kind string) time.Duration {
+	m.mutex.Lock()
+	observed := slices.Clone(m.observedIntervals[kind])
+	m.mutex.Unlock()
+
+	if len(observed) >= minObservedIntervals {
+		slices.Sort(observed)
+		return time.Duration(observed[len(observed)/2]) * time.Second
+	}
+	if interval, ok := DefaultReportIntervals[kind]; ok {
+		return interval
+	}
+	return defaultReportInterval
+}
+
+func (m *Monitor) observeInterval(kind string, seconds int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	intervals := append(m.observedIntervals[kind], seconds)
+	if len(intervals) > maxObservedIntervals {
+		intervals = intervals[len(intervals)-maxObservedIntervals:]
+	}
+	m.observedIntervals[kind] = intervals
+}
+
+func deviceNotification(device *data.StoreDevice, severity notifications.Severity, title string, message string) notifications.Notification {
+	return notifications.Notification{
+		Title:    title,
+		Message:  message,
+		Severity: severity,
+		Source:   "staleness",
+		Fields: map[string]string{
+			"device":      device.Name,
+			"device_id":   device.ID,
+			"device_kind": device.Kind,
+			"last_report": data.EpochSecondsToExcelDate(device.LastEventTimestamp),
+		},
+	}
+}
@@ -0,0 +1,178 @@
+package staleness
+
+import (
+	"com/connections/db/dbfake"
+	"com/data"
+	"com/notifications"
+	"com/utils"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExpectedInterval(t *testing.T) {
+	m := NewMonitor(dbfake.NewConnection())
+	if interval := m.ExpectedInterval("LeakSensor"); interval != 4*time.Hour {
+		t.Errorf("expected the default of the kind, got %v", interval)
+	}
+	if interval := m.ExpectedInterval("Unknown"); interval != defaultReportInterval {
+		t.Errorf("expected the default of unknown kinds, got %v", interval)
+	}
+
+	for _, seconds := range []int64{600, 60, 120, 900} {
+		m.observeInterval("THSensor", seconds)
+	}
+	if interval := m.ExpectedInterval("THSensor"); interval != time.Hour {
+		t.Errorf("expected the default before %v observations, got %v", minObservedIntervals, interval)
+	}
+	m.observeInterval("THSensor", 300)
+	if interval := m.ExpectedInterval("THSensor"); interval != 5*time.Minute {
+		t.Errorf("expected the median of observations, got %v", interval)
+	}
+}
+
+func TestObserveIntervalKeepsLatest(t *testing.T) {
+	m := NewMonitor(dbfake.NewConnection())
+	for range 100 {
+		m.observeInterval("THSensor", 3600)
+	}
+	for range maxObservedIntervals {
+		m.observeInterval("THSensor", 60)
+	}
+	if observed := len(m.observedIntervals["THSensor"]); observed != maxObservedIntervals {
+		t.Errorf("expected %v observations kept, got %v", maxObservedIntervals, observed)
+	}
+	if interval := m.ExpectedInterval("THSensor"); interval != time.Minute {
+		t.Errorf("expected older observations to be dropped, got %v", interval)
+	}
+}
+
+// A context with a notifier posting to a webhook, and the notifications it received.
+func withRecordedNotifications(t *testing.T, ctx context.Context, dbConnection *dbfake.Connection) (context.Context, *[]notifications.Notification) {
+	t.Helper()
+	received := []notifications.Notification{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Notification notifications.Notification `json:"notification"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			t.Errorf("error decoding notification: %v", err)
+		}
+		received = append(received, payload.Notification)
+	}))
+	t.Cleanup(server.Close)
+	notifier, err := notifications.NewNotifier(dbConnection, notifications.Config{
+		Channels: []notifications.ChannelConfig{{Name: "hook", Type: notifications.Webhook, URL: server.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return notifications.ContextWithNotifier(ctx, notifier), &received
+}
+
+func TestStatusTransitions(t *testing.T) {
+	dbConnection := dbfake.NewConnection()
+	ctx, received := withRecordedNotifications(t, t.Context(), dbConnection)
+	m := NewMonitor(dbConnection)
+	now := utils.TimeSeconds()
+	hour := int64(3600)
+	add := func(name string, status string, lastEventTimestamp int64) string {
+		t.Helper()
+		id, err := dbConnection.Devices().Add(ctx, data.Device{Kind: "THSensor", Name: name, Status: status, LastEventTimestamp: lastEventTimestamp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	ids := map[string]string{
+		"reporting": add("reporting", data.DeviceOnline, now-600),
+		"stale":     add("stale", data.DeviceOnline, now-3*hour),
+		"offline":   add("offline", data.DeviceStale, now-7*hour),
+		"recovered": add("recovered", data.DeviceStale, now-600),
+		"new":       add("new", data.DeviceUnknown, 0),
+	}
+	statuses := func() map[string]string {
+		t.Helper()
+		devices, err := data.Collect(dbConnection.Devices().Get(ctx, data.DeviceFilter{}).All(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses := map[string]string{}
+		for _, device := range devices {
+			statuses[device.Name] = device.Status
+		}
+		return statuses
+	}
+
+	err := m.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"reporting": data.DeviceOnline,
+		"stale":     data.DeviceStale,
+		"offline":   data.DeviceOffline,
+		"recovered": data.DeviceOnline,
+		"new":       data.DeviceUnknown,
+	}
+	for name, status := range statuses() {
+		if status != expected[name] {
+			t.Errorf("expected %v to be %v, got %v", name, expected[name], status)
+		}
+	}
+	// Only devices going silent are notified of, by how long they have been silent
+	if len(*received) != 2 {
+		t.Fatalf("expected notifications of the stale and offline devices, got %v", *received)
+	}
+	severities := map[string]notifications.Severity{}
+	for _, notification := range *received {
+		severities[notification.Fields["device_id"]] = notification.Severity
+	}
+	if severities[ids["stale"]] != notifications.Warning || severities[ids["offline"]] != notifications.Critical {
+		t.Errorf("expected a warning of the stale device and a critical notification of the offline one, got %v", *received)
+	}
+
+	err = m.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*received) != 2 {
+		t.Fatalf("expected no notifications of unchanged statuses, got %v", (*received)[2:])
+	}
+
+	// A report brings the stale device back online, noting its recovery and how long it was silent
+	staleID := ids["stale"]
+	devices, err := data.Collect(dbConnection.Devices().Get(ctx, data.DeviceFilter{ID: &staleID}).All(ctx))
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("expected the stale device, got %v, %v", devices, err)
+	}
+	device := devices[0]
+	event := data.StoreEvent{Event: data.Event{EventSourceDeviceID: device.ID, EventTimestamp: now}}
+	err = m.HandleEvents(ctx, &device, []data.StoreEvent{event})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses()["stale"] != data.DeviceOnline {
+		t.Errorf("expected the reporting device to be online, got %v", statuses()["stale"])
+	}
+	if len(*received) != 3 || (*received)[2].Severity != notifications.Info || (*received)[2].Fields["device_id"] != ids["stale"] {
+		t.Fatalf("expected a notification of the recovery, got %v", *received)
+	}
+	if observed := m.observedIntervals["THSensor"]; len(observed) != 1 || observed[0] != 3*hour {
+		t.Errorf("expected the gap since the last report to be observed, got %v", observed)
+	}
+
+	// Reports older than the last one change nothing
+	event.EventTimestamp = now - hour
+	err = m.HandleEvents(ctx, &device, []data.StoreEvent{event})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*received) != 3 || len(m.observedIntervals["THSensor"]) != 1 {
+		t.Errorf("expected an older report to be ignored, got notifications %v", *received)
+	}
+}
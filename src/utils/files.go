@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"com/logs"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Read the JSON file at path into a new T.
+func ReadJsonFile[T any](path string) (*T, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %v: %w", path, err)
+	}
+	defer logs.LogErrors(file.Close, fmt.Sprintf("error closing file %v", path))
+
+	var out T
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&out)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %v: %w", path, err)
+	}
+	return &out, nil
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// Counts and hashes the bytes written through it.
+type HashingWriter struct {
+	writer io.Writer
+	hash   hash.Hash
+	count  int64
+}
+
+func NewHashingWriter(w io.Writer) *HashingWriter {
+	return &HashingWriter{writer: w, hash: sha256.New()}
+}
+
+func (h *HashingWriter) Write(p []byte) (int, error) {
+	n, err := h.writer.Write(p)
+	h.hash.Write(p[:n])
+	h.count += int64(n)
+	return n, err
+}
+
+// Number of bytes written.
+func (h *HashingWriter) Count() int64 {
+	return h.count
+}
+
+// Hex-encoded SHA-256 of the bytes written.
+func (h *HashingWriter) SHA256() string {
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
@@ -1,18 +1,21 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 )
 
-// Turns any object that can be turned into a map into a map.
+// Turns any object that can be turned into a map into a map. Numbers are kept as json.Number, preserving their representation.
 func ToMap[T any](data any) (map[string]T, error) {
 	marshalled, err := json.Marshal(data)
 	if err != nil {
 		return nil, fmt.Errorf("error while marshalling %v: %w", data, err)
 	}
 	var mapped map[string]T
-	err = json.Unmarshal(marshalled, &mapped)
+	decoder := json.NewDecoder(bytes.NewReader(marshalled))
+	decoder.UseNumber()
+	err = decoder.Decode(&mapped)
 	if err != nil {
 		return nil, fmt.Errorf("error while unmarshalling %v with intermediate value %v: %w", data, marshalled, err)
 	}
@@ -22,6 +25,8 @@ func ToMap[T any](data any) (map[string]T, error) {
 type KVPair struct {
 	K string
 	V string
+	// The value before it was formatted into V.
+	Raw any
 }
 
 // Traverse a map m where all keys and nested keys are strings, and values are strings or maps of strings, adding all key value pairs to array a.
@@ -35,7 +40,7 @@ func FlattenMap(m map[string]any, a []KVPair, keyPrefix string) []KVPair {
 		case map[string]any:
 			a = append(a, FlattenMap(v, []KVPair{}, keyPrefix+k)...)
 		default:
-			a = append(a, KVPair{K: keyPrefix + k, V: fmt.Sprint(v)})
+			a = append(a, KVPair{K: keyPrefix + k, V: fmt.Sprint(v), Raw: v})
 		}
 	}
 	return a
@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -69,6 +70,67 @@ func PostForm[T any](ctx context.Context, urlString string, body map[string]stri
 	return interpretResponse[T](ctx, response, err)
 }
 
+// Post JSON to url, ignoring the response body. For endpoints that do not respond with JSON, such as webhooks.
+func PostJsonIgnoringResponse(ctx context.Context, urlString string, headers map[string]string, body any) error {
+	bodyJson, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error while marshalling %v: %w", body, err)
+	}
+	return PostBytes(ctx, urlString, "application/json", headers, bodyJson)
+}
+
+// A non-2xx response to a request.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	// The start of the response body, which often says why the request failed.
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("non-2xx code received from %v: %v", e.URL, e.Status)
+	}
+	return fmt.Sprintf("non-2xx code received from %v: %v: %v", e.URL, e.Status, e.Message)
+}
+
+// Bytes of a failed response's body kept in its StatusError.
+const statusMessageLimit = 1024
+
+// Post the body to url with the content type, ignoring the response body unless it fails. Non-2xx responses are StatusErrors.
+func PostBytes(ctx context.Context, urlString string, contentType string, headers map[string]string, body []byte) error {
+	// Build request
+	reqctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(
+		reqctx,
+		http.MethodPost,
+		urlString,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return fmt.Errorf("error while building request with url %v: %w", urlString, err)
+	}
+	request.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		request.Header.Set(k, v)
+	}
+
+	// Do request
+	client := &http.Client{}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error during request to %v: %w", urlString, err)
+	}
+	defer logs.LogErrorsWithContext(ctx, response.Body.Close, fmt.Sprintf("Closing body %v", response.Body))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, statusMessageLimit))
+		return &StatusError{URL: urlString, StatusCode: response.StatusCode, Status: response.Status, Message: strings.TrimSpace(string(message))}
+	}
+	return nil
+}
+
 func interpretResponse[T any](ctx context.Context, response *http.Response, err error) (*T, error) {
 	// Check statuses
 	if err != nil {
@@ -80,8 +142,11 @@ func interpretResponse[T any](ctx context.Context, response *http.Response, err
 	}
 	defer logs.LogErrorsWithContext(ctx, response.Body.Close, fmt.Sprintf("Closing body %v", response.Body))
 	// Cast to type
+	// Numbers in untyped fields are kept as json.Number, preserving their representation
 	var out *T
-	err = json.NewDecoder(response.Body).Decode(&out)
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+	err = decoder.Decode(&out)
 	if err != nil {
 		return nil, fmt.Errorf("error during decoding of response %v, %w", response.Body, err)
 	}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -8,3 +12,42 @@ import (
 func TimeSeconds() int64 {
 	return time.Now().UTC().Unix()
 }
+
+// A duration that is read from JSON as a string such as "15m" or "90d".
+type Duration time.Duration
+
+func (d Duration) Seconds() int64 {
+	return int64(time.Duration(d) / time.Second)
+}
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return fmt.Errorf("duration %s must be a string: %w", b, err)
+	}
+	parsed, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Parse a duration as time.ParseDuration does, additionally allowing a whole number of days such as "90d".
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing days in duration %v: %w", s, err)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing duration %v: %w", s, err)
+	}
+	return d, nil
+}